@@ -0,0 +1,152 @@
+package attachments
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"signalbot_go/signalcli"
+)
+
+// directory in which signal-cli stores received attachments
+// ($XDG_DATA_HOME/signal-cli/attachments)
+func StorageDir() string {
+	data := os.Getenv("XDG_DATA_HOME")
+	if data == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		data = filepath.Join(home, ".local", "share")
+	}
+	return filepath.Join(data, "signal-cli", "attachments")
+}
+
+// an attachment of a received message
+type Received struct {
+	signalcli.Attachment
+	// readable file with the content of the attachment
+	Path string
+}
+
+// open the attachment for reading
+func (r *Received) Open() (*os.File, error) {
+	return os.Open(r.Path)
+}
+
+// the content type without parameters (e.g. "image/png")
+func (r *Received) MediaType() string {
+	t, _, err := mime.ParseMediaType(r.ContentType)
+	if err != nil {
+		return strings.ToLower(r.ContentType)
+	}
+	return t
+}
+
+// check if the attachment matches one of the patterns (MIME types like
+// "text/calendar" or "image/*")
+func (r *Received) Matches(patterns []string) bool {
+	t := r.MediaType()
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if p == "*/*" || p == t {
+			return true
+		}
+		if prefix, ok := strings.CutSuffix(p, "/*"); ok && strings.HasPrefix(t, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// resolve the attachments of `m` to readable files. The metadata the driver
+// received is completed by looking at the files. Attachments which cannot be
+// read are left out and reported in the error.
+func Resolve(m *signalcli.Message) ([]Received, error) {
+	ret := make([]Received, 0, len(m.Attachments))
+	var errs []error
+	for i, file := range m.Attachments {
+		var meta signalcli.Attachment
+		if i < len(m.AttachmentMeta) {
+			meta = m.AttachmentMeta[i]
+		}
+		r, err := resolve(file, meta)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		ret = append(ret, r)
+	}
+	return ret, errors.Join(errs...)
+}
+
+func resolve(file string, meta signalcli.Attachment) (Received, error) {
+	r := Received{Attachment: meta, Path: file}
+	if !filepath.IsAbs(file) {
+		r.Path = filepath.Join(StorageDir(), file)
+	}
+	f, err := os.Open(r.Path)
+	if err != nil {
+		return Received{}, fmt.Errorf("attachment %s: %w", file, err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return Received{}, fmt.Errorf("attachment %s: %w", file, err)
+	}
+	r.Size = info.Size()
+	if r.Id == "" {
+		r.Id = filepath.Base(file)
+	}
+
+	if r.ContentType == "" {
+		r.ContentType = mime.TypeByExtension(filepath.Ext(r.Filename))
+	}
+	if r.ContentType == "" {
+		r.ContentType = mime.TypeByExtension(filepath.Ext(r.Path))
+	}
+	if r.ContentType == "" {
+		head := make([]byte, 512)
+		n, err := io.ReadFull(f, head)
+		if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+			return Received{}, fmt.Errorf("attachment %s: %w", file, err)
+		}
+		r.ContentType = http.DetectContentType(head[:n])
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return Received{}, fmt.Errorf("attachment %s: %w", file, err)
+		}
+	}
+
+	if strings.HasPrefix(r.MediaType(), "image/") && (r.Width == 0 || r.Height == 0) {
+		// unknown formats just have no dimensions
+		if cfg, _, err := image.DecodeConfig(f); err == nil {
+			r.Width, r.Height = cfg.Width, cfg.Height
+		}
+	}
+	return r, nil
+}
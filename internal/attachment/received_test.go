@@ -0,0 +1,92 @@
+package attachments
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"signalbot_go/signalcli"
+)
+
+func TestResolve(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("XDG_DATA_HOME", dir)
+	storage := StorageDir()
+	if err := os.MkdirAll(storage, 0o755); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	// stored by signal-cli without extension
+	f, err := os.Create(filepath.Join(storage, "abc"))
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if err := png.Encode(f, image.NewGray(image.Rect(0, 0, 3, 2))); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	f.Close()
+	ics := filepath.Join(dir, "event.ics")
+	if err := os.WriteFile(ics, []byte("BEGIN:VCALENDAR\r\nEND:VCALENDAR\r\n"), 0o600); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	m := &signalcli.Message{
+		Attachments: []string{"abc", ics, "missing"},
+		AttachmentMeta: []signalcli.Attachment{
+			{Id: "abc", Filename: "photo"},
+			{Id: "event.ics", ContentType: "text/calendar; charset=utf-8", Filename: "event.ics"},
+			{Id: "missing"},
+		},
+	}
+	received, err := Resolve(m)
+	if err == nil {
+		t.Fatalf("The missing attachment was not reported")
+	}
+	if len(received) != 2 {
+		t.Fatalf("Was: %+v", received)
+	}
+
+	img := received[0]
+	if img.Path != filepath.Join(storage, "abc") || img.MediaType() != "image/png" || img.Width != 3 || img.Height != 2 || img.Size == 0 {
+		t.Fatalf("Was: %+v", img)
+	}
+	cal := received[1]
+	if cal.Path != ics || cal.MediaType() != "text/calendar" || cal.Filename != "event.ics" {
+		t.Fatalf("Was: %+v", cal)
+	}
+
+	for _, tc := range []struct {
+		r        Received
+		patterns []string
+		exp      bool
+	}{
+		{img, []string{"image/*"}, true},
+		{img, []string{"image/png"}, true},
+		{img, []string{"image/jpeg", "text/*"}, false},
+		{cal, []string{"text/calendar"}, true},
+		{cal, []string{"*/*"}, true},
+		{cal, nil, false},
+	} {
+		if was := tc.r.Matches(tc.patterns); was != tc.exp {
+			t.Fatalf("%s %v: Was: %v but should be: %v", tc.r.ContentType, tc.patterns, was, tc.exp)
+		}
+	}
+}
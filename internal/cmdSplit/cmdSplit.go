@@ -18,8 +18,10 @@ package cmdsplit
 
 import (
 	"errors"
-	"strings"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // errors
@@ -27,6 +29,7 @@ var (
 	ErrTrailingQuoteEscape error = errors.New("Malformed string, open escape sequence or quote in the end")
 	ErrTrailingEscape      error = errors.New("Malformed string, open escape sequence in the end")
 	ErrInvalidRange        error = errors.New("Invalid range provided")
+	ErrInvalidDuration     error = errors.New("Invalid duration provided")
 )
 
 // possible states of the DFA
@@ -166,3 +169,43 @@ func ParseNumberRange(s string) ([]int, error) {
 	}
 	return result, nil
 }
+
+var durationDaysRe *regexp.Regexp = regexp.MustCompile(`^([0-9]+)([dw])(.*)$`)
+
+// parse a duration like `time.ParseDuration` does. Additionally a leading
+// amount of days (e.g. "7d") or weeks (e.g. "2w") is supported (e.g. "1d12h").
+func ParseDuration(s string) (time.Duration, error) {
+	var ret time.Duration
+	if m := durationDaysRe.FindStringSubmatch(s); m != nil {
+		n, err := strconv.Atoi(m[1])
+		if err != nil {
+			return 0, ErrInvalidDuration
+		}
+		ret = time.Duration(n) * 24 * time.Hour
+		if m[2] == "w" {
+			ret *= 7
+		}
+		s = m[3]
+		if s == "" {
+			return ret, nil
+		}
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, ErrInvalidDuration
+	}
+	return ret + d, nil
+}
+
+// duration which can be parsed from arguments with `ParseDuration`
+type Duration time.Duration
+
+// parse the duration with `ParseDuration`
+func (d *Duration) UnmarshalText(b []byte) error {
+	v, err := ParseDuration(string(b))
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
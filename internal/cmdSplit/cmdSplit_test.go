@@ -20,6 +20,7 @@ import (
 	cmdsplit "signalbot_go/internal/cmdSplit"
 	"testing"
 	"reflect"
+	"time"
 )
 
 func TestSplitSucc(t *testing.T) {
@@ -123,3 +124,27 @@ func TestParseNumberRange(t *testing.T) {
 		})
 	}
 }
+
+func TestParseDuration(t *testing.T) {
+	tests := map[string]time.Duration{
+		"7d":    7 * 24 * time.Hour,
+		"2w":    14 * 24 * time.Hour,
+		"1d12h": 36 * time.Hour,
+		"90m":   90 * time.Minute,
+	}
+	for in, should := range tests {
+		d, err := cmdsplit.ParseDuration(in)
+		if err != nil {
+			t.Fatalf("Err on %v: %v", in, err)
+		}
+		if d != should {
+			t.Fatalf("Was: %v but should be %v", d, should)
+		}
+	}
+
+	for _, in := range []string{"", "d", "7x", "7d1"} {
+		if _, err := cmdsplit.ParseDuration(in); err != cmdsplit.ErrInvalidDuration {
+			t.Fatalf("Should have returned %v on %v", cmdsplit.ErrInvalidDuration, in)
+		}
+	}
+}
@@ -33,7 +33,8 @@ type equaler[T any] interface {
 	Equals(other T) bool
 }
 
-type diffStringerEqualer[T any] interface {
+// constraint of the elements of the list-collections
+type DiffStringerEqualer[T any] interface {
 	equaler[T]
 	diffStringer
 }
@@ -43,43 +44,67 @@ type diffStringerEqualer[T any] interface {
 // kinda tree.
 // This object can then be used to diff an arbitrary state with the stored one
 // and/or store the given state afterwards.
-type Differ[S comparable, T comparable, U diffStringerEqualer[U]] map[S]map[T][]U
+type Differ[S comparable, T comparable, U DiffStringerEqualer[U]] map[S]map[T][]U
 
 // Generate a diff between the stored state (found by following `l1` and `l2`)
 // the and provided state `dataB`.
 // The output is generated with the help of the AddString/RemString functions
 // of the U elements.
 func (d *Differ[S, T, U]) Diff(l1 S, l2 T, dataB []U) string {
-	first := true // used to omit the leading newline in the first iteration
 	a, ok := (*d)[l1]
 	if !ok {
 		// everything is new as the path wasn't found
-		builder := strings.Builder{}
-		for _, dB := range dataB {
-			if !first {
-				builder.WriteRune('\n')
-			} else {
-				first = false
-			}
-			builder.WriteString(dB.AddString())
-		}
-		return builder.String()
+		return addAll(dataB)
 	}
 	dataA, ok := a[l2]
 	if !ok {
 		// everything is new as the path wasn't found
-		builder := strings.Builder{}
-		for _, dB := range dataB {
-			if !first {
-				builder.WriteRune('\n')
-			} else {
-				first = false
+		return addAll(dataB)
+	}
+	return diff(dataA, dataB)
+}
+
+// format all elements of `dataB` as being added
+func addAll[U DiffStringerEqualer[U]](dataB []U) string {
+	first := true // used to omit the leading newline in the first iteration
+	builder := strings.Builder{}
+	for _, dB := range dataB {
+		if !first {
+			builder.WriteRune('\n')
+		} else {
+			first = false
+		}
+		builder.WriteString(dB.AddString())
+	}
+	return builder.String()
+}
+
+// check if `dataA` and `dataB` contain the same elements
+func equal[U DiffStringerEqualer[U]](dataA, dataB []U) bool {
+	contains := func(data []U, x U) bool {
+		for _, d := range data {
+			if d.Equals(x) {
+				return true
 			}
-			builder.WriteString(dB.AddString())
 		}
-		return builder.String()
+		return false
+	}
+	for _, dA := range dataA {
+		if !contains(dataB, dA) {
+			return false
+		}
+	}
+	for _, dB := range dataB {
+		if !contains(dataA, dB) {
+			return false
+		}
 	}
+	return true
+}
 
+// generate the diff between `dataA` and `dataB` (removed elements first)
+func diff[U DiffStringerEqualer[U]](dataA, dataB []U) string {
+	first := true // used to omit the leading newline in the first iteration
 	builder := strings.Builder{}
 
 	// check if elements of dataA are contained in dataB
@@ -19,6 +19,7 @@ package differ_test
 import (
 	"signalbot_go/internal/differ"
 	"testing"
+	"time"
 )
 
 type content string
@@ -75,3 +76,57 @@ func TestDiff(t *testing.T) {
 		t.Fatalf("Wrong output when diffing with other path")
 	}
 }
+
+func TestHistory(t *testing.T) {
+	hist := differ.NewHistory[string, string, content](2)
+	t0 := time.Date(2024, 10, 1, 12, 0, 0, 0, time.UTC)
+
+	// initial store -> everything is new
+	out := hist.DiffStoreAt("hello", "world", []content{"a", "b"}, t0)
+	if out != `> a
+> b` {
+		t.Fatalf("Wrong output when storing first content. '%s'", out)
+	}
+
+	// same content -> no new snapshot
+	hist.DiffStoreAt("hello", "world", []content{"b", "a"}, t0.Add(time.Hour))
+	if l, _ := hist.Latest("hello", "world"); !l.Time.Equal(t0) {
+		t.Fatalf("Unchanged content should not create a snapshot")
+	}
+
+	out = hist.DiffStoreAt("hello", "world", []content{"a", "c"}, t0.Add(24*time.Hour))
+	if out != `< b
+> c` {
+		t.Fatalf("Wrong output when changing the content. '%s'", out)
+	}
+	hist.DiffStoreAt("hello", "world", []content{"c"}, t0.Add(48*time.Hour))
+
+	// bounded -> the initial snapshot got dropped
+	if s, _ := hist.At("hello", "world", t0); !s.Time.Equal(t0.Add(24*time.Hour)) {
+		t.Fatalf("Oldest snapshot should have been dropped. Oldest is from %v", s.Time)
+	}
+
+	// diff against the state of a point in time
+	out = hist.DiffSince("hello", "world", t0.Add(36*time.Hour), []content{"c", "d"})
+	if out != `< a
+> d` {
+		t.Fatalf("Wrong output when diffing against the past. '%s'", out)
+	}
+
+	changes := hist.Changes("hello", "world", time.Time{})
+	if len(changes) != 1 || changes[0].Diff != "< a" || !changes[0].Time.Equal(t0.Add(48*time.Hour)) {
+		t.Fatalf("Wrong changes listed: %v", changes)
+	}
+
+	// import from a plain differ only fills unknown paths
+	diff := differ.Differ[string, string, content]{
+		"hello": {"world": {"x"}, "test": {"y"}},
+	}
+	hist.Import(diff, t0)
+	if l, _ := hist.Latest("hello", "world"); len(l.Data) != 1 || l.Data[0] != "c" {
+		t.Fatalf("Import should not overwrite existing history")
+	}
+	if l, ok := hist.Latest("hello", "test"); !ok || l.Data[0] != "y" {
+		t.Fatalf("Import did not add the missing path")
+	}
+}
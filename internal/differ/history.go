@@ -0,0 +1,207 @@
+package differ
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"strings"
+	"time"
+)
+
+// default amount of snapshots kept per path if nothing else is specified
+const DefaultHistorySize int = 20
+
+// state of the list-collection of U at a certain point in time
+type Snapshot[U any] struct {
+	Time time.Time `yaml:"time"`
+	Data []U       `yaml:"data"`
+}
+
+// stores a bounded history of timestamped snapshots of the list-collection of
+// U. Like with the `Differ` the list-collection is found by following a path
+// of two parameters S and T.
+// A new snapshot is only added if the state differs from the latest one. If
+// more than `MaxLen` snapshots are stored for a path, the oldest ones are
+// dropped.
+// Should be created with `NewHistory`.
+type History[S comparable, T comparable, U DiffStringerEqualer[U]] struct {
+	MaxLen    int                       `yaml:"-"`
+	Snapshots map[S]map[T][]Snapshot[U] `yaml:"snapshots"`
+}
+
+// create a new History which keeps at most `maxLen` snapshots per path. If
+// `maxLen` is not positive, `DefaultHistorySize` is used.
+func NewHistory[S comparable, T comparable, U DiffStringerEqualer[U]](maxLen int) *History[S, T, U] {
+	if maxLen <= 0 {
+		maxLen = DefaultHistorySize
+	}
+	return &History[S, T, U]{
+		MaxLen:    maxLen,
+		Snapshots: make(map[S]map[T][]Snapshot[U]),
+	}
+}
+
+// returns the snapshots stored for the path (oldest first)
+func (h *History[S, T, U]) get(l1 S, l2 T) []Snapshot[U] {
+	a, ok := h.Snapshots[l1]
+	if !ok {
+		return nil
+	}
+	return a[l2]
+}
+
+// returns the most recent snapshot stored for the path. The bool is false if
+// nothing is stored for this path yet.
+func (h *History[S, T, U]) Latest(l1 S, l2 T) (Snapshot[U], bool) {
+	snaps := h.get(l1, l2)
+	if len(snaps) == 0 {
+		return Snapshot[U]{}, false
+	}
+	return snaps[len(snaps)-1], true
+}
+
+// returns the snapshot which was the current state at time `t`. If the
+// history does not reach back that far, the oldest snapshot is returned. The
+// bool is false if nothing is stored for this path yet.
+func (h *History[S, T, U]) At(l1 S, l2 T, t time.Time) (Snapshot[U], bool) {
+	snaps := h.get(l1, l2)
+	if len(snaps) == 0 {
+		return Snapshot[U]{}, false
+	}
+	ret := snaps[0]
+	for _, s := range snaps[1:] {
+		if s.Time.After(t) {
+			break
+		}
+		ret = s
+	}
+	return ret, true
+}
+
+// Generate a diff between the latest stored state and the provided state
+// `dataB`. Like `Differ.Diff` everything is new if the path wasn't found.
+func (h *History[S, T, U]) Diff(l1 S, l2 T, dataB []U) string {
+	if latest, ok := h.Latest(l1, l2); ok {
+		return diff(latest.Data, dataB)
+	}
+	return addAll(dataB)
+}
+
+// Generate a diff between the state which was current at `since` and the
+// provided state `dataB`.
+func (h *History[S, T, U]) DiffSince(l1 S, l2 T, since time.Time, dataB []U) string {
+	if snap, ok := h.At(l1, l2, since); ok {
+		return diff(snap.Data, dataB)
+	}
+	return addAll(dataB)
+}
+
+// same as `Diff` but automatically stores the provided `dataB` as a new
+// snapshot (taken now) after comparing.
+func (h *History[S, T, U]) DiffStore(l1 S, l2 T, dataB []U) string {
+	return h.DiffStoreAt(l1, l2, dataB, time.Now())
+}
+
+// same as `DiffStore` but the snapshot is stored with the timestamp `t`.
+func (h *History[S, T, U]) DiffStoreAt(l1 S, l2 T, dataB []U, t time.Time) string {
+	if h == nil {
+		return ""
+	}
+
+	resp := h.Diff(l1, l2, dataB)
+	h.Add(l1, l2, Snapshot[U]{Time: t, Data: dataB})
+	return resp
+}
+
+// append the snapshot to the history of the path if it differs from the
+// latest one. Drops old snapshots if the history gets too long. Returns
+// whether the snapshot was added.
+func (h *History[S, T, U]) Add(l1 S, l2 T, snap Snapshot[U]) bool {
+	if latest, ok := h.Latest(l1, l2); ok && equal(latest.Data, snap.Data) {
+		return false
+	}
+	if h.Snapshots == nil {
+		h.Snapshots = make(map[S]map[T][]Snapshot[U])
+	}
+	if _, ok := h.Snapshots[l1]; !ok {
+		h.Snapshots[l1] = make(map[T][]Snapshot[U])
+	}
+	snaps := append(h.Snapshots[l1][l2], snap)
+	if h.MaxLen > 0 && len(snaps) > h.MaxLen {
+		snaps = snaps[len(snaps)-h.MaxLen:]
+	}
+	h.Snapshots[l1][l2] = snaps
+	return true
+}
+
+// a change between two consecutive snapshots
+type Change struct {
+	// time of the snapshot which introduced the change
+	Time time.Time
+	// formatted diff (see `Differ.Diff`)
+	Diff string
+}
+
+// list all changes which happened after `since` (pass the zero time to get
+// all changes). The initial snapshot of a path is not considered to be a
+// change.
+func (h *History[S, T, U]) Changes(l1 S, l2 T, since time.Time) []Change {
+	snaps := h.get(l1, l2)
+	ret := make([]Change, 0, len(snaps))
+	for i := 1; i < len(snaps); i++ {
+		if snaps[i].Time.Before(since) {
+			continue
+		}
+		d := diff(snaps[i-1].Data, snaps[i].Data)
+		if d == "" {
+			continue
+		}
+		ret = append(ret, Change{Time: snaps[i].Time, Diff: d})
+	}
+	return ret
+}
+
+// same as `Changes` but formats the changes already. Each change is
+// introduced by the time it was observed (formatted in `loc`).
+func (h *History[S, T, U]) ChangesString(l1 S, l2 T, since time.Time, loc *time.Location) string {
+	builder := strings.Builder{}
+	first := true
+	for _, c := range h.Changes(l1, l2, since) {
+		if !first {
+			builder.WriteString("\n\n")
+		} else {
+			first = false
+		}
+		builder.WriteString(c.Time.In(loc).Format("2006-01-02 15:04"))
+		builder.WriteString(":\n")
+		builder.WriteString(c.Diff)
+	}
+	return builder.String()
+}
+
+// import the state stored in a `Differ` as snapshots taken at time `t`.
+// Paths which already have a history are skipped. Can be used to migrate
+// from a plain `Differ` to a `History`.
+func (h *History[S, T, U]) Import(d Differ[S, T, U], t time.Time) {
+	for l1, a := range d {
+		for l2, data := range a {
+			if _, ok := h.Latest(l1, l2); ok {
+				continue
+			}
+			h.Add(l1, l2, Snapshot[U]{Time: t, Data: data})
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package modtest
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+// helpers for testing modules without a signal account and without network
+// access
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"os"
+	"signalbot_go/internal/signalsender"
+	"signalbot_go/signalcli"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// SignalSender which only records what would have been sent. Safe for
+// concurrent use.
+type Sender struct {
+	mu        sync.Mutex
+	Responses []string
+	Typing    int
+}
+
+func (s *Sender) SendGeneric(message string, attachments []string, recipient string, groupID []byte, notify bool) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Responses = append(s.Responses, message)
+	return int64(len(s.Responses)), nil
+}
+
+func (s *Sender) Respond(message string, attachments []string, m *signalcli.Message, notify bool) (int64, error) {
+	return s.SendGeneric(message, attachments, m.Sender, m.GroupId, notify)
+}
+
+// records the message with its markup
+func (s *Sender) RespondStyled(message string, attachments []string, m *signalcli.Message, notify bool) (int64, error) {
+	return s.Respond(message, attachments, m, notify)
+}
+
+// counts how often typing was started
+func (s *Sender) StartTyping(m *signalcli.Message) (stop func()) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Typing++
+	return func() {}
+}
+
+// returns a copy of the responses recorded so far
+func (s *Sender) Get() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	ret := make([]string, len(s.Responses))
+	copy(ret, s.Responses)
+	return ret
+}
+
+// http.RoundTripper which answers requests with the content of files. Maps
+// the url (without the query) to the filename or (if prefixed with "=") to
+// the literal body. Unknown urls are answered with 404.
+type Transport map[string]string
+
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body != nil {
+		req.Body.Close()
+	}
+	u := *req.URL
+	u.RawQuery = ""
+
+	resp := &http.Response{
+		StatusCode: http.StatusNotFound,
+		Header:     make(http.Header),
+		Body:       io.NopCloser(strings.NewReader("")),
+		Request:    req,
+	}
+	src, ok := t[u.String()]
+	if !ok {
+		return resp, nil
+	}
+
+	var body []byte
+	if lit, ok := strings.CutPrefix(src, "="); ok {
+		body = []byte(lit)
+	} else {
+		var err error
+		if body, err = os.ReadFile(src); err != nil {
+			return nil, err
+		}
+	}
+	resp.StatusCode = http.StatusOK
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return resp, nil
+}
+
+// route all requests made with the http.DefaultClient through `rt` until the
+// test finished.
+func MockHTTP(t *testing.T, rt http.RoundTripper) {
+	old := http.DefaultClient.Transport
+	http.DefaultClient.Transport = rt
+	t.Cleanup(func() {
+		http.DefaultClient.Transport = old
+	})
+}
+
+// can handle a signal-message (see signalserver.Handler)
+type Handler interface {
+	Handle(m *signalcli.Message, signal signalsender.SignalSender, virtRcv func(*signalcli.Message))
+}
+
+// handle all messages concurrently (like the signalserver does) and wait
+// until all of them are handled. Virtually received messages are dropped.
+func Fire(h Handler, signal signalsender.SignalSender, msgs ...*signalcli.Message) {
+	wg := sync.WaitGroup{}
+	for _, m := range msgs {
+		wg.Add(1)
+		go func(m signalcli.Message) {
+			defer wg.Done()
+			h.Handle(&m, signal, func(*signalcli.Message) {})
+		}(*m)
+	}
+	wg.Wait()
+}
+
+// create `n` copies of a message with the text `msg` sent in `chat` by
+// `sender`
+func Messages(n int, chat string, sender string, msg string) []*signalcli.Message {
+	ret := make([]*signalcli.Message, n)
+	for i := range ret {
+		ret[i] = &signalcli.Message{
+			Timestamp: int64(i),
+			Sender:    sender,
+			Chat:      chat,
+			Message:   msg,
+		}
+	}
+	return ret
+}
@@ -20,6 +20,7 @@ import (
 	"context"
 	"fmt"
 	"math"
+	"sync/atomic"
 	"time"
 
 	"log/slog"
@@ -37,7 +38,7 @@ type ReocEventImpl[T any] struct {
 
 	Foo          func(time.Time, ReocEvent[T]) `yaml:"-"`
 	log          *slog.Logger                  `yaml:"-"`
-	checkStopped func() bool                   `yaml:"-"`
+	checkStopped atomic.Value                  `yaml:"-"` // func() bool, set on start
 	cancel_      context.CancelFunc
 }
 
@@ -77,6 +78,7 @@ func (event *ReocEventImpl[T]) cancel() {
 func (event *ReocEventImpl[T]) runAsync(ctx context.Context) (context.Context, context.CancelFunc) {
 	c, cFun := context.WithCancel(ctx)
 	event.cancel_ = cFun
+	event.setStarted(c)
 	go event.run(c)
 	return c, cFun
 }
@@ -84,9 +86,6 @@ func (event *ReocEventImpl[T]) runAsync(ctx context.Context) (context.Context, c
 // most basic run function. You most probably don't want to shadow/override
 // this in a type embedding this one
 func (event *ReocEventImpl[T]) run_(ctx context.Context) {
-	event.checkStopped = func() bool {
-		return ctx.Err() != nil
-	}
 	// calculate how long until the event occurs the next time
 	start_in := time.Until(event.Start)
 	if start_inS := start_in.Seconds(); start_inS < 0 {
@@ -114,10 +113,18 @@ func (event *ReocEventImpl[T]) run_(ctx context.Context) {
 	}
 }
 
+// remember that the event-loop runs in `ctx`. Has to be called before the
+// loop is started, Stopped might be called concurrently right away.
+func (event *ReocEventImpl[T]) setStarted(ctx context.Context) {
+	event.checkStopped.Store(func() bool {
+		return ctx.Err() != nil
+	})
+}
+
 // return if the event-loop was stopped
 func (event *ReocEventImpl[T]) Stopped() bool {
-	if event.checkStopped != nil {
-		return event.checkStopped()
+	if checkStopped, ok := event.checkStopped.Load().(func() bool); ok {
+		return checkStopped()
 	}
 	return false
 }
@@ -134,7 +141,7 @@ func (event *ReocEventImpl[T]) Metadata() T {
 	return event.Metadata_store
 }
 
-func (r ReocEventImpl[T]) String() string {
+func (r *ReocEventImpl[T]) String() string {
 	// return fmt.Sprintf("{id: %v, start: %v, int: %v, desc: %v}", r.Id, r.Start, r.Interval, r.Description)
 	return fmt.Sprintf("{desc: %v, start: %v, int: %v}", r.Description, r.Start.Format(time.RFC3339), r.Interval)
 }
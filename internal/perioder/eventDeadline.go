@@ -39,9 +39,6 @@ func NewReocEventImplDeadline[T any](start time.Time, interval time.Duration, st
 // start the event-loop synchronously
 func (event *ReocEventImplDeadline[T]) run(ctx context.Context) {
 	if time.Now().Compare(event.Stop) == 1 {
-		event.checkStopped = func() bool {
-			return true
-		}
 		return // do not start if deadline already exceeded
 	}
 	event.run_(ctx)
@@ -51,10 +48,12 @@ func (event *ReocEventImplDeadline[T]) run(ctx context.Context) {
 func (event *ReocEventImplDeadline[T]) runAsync(ctx context.Context) (context.Context, context.CancelFunc) {
 	c, cFun := context.WithDeadline(ctx, event.Stop)
 	event.cancel_ = cFun
+	// the context is already done if the deadline was exceeded
+	event.setStarted(c)
 	go event.run(c)
 	return c, cFun
 }
 
-func (r ReocEventImplDeadline[T]) String() string {
+func (r *ReocEventImplDeadline[T]) String() string {
 	return fmt.Sprintf("{start: %v, stop: %v, int: %v, desc: %v}", r.Start.Format(time.RFC3339), r.Stop.Format(time.RFC3339), r.Interval, r.Description)
 }
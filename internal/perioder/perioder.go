@@ -113,7 +113,7 @@ func (p *PerioderImpl[T]) Start(ctx context.Context) {
 			delete(p.events, id)
 			p.eventsMutex.Unlock()
 		case <-ctx.Done():
-			break
+			return
 		}
 	}
 }
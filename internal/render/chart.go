@@ -0,0 +1,391 @@
+package render
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"math"
+	"strconv"
+	"strings"
+
+	attachments "signalbot_go/internal/attachment"
+)
+
+// how the values of a series are drawn
+type SeriesKind int
+
+const (
+	// connected points
+	Line SeriesKind = iota
+	// filled area between Values (lower) and Upper
+	Band
+	// one bar per point, starting at zero
+	Bars
+	// background of the point, Values between 0 (none) and 1 (full color)
+	Shade
+)
+
+// one row of values of a chart. NaN values are left out.
+type Series struct {
+	Name   string
+	Kind   SeriesKind
+	Values []float64
+	// upper values of a Band
+	Upper []float64
+	Color color.NRGBA
+	// use the right axis instead of the left one (not used for Shade)
+	Right bool
+}
+
+// values over a common x axis which can be rendered as PNG
+type Chart struct {
+	// printed bold above the chart (optional)
+	Title string
+	// one label per point of the x axis, empty labels are skipped
+	Labels []string
+	Series []Series
+	// units printed at the axes
+	LeftUnit  string
+	RightUnit string
+	// printed below the chart (optional, e.g. the source of the data)
+	Footer string
+}
+
+// size of the plot area (in pixels)
+const (
+	plotWidth  = 720
+	plotHeight = 260
+	tickLength = 4
+)
+
+// a range of values mapped to the height of the plot
+type axis struct {
+	min, max, step float64
+}
+
+// step between the ticks so that there are about n ticks
+func niceStep(span float64, n int) float64 {
+	if span <= 0 {
+		return 1
+	}
+	raw := span / float64(n)
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, f := range []float64{1, 2, 5, 10} {
+		if raw <= f*mag {
+			return f * mag
+		}
+	}
+	return 10 * mag
+}
+
+// the axis which contains all values (and zero if zero is set)
+func newAxis(values []float64, zero bool) (axis, bool) {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		lo, hi = min(lo, v), max(hi, v)
+	}
+	if math.IsInf(lo, 1) {
+		return axis{}, false
+	}
+	if zero {
+		lo, hi = min(lo, 0), max(hi, 0)
+	}
+	if hi-lo < 1 {
+		hi = lo + 1
+	}
+	step := niceStep(hi-lo, 5)
+	return axis{
+		min:  math.Floor(lo/step) * step,
+		max:  math.Ceil(hi/step) * step,
+		step: step,
+	}, true
+}
+
+// y coordinate of the value
+func (a axis) y(top int, v float64) int {
+	return top + int(math.Round(float64(plotHeight)*(a.max-v)/(a.max-a.min)))
+}
+
+// the ticks of the axis
+func (a axis) ticks() []float64 {
+	ret := []float64{}
+	for v := a.min; v <= a.max+a.step/2; v += a.step {
+		ret = append(ret, v)
+	}
+	return ret
+}
+
+func formatTick(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// fill the rectangle, blending the color over the image
+func blend(img draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Over)
+}
+
+// draw a line with a width of 2 pixels
+func drawLine(img draw.Image, x0, y0, x1, y1 int, c color.Color) {
+	steps := max(abs(x1-x0), abs(y1-y0), 1)
+	for i := 0; i <= steps; i++ {
+		x := x0 + (x1-x0)*i/steps
+		y := y0 + (y1-y0)*i/steps
+		fill(img, image.Rect(x, y, x+2, y+2), c)
+	}
+}
+
+func abs(i int) int {
+	if i < 0 {
+		return -i
+	}
+	return i
+}
+
+// render the chart
+func (c *Chart) Image() (image.Image, error) {
+	regular, bold, err := faces()
+	if err != nil {
+		return nil, err
+	}
+	lineHeight := regular.Metrics().Height.Ceil()
+
+	n := len(c.Labels)
+	for _, s := range c.Series {
+		n = max(n, len(s.Values))
+	}
+	if n == 0 {
+		n = 1
+	}
+
+	// the ranges of both axes
+	var leftValues, rightValues []float64
+	leftZero, rightZero := false, false
+	for _, s := range c.Series {
+		if s.Kind == Shade {
+			continue
+		}
+		values := append(append([]float64{}, s.Values...), s.Upper...)
+		if s.Right {
+			rightValues = append(rightValues, values...)
+			rightZero = rightZero || s.Kind == Bars
+		} else {
+			leftValues = append(leftValues, values...)
+			leftZero = leftZero || s.Kind == Bars
+		}
+	}
+	left, hasLeft := newAxis(leftValues, leftZero)
+	right, hasRight := newAxis(rightValues, rightZero)
+
+	axisWidth := func(a axis, ok bool, unit string) int {
+		w := textWidth(regular, []string{unit})
+		if ok {
+			for _, t := range a.ticks() {
+				w = max(w, textWidth(regular, []string{formatTick(t)}))
+			}
+		}
+		return w + tickLength + 2*padding
+	}
+	leftWidth := axisWidth(left, hasLeft, c.LeftUnit)
+	rightWidth := axisWidth(right, hasRight, c.RightUnit)
+
+	// layout from top to bottom: title, unit, plot, labels, legend, footer
+	var title, footer []string
+	titleHeight, footerHeight := 0, 0
+	if c.Title != "" {
+		title = strings.Split(c.Title, "\n")
+		titleHeight = len(title)*lineHeight + padding
+	}
+	if c.Footer != "" {
+		footer = strings.Split(c.Footer, "\n")
+		footerHeight = len(footer)*lineHeight + padding
+	}
+	top := padding + titleHeight + lineHeight + padding
+	plotLeft := padding + leftWidth
+	plotRight := plotLeft + plotWidth
+	bottom := top + plotHeight
+	legendTop := bottom + tickLength + lineHeight + 2*padding
+	height := legendTop + lineHeight + padding + footerHeight + padding
+	width := plotRight + rightWidth + padding
+
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	fill(img, img.Bounds(), colorBackground)
+	if title != nil {
+		drawText(img, bold, padding, padding, title)
+	}
+
+	slot := float64(plotWidth) / float64(n)
+	center := func(i int) int {
+		return plotLeft + int(slot*float64(i)+slot/2)
+	}
+	valid := func(values []float64, i int) bool {
+		return i < len(values) && !math.IsNaN(values[i])
+	}
+
+	// shades are the background of the plot
+	for _, s := range c.Series {
+		if s.Kind != Shade {
+			continue
+		}
+		for i := range n {
+			if !valid(s.Values, i) {
+				continue
+			}
+			col := s.Color
+			col.A = uint8(float64(col.A) * min(max(s.Values[i], 0), 1))
+			blend(img, image.Rect(plotLeft+int(slot*float64(i)), top, plotLeft+int(slot*float64(i+1)), bottom), col)
+		}
+	}
+
+	// grid with the ticks of the left axis (or the right one if there is
+	// no left one)
+	grid, gridOk := left, hasLeft
+	if !gridOk {
+		grid, gridOk = right, hasRight
+	}
+	if gridOk {
+		for _, t := range grid.ticks() {
+			y := grid.y(top, t)
+			fill(img, image.Rect(plotLeft, y, plotRight, y+1), colorStripe)
+		}
+	}
+	if hasLeft {
+		drawText(img, regular, padding, top-lineHeight-padding, []string{c.LeftUnit})
+		for _, t := range left.ticks() {
+			y := left.y(top, t)
+			fill(img, image.Rect(plotLeft-tickLength, y, plotLeft, y+1), colorGrid)
+			label := []string{formatTick(t)}
+			drawText(img, regular, plotLeft-tickLength-padding-textWidth(regular, label), y-lineHeight/2, label)
+		}
+	}
+	if hasRight {
+		unit := []string{c.RightUnit}
+		drawText(img, regular, width-padding-textWidth(regular, unit), top-lineHeight-padding, unit)
+		for _, t := range right.ticks() {
+			y := right.y(top, t)
+			fill(img, image.Rect(plotRight, y, plotRight+tickLength, y+1), colorGrid)
+			drawText(img, regular, plotRight+tickLength+padding, y-lineHeight/2, []string{formatTick(t)})
+		}
+	}
+
+	// bars, then bands, then lines
+	for _, kind := range []SeriesKind{Bars, Band, Line} {
+		for _, s := range c.Series {
+			if s.Kind != kind {
+				continue
+			}
+			a := left
+			if s.Right {
+				a = right
+			}
+			switch kind {
+			case Bars:
+				w := max(int(slot*0.6), 1)
+				for i := range n {
+					if !valid(s.Values, i) || s.Values[i] == 0 {
+						continue
+					}
+					y0, y1 := a.y(top, 0), a.y(top, s.Values[i])
+					blend(img, image.Rect(center(i)-w/2, min(y0, y1), center(i)-w/2+w, max(y0, y1)), s.Color)
+				}
+			case Band:
+				// column by column between the centers of the points
+				area := s.Color
+				area.A /= 3
+				for i := 0; i+1 < n; i++ {
+					if !valid(s.Values, i) || !valid(s.Values, i+1) || !valid(s.Upper, i) || !valid(s.Upper, i+1) {
+						continue
+					}
+					x0, x1 := center(i), center(i+1)
+					for x := x0; x < x1; x++ {
+						f := float64(x-x0) / float64(x1-x0)
+						lo := s.Values[i] + f*(s.Values[i+1]-s.Values[i])
+						hi := s.Upper[i] + f*(s.Upper[i+1]-s.Upper[i])
+						blend(img, image.Rect(x, a.y(top, hi), x+1, a.y(top, lo)), area)
+					}
+				}
+				drawSeries(img, a, top, n, center, s.Values, s.Color)
+				drawSeries(img, a, top, n, center, s.Upper, s.Color)
+			case Line:
+				drawSeries(img, a, top, n, center, s.Values, s.Color)
+			}
+		}
+	}
+
+	// frame of the plot
+	fill(img, image.Rect(plotLeft, top, plotRight, top+1), colorGrid)
+	fill(img, image.Rect(plotLeft, bottom, plotRight+1, bottom+1), colorGrid)
+	fill(img, image.Rect(plotLeft, top, plotLeft+1, bottom), colorGrid)
+	fill(img, image.Rect(plotRight, top, plotRight+1, bottom), colorGrid)
+
+	// labels of the x axis, skipping the ones which would overlap
+	lastEnd := 0
+	for i := 0; i < n && i < len(c.Labels); i++ {
+		if c.Labels[i] == "" {
+			continue
+		}
+		label := []string{c.Labels[i]}
+		w := textWidth(regular, label)
+		x := center(i) - w/2
+		if x < lastEnd+padding {
+			continue
+		}
+		fill(img, image.Rect(center(i), bottom, center(i)+1, bottom+tickLength), colorGrid)
+		drawText(img, regular, x, bottom+tickLength+padding, label)
+		lastEnd = x + w
+	}
+
+	// legend
+	x := plotLeft
+	for _, s := range c.Series {
+		if s.Name == "" {
+			continue
+		}
+		fill(img, image.Rect(x, legendTop+lineHeight/4, x+lineHeight/2, legendTop+lineHeight*3/4), s.Color)
+		x += lineHeight/2 + padding
+		drawText(img, regular, x, legendTop, []string{s.Name})
+		x += textWidth(regular, []string{s.Name}) + 3*padding
+	}
+
+	if footer != nil {
+		drawText(img, regular, padding, legendTop+lineHeight+padding, footer)
+	}
+	return img, nil
+}
+
+// connect the valid points of the values
+func drawSeries(img draw.Image, a axis, top int, n int, center func(int) int, values []float64, col color.Color) {
+	for i := 0; i+1 < n && i+1 < len(values); i++ {
+		if math.IsNaN(values[i]) || math.IsNaN(values[i+1]) {
+			continue
+		}
+		drawLine(img, center(i), a.y(top, values[i]), center(i+1), a.y(top, values[i+1]), col)
+	}
+}
+
+// render the chart into a temporary PNG file (see PNG)
+func (c *Chart) PNG() (attachments.File, error) {
+	img, err := c.Image()
+	if err != nil {
+		return nil, err
+	}
+	return PNG(img)
+}
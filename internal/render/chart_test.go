@@ -0,0 +1,82 @@
+package render
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+func TestNiceStep(t *testing.T) {
+	tests := []struct {
+		span float64
+		step float64
+	}{
+		{span: 10, step: 2},
+		{span: 23, step: 5},
+		{span: 0.4, step: 0.1},
+		{span: 0, step: 1},
+	}
+	for _, tt := range tests {
+		if got := niceStep(tt.span, 5); math.Abs(got-tt.step) > 1e-9 {
+			t.Errorf("span %v: was %v but should be %v", tt.span, got, tt.step)
+		}
+	}
+}
+
+func TestChart(t *testing.T) {
+	barColor := color.NRGBA{0x30, 0x60, 0xd0, 0xff}
+	c := Chart{
+		Title:  "Weather",
+		Labels: []string{"Mon", "Tue", "", "Thu"},
+		Series: []Series{
+			{Name: "Sun", Kind: Shade, Values: []float64{1, 0.5, 0, math.NaN()}, Color: color.NRGBA{0xff, 0xd0, 0x40, 0x60}},
+			{Name: "Temp", Kind: Band, Values: []float64{-2, 1, 3, 0}, Upper: []float64{5, 8, 12, math.NaN()}, Color: color.NRGBA{0xd0, 0x40, 0x30, 0xff}},
+			{Name: "Rain", Kind: Bars, Values: []float64{0, 4, 12.5, 1}, Color: barColor, Right: true},
+		},
+		LeftUnit:  "°C",
+		RightUnit: "mm",
+		Footer:    "Source: example.org",
+	}
+	img, err := c.Image()
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	b := img.Bounds()
+	if b.Dx() < plotWidth || b.Dy() < plotHeight {
+		t.Fatalf("Image too small: %v", b)
+	}
+	// the bars are drawn with their color
+	found := false
+	for x := b.Min.X; x < b.Max.X && !found; x++ {
+		for y := b.Min.Y; y < b.Max.Y && !found; y++ {
+			r, g, bl, _ := img.At(x, y).RGBA()
+			found = r>>8 == uint32(barColor.R) && g>>8 == uint32(barColor.G) && bl>>8 == uint32(barColor.B)
+		}
+	}
+	if !found {
+		t.Fatalf("The bars were not drawn")
+	}
+
+	// empty charts still render
+	empty := Chart{}
+	if _, err := empty.Image(); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package render
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/font/sfnt"
+)
+
+// size of the text in points
+const fontSize = 13
+
+var (
+	fontsOnce   sync.Once
+	regularFont *sfnt.Font
+	boldFont    *sfnt.Font
+	fontsErr    error
+)
+
+// new faces used for rendering (the Go fonts are embedded, so no font has to
+// be installed). A face must not be used concurrently, so every render
+// creates its own, only the parsed fonts are shared.
+func faces() (font.Face, font.Face, error) {
+	fontsOnce.Do(func() {
+		regularFont, fontsErr = opentype.Parse(goregular.TTF)
+		if fontsErr != nil {
+			return
+		}
+		boldFont, fontsErr = opentype.Parse(gobold.TTF)
+	})
+	if fontsErr != nil {
+		return nil, nil, fontsErr
+	}
+	regular, err := newFace(regularFont)
+	if err != nil {
+		return nil, nil, err
+	}
+	bold, err := newFace(boldFont)
+	if err != nil {
+		return nil, nil, err
+	}
+	return regular, bold, nil
+}
+
+func newFace(f *sfnt.Font) (font.Face, error) {
+	return opentype.NewFace(f, &opentype.FaceOptions{
+		Size:    fontSize,
+		DPI:     96,
+		Hinting: font.HintingFull,
+	})
+}
@@ -0,0 +1,223 @@
+package render
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"image"
+	"image/color"
+	"image/draw"
+	"image/png"
+	"strings"
+
+	attachments "signalbot_go/internal/attachment"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/math/fixed"
+)
+
+// colors of the rendered images
+var (
+	colorBackground = color.RGBA{0xff, 0xff, 0xff, 0xff}
+	colorText       = color.RGBA{0x20, 0x20, 0x20, 0xff}
+	colorHeader     = color.RGBA{0xdd, 0xe4, 0xee, 0xff}
+	colorStripe     = color.RGBA{0xf4, 0xf6, 0xf9, 0xff}
+	colorGrid       = color.RGBA{0xc0, 0xc6, 0xd0, 0xff}
+)
+
+// space around the text of a cell (in pixels)
+const padding = 6
+
+// tabular data which can be rendered as PNG. Cells may contain multiple lines.
+type Table struct {
+	// printed bold above the table (optional)
+	Title  string
+	Header []string
+	Rows   [][]string
+	// printed below the table (optional, e.g. the source of the data)
+	Footer string
+}
+
+// amount of columns of the table
+func (t *Table) columns() int {
+	n := len(t.Header)
+	for _, r := range t.Rows {
+		n = max(n, len(r))
+	}
+	return n
+}
+
+// the cell of the row (empty if the row is shorter)
+func cell(row []string, i int) []string {
+	if i >= len(row) || row[i] == "" {
+		return nil
+	}
+	return strings.Split(row[i], "\n")
+}
+
+// width of the widest line
+func textWidth(face font.Face, lines []string) int {
+	w := 0
+	for _, l := range lines {
+		w = max(w, font.MeasureString(face, l).Ceil())
+	}
+	return w
+}
+
+// draw the lines with the top left corner at x,y
+func drawText(img draw.Image, face font.Face, x int, y int, lines []string) {
+	m := face.Metrics()
+	d := font.Drawer{Dst: img, Src: image.NewUniform(colorText), Face: face}
+	for i, l := range lines {
+		d.Dot = fixed.P(x, y+m.Ascent.Ceil()+i*m.Height.Ceil())
+		d.DrawString(l)
+	}
+}
+
+func fill(img draw.Image, r image.Rectangle, c color.Color) {
+	draw.Draw(img, r, image.NewUniform(c), image.Point{}, draw.Src)
+}
+
+// render the table
+func (t *Table) Image() (image.Image, error) {
+	regular, bold, err := faces()
+	if err != nil {
+		return nil, err
+	}
+	lineHeight := regular.Metrics().Height.Ceil()
+
+	rows := make([][]string, 0, len(t.Rows)+1)
+	if len(t.Header) > 0 {
+		rows = append(rows, t.Header)
+	}
+	rows = append(rows, t.Rows...)
+
+	// size of the columns and rows
+	cols := t.columns()
+	widths := make([]int, cols)
+	heights := make([]int, len(rows))
+	for r, row := range rows {
+		face := regular
+		if r == 0 && len(t.Header) > 0 {
+			face = bold
+		}
+		lines := 1
+		for c := range widths {
+			content := cell(row, c)
+			widths[c] = max(widths[c], textWidth(face, content)+2*padding)
+			lines = max(lines, len(content))
+		}
+		heights[r] = lines*lineHeight + 2*padding
+	}
+	tableWidth := 1
+	for _, w := range widths {
+		tableWidth += w
+	}
+	tableHeight := 1
+	for _, h := range heights {
+		tableHeight += h
+	}
+
+	// title and footer
+	width := tableWidth
+	titleHeight, footerHeight := 0, 0
+	var title, footer []string
+	if t.Title != "" {
+		title = strings.Split(t.Title, "\n")
+		titleHeight = len(title)*lineHeight + padding
+		width = max(width, textWidth(bold, title))
+	}
+	if t.Footer != "" {
+		footer = strings.Split(t.Footer, "\n")
+		footerHeight = len(footer)*lineHeight + padding
+		width = max(width, textWidth(regular, footer))
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, width+2*padding, titleHeight+tableHeight+footerHeight+2*padding))
+	fill(img, img.Bounds(), colorBackground)
+	if title != nil {
+		drawText(img, bold, padding, padding, title)
+	}
+
+	// backgrounds of the rows, then the grid and the text
+	x0, y0 := padding, padding+titleHeight
+	y := y0
+	for r, h := range heights {
+		bg := colorBackground
+		if r == 0 && len(t.Header) > 0 {
+			bg = colorHeader
+		} else if r%2 == 0 {
+			bg = colorStripe
+		}
+		fill(img, image.Rect(x0, y, x0+tableWidth, y+h), bg)
+		y += h
+	}
+	x := x0
+	for c := 0; c <= cols; c++ {
+		fill(img, image.Rect(x, y0, x+1, y0+tableHeight), colorGrid)
+		if c < cols {
+			x += widths[c]
+		}
+	}
+	y = y0
+	for r := 0; r <= len(rows); r++ {
+		fill(img, image.Rect(x0, y, x0+tableWidth, y+1), colorGrid)
+		if r < len(rows) {
+			y += heights[r]
+		}
+	}
+	y = y0
+	for r, row := range rows {
+		face := regular
+		if r == 0 && len(t.Header) > 0 {
+			face = bold
+		}
+		x := x0
+		for c, w := range widths {
+			drawText(img, face, x+padding, y+padding, cell(row, c))
+			x += w
+		}
+		y += heights[r]
+	}
+
+	if footer != nil {
+		drawText(img, regular, padding, y0+tableHeight+padding, footer)
+	}
+	return img, nil
+}
+
+// render the image into a temporary PNG file which can be sent as
+// attachment. The caller has to close the file.
+func PNG(img image.Image) (attachments.File, error) {
+	f, err := attachments.NewFileImpl("png")
+	if err != nil {
+		return nil, err
+	}
+	if err := png.Encode(f.File(), img); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+// render the table into a temporary PNG file (see PNG)
+func (t *Table) PNG() (attachments.File, error) {
+	img, err := t.Image()
+	if err != nil {
+		return nil, err
+	}
+	return PNG(img)
+}
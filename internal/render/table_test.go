@@ -0,0 +1,101 @@
+package render
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"image/png"
+	"os"
+	"sync"
+	"testing"
+)
+
+func TestTable(t *testing.T) {
+	tab := Table{
+		Title:  "Mensa Arcisstraße",
+		Header: []string{"Day", "Meal"},
+		Rows: [][]string{
+			{"Mon", "Pasta\nPizza"},
+			{"Tue"},
+			{"Wed", "Grill", "extra column"},
+		},
+		Footer: "Source: example.org",
+	}
+	img, err := tab.Image()
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	b := img.Bounds()
+	if b.Dx() < 100 || b.Dy() < 100 {
+		t.Fatalf("Image too small: %v", b)
+	}
+	// the header has its own background
+	found := false
+	for x := b.Min.X; x < b.Max.X && !found; x++ {
+		for y := b.Min.Y; y < b.Max.Y/2 && !found; y++ {
+			found = img.At(x, y) == colorHeader
+		}
+	}
+	if !found {
+		t.Fatalf("The header was not drawn")
+	}
+
+	f, err := tab.PNG()
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	defer f.Close()
+	r, err := os.Open(f.Path())
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	defer r.Close()
+	decoded, err := png.Decode(r)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if decoded.Bounds() != b {
+		t.Fatalf("Was: %v but should be: %v", decoded.Bounds(), b)
+	}
+}
+
+// handlers render concurrently, run with -race
+func TestConcurrentRender(t *testing.T) {
+	tab := Table{Header: []string{"Day", "Meal"}, Rows: [][]string{{"Mon", "Pasta"}}}
+	c := Chart{Labels: []string{"Mon", "Tue"}, Series: []Series{{Name: "Rain", Kind: Bars, Values: []float64{1, 2}}}}
+	var wg sync.WaitGroup
+	errs := make(chan error, 8)
+	for i := 0; i < 4; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			_, err := tab.Image()
+			errs <- err
+		}()
+		go func() {
+			defer wg.Done()
+			_, err := c.Image()
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Err: %v", err)
+		}
+	}
+}
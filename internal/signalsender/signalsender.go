@@ -26,4 +26,10 @@ type SignalSender interface {
 	SendGeneric(message string, attachments []string, recipient string, groupID []byte, notify bool) (timestamp int64, err error)
 	// respond to a certain message. The recipient/groupID will be extracted from the message
 	Respond(message string, attachments []string, m *signalcli.Message, notify bool) (timestamp int64, err error)
+	// like Respond but the markup of the message (see signalcli.ParseMarkup)
+	// is converted into text styles. Text which is not meant as markup has to
+	// be escaped with signalcli.EscapeMarkup.
+	RespondStyled(message string, attachments []string, m *signalcli.Message, notify bool) (timestamp int64, err error)
+	// show the typing indicator in the chat of `m` until `stop` is called
+	StartTyping(m *signalcli.Message) (stop func())
 }
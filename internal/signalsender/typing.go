@@ -0,0 +1,56 @@
+package signalsender
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"signalbot_go/signalcli"
+	"sync"
+)
+
+// SignalSender which shows the typing indicator until the first message is
+// sent
+type typingSender struct {
+	SignalSender
+	stop func()
+}
+
+// show the typing indicator in the chat of `m` until the first message is
+// sent with the returned SignalSender or until `stop` is called.
+func WithTyping(s SignalSender, m *signalcli.Message) (ret SignalSender, stop func()) {
+	once := sync.Once{}
+	stopTyping := s.StartTyping(m)
+	t := &typingSender{
+		SignalSender: s,
+		stop:         func() { once.Do(stopTyping) },
+	}
+	return t, t.stop
+}
+
+func (t *typingSender) SendGeneric(message string, attachments []string, recipient string, groupID []byte, notify bool) (int64, error) {
+	t.stop()
+	return t.SignalSender.SendGeneric(message, attachments, recipient, groupID, notify)
+}
+
+func (t *typingSender) Respond(message string, attachments []string, m *signalcli.Message, notify bool) (int64, error) {
+	t.stop()
+	return t.SignalSender.Respond(message, attachments, m, notify)
+}
+
+func (t *typingSender) RespondStyled(message string, attachments []string, m *signalcli.Message, notify bool) (int64, error) {
+	t.stop()
+	return t.SignalSender.RespondStyled(message, attachments, m, notify)
+}
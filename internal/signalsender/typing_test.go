@@ -0,0 +1,65 @@
+package signalsender_test
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"signalbot_go/internal/signalsender"
+	"signalbot_go/signalcli"
+	"testing"
+)
+
+// records when typing was started/stopped relative to the sent messages
+type typingRecorder struct {
+	events []string
+}
+
+func (r *typingRecorder) SendGeneric(message string, attachments []string, recipient string, groupID []byte, notify bool) (int64, error) {
+	r.events = append(r.events, "send "+message)
+	return 0, nil
+}
+
+func (r *typingRecorder) Respond(message string, attachments []string, m *signalcli.Message, notify bool) (int64, error) {
+	return r.SendGeneric(message, attachments, m.Sender, m.GroupId, notify)
+}
+
+func (r *typingRecorder) RespondStyled(message string, attachments []string, m *signalcli.Message, notify bool) (int64, error) {
+	return r.SendGeneric(message, attachments, m.Sender, m.GroupId, notify)
+}
+
+func (r *typingRecorder) StartTyping(m *signalcli.Message) (stop func()) {
+	r.events = append(r.events, "start")
+	return func() { r.events = append(r.events, "stop") }
+}
+
+func TestWithTyping(t *testing.T) {
+	r := &typingRecorder{}
+	m := &signalcli.Message{Sender: "+49a"}
+	s, stop := signalsender.WithTyping(r, m)
+	s.Respond("1", nil, m, false)
+	s.SendGeneric("2", nil, "+49a", nil, false)
+	stop()
+
+	exp := []string{"start", "stop", "send 1", "send 2"}
+	if len(r.events) != len(exp) {
+		t.Fatalf("Was: %v but should be %v", r.events, exp)
+	}
+	for i := range exp {
+		if r.events[i] != exp[i] {
+			t.Fatalf("Was: %v but should be %v", r.events, exp)
+		}
+	}
+}
@@ -0,0 +1,375 @@
+package state
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Store which keeps each key yaml-encoded in a separate file in its
+// directory. Writes are atomic (a temporary file is written and renamed
+// afterwards), so a crash never leaves a half written file behind. Logs are
+// stored as yaml-stream, reading them always reads the whole file. Each table
+// is a yaml list of its records, every change rewrites the whole file.
+// Create with `NewFileStore`.
+type FileStore struct {
+	dir  string
+	mu   sync.Mutex
+	subs map[string]*FileStore // guarded by mu
+}
+
+// create a new store which keeps its data in `dir`. The directory is created
+// if it doesn't exist yet.
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	return &FileStore{
+		dir: dir,
+	}, nil
+}
+
+// the data of the namespace is kept in a subdirectory of this store. The same
+// name always returns the same store, so all writes to the directory share
+// its lock.
+func (s *FileStore) Sub(name string) (Store, error) {
+	if err := validKey(name); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if sub, ok := s.subs[name]; ok {
+		return sub, nil
+	}
+	sub, err := NewFileStore(filepath.Join(s.dir, name))
+	if err != nil {
+		return nil, err
+	}
+	if s.subs == nil {
+		s.subs = make(map[string]*FileStore)
+	}
+	s.subs[name] = sub
+	return sub, nil
+}
+
+func (s *FileStore) path(key string, ext string) (string, error) {
+	if err := validKey(key); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.dir, key+ext), nil
+}
+
+func (s *FileStore) Load(key string, v any) (version uint, err error) {
+	fn, err := s.path(key, ".yaml")
+	if err != nil {
+		return 0, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(fn)
+	if os.IsNotExist(err) {
+		return 0, ErrNotFound
+	} else if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	var e envelope
+	if err := yaml.NewDecoder(f).Decode(&e); err != nil {
+		return 0, fmt.Errorf("state %s: %v", key, err)
+	}
+	if err := e.Data.Decode(v); err != nil {
+		return e.Version, fmt.Errorf("state %s: %v", key, err)
+	}
+	return e.Version, nil
+}
+
+func (s *FileStore) Save(key string, version uint, v any) error {
+	fn, err := s.path(key, ".yaml")
+	if err != nil {
+		return err
+	}
+
+	e := envelope{Version: version}
+	if err := e.Data.Encode(v); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.write(key, fn, e)
+}
+
+// atomically replace the file `fn` with the yaml-encoded `v`. s.mu has to be
+// held.
+func (s *FileStore) write(key string, fn string, v any) error {
+	f, err := os.CreateTemp(s.dir, "."+key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	// only has an effect if the rename didn't happen
+	defer os.Remove(f.Name())
+
+	enc := yaml.NewEncoder(f)
+	if err := enc.Encode(v); err != nil {
+		f.Close()
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), fn)
+}
+
+func (s *FileStore) Delete(key string) error {
+	fn, err := s.path(key, ".yaml")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.Remove(fn); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *FileStore) Keys(prefix string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		key, ok := strings.CutSuffix(e.Name(), ".yaml")
+		// temporary files start with a '.' and are no valid keys
+		if !ok || validKey(key) != nil || !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		ret = append(ret, key)
+	}
+	sort.Strings(ret)
+	return ret, nil
+}
+
+func (s *FileStore) Append(name string, v any) error {
+	fn, err := s.path(name, ".log")
+	if err != nil {
+		return err
+	}
+
+	e := Entry{Time: time.Now()}
+	if err := e.Data.Encode(v); err != nil {
+		return err
+	}
+	// encode the whole document first so that it can be written at once
+	buf := bytes.NewBufferString("---\n")
+	enc := yaml.NewEncoder(buf)
+	if err := enc.Encode(e); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.OpenFile(fn, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func (s *FileStore) Tail(name string, n int) ([]Entry, error) {
+	fn, err := s.path(name, ".log")
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, err := os.Open(fn)
+	if os.IsNotExist(err) {
+		return []Entry{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ret := make([]Entry, 0)
+	d := yaml.NewDecoder(f)
+	for {
+		var e Entry
+		if err := d.Decode(&e); errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("log %s: %v", name, err)
+		}
+		ret = append(ret, e)
+	}
+	if n > 0 && len(ret) > n {
+		ret = ret[len(ret)-n:]
+	}
+	return ret, nil
+}
+
+// read all records of a table (sorted). s.mu has to be held.
+func (s *FileStore) records(table string, fn string) ([]Record, error) {
+	f, err := os.Open(fn)
+	if os.IsNotExist(err) {
+		return []Record{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ret := make([]Record, 0)
+	if err := yaml.NewDecoder(f).Decode(&ret); err != nil && !errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("table %s: %v", table, err)
+	}
+	return ret, nil
+}
+
+func (s *FileStore) Put(table string, group string, key string, t time.Time, v any) error {
+	fn, err := s.path(table, ".rec")
+	if err != nil {
+		return err
+	}
+
+	r := Record{Group: group, Key: key, Time: time.UnixMilli(t.UnixMilli())}
+	if err := r.Data.Encode(v); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.records(table, fn)
+	if err != nil {
+		return err
+	}
+	// keep the records sorted by time, group and key
+	i, found := sort.Find(len(records), func(i int) int {
+		return compareRecords(&r, &records[i])
+	})
+	if found {
+		records[i] = r
+	} else {
+		records = slices.Insert(records, i, r)
+	}
+	return s.write(table, fn, records)
+}
+
+// order of the records in a table
+func compareRecords(a *Record, b *Record) int {
+	return cmp.Or(
+		a.Time.Compare(b.Time),
+		strings.Compare(a.Group, b.Group),
+		strings.Compare(a.Key, b.Key),
+	)
+}
+
+func (s *FileStore) Records(table string, q Query) ([]Record, error) {
+	fn, err := s.path(table, ".rec")
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.records(table, fn)
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]Record, 0)
+	for i := range records {
+		if q.matches(&records[i]) {
+			ret = append(ret, records[i])
+		}
+	}
+	if q.Last > 0 && len(ret) > q.Last {
+		ret = ret[len(ret)-q.Last:]
+	}
+	return ret, nil
+}
+
+func (s *FileStore) Remove(table string, q Query) error {
+	fn, err := s.path(table, ".rec")
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records, err := s.records(table, fn)
+	if err != nil {
+		return err
+	}
+	n := len(records)
+	records = slices.DeleteFunc(records, func(r Record) bool {
+		return q.matches(&r)
+	})
+	if len(records) == n {
+		return nil
+	}
+	return s.write(table, fn, records)
+}
+
+func (s *FileStore) Close() error {
+	return nil
+}
@@ -0,0 +1,325 @@
+package state
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	_ "modernc.org/sqlite" // pure go, no cgo required
+)
+
+const sqliteSchema string = `
+CREATE TABLE IF NOT EXISTS state (
+	ns      TEXT    NOT NULL,
+	key     TEXT    NOT NULL,
+	version INTEGER NOT NULL,
+	data    BLOB    NOT NULL,
+	updated INTEGER NOT NULL,
+	PRIMARY KEY (ns, key)
+);
+CREATE TABLE IF NOT EXISTS log (
+	id   INTEGER PRIMARY KEY AUTOINCREMENT,
+	ns   TEXT    NOT NULL,
+	name TEXT    NOT NULL,
+	time INTEGER NOT NULL,
+	data BLOB    NOT NULL
+);
+CREATE INDEX IF NOT EXISTS log_ns_name ON log (ns, name, id);
+CREATE TABLE IF NOT EXISTS records (
+	ns   TEXT    NOT NULL,
+	tbl  TEXT    NOT NULL,
+	grp  TEXT    NOT NULL,
+	key  TEXT    NOT NULL,
+	time INTEGER NOT NULL,
+	data BLOB    NOT NULL,
+	PRIMARY KEY (ns, tbl, grp, key, time)
+);
+CREATE INDEX IF NOT EXISTS records_time ON records (ns, tbl, time);
+`
+
+// Store which keeps all data in a single SQLite database. All namespaces
+// (see `Sub`) share the same database, values are stored yaml-encoded like
+// with the `FileStore`.
+// Create with `NewSQLiteStore`.
+type SQLiteStore struct {
+	db *sql.DB
+	ns string
+	// only the root store owns the database connection
+	owner bool
+}
+
+// open (or create) the SQLite database `fn`.
+func NewSQLiteStore(fn string) (*SQLiteStore, error) {
+	if err := os.MkdirAll(filepath.Dir(fn), 0o700); err != nil {
+		return nil, err
+	}
+	dsn := (&url.URL{
+		Scheme:   "file",
+		Opaque:   fn,
+		RawQuery: "_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)",
+	}).String()
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite only supports one writer anyhow, serialize in the pool instead
+	// of running into SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &SQLiteStore{
+		db:    db,
+		owner: true,
+	}, nil
+}
+
+// namespaces are separated by '/', the data of the namespace is stored in the
+// same database.
+func (s *SQLiteStore) Sub(name string) (Store, error) {
+	if err := validKey(name); err != nil {
+		return nil, err
+	}
+	ns := name
+	if s.ns != "" {
+		ns = s.ns + "/" + name
+	}
+	return &SQLiteStore{
+		db: s.db,
+		ns: ns,
+	}, nil
+}
+
+func (s *SQLiteStore) Load(key string, v any) (version uint, err error) {
+	if err := validKey(key); err != nil {
+		return 0, err
+	}
+
+	var data []byte
+	err = s.db.QueryRow(`SELECT version, data FROM state WHERE ns = ? AND key = ?`, s.ns, key).Scan(&version, &data)
+	if err == sql.ErrNoRows {
+		return 0, ErrNotFound
+	} else if err != nil {
+		return 0, err
+	}
+
+	if err := yaml.Unmarshal(data, v); err != nil {
+		return version, fmt.Errorf("state %s: %v", key, err)
+	}
+	return version, nil
+}
+
+func (s *SQLiteStore) Save(key string, version uint, v any) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`INSERT INTO state (ns, key, version, data, updated) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (ns, key) DO UPDATE SET version = excluded.version, data = excluded.data, updated = excluded.updated`,
+		s.ns, key, version, data, time.Now().UnixMilli())
+	return err
+}
+
+func (s *SQLiteStore) Delete(key string) error {
+	if err := validKey(key); err != nil {
+		return err
+	}
+	_, err := s.db.Exec(`DELETE FROM state WHERE ns = ? AND key = ?`, s.ns, key)
+	return err
+}
+
+func (s *SQLiteStore) Keys(prefix string) ([]string, error) {
+	// substr instead of LIKE, otherwise '_' in the prefix would need escaping
+	rows, err := s.db.Query(`SELECT key FROM state WHERE ns = ? AND substr(key, 1, length(?)) = ? ORDER BY key`, s.ns, prefix, prefix)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]string, 0)
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			return nil, err
+		}
+		ret = append(ret, key)
+	}
+	return ret, rows.Err()
+}
+
+func (s *SQLiteStore) Append(name string, v any) error {
+	if err := validKey(name); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`INSERT INTO log (ns, name, time, data) VALUES (?, ?, ?, ?)`, s.ns, name, time.Now().UnixMilli(), data)
+	return err
+}
+
+func (s *SQLiteStore) Tail(name string, n int) ([]Entry, error) {
+	if err := validKey(name); err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		n = -1 // no limit
+	}
+
+	rows, err := s.db.Query(`SELECT time, data FROM (
+			SELECT id, time, data FROM log WHERE ns = ? AND name = ? ORDER BY id DESC LIMIT ?
+		) ORDER BY id`, s.ns, name, n)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]Entry, 0)
+	for rows.Next() {
+		var t int64
+		var data []byte
+		if err := rows.Scan(&t, &data); err != nil {
+			return nil, err
+		}
+		e := Entry{Time: time.UnixMilli(t)}
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("log %s: %v", name, err)
+		}
+		// unwrap the document node
+		if doc.Kind == yaml.DocumentNode && len(doc.Content) == 1 {
+			e.Data = *doc.Content[0]
+		} else {
+			e.Data = doc
+		}
+		ret = append(ret, e)
+	}
+	return ret, rows.Err()
+}
+
+func (s *SQLiteStore) Put(table string, group string, key string, t time.Time, v any) error {
+	if err := validKey(table); err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`INSERT INTO records (ns, tbl, grp, key, time, data) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (ns, tbl, grp, key, time) DO UPDATE SET data = excluded.data`,
+		s.ns, table, group, key, t.UnixMilli(), data)
+	return err
+}
+
+// build the WHERE clause selecting the records of `table` which match `q`
+func (s *SQLiteStore) where(table string, q Query) (string, []any) {
+	cond := "ns = ? AND tbl = ?"
+	args := []any{s.ns, table}
+	if q.Group != "" {
+		cond += " AND grp = ?"
+		args = append(args, q.Group)
+	}
+	if q.Key != "" {
+		cond += " AND key = ?"
+		args = append(args, q.Key)
+	}
+	if !q.Since.IsZero() {
+		cond += " AND time >= ?"
+		args = append(args, q.Since.UnixMilli())
+	}
+	if !q.Before.IsZero() {
+		cond += " AND time < ?"
+		args = append(args, q.Before.UnixMilli())
+	}
+	return cond, args
+}
+
+func (s *SQLiteStore) Records(table string, q Query) ([]Record, error) {
+	if err := validKey(table); err != nil {
+		return nil, err
+	}
+	limit := q.Last
+	if limit <= 0 {
+		limit = -1 // no limit
+	}
+
+	cond, args := s.where(table, q)
+	rows, err := s.db.Query(`SELECT grp, key, time, data FROM (
+			SELECT grp, key, time, data FROM records WHERE `+cond+` ORDER BY time DESC, grp DESC, key DESC LIMIT ?
+		) ORDER BY time, grp, key`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ret := make([]Record, 0)
+	for rows.Next() {
+		var t int64
+		var data []byte
+		var r Record
+		if err := rows.Scan(&r.Group, &r.Key, &t, &data); err != nil {
+			return nil, err
+		}
+		r.Time = time.UnixMilli(t)
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, fmt.Errorf("table %s: %v", table, err)
+		}
+		// unwrap the document node
+		if doc.Kind == yaml.DocumentNode && len(doc.Content) == 1 {
+			r.Data = *doc.Content[0]
+		} else {
+			r.Data = doc
+		}
+		ret = append(ret, r)
+	}
+	return ret, rows.Err()
+}
+
+func (s *SQLiteStore) Remove(table string, q Query) error {
+	if err := validKey(table); err != nil {
+		return err
+	}
+	cond, args := s.where(table, q)
+	_, err := s.db.Exec(`DELETE FROM records WHERE `+cond, args...)
+	return err
+}
+
+func (s *SQLiteStore) Close() error {
+	if !s.owner {
+		return nil
+	}
+	return s.db.Close()
+}
@@ -0,0 +1,159 @@
+package state
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// errors
+var (
+	ErrNotFound       error = errors.New("No state stored for this key")
+	ErrInvalidKey     error = errors.New("Invalid key, only alphanumerics, '-', '_' and '.' are allowed")
+	ErrInvalidBackend error = errors.New("Invalid state backend")
+)
+
+var keyRe *regexp.Regexp = regexp.MustCompile(`^[a-zA-Z0-9_-][a-zA-Z0-9_.-]*$`)
+
+func validKey(key string) error {
+	if !keyRe.MatchString(key) {
+		return ErrInvalidKey
+	}
+	return nil
+}
+
+// key/value store for the runtime state of the bot. Values are stored
+// together with a schema version, so the user of the store is able to migrate
+// old data. Besides the plain values a store holds append-only logs (e.g. for
+// auditing).
+// Implementations have to be safe for concurrent use.
+type Store interface {
+	// read the value stored for `key` into `v`. Returns the schema version
+	// the value was stored with. If nothing is stored for `key`,
+	// ErrNotFound is returned.
+	Load(key string, v any) (version uint, err error)
+	// store `v` for `key` with the schema version `version`.
+	Save(key string, version uint, v any) error
+	// remove the value stored for `key`. Removing a non-existing key is not
+	// an error.
+	Delete(key string) error
+	// list all keys (sorted) which start with `prefix`.
+	Keys(prefix string) ([]string, error)
+
+	// append `v` to the log `name`
+	Append(name string, v any) error
+	// read the last `n` entries of the log `name` (oldest first). If `n` is
+	// not positive, all entries are returned.
+	Tail(name string, n int) ([]Entry, error)
+
+	// store `v` as record of the table `table`. A record is identified by
+	// its `group`, `key` and time `t` (millisecond precision), an existing
+	// record is replaced.
+	Put(table string, group string, key string, t time.Time, v any) error
+	// read the records of the table `table` which match `q` (oldest first).
+	Records(table string, q Query) ([]Record, error)
+	// remove the records of the table `table` which match `q`. `q.Last` is
+	// ignored.
+	Remove(table string, q Query) error
+
+	// get a store for a namespace (e.g. a module). The data of the namespace
+	// is kept separate from the data of this store.
+	Sub(name string) (Store, error)
+	// release the resources of the store. Closing a store returned by `Sub`
+	// is a no-op.
+	Close() error
+}
+
+// entry of a log
+type Entry struct {
+	Time time.Time `yaml:"time"`
+	Data yaml.Node `yaml:"data"`
+}
+
+// decode the data of the entry into `v`
+func (e *Entry) Decode(v any) error {
+	return e.Data.Decode(v)
+}
+
+// record of a table. Tables hold data which is queried by group (e.g. the
+// chat) and time, like reminders or the snapshots of a history.
+type Record struct {
+	Group string    `yaml:"group"`
+	Key   string    `yaml:"key"`
+	Time  time.Time `yaml:"time"`
+	Data  yaml.Node `yaml:"data"`
+}
+
+// decode the data of the record into `v`
+func (r *Record) Decode(v any) error {
+	return r.Data.Decode(v)
+}
+
+// selects records of a table. Fields which are not set don't restrict the
+// selection.
+type Query struct {
+	Group string
+	Key   string
+	// only records at or after this time
+	Since time.Time
+	// only records before this time
+	Before time.Time
+	// only the last `Last` of the selected records
+	Last int
+}
+
+// check if the record is selected by the query (`Last` is not considered).
+// Like the time of the record, the times are compared in milliseconds.
+func (q *Query) matches(r *Record) bool {
+	return (q.Group == "" || r.Group == q.Group) &&
+		(q.Key == "" || r.Key == q.Key) &&
+		(q.Since.IsZero() || r.Time.UnixMilli() >= q.Since.UnixMilli()) &&
+		(q.Before.IsZero() || r.Time.UnixMilli() < q.Before.UnixMilli())
+}
+
+// every value is wrapped in an envelope storing the schema version of the
+// data.
+type envelope struct {
+	Version uint      `yaml:"version"`
+	Data    yaml.Node `yaml:"data"`
+}
+
+// available backends for the state
+type Backend string
+
+var (
+	BackendYaml   Backend = "yaml"
+	BackendSQLite Backend = "sqlite"
+)
+
+// open the store of the backend `backend` in the directory `dir`. An empty
+// backend defaults to BackendYaml.
+func Open(backend Backend, dir string) (Store, error) {
+	switch backend {
+	case "", BackendYaml:
+		return NewFileStore(dir)
+	case BackendSQLite:
+		return NewSQLiteStore(filepath.Join(dir, "state.db"))
+	}
+	return nil, fmt.Errorf("%v: %s", ErrInvalidBackend, backend)
+}
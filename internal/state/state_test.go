@@ -0,0 +1,283 @@
+package state_test
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"signalbot_go/internal/state"
+	"sync"
+	"testing"
+	"time"
+)
+
+type data struct {
+	Name  string            `yaml:"name"`
+	Items map[string]string `yaml:"items"`
+}
+
+func testStore(t *testing.T, root state.Store) {
+	defer root.Close()
+	s, err := root.Sub("module")
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	var out data
+	if _, err := s.Load("data", &out); err != state.ErrNotFound {
+		t.Fatalf("Should have returned %v but was %v", state.ErrNotFound, err)
+	}
+
+	in := data{Name: "test", Items: map[string]string{"a": "b"}}
+	if err := s.Save("data", 3, in); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	version, err := s.Load("data", &out)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if version != 3 {
+		t.Fatalf("Was: %v but should be %v", version, 3)
+	}
+	if !reflect.DeepEqual(in, out) {
+		t.Fatalf("Was: %v but should be %v", out, in)
+	}
+
+	// namespaces are separated
+	if _, err := root.Load("data", &out); err != state.ErrNotFound {
+		t.Fatalf("Should have returned %v but was %v", state.ErrNotFound, err)
+	}
+
+	if err := s.Save("data.b", 1, in); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if err := s.Save("other", 1, in); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	keys, err := s.Keys("data")
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if !reflect.DeepEqual(keys, []string{"data", "data.b"}) {
+		t.Fatalf("Was: %v but should be %v", keys, []string{"data", "data.b"})
+	}
+
+	if err := s.Delete("data"); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if _, err := s.Load("data", &out); err != state.ErrNotFound {
+		t.Fatalf("Should have returned %v but was %v", state.ErrNotFound, err)
+	}
+
+	if err := s.Save("../escape", 1, in); err != state.ErrInvalidKey {
+		t.Fatalf("Should have returned %v but was %v", state.ErrInvalidKey, err)
+	}
+
+	// logs
+	entries, err := s.Tail("audit", 10)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("Was: %v but should be empty", entries)
+	}
+	for i := range 5 {
+		if err := s.Append("audit", i); err != nil {
+			t.Fatalf("Err: %v", err)
+		}
+	}
+	entries, err = s.Tail("audit", 3)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	got := make([]int, 0, len(entries))
+	for _, e := range entries {
+		var i int
+		if err := e.Decode(&i); err != nil {
+			t.Fatalf("Err: %v", err)
+		}
+		got = append(got, i)
+	}
+	if !reflect.DeepEqual(got, []int{2, 3, 4}) {
+		t.Fatalf("Was: %v but should be %v", got, []int{2, 3, 4})
+	}
+	entries, err = s.Tail("audit", 0)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if len(entries) != 5 {
+		t.Fatalf("Was: %v but should be %v", len(entries), 5)
+	}
+
+	testRecords(t, s)
+	testSharedSub(t, root)
+}
+
+// stores of the same namespace write concurrently (run with -race)
+func testSharedSub(t *testing.T, root state.Store) {
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	var wg sync.WaitGroup
+	errs := make(chan error, 40)
+	for i := range 2 {
+		s, err := root.Sub("shared")
+		if err != nil {
+			t.Fatalf("Err: %v", err)
+		}
+		for j := range 20 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				errs <- s.Put("table", fmt.Sprint(i), fmt.Sprint(j), base, j)
+			}()
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Err: %v", err)
+		}
+	}
+	s, err := root.Sub("shared")
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	records, err := s.Records("table", state.Query{})
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if len(records) != 40 {
+		t.Fatalf("Was: %v but should be %v records", len(records), 40)
+	}
+}
+
+// decode the records into "group/key:data" strings
+func recordStrings(t *testing.T, records []state.Record) []string {
+	ret := make([]string, 0, len(records))
+	for _, r := range records {
+		var d string
+		if err := r.Decode(&d); err != nil {
+			t.Fatalf("Err: %v", err)
+		}
+		ret = append(ret, r.Group+"/"+r.Key+":"+d)
+	}
+	return ret
+}
+
+func testRecords(t *testing.T, s state.Store) {
+	records, err := s.Records("reminders", state.Query{})
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if len(records) != 0 {
+		t.Fatalf("Was: %v but should be empty", records)
+	}
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	in := []struct {
+		group, key string
+		t          time.Time
+		data       string
+	}{
+		{"chatA", "alice", base.Add(2 * time.Hour), "a2"},
+		{"chatB", "bob", base.Add(time.Hour), "b1"},
+		{"chatA", "bob", base, "a0"},
+		{"chatA", "alice", base.Add(3 * time.Hour), "a3"},
+		// replaces the record with the same group, key and time
+		{"chatA", "alice", base.Add(2 * time.Hour), "a2'"},
+	}
+	for _, r := range in {
+		if err := s.Put("reminders", r.group, r.key, r.t, r.data); err != nil {
+			t.Fatalf("Err: %v", err)
+		}
+	}
+	if err := s.Put("../escape", "", "", base, ""); err != state.ErrInvalidKey {
+		t.Fatalf("Should have returned %v but was %v", state.ErrInvalidKey, err)
+	}
+
+	tests := []struct {
+		q   state.Query
+		out []string
+	}{
+		{state.Query{}, []string{"chatA/bob:a0", "chatB/bob:b1", "chatA/alice:a2'", "chatA/alice:a3"}},
+		{state.Query{Group: "chatA"}, []string{"chatA/bob:a0", "chatA/alice:a2'", "chatA/alice:a3"}},
+		{state.Query{Key: "bob"}, []string{"chatA/bob:a0", "chatB/bob:b1"}},
+		{state.Query{Group: "chatA", Last: 2}, []string{"chatA/alice:a2'", "chatA/alice:a3"}},
+		{state.Query{Since: base.Add(time.Hour), Before: base.Add(3 * time.Hour)}, []string{"chatB/bob:b1", "chatA/alice:a2'"}},
+	}
+	for _, test := range tests {
+		records, err := s.Records("reminders", test.q)
+		if err != nil {
+			t.Fatalf("Err: %v", err)
+		}
+		if got := recordStrings(t, records); !reflect.DeepEqual(got, test.out) {
+			t.Fatalf("Query %+v was: %v but should be %v", test.q, got, test.out)
+		}
+	}
+	records, err = s.Records("reminders", state.Query{Last: 1})
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if !records[0].Time.Equal(base.Add(3 * time.Hour)) {
+		t.Fatalf("Was: %v but should be %v", records[0].Time, base.Add(3*time.Hour))
+	}
+
+	// tables are separate from the keys and logs
+	if _, err := s.Load("reminders", new(string)); err != state.ErrNotFound {
+		t.Fatalf("Should have returned %v but was %v", state.ErrNotFound, err)
+	}
+
+	if err := s.Remove("reminders", state.Query{Group: "chatA", Before: base.Add(3 * time.Hour)}); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	records, err = s.Records("reminders", state.Query{})
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if got, out := recordStrings(t, records), []string{"chatB/bob:b1", "chatA/alice:a3"}; !reflect.DeepEqual(got, out) {
+		t.Fatalf("Was: %v but should be %v", got, out)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	dir := t.TempDir()
+	root, err := state.NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	testStore(t, root)
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(dir + "/module")
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	for _, e := range entries {
+		if e.Name()[0] == '.' {
+			t.Fatalf("Temporary file left in the store: %v", e.Name())
+		}
+	}
+}
+
+func TestSQLiteStore(t *testing.T) {
+	root, err := state.Open(state.BackendSQLite, t.TempDir())
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	testStore(t, root)
+}
@@ -21,10 +21,13 @@ import (
 	"os"
 	"path/filepath"
 	"signalbot_go/internal/signalsender"
+	"signalbot_go/internal/state"
 	"signalbot_go/modules"
 	"signalbot_go/signalcli"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/alexflint/go-arg"
 	"log/slog"
@@ -37,15 +40,18 @@ type Buechertreff struct {
 	modules.Module
 	Series  map[string]string `yaml:"series"`
 	fetcher Fetcher           `yaml:"-"`
+
+	mu sync.Mutex `yaml:"-"` // guards the series
 }
 
 // instanciates a new Buechertreff from a configuration file
 // (cfgDir/buechertreff.yaml)
-func NewBuechertreff(log *slog.Logger, cfgDir string) (*Buechertreff, error) {
+func NewBuechertreff(log *slog.Logger, cfgDir string, st state.Store) (*Buechertreff, error) {
 	r := Buechertreff{
 		Module:  modules.NewModule(log, cfgDir),
 		fetcher: Fetcher{},
 	}
+	r.State = st
 
 	f, err := os.Open(filepath.Join(r.ConfigDir, "buechertreff.yaml"))
 	if err != nil {
@@ -60,6 +66,13 @@ func NewBuechertreff(log *slog.Logger, cfgDir string) (*Buechertreff, error) {
 		return nil, err
 	}
 
+	if r.Series == nil {
+		r.Series = make(map[string]string)
+	}
+	if err := r.loadAddedSeries(); err != nil {
+		return nil, err
+	}
+
 	// validation
 	if err := r.Validate(); err != nil {
 		return nil, err
@@ -68,6 +81,40 @@ func NewBuechertreff(log *slog.Logger, cfgDir string) (*Buechertreff, error) {
 	return &r, nil
 }
 
+// the series added at runtime are stored in this table of the state. Each
+// series is a record, the group is the chat it was added in and the key its
+// name.
+const seriesTable string = "series"
+
+// read the series added at runtime from the state
+func (r *Buechertreff) loadAddedSeries() error {
+	records, err := r.Records(seriesTable, state.Query{})
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		var url string
+		if err := rec.Decode(&url); err != nil {
+			return fmt.Errorf("series %s: %v", rec.Key, err)
+		}
+		r.Series[rec.Key] = url
+	}
+	return nil
+}
+
+// add a series at runtime in `chat`. r.mu has to be held.
+func (r *Buechertreff) insert(chat string, which string, url string) {
+	r.Series[which] = url
+	// replaces the series if it was added before
+	if err := r.RemoveRecords(seriesTable, state.Query{Key: which}); err != nil {
+		r.Log.Error(fmt.Sprintf("Error saving the added series: %v", err))
+		return
+	}
+	if err := r.PutRecord(seriesTable, chat, which, time.Now(), url); err != nil {
+		r.Log.Error(fmt.Sprintf("Error saving the added series: %v", err))
+	}
+}
+
 // validates the buechertreff struct
 func (r *Buechertreff) Validate() error {
 	// validate the generic module first
@@ -101,8 +148,9 @@ func (r *Buechertreff) Handle(m *signalcli.Message, signal signalsender.SignalSe
 		return
 	}
 
+	r.mu.Lock()
 	if args.Insert != "" {
-		r.Series[args.Which] = args.Insert
+		r.insert(m.Chat, args.Which, args.Insert)
 	}
 
 	url, ok := r.Series[args.Which]
@@ -117,11 +165,13 @@ func (r *Buechertreff) Handle(m *signalcli.Message, signal signalsender.SignalSe
 		for k := range r.Series {
 			sorted = append(sorted, k)
 		}
+		r.mu.Unlock()
 		sorted.Sort()
 		builder.WriteString(strings.Join(sorted, ", "))
 		r.SendError(m, signal, builder.String())
 		return
 	}
+	r.mu.Unlock()
 
 	// execute the query
 	reader, err := r.fetcher.getReader(url)
@@ -149,22 +199,3 @@ func (r *Buechertreff) Handle(m *signalcli.Message, signal signalsender.SignalSe
 		return
 	}
 }
-
-// save config file in case something has changed (module allows to add new
-// series during runtime)
-func (r *Buechertreff) Close(virtRcv func(*signalcli.Message)) {
-	r.Module.Close(virtRcv)
-
-	f, err := os.Create(filepath.Join(r.ConfigDir, "buechertreff.yaml"))
-	if err != nil {
-		r.Log.Error(fmt.Sprintf("Error opening 'buechertreff.yaml': %v", err))
-	}
-	defer f.Close()
-
-	e := yaml.NewEncoder(f)
-	defer e.Close()
-	err = e.Encode(r)
-	if err != nil {
-		r.Log.Error(fmt.Sprintf("Error endcoding to 'buechertreff.yaml': %v", err))
-	}
-}
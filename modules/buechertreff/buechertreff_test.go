@@ -0,0 +1,63 @@
+package buechertreff
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"bytes"
+	"os"
+	"signalbot_go/internal/modtest"
+	"signalbot_go/internal/state"
+	"testing"
+)
+
+func TestAddedSeries(t *testing.T) {
+	modtest.MockHTTP(t, modtest.Transport{"https://bt.test/a": "test1.html"})
+	st, err := state.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	cfg, err := os.ReadFile("buechertreff.yaml")
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	buecher, err := NewBuechertreff(nopLog(), "./", st)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	sender := &modtest.Sender{}
+	modtest.Fire(buecher, sender, modtest.Messages(1, "+49123", "+49123", "a -i https://bt.test/a")...)
+	if resps := sender.Get(); len(resps) != 1 {
+		t.Fatalf("Was: %v", resps)
+	}
+	buecher.Close(nil)
+
+	// the configuration stays untouched, the series is in the state
+	if after, err := os.ReadFile("buechertreff.yaml"); err != nil || !bytes.Equal(after, cfg) {
+		t.Fatalf("Was: %q (%v) but should be: %q", after, err, cfg)
+	}
+	restarted, err := NewBuechertreff(nopLog(), "./", st)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if restarted.Series["a"] != "https://bt.test/a" {
+		t.Fatalf("Was: %v but should contain the added series", restarted.Series)
+	}
+	records, err := st.Records(seriesTable, state.Query{Group: "+49123", Key: "a"})
+	if err != nil || len(records) != 1 {
+		t.Fatalf("Was: %v %v but should be %v record", records, err, 1)
+	}
+}
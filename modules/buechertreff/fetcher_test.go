@@ -30,7 +30,7 @@ func nopLog() *slog.Logger {
 
 func TestFetcher(t *testing.T) {
 	log := nopLog()
-	buecher, err := NewBuechertreff(log, "./")
+	buecher, err := NewBuechertreff(log, "./", nil)
 	if err != nil {
 		panic(err)
 	}
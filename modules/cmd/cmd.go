@@ -27,6 +27,7 @@ import (
 	"signalbot_go/internal/signalsender"
 	"signalbot_go/modules"
 	"signalbot_go/signalcli"
+	"strings"
 
 	"log/slog"
 	"gopkg.in/yaml.v3"
@@ -144,7 +145,12 @@ func (r *Cmd) Handle(m *signalcli.Message, signal signalsender.SignalSender, vir
 		}
 
 		r.Log.Info(fmt.Sprintf("Command returned successfully. Output:\n%s", output))
-		_, err = signal.Respond(string(output), nil, m, true)
+		reply := string(output)
+		if strings.TrimSpace(reply) != "" {
+			// command output is usually aligned for a terminal
+			reply = "```\n" + signalcli.EscapeMarkup(reply) + "```"
+		}
+		_, err = signal.RespondStyled(reply, nil, m, true)
 		if err != nil {
 			r.Log.Error(fmt.Sprintf("Failed to send reply to %v", m))
 		}
@@ -0,0 +1,186 @@
+package contacts
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"signalbot_go/internal/signalsender"
+	"signalbot_go/modules"
+	"signalbot_go/signalcli"
+
+	"github.com/alexflint/go-arg"
+	"log/slog"
+)
+
+// the contact and profile management of the account (implemented by
+// signalcli.Account)
+type Manager interface {
+	ListContacts() ([]signalcli.Contact, error)
+	SetContactName(number string, name string) error
+	SetContactBlocked(number string, block bool) error
+	SetExpirationTimer(number string, expiration time.Duration) error
+	UpdateProfile(name string, about string, aboutEmoji string, avatar string, remove bool) error
+}
+
+// contacts module, manages the contacts of the bot account. Should be
+// instanciated with `NewContacts`.
+type Contacts struct {
+	modules.Module
+	contacts Manager `yaml:"-"`
+}
+
+func NewContacts(log *slog.Logger, cfgDir string, contacts Manager) (*Contacts, error) {
+	r := Contacts{
+		Module:   modules.NewModule(log, cfgDir),
+		contacts: contacts,
+	}
+
+	// validation
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func (r *Contacts) Validate() error {
+	if err := r.Module.Validate(); err != nil {
+		return err
+	}
+	if r.contacts == nil {
+		return errors.New("no contact manager set")
+	}
+	return nil
+}
+
+// specifies the arguments when handling a request to this module
+type Args struct {
+	Ls      *lsArgs     `arg:"subcommand:list|ls|l"`
+	Name    *nameArgs   `arg:"subcommand:name|n"`
+	Block   *numberArgs `arg:"subcommand:block"`
+	Unblock *numberArgs `arg:"subcommand:unblock"`
+	Expire  *expireArgs `arg:"subcommand:expire"`
+}
+
+type lsArgs struct{}
+
+type numberArgs struct {
+	Number string `arg:"positional,required" help:"phone number of the contact"`
+}
+
+type nameArgs struct {
+	numberArgs
+	Name string `arg:"positional,required"`
+}
+
+type expireArgs struct {
+	numberArgs
+	Expiration time.Duration `arg:"positional,required" help:"time until messages disappear (0 to disable)"`
+}
+
+// Handle a message from the signalcli. Parses the message, executes the
+// operation and responds to signal.
+func (r *Contacts) Handle(m *signalcli.Message, signal signalsender.SignalSender, virtRcv func(*signalcli.Message)) {
+	var args Args
+	parser, err := arg.NewParser(arg.Config{}, &args)
+	if err != nil {
+		r.Log.Error(fmt.Sprintf("newParser -> %v", err))
+		return
+	}
+
+	if err := r.Module.Handle(m, signal, virtRcv, parser); err != nil {
+		errMsg := err.Error()
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+		return
+	}
+
+	var reply string
+	switch {
+	case args.Ls != nil:
+		reply, err = r.ls()
+	case args.Name != nil:
+		err = r.contacts.SetContactName(args.Name.Number, args.Name.Name)
+		reply = fmt.Sprintf("Named %s %s", args.Name.Number, args.Name.Name)
+	case args.Block != nil:
+		err = r.contacts.SetContactBlocked(args.Block.Number, true)
+		reply = fmt.Sprintf("Blocked %s", args.Block.Number)
+	case args.Unblock != nil:
+		err = r.contacts.SetContactBlocked(args.Unblock.Number, false)
+		reply = fmt.Sprintf("Unblocked %s", args.Unblock.Number)
+	case args.Expire != nil:
+		err = r.contacts.SetExpirationTimer(args.Expire.Number, args.Expire.Expiration)
+		if args.Expire.Expiration == 0 {
+			reply = fmt.Sprintf("Disabled disappearing messages for %s", args.Expire.Number)
+		} else {
+			reply = fmt.Sprintf("Messages with %s disappear after %v", args.Expire.Number, args.Expire.Expiration)
+		}
+	default:
+		err = errors.New("unknown/no subcommand")
+	}
+	if err != nil {
+		errMsg := fmt.Sprintf("Error: %v", err)
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+		return
+	}
+
+	// respond
+	_, err = signal.Respond(reply, nil, m, true)
+	if err != nil {
+		errMsg := fmt.Sprintf("Error: %v", err)
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+	}
+}
+
+// list all contacts, blocked ones are marked with a '!'
+func (r *Contacts) ls() (string, error) {
+	contacts, err := r.contacts.ListContacts()
+	if err != nil {
+		return "", err
+	}
+	if len(contacts) == 0 {
+		return "No contacts", nil
+	}
+	slices.SortFunc(contacts, func(a, b signalcli.Contact) int {
+		if c := strings.Compare(a.Name, b.Name); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Number, b.Number)
+	})
+	builder := strings.Builder{}
+	for i, c := range contacts {
+		if i > 0 {
+			builder.WriteRune('\n')
+		}
+		if c.Name != "" {
+			builder.WriteString(fmt.Sprintf("%s (%s)", c.Name, c.Number))
+		} else {
+			builder.WriteString(c.Number)
+		}
+		if c.Blocked {
+			builder.WriteString(" !")
+		}
+	}
+	return builder.String(), nil
+}
@@ -0,0 +1,107 @@
+package contacts
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"io"
+	"log/slog"
+	"signalbot_go/internal/modtest"
+	"signalbot_go/signalcli"
+	"testing"
+	"time"
+)
+
+// in-memory contact management, records the last call
+type fakeManager struct {
+	contacts []signalcli.Contact
+	last     string
+}
+
+func (f *fakeManager) ListContacts() ([]signalcli.Contact, error) { return f.contacts, nil }
+func (f *fakeManager) SetContactName(number string, name string) error {
+	f.last = "name " + number + " " + name
+	return nil
+}
+func (f *fakeManager) SetContactBlocked(number string, block bool) error {
+	if block {
+		f.last = "block " + number
+	} else {
+		f.last = "unblock " + number
+	}
+	return nil
+}
+func (f *fakeManager) SetExpirationTimer(number string, expiration time.Duration) error {
+	f.last = "expire " + number + " " + expiration.String()
+	return nil
+}
+func (f *fakeManager) UpdateProfile(name string, about string, aboutEmoji string, avatar string, remove bool) error {
+	f.last = "profile " + name + "|" + about + "|" + aboutEmoji + "|" + avatar
+	if remove {
+		f.last += "|remove"
+	}
+	return nil
+}
+
+func TestContacts(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := &fakeManager{contacts: []signalcli.Contact{
+		{Number: "+49b", Name: "Bob", Blocked: true},
+		{Number: "+49c"},
+		{Number: "+49a", Name: "Alice"},
+	}}
+	c, err := NewContacts(log, "", f)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	p, err := NewProfile(log, "", f)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	type handler func(m *signalcli.Message, s *modtest.Sender)
+	contacts := func(m *signalcli.Message, s *modtest.Sender) { c.Handle(m, s, func(*signalcli.Message) {}) }
+	profile := func(m *signalcli.Message, s *modtest.Sender) { p.Handle(m, s, func(*signalcli.Message) {}) }
+	for _, tc := range []struct {
+		handle handler
+		msg    string
+		att    []string
+		reply  string
+		last   string
+	}{
+		{handle: contacts, msg: "ls", reply: "+49c\nAlice (+49a)\nBob (+49b) !"},
+		{handle: contacts, msg: "name +49c Carol", reply: "Named +49c Carol", last: "name +49c Carol"},
+		{handle: contacts, msg: "block +49c", reply: "Blocked +49c", last: "block +49c"},
+		{handle: contacts, msg: "unblock +49b", reply: "Unblocked +49b", last: "unblock +49b"},
+		{handle: contacts, msg: "expire +49a 1h", reply: "Messages with +49a disappear after 1h0m0s", last: "expire +49a 1h0m0s"},
+		{handle: contacts, msg: "expire +49a 0s", reply: "Disabled disappearing messages for +49a", last: "expire +49a 0s"},
+		{handle: profile, msg: "-n Bot -a beep", reply: "Updated the profile", last: "profile Bot|beep||"},
+		{handle: profile, msg: "", att: []string{"img"}, reply: "Updated the profile", last: "profile |||img"},
+		{handle: profile, msg: "--remove-avatar", reply: "Updated the profile", last: "profile ||||remove"},
+		{handle: profile, msg: "", reply: "Error: nothing to update"},
+	} {
+		f.last = ""
+		s := &modtest.Sender{}
+		m := &signalcli.Message{Sender: "+49a", Chat: "+49a", Message: tc.msg, Attachments: tc.att}
+		tc.handle(m, s)
+		if got := s.Get(); len(got) != 1 || got[0] != tc.reply {
+			t.Fatalf("%s: Was: %q but should be %q", tc.msg, got, tc.reply)
+		}
+		if f.last != tc.last {
+			t.Fatalf("%s: Called %q but should be %q", tc.msg, f.last, tc.last)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package contacts
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"errors"
+	"fmt"
+
+	"signalbot_go/internal/signalsender"
+	"signalbot_go/modules"
+	"signalbot_go/signalcli"
+
+	"github.com/alexflint/go-arg"
+	"log/slog"
+)
+
+// profile module, updates the profile of the bot account. Should be
+// instanciated with `NewProfile`.
+type Profile struct {
+	modules.Module
+	profile Manager `yaml:"-"`
+}
+
+func NewProfile(log *slog.Logger, cfgDir string, profile Manager) (*Profile, error) {
+	r := Profile{
+		Module:  modules.NewModule(log, cfgDir),
+		profile: profile,
+	}
+
+	// validation
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func (r *Profile) Validate() error {
+	if err := r.Module.Validate(); err != nil {
+		return err
+	}
+	if r.profile == nil {
+		return errors.New("no profile manager set")
+	}
+	return nil
+}
+
+// specifies the arguments when handling a request to this module. An image
+// attached to the message becomes the new avatar.
+type ProfileArgs struct {
+	Name         string `arg:"--name,-n" help:"display name"`
+	About        string `arg:"--about,-a"`
+	Emoji        string `arg:"--emoji,-e" help:"emoji shown next to the about text"`
+	RemoveAvatar bool   `arg:"--remove-avatar"`
+}
+
+// Handle a message from the signalcli. Parses the message, executes the
+// operation and responds to signal.
+func (r *Profile) Handle(m *signalcli.Message, signal signalsender.SignalSender, virtRcv func(*signalcli.Message)) {
+	var args ProfileArgs
+	parser, err := arg.NewParser(arg.Config{}, &args)
+	if err != nil {
+		r.Log.Error(fmt.Sprintf("newParser -> %v", err))
+		return
+	}
+
+	if err := r.Module.Handle(m, signal, virtRcv, parser); err != nil {
+		errMsg := err.Error()
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+		return
+	}
+
+	avatar := ""
+	if len(m.Attachments) > 0 {
+		avatar = m.Attachments[0]
+	}
+	if args.Name == "" && args.About == "" && args.Emoji == "" && avatar == "" && !args.RemoveAvatar {
+		errMsg := "Error: nothing to update"
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+		return
+	}
+	if avatar != "" && args.RemoveAvatar {
+		errMsg := "Error: either attach an avatar or remove it"
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+		return
+	}
+
+	if err := r.profile.UpdateProfile(args.Name, args.About, args.Emoji, avatar, args.RemoveAvatar); err != nil {
+		errMsg := fmt.Sprintf("Error: %v", err)
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+		return
+	}
+
+	// respond
+	_, err = signal.Respond("Updated the profile", nil, m, true)
+	if err != nil {
+		errMsg := fmt.Sprintf("Error: %v", err)
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+	}
+}
@@ -0,0 +1,104 @@
+package fernsehserien
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+
+import (
+	"signalbot_go/internal/modtest"
+	"signalbot_go/internal/state"
+	"sort"
+	"testing"
+)
+
+// run with -race
+func TestConcurrentInsertDiff(t *testing.T) {
+	modtest.MockHTTP(t, modtest.Transport{
+		"https://fs.test/a": "test1A.html",
+		"https://fs.test/b": "test1B.html",
+		"https://fs.test/c": "test1C.html",
+	})
+	st, err := state.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	fserie, err := NewFernsehserien(nopLog(), "./", st)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	msgs := modtest.Messages(1, "+49123", "+49123", "a -i https://fs.test/a --diff")
+	msgs = append(msgs, modtest.Messages(1, "+49123", "+49456", "b -i https://fs.test/b --diff")...)
+	msgs = append(msgs, modtest.Messages(1, "+49456", "+49123", "c -i https://fs.test/c")...)
+	msgs = append(msgs, modtest.Messages(10, "+49123", "+49123", "all --diff")...)
+	msgs = append(msgs, modtest.Messages(5, "+49123", "+49123", "all --history")...)
+	sender := &modtest.Sender{}
+	modtest.Fire(fserie, sender, msgs...)
+
+	if resps := sender.Get(); len(resps) != len(msgs) {
+		t.Fatalf("Was: %v responses but should be %v", len(resps), len(msgs))
+	}
+
+	all := fserie.Aliases["all"]
+	sort.Strings(all)
+	if len(all) != 3 || all[0] != "a" || all[1] != "b" || all[2] != "c" {
+		t.Fatalf("Was: %v but should be %v", all, []string{"a", "b", "c"})
+	}
+
+	// the inserted series survive a restart
+	fserie, err = NewFernsehserien(nopLog(), "./", st)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if len(fserie.Series) != 3 {
+		t.Fatalf("Was: %v but should have %v series", fserie.Series, 3)
+	}
+	records, err := st.Records(seriesTable, state.Query{Group: "+49123"})
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Was: %v but should be the %v series added in the chat", records, 2)
+	}
+}
+
+func TestAddedSeriesMigration(t *testing.T) {
+	st, err := state.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	// the added series used to be stored as a single value
+	if err := st.Save(addedSeriesKey, 1, map[string]string{"a": "https://fs.test/a"}); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	fserie, err := NewFernsehserien(nopLog(), "./", st)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if fserie.Series["a"] != "https://fs.test/a" || len(fserie.Aliases["a"]) != 1 {
+		t.Fatalf("Was: %v but should contain the migrated series", fserie.Series)
+	}
+	if _, err := st.Load(addedSeriesKey, new(map[string]string)); err != state.ErrNotFound {
+		t.Fatalf("Should have returned %v but was %v", state.ErrNotFound, err)
+	}
+	records, err := st.Records(seriesTable, state.Query{Key: "a"})
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if len(records) != 1 {
+		t.Fatalf("Was: %v but should be %v record", records, 1)
+	}
+}
@@ -17,15 +17,19 @@ package fernsehserien
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"signalbot_go/internal/differ"
 	"signalbot_go/internal/signalsender"
+	"signalbot_go/internal/state"
 	"signalbot_go/modules"
 	"signalbot_go/signalcli"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/alexflint/go-arg"
 	"log/slog"
@@ -37,20 +41,26 @@ import (
 type Fernsehserien struct {
 	modules.Module
 
-	fetcher            Fetcher                                `yaml:"-"`
-	Series             map[string]string                      `yaml:"series"`
-	Aliases            map[string][]string                    `yaml:"aliases"`
-	UnavailableSenders map[string]bool                        `yaml:"unavailableSenders"`
-	Lasts              differ.Differ[string, string, sending] `yaml:"lasts"` // stores last chat->user->sending
+	fetcher            Fetcher                   `yaml:"-"`
+	Series             map[string]string         `yaml:"series"`
+	Aliases            map[string][]string       `yaml:"aliases"`
+	UnavailableSenders map[string]bool           `yaml:"unavailableSenders"`
+	History            *modules.History[sending] `yaml:"-"` // stores chat->user->sendings
+	AddedSeries        map[string]string         `yaml:"-"` // series added at runtime
+	HistorySize        int                       `yaml:"historySize,omitempty"`
+	// deprecated, only read to migrate old configurations to the History
+	Lasts differ.Differ[string, string, sending] `yaml:"lasts,omitempty"`
+
+	mu sync.Mutex `yaml:"-"` // guards the History and the series
 }
 
 // instanciates a new Fernsehserien from a configuration file
 // (cfgDir/fernsehserien.yaml)
-func NewFernsehserien(log *slog.Logger, cfgDir string) (*Fernsehserien, error) {
+func NewFernsehserien(log *slog.Logger, cfgDir string, st state.Store) (*Fernsehserien, error) {
 	r := Fernsehserien{
 		Module: modules.NewModule(log, cfgDir),
-		Lasts:  make(differ.Differ[string, string, sending]),
 	}
+	r.State = st
 
 	f, err := os.Open(filepath.Join(r.ConfigDir, "fernsehserien.yaml"))
 	if err != nil {
@@ -65,6 +75,15 @@ func NewFernsehserien(log *slog.Logger, cfgDir string) (*Fernsehserien, error) {
 		return nil, err
 	}
 
+	r.History, err = modules.NewHistory[sending](&r.Module, r.HistorySize)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.Lasts) > 0 {
+		r.History.Import(r.Lasts, time.Now())
+	}
+	r.Lasts = nil
+
 	if r.Aliases == nil {
 		r.Aliases = make(map[string][]string)
 	}
@@ -72,6 +91,14 @@ func NewFernsehserien(log *slog.Logger, cfgDir string) (*Fernsehserien, error) {
 		r.Series = make(map[string]string)
 	}
 
+	r.AddedSeries = make(map[string]string)
+	if err := r.loadAddedSeries(); err != nil {
+		return nil, err
+	}
+	for s, url := range r.AddedSeries {
+		r.Series[s] = url
+	}
+
 	all := make([]string, 0, len(r.Series))
 	for s := range r.Series {
 		r.Aliases[s] = []string{s}
@@ -87,6 +114,49 @@ func NewFernsehserien(log *slog.Logger, cfgDir string) (*Fernsehserien, error) {
 	return &r, nil
 }
 
+// the series added at runtime (which also are aliases) are stored in this
+// table of the state. Each series is a record, the group is the chat it was
+// added in and the key its name.
+const seriesTable string = "series"
+
+// key under which the added series used to be stored as a single value. Only
+// read to migrate them to the seriesTable.
+const addedSeriesKey string = "series"
+
+// read the series added at runtime from the state
+func (r *Fernsehserien) loadAddedSeries() error {
+	records, err := r.Records(seriesTable, state.Query{})
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		var url string
+		if err := rec.Decode(&url); err != nil {
+			return fmt.Errorf("series %s: %v", rec.Key, err)
+		}
+		r.AddedSeries[rec.Key] = url
+	}
+
+	// migrate the series stored as single value
+	old := make(map[string]string)
+	if version, err := r.LoadState(addedSeriesKey, &old); err != nil {
+		return err
+	} else if version == 0 {
+		return nil
+	}
+	now := time.Now()
+	for s, url := range old {
+		if _, ok := r.AddedSeries[s]; ok {
+			continue
+		}
+		if err := r.PutRecord(seriesTable, "", s, now, url); err != nil {
+			return err
+		}
+		r.AddedSeries[s] = url
+	}
+	return r.DeleteState(addedSeriesKey)
+}
+
 // validate a fernsehserien struct
 func (r *Fernsehserien) Validate() error {
 	// validate the generic module first
@@ -110,6 +180,7 @@ type Args struct {
 	Quiet  bool   `arg:"-q,--quiet" default:"false"`
 	Diff   bool   `arg:"--diff" default:"false"`
 	Data   bool   `arg:"-d,--data" default:"true"`
+	modules.HistoryArgs
 }
 
 // Handle a message from the signalcli. Parses the message, executes the query
@@ -130,37 +201,39 @@ func (r *Fernsehserien) Handle(m *signalcli.Message, signal signalsender.SignalS
 		return
 	}
 
-	if args.Insert != "" {
-		r.Series[args.Which] = args.Insert
-		r.Aliases["all"] = append(r.Aliases["all"], args.Insert)
-	}
-
-	urls := make(map[string]string)
 	chat := m.Chat
 	if args.Which == "all" {
-		urls = r.Series
 		chat += "L" // different diffing for "all" command
-	} else {
-		resolvedL, ok := r.Aliases[args.Which]
-		if !ok {
-			errMsg := fmt.Sprintf("Error: %v is unknown", args.Which)
-			r.Log.Error(errMsg)
-			builder := strings.Builder{}
-			builder.WriteString(errMsg)
-			builder.WriteRune('\n')
-			builder.WriteString("Available series: ")
-			sorted := make(sort.StringSlice, 0, len(r.Aliases))
-			for k := range r.Aliases {
-				sorted = append(sorted, k)
+	}
+	r.mu.Lock()
+	if args.Insert != "" {
+		r.insert(m.Chat, args.Which, args.Insert)
+	}
+	urls, err := r.resolve(args.Which)
+	r.mu.Unlock()
+	if err != nil {
+		errMsg := err.Error()
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+		return
+	}
+
+	if args.History {
+		r.mu.Lock()
+		respS := r.History.ChangesString(chat, m.Sender, args.SinceTime(), time.Local)
+		r.mu.Unlock()
+		if respS == "" {
+			if args.Quiet {
+				return
 			}
-			sorted.Sort()
-			builder.WriteString(strings.Join(sorted, ", "))
-			r.SendError(m, signal, builder.String())
-			return
+			respS = "No changes"
 		}
-		for _, re := range resolvedL {
-			urls[re] = r.Series[re]
+		if _, err := signal.Respond(respS, []string{}, m, true); err != nil {
+			errMsg := fmt.Sprintf("Error: %v", err)
+			r.Log.Error(errMsg)
+			r.SendError(m, signal, errMsg)
 		}
+		return
 	}
 
 	// execute the query
@@ -189,16 +262,27 @@ func (r *Fernsehserien) Handle(m *signalcli.Message, signal signalsender.SignalS
 	if args.Data {
 		resp.WriteString(items.String())
 	}
-	if args.Data && args.Diff {
+	if args.Data && (args.Diff || args.Since != 0) {
 		resp.WriteRune('\n')
 	}
-	if args.Diff {
-		d := r.Lasts.DiffStore(chat, m.Sender, items)
+	r.mu.Lock()
+	if args.Since != 0 {
+		d := r.History.DiffSince(chat, m.Sender, args.SinceTime(), items)
+		if args.Diff {
+			r.History.DiffStore(chat, m.Sender, items)
+		}
+		if d != "" {
+			resp.WriteString("Diff:\n")
+			resp.WriteString(d)
+		}
+	} else if args.Diff {
+		d := r.History.DiffStore(chat, m.Sender, items)
 		if d != "" {
 			resp.WriteString("Diff:\n")
 			resp.WriteString(d)
 		}
 	}
+	r.mu.Unlock()
 	respS := resp.String()
 
 	if respS == "" {
@@ -216,22 +300,51 @@ func (r *Fernsehserien) Handle(m *signalcli.Message, signal signalsender.SignalS
 	}
 }
 
-// save config file in case something has changed (module allows to add new
-// series during runtime)
-func (r *Fernsehserien) Close(virtRcv func(*signalcli.Message)) {
-	r.Module.Close(virtRcv)
+// add a series at runtime in `chat`. r.mu has to be held.
+func (r *Fernsehserien) insert(chat string, which string, url string) {
+	if _, ok := r.Series[which]; !ok {
+		r.Aliases[which] = []string{which}
+		r.Aliases["all"] = append(r.Aliases["all"], which)
+	}
+	r.Series[which] = url
+	r.AddedSeries[which] = url
+	// replaces the series if it was added before
+	if err := r.RemoveRecords(seriesTable, state.Query{Key: which}); err != nil {
+		r.Log.Error(fmt.Sprintf("Error saving the added series: %v", err))
+		return
+	}
+	if err := r.PutRecord(seriesTable, chat, which, time.Now(), url); err != nil {
+		r.Log.Error(fmt.Sprintf("Error saving the added series: %v", err))
+	}
+}
 
-	delete(r.Aliases, "all") // "all" alias is always a generated one
-	f, err := os.Create(filepath.Join(r.ConfigDir, "fernsehserien.yaml"))
-	if err != nil {
-		r.Log.Error(fmt.Sprintf("Error opening 'fernsehserien.yaml': %v", err))
+// resolve an alias to the urls of the series. The returned map is a copy and
+// can be used without holding r.mu. r.mu has to be held.
+func (r *Fernsehserien) resolve(which string) (map[string]string, error) {
+	urls := make(map[string]string)
+	if which == "all" {
+		for name, url := range r.Series {
+			urls[name] = url
+		}
+		return urls, nil
 	}
-	defer f.Close()
 
-	e := yaml.NewEncoder(f)
-	defer e.Close()
-	err = e.Encode(r)
-	if err != nil {
-		r.Log.Error(fmt.Sprintf("Error endcoding to 'fernsehserien.yaml': %v", err))
+	resolvedL, ok := r.Aliases[which]
+	if !ok {
+		builder := strings.Builder{}
+		builder.WriteString(fmt.Sprintf("Error: %v is unknown", which))
+		builder.WriteRune('\n')
+		builder.WriteString("Available series: ")
+		sorted := make(sort.StringSlice, 0, len(r.Aliases))
+		for k := range r.Aliases {
+			sorted = append(sorted, k)
+		}
+		sorted.Sort()
+		builder.WriteString(strings.Join(sorted, ", "))
+		return nil, errors.New(builder.String())
+	}
+	for _, re := range resolvedL {
+		urls[re] = r.Series[re]
 	}
+	return urls, nil
 }
@@ -41,7 +41,7 @@ var location *time.Location = loadZone()
 
 func TestFetcher(t *testing.T) {
 	log := nopLog()
-	fserie, err := NewFernsehserien(log, "./")
+	fserie, err := NewFernsehserien(log, "./", nil)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,275 @@
+package group
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"signalbot_go/internal/signalsender"
+	"signalbot_go/modules"
+	"signalbot_go/signalcli"
+
+	"github.com/alexflint/go-arg"
+	"log/slog"
+)
+
+// the group management of the account (implemented by signalcli.Account)
+type Manager interface {
+	ListGroups() ([]signalcli.Group, error)
+	GetGroup(groupId []byte) (signalcli.Group, error)
+	CreateGroup(name string, members []string, avatar string) ([]byte, error)
+	UpdateGroup(groupId []byte, update signalcli.GroupUpdate) error
+	JoinGroup(inviteURI string) error
+}
+
+// group module, manages the groups of the bot account. Should be
+// instanciated with `NewGroup`.
+type Group struct {
+	modules.Module
+	groups Manager `yaml:"-"`
+}
+
+func NewGroup(log *slog.Logger, cfgDir string, groups Manager) (*Group, error) {
+	r := Group{
+		Module: modules.NewModule(log, cfgDir),
+		groups: groups,
+	}
+
+	// validation
+	if err := r.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+func (r *Group) Validate() error {
+	if err := r.Module.Validate(); err != nil {
+		return err
+	}
+	if r.groups == nil {
+		return errors.New("no group manager set")
+	}
+	return nil
+}
+
+// specifies the arguments when handling a request to this module
+type Args struct {
+	Ls      *lsArgs     `arg:"subcommand:list|ls|l"`
+	Members *groupArgs  `arg:"subcommand:members|m"`
+	Create  *createArgs `arg:"subcommand:create"`
+	Add     *memberArgs `arg:"subcommand:add|a"`
+	Rm      *memberArgs `arg:"subcommand:remove|rm|r"`
+	Rename  *renameArgs `arg:"subcommand:rename"`
+	Avatar  *groupArgs  `arg:"subcommand:avatar"`
+	Join    *joinArgs   `arg:"subcommand:join"`
+}
+
+type lsArgs struct{}
+
+// selects the group to work on
+type groupArgs struct {
+	Group string `arg:"--group,-g" help:"name or id of the group (default: the group the message was sent in)"`
+}
+
+type createArgs struct {
+	Name    string   `arg:"--name,-n,required"`
+	Members []string `arg:"positional" help:"phone numbers of the members to invite"`
+}
+
+type memberArgs struct {
+	groupArgs
+	Members []string `arg:"positional,required" help:"phone numbers"`
+}
+
+type renameArgs struct {
+	groupArgs
+	Name string `arg:"positional,required"`
+}
+
+type joinArgs struct {
+	Uri string `arg:"positional,required" help:"invite link"`
+}
+
+// Handle a message from the signalcli. Parses the message, executes the
+// operation and responds to signal.
+func (r *Group) Handle(m *signalcli.Message, signal signalsender.SignalSender, virtRcv func(*signalcli.Message)) {
+	var args Args
+	parser, err := arg.NewParser(arg.Config{}, &args)
+	if err != nil {
+		r.Log.Error(fmt.Sprintf("newParser -> %v", err))
+		return
+	}
+
+	if err := r.Module.Handle(m, signal, virtRcv, parser); err != nil {
+		errMsg := err.Error()
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+		return
+	}
+
+	var (
+		reply string
+		g     signalcli.Group
+	)
+	switch {
+	case args.Ls != nil:
+		reply, err = r.ls()
+	case args.Members != nil:
+		reply, err = r.members(args.Members, m)
+	case args.Create != nil:
+		reply, err = r.create(args.Create, m)
+	case args.Add != nil:
+		g, err = r.update(&args.Add.groupArgs, m, signalcli.GroupUpdate{AddMembers: args.Add.Members})
+		reply = fmt.Sprintf("Added %d member(s) to %s", len(args.Add.Members), g.Name)
+	case args.Rm != nil:
+		g, err = r.update(&args.Rm.groupArgs, m, signalcli.GroupUpdate{RemoveMembers: args.Rm.Members})
+		reply = fmt.Sprintf("Removed %d member(s) from %s", len(args.Rm.Members), g.Name)
+	case args.Rename != nil:
+		g, err = r.update(&args.Rename.groupArgs, m, signalcli.GroupUpdate{Name: &args.Rename.Name})
+		reply = fmt.Sprintf("Renamed %s to %s", g.Name, args.Rename.Name)
+	case args.Avatar != nil:
+		if len(m.Attachments) == 0 {
+			err = errors.New("attach the new avatar to the message")
+			break
+		}
+		g, err = r.update(args.Avatar, m, signalcli.GroupUpdate{Avatar: m.Attachments[0]})
+		reply = fmt.Sprintf("Changed the avatar of %s", g.Name)
+	case args.Join != nil:
+		if err = r.groups.JoinGroup(args.Join.Uri); err == nil {
+			reply = "Joined the group"
+		}
+	default:
+		err = errors.New("unknown/no subcommand")
+	}
+	if err != nil {
+		errMsg := fmt.Sprintf("Error: %v", err)
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+		return
+	}
+
+	// respond
+	_, err = signal.Respond(reply, nil, m, true)
+	if err != nil {
+		errMsg := fmt.Sprintf("Error: %v", err)
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+	}
+}
+
+// find the group by name or id (hex or base64). If `which` is empty the
+// group the message was sent in is used.
+func (r *Group) resolve(which string, m *signalcli.Message) (signalcli.Group, error) {
+	if which == "" {
+		if len(m.GroupId) == 0 {
+			return signalcli.Group{}, errors.New("not sent in a group, select one with --group")
+		}
+		return r.groups.GetGroup(m.GroupId)
+	}
+	groups, err := r.groups.ListGroups()
+	if err != nil {
+		return signalcli.Group{}, err
+	}
+	var found []signalcli.Group
+	for _, g := range groups {
+		if id, err := hex.DecodeString(which); err == nil && bytes.Equal(id, g.Id) {
+			return g, nil
+		}
+		if id, err := base64.StdEncoding.DecodeString(which); err == nil && bytes.Equal(id, g.Id) {
+			return g, nil
+		}
+		if strings.EqualFold(g.Name, which) {
+			found = append(found, g)
+		}
+	}
+	switch len(found) {
+	case 0:
+		return signalcli.Group{}, fmt.Errorf("group %q not found", which)
+	case 1:
+		return found[0], nil
+	default:
+		return signalcli.Group{}, fmt.Errorf("group name %q is ambiguous, use the id", which)
+	}
+}
+
+// list all groups
+func (r *Group) ls() (string, error) {
+	groups, err := r.groups.ListGroups()
+	if err != nil {
+		return "", err
+	}
+	slices.SortFunc(groups, func(a, b signalcli.Group) int { return strings.Compare(a.Name, b.Name) })
+	builder := strings.Builder{}
+	for i, g := range groups {
+		if i > 0 {
+			builder.WriteRune('\n')
+		}
+		builder.WriteString(fmt.Sprintf("%s (%s): %d members", g.Name, hex.EncodeToString(g.Id), len(g.Members)))
+	}
+	if len(groups) == 0 {
+		return "No groups", nil
+	}
+	return builder.String(), nil
+}
+
+// list the members of a group, admins are marked with a '*'
+func (r *Group) members(args *groupArgs, m *signalcli.Message) (string, error) {
+	g, err := r.resolve(args.Group, m)
+	if err != nil {
+		return "", err
+	}
+	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("%s:", g.Name))
+	for _, mem := range g.Members {
+		builder.WriteString("\n- ")
+		builder.WriteString(mem)
+		if slices.Contains(g.Admins, mem) {
+			builder.WriteString(" *")
+		}
+	}
+	return builder.String(), nil
+}
+
+// create a new group, an attachment of the message is used as avatar
+func (r *Group) create(args *createArgs, m *signalcli.Message) (string, error) {
+	avatar := ""
+	if len(m.Attachments) > 0 {
+		avatar = m.Attachments[0]
+	}
+	gid, err := r.groups.CreateGroup(args.Name, args.Members, avatar)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("Created %s (%s)", args.Name, hex.EncodeToString(gid)), nil
+}
+
+// apply `update` to the selected group. Returns the group as it was before
+// the update.
+func (r *Group) update(args *groupArgs, m *signalcli.Message, update signalcli.GroupUpdate) (signalcli.Group, error) {
+	g, err := r.resolve(args.Group, m)
+	if err != nil {
+		return g, err
+	}
+	return g, r.groups.UpdateGroup(g.Id, update)
+}
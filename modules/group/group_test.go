@@ -0,0 +1,95 @@
+package group
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"io"
+	"log/slog"
+	"reflect"
+	"signalbot_go/internal/modtest"
+	"signalbot_go/signalcli"
+	"testing"
+)
+
+// in-memory group management
+type fakeManager struct {
+	groups  []signalcli.Group
+	updates []signalcli.GroupUpdate
+	created []string
+}
+
+func (f *fakeManager) ListGroups() ([]signalcli.Group, error) { return f.groups, nil }
+func (f *fakeManager) GetGroup(groupId []byte) (signalcli.Group, error) {
+	for _, g := range f.groups {
+		if string(g.Id) == string(groupId) {
+			return g, nil
+		}
+	}
+	return signalcli.Group{}, io.EOF
+}
+func (f *fakeManager) CreateGroup(name string, members []string, avatar string) ([]byte, error) {
+	f.created = append(f.created, name)
+	return []byte{0xab}, nil
+}
+func (f *fakeManager) UpdateGroup(groupId []byte, update signalcli.GroupUpdate) error {
+	f.updates = append(f.updates, update)
+	return nil
+}
+func (f *fakeManager) JoinGroup(inviteURI string) error { return nil }
+
+func TestGroup(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	f := &fakeManager{groups: []signalcli.Group{
+		{Id: []byte{1}, Name: "Family", Members: []string{"+49a", "+49b"}, Admins: []string{"+49a"}},
+		{Id: []byte{2}, Name: "Work", Members: []string{"+49a"}},
+	}}
+	g, err := NewGroup(log, "", f)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	for _, tc := range []struct {
+		msg   string
+		gid   []byte
+		att   []string
+		reply string
+		upd   *signalcli.GroupUpdate
+	}{
+		{msg: "ls", reply: "Family (01): 2 members\nWork (02): 1 members"},
+		{msg: "members", gid: []byte{1}, reply: "Family:\n- +49a *\n- +49b"},
+		{msg: "members -g work", reply: "Work:\n- +49a"},
+		{msg: "members -g 02", reply: "Work:\n- +49a"},
+		{msg: "members", reply: "Error: not sent in a group, select one with --group"},
+		{msg: "add -g Family +49c", reply: "Added 1 member(s) to Family", upd: &signalcli.GroupUpdate{AddMembers: []string{"+49c"}}},
+		{msg: "rm +49b", gid: []byte{1}, reply: "Removed 1 member(s) from Family", upd: &signalcli.GroupUpdate{RemoveMembers: []string{"+49b"}}},
+		{msg: "avatar", gid: []byte{2}, reply: "Error: attach the new avatar to the message"},
+		{msg: "avatar", gid: []byte{2}, att: []string{"img"}, reply: "Changed the avatar of Work", upd: &signalcli.GroupUpdate{Avatar: "img"}},
+		{msg: "create -n Club +49a", reply: "Created Club (ab)"},
+		{msg: "members -g nope", reply: `Error: group "nope" not found`},
+	} {
+		f.updates = nil
+		s := &modtest.Sender{}
+		m := &signalcli.Message{Sender: "+49a", Chat: "+49a", Message: tc.msg, GroupId: tc.gid, Attachments: tc.att}
+		g.Handle(m, s, func(*signalcli.Message) {})
+		if got := s.Get(); len(got) != 1 || got[0] != tc.reply {
+			t.Fatalf("%s: Was: %q but should be %q", tc.msg, got, tc.reply)
+		}
+		if tc.upd != nil && (len(f.updates) != 1 || !reflect.DeepEqual(f.updates[0], *tc.upd)) {
+			t.Fatalf("%s: Was: %+v but should be %+v", tc.msg, f.updates, *tc.upd)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package modules
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"fmt"
+	cmdsplit "signalbot_go/internal/cmdSplit"
+	"signalbot_go/internal/differ"
+	"signalbot_go/internal/state"
+	"time"
+)
+
+// the differ history of a module is stored in this table of its state. Each
+// snapshot is a record, the group is the chat and the key the sender.
+const HistoryTable string = "history"
+
+// key under which the whole differ history used to be stored as a single
+// value. Only read to migrate it to the HistoryTable.
+const historyKey string = "history"
+
+// differ history (chat -> sender -> snapshots) of a module, which stores every
+// new snapshot in the state of the module. Create with `NewHistory`.
+type History[U differ.DiffStringerEqualer[U]] struct {
+	*differ.History[string, string, U]
+	m *Module
+}
+
+// create a history keeping at most `maxLen` snapshots per chat and sender and
+// read the snapshots stored in the state of `m`.
+func NewHistory[U differ.DiffStringerEqualer[U]](m *Module, maxLen int) (*History[U], error) {
+	h := &History[U]{
+		History: differ.NewHistory[string, string, U](maxLen),
+		m:       m,
+	}
+	records, err := m.Records(HistoryTable, state.Query{})
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range records {
+		var data []U
+		if err := r.Decode(&data); err != nil {
+			return nil, fmt.Errorf("history: %v", err)
+		}
+		h.History.Add(r.Group, r.Key, differ.Snapshot[U]{Time: r.Time, Data: data})
+	}
+
+	// migrate the history stored as single value
+	old := differ.NewHistory[string, string, U](maxLen)
+	if version, err := m.LoadState(historyKey, old); err != nil {
+		return nil, err
+	} else if version != 0 {
+		for chat, a := range old.Snapshots {
+			for sender, snaps := range a {
+				// already migrated
+				if _, ok := h.Latest(chat, sender); ok {
+					continue
+				}
+				for _, snap := range snaps {
+					h.add(chat, sender, snap)
+				}
+			}
+		}
+		if err := m.DeleteState(historyKey); err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// add the snapshot to the history and store it if it was added
+func (h *History[U]) add(chat string, sender string, snap differ.Snapshot[U]) {
+	if !h.History.Add(chat, sender, snap) {
+		return
+	}
+	if err := h.m.PutRecord(HistoryTable, chat, sender, snap.Time, snap.Data); err != nil {
+		h.m.Log.Error(fmt.Sprintf("Error saving the history: %v", err))
+		return
+	}
+	// drop what fell out of the history
+	oldest := h.Snapshots[chat][sender][0]
+	if err := h.m.RemoveRecords(HistoryTable, state.Query{Group: chat, Key: sender, Before: oldest.Time}); err != nil {
+		h.m.Log.Error(fmt.Sprintf("Error pruning the history: %v", err))
+	}
+}
+
+// same as `differ.History.DiffStore` but stores the new snapshot in the state
+func (h *History[U]) DiffStore(chat string, sender string, dataB []U) string {
+	return h.DiffStoreAt(chat, sender, dataB, time.Now())
+}
+
+// same as `differ.History.DiffStoreAt` but stores the new snapshot in the
+// state
+func (h *History[U]) DiffStoreAt(chat string, sender string, dataB []U, t time.Time) string {
+	resp := h.Diff(chat, sender, dataB)
+	h.add(chat, sender, differ.Snapshot[U]{Time: t, Data: dataB})
+	return resp
+}
+
+// same as `differ.History.Import` but stores the imported snapshots in the
+// state
+func (h *History[U]) Import(d differ.Differ[string, string, U], t time.Time) {
+	for chat, a := range d {
+		for sender, data := range a {
+			if _, ok := h.Latest(chat, sender); ok {
+				continue
+			}
+			h.add(chat, sender, differ.Snapshot[U]{Time: t, Data: data})
+		}
+	}
+}
+
+// arguments for querying the differ history. Embed this in the Args of
+// modules which keep a `differ.History`.
+type HistoryArgs struct {
+	Since   cmdsplit.Duration `arg:"--since" help:"diff against the state of this long ago (e.g. 7d)"`
+	History bool              `arg:"--history" default:"false" help:"list the stored changes (limited by --since)"`
+}
+
+// returns the point in time --since refers to. Zero if --since was not set.
+func (a *HistoryArgs) SinceTime() time.Time {
+	if a.Since == 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(-time.Duration(a.Since))
+}
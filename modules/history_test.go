@@ -0,0 +1,97 @@
+package modules_test
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"io"
+	"log/slog"
+	"reflect"
+	"signalbot_go/internal/differ"
+	"signalbot_go/internal/state"
+	"signalbot_go/modules"
+	"testing"
+	"time"
+)
+
+type item string
+
+func (i item) AddString() string      { return "+ " + string(i) }
+func (i item) RemString() string      { return "- " + string(i) }
+func (i item) Equals(other item) bool { return i == other }
+
+func newModule(t *testing.T, st state.Store) *modules.Module {
+	m := modules.NewModule(slog.New(slog.NewTextHandler(io.Discard, nil)), t.TempDir())
+	m.State = st
+	return &m
+}
+
+func TestHistoryRecords(t *testing.T) {
+	st, err := state.Open(state.BackendSQLite, t.TempDir())
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	defer st.Close()
+
+	// the history used to be stored as a single value
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.Local)
+	old := differ.NewHistory[string, string, item](0)
+	old.DiffStoreAt("chat", "alice", []item{"a"}, base)
+	old.DiffStoreAt("chat", "alice", []item{"a", "b"}, base.Add(time.Hour))
+	if err := st.Save("history", 1, old); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	m := newModule(t, st)
+	h, err := modules.NewHistory[item](m, 2)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if _, err := st.Load("history", old); err != state.ErrNotFound {
+		t.Fatalf("Should have returned %v but was %v", state.ErrNotFound, err)
+	}
+
+	h.DiffStoreAt("chat", "alice", []item{"b"}, base.Add(2*time.Hour))
+	// equal to the latest snapshot, not stored
+	h.DiffStoreAt("chat", "alice", []item{"b"}, base.Add(3*time.Hour))
+	h.DiffStoreAt("other", "bob", []item{"c"}, base.Add(4*time.Hour))
+
+	// only the last two snapshots of a path are kept
+	records, err := st.Records(modules.HistoryTable, state.Query{Group: "chat"})
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	times := make([]time.Time, 0, len(records))
+	for _, r := range records {
+		times = append(times, r.Time)
+	}
+	if out := []time.Time{base.Add(time.Hour), base.Add(2 * time.Hour)}; !reflect.DeepEqual(times, out) {
+		t.Fatalf("Was: %v but should be %v", times, out)
+	}
+
+	// the snapshots are read again
+	h, err = modules.NewHistory[item](newModule(t, st), 2)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	latest, ok := h.Latest("other", "bob")
+	if !ok || !reflect.DeepEqual(latest.Data, []item{"c"}) {
+		t.Fatalf("Was: %v but should be %v", latest.Data, []item{"c"})
+	}
+	if changes := h.Changes("chat", "alice", time.Time{}); len(changes) != 1 || changes[0].Diff != "- a" {
+		t.Fatalf("Was: %v but should be %v", changes, "- a")
+	}
+}
@@ -0,0 +1,76 @@
+package hugendubel
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"signalbot_go/internal/modtest"
+	"signalbot_go/internal/state"
+	"testing"
+)
+
+const testCfg string = `
+queries:
+  a:
+    query: a
+  b:
+    query: b
+querySize: 20
+`
+
+// run with -race
+func TestConcurrentDiff(t *testing.T) {
+	modtest.MockHTTP(t, modtest.Transport{
+		"https://www.hugendubel.de/rest/v1/authentication/anonymousloginjwt": `={"resultCode": 0}`,
+		"https://www.hugendubel.de/rest/v1/articlesearch/advanced":           `={"result": {"articles": [{"articleAttributeView": {"title": "title", "authorList": "author"}}], "totalResults": 1}}`,
+	})
+	cfgDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(cfgDir, "hugendubel.yaml"), []byte(testCfg), 0o600); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	st, err := state.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	log := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	hugendubel, err := NewHugendubel(log, cfgDir, st)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	msgs := modtest.Messages(10, "+49123", "+49123", "all --diff")
+	msgs = append(msgs, modtest.Messages(5, "+49123", "+49123", "all --history")...)
+	sender := &modtest.Sender{}
+	modtest.Fire(hugendubel, sender, msgs...)
+
+	resps := sender.Get()
+	if len(resps) != len(msgs) {
+		t.Fatalf("Was: %v responses but should be %v", len(resps), len(msgs))
+	}
+	diffs := 0
+	for _, r := range resps {
+		if r != "No data/changes" && r != "No changes" {
+			diffs++
+		}
+	}
+	if diffs != 1 {
+		t.Fatalf("Was: %v diffs but should be %v\n%v", diffs, 1, resps)
+	}
+}
@@ -22,10 +22,13 @@ import (
 	"path/filepath"
 	"signalbot_go/internal/differ"
 	"signalbot_go/internal/signalsender"
+	"signalbot_go/internal/state"
 	"signalbot_go/modules"
 	"signalbot_go/signalcli"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/alexflint/go-arg"
 	"log/slog"
@@ -37,20 +40,25 @@ import (
 type Hugendubel struct {
 	modules.Module
 
-	fetcher *Fetcher                             `yaml:"-"`
-	Queries map[string]query                    `yaml:"queries"`
-	Aliases map[string][]string                 `yaml:"aliases"`
-	Lasts   differ.Differ[string, string, book] `yaml:"lasts"` // stores last chat->user->sending
-	QuerySize uint `yaml:"querySize"`
+	fetcher     *Fetcher               `yaml:"-"`
+	Queries     map[string]query       `yaml:"queries"`
+	Aliases     map[string][]string    `yaml:"aliases"`
+	History     *modules.History[book] `yaml:"-"` // stores chat->user->books
+	HistorySize int                    `yaml:"historySize,omitempty"`
+	QuerySize   uint                   `yaml:"querySize"`
+	// deprecated, only read to migrate old configurations to the History
+	Lasts differ.Differ[string, string, book] `yaml:"lasts,omitempty"`
+
+	mu sync.Mutex `yaml:"-"` // guards the History
 }
 
 // instanciates a new Hugendubel from a configuration file
 // (cfgDir/hugendubel.yaml)
-func NewHugendubel(log *slog.Logger, cfgDir string) (*Hugendubel, error) {
+func NewHugendubel(log *slog.Logger, cfgDir string, st state.Store) (*Hugendubel, error) {
 	r := Hugendubel{
 		Module: modules.NewModule(log, cfgDir),
-		Lasts:  make(differ.Differ[string, string, book]),
 	}
+	r.State = st
 
 	f, err := os.Open(filepath.Join(r.ConfigDir, "hugendubel.yaml"))
 	if err != nil {
@@ -65,6 +73,15 @@ func NewHugendubel(log *slog.Logger, cfgDir string) (*Hugendubel, error) {
 		return nil, err
 	}
 
+	r.History, err = modules.NewHistory[book](&r.Module, r.HistorySize)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.Lasts) > 0 {
+		r.History.Import(r.Lasts, time.Now())
+	}
+	r.Lasts = nil
+
 	if r.Aliases == nil {
 		r.Aliases = make(map[string][]string)
 	}
@@ -114,6 +131,7 @@ type Args struct {
 	Which string `arg:"positional"`
 	Quiet bool   `arg:"-q,--quiet" default:"false"`
 	Diff  bool   `arg:"--diff" default:"false"`
+	modules.HistoryArgs
 }
 
 // Handle a message from the signalcli. Parses the message, executes the query
@@ -167,6 +185,24 @@ func (r *Hugendubel) Handle(m *signalcli.Message, signal signalsender.SignalSend
 		}
 	}
 
+	if args.History {
+		r.mu.Lock()
+		respS := r.History.ChangesString(chat, m.Sender, args.SinceTime(), time.Local)
+		r.mu.Unlock()
+		if respS == "" {
+			if args.Quiet {
+				return
+			}
+			respS = "No changes"
+		}
+		if _, err := signal.Respond(respS, []string{}, m, true); err != nil {
+			errMsg := fmt.Sprintf("Error: %v", err)
+			r.Log.Error(errMsg)
+			r.SendError(m, signal, errMsg)
+		}
+		return
+	}
+
 	// execute the query
 	items, err := r.fetcher.get(queries)
 	if err != nil {
@@ -178,15 +214,26 @@ func (r *Hugendubel) Handle(m *signalcli.Message, signal signalsender.SignalSend
 
 	// respond
 	resp := strings.Builder{}
-	if !args.Diff {
+	r.mu.Lock()
+	if args.Since != 0 {
+		d := r.History.DiffSince(chat, m.Sender, args.SinceTime(), items)
+		if args.Diff {
+			r.History.DiffStore(chat, m.Sender, items)
+		}
+		if d != "" {
+			resp.WriteString("Diff:\n")
+			resp.WriteString(d)
+		}
+	} else if !args.Diff {
 		resp.WriteString(items.String())
 	} else {
-		d := r.Lasts.DiffStore(chat, m.Sender, items)
+		d := r.History.DiffStore(chat, m.Sender, items)
 		if d != "" {
 			resp.WriteString("Diff:\n")
 			resp.WriteString(d)
 		}
 	}
+	r.mu.Unlock()
 	respS := resp.String()
 
 	if respS == "" {
@@ -204,23 +251,3 @@ func (r *Hugendubel) Handle(m *signalcli.Message, signal signalsender.SignalSend
 		return
 	}
 }
-
-// save config file in case something has changed (module allows to add new
-// series during runtime)
-func (r *Hugendubel) Close(virtRcv func(*signalcli.Message)) {
-	r.Module.Close(virtRcv)
-
-	delete(r.Aliases, "all") // "all" alias is always a generated one
-	f, err := os.Create(filepath.Join(r.ConfigDir, "hugendubel.yaml"))
-	if err != nil {
-		r.Log.Error(fmt.Sprintf("Error opening 'hugendubel.yaml': %v", err))
-	}
-	defer f.Close()
-
-	e := yaml.NewEncoder(f)
-	defer e.Close()
-	err = e.Encode(r)
-	if err != nil {
-		r.Log.Error(fmt.Sprintf("Error endcoding to 'hugendubel.yaml': %v", err))
-	}
-}
@@ -23,7 +23,9 @@ import (
 	"io"
 	cmdsplit "signalbot_go/internal/cmdSplit"
 	"signalbot_go/internal/signalsender"
+	"signalbot_go/internal/state"
 	"signalbot_go/signalcli"
+	"time"
 
 	"github.com/alexflint/go-arg"
 	"log/slog"
@@ -32,6 +34,9 @@ import (
 type Module struct {
 	Log       *slog.Logger `yaml:"-"`
 	ConfigDir string       `yaml:"-"`
+	// store for runtime state. The configuration in ConfigDir is never
+	// written, everything which changes at runtime goes here. Might be nil.
+	State state.Store `yaml:"-"`
 }
 
 func NewModule(log *slog.Logger, cfgDir string) Module {
@@ -102,6 +107,66 @@ func (r *Module) Handle(m *signalcli.Message, signal signalsender.SignalSender,
 	return nil
 }
 
+// read the runtime state (e.g. a differ history) stored for `key` into `v`.
+// If nothing is stored yet (or the module has no state store) this is not an
+// error, `v` stays untouched in this case. Returns the schema version the
+// state was stored with.
+func (r *Module) LoadState(key string, v any) (uint, error) {
+	if r.State == nil {
+		return 0, nil
+	}
+	version, err := r.State.Load(key, v)
+	if err == state.ErrNotFound {
+		return 0, nil
+	}
+	return version, err
+}
+
+// store the runtime state `v` for `key` with the schema version `version`.
+// Does nothing if the module has no state store.
+func (r *Module) SaveState(key string, version uint, v any) error {
+	if r.State == nil {
+		return nil
+	}
+	return r.State.Save(key, version, v)
+}
+
+// remove the runtime state stored for `key`. Does nothing if the module has
+// no state store.
+func (r *Module) DeleteState(key string) error {
+	if r.State == nil {
+		return nil
+	}
+	return r.State.Delete(key)
+}
+
+// store `v` as record of the table `table` (see `state.Store.Put`). Does
+// nothing if the module has no state store.
+func (r *Module) PutRecord(table string, group string, key string, t time.Time, v any) error {
+	if r.State == nil {
+		return nil
+	}
+	return r.State.Put(table, group, key, t, v)
+}
+
+// read the records of the table `table` which match `q` (oldest first). If the
+// module has no state store, there are no records.
+func (r *Module) Records(table string, q state.Query) ([]state.Record, error) {
+	if r.State == nil {
+		return []state.Record{}, nil
+	}
+	return r.State.Records(table, q)
+}
+
+// remove the records of the table `table` which match `q`. Does nothing if the
+// module has no state store.
+func (r *Module) RemoveRecords(table string, q state.Query) error {
+	if r.State == nil {
+		return nil
+	}
+	return r.State.Remove(table, q)
+}
+
 func (r *Module) Start(virtRcv func(*signalcli.Message)) error {
 	return nil
 }
@@ -0,0 +1,60 @@
+package news
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+
+import (
+	"signalbot_go/internal/modtest"
+	"signalbot_go/internal/state"
+	"testing"
+)
+
+// run with -race
+func TestConcurrentBreakingDiff(t *testing.T) {
+	modtest.MockHTTP(t, modtest.Transport{
+		"https://www.tagesschau.de/json/headerapp": "testBreaking1B.json",
+	})
+	st, err := state.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	news, err := NewNews(nopLog(), "./", st)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	msgs := modtest.Messages(10, "+49123", "+49123", "breaking --diff")
+	msgs = append(msgs, modtest.Messages(5, "+49123", "+49123", "breaking --history")...)
+	sender := &modtest.Sender{}
+	modtest.Fire(news, sender, msgs...)
+
+	resps := sender.Get()
+	if len(resps) != len(msgs) {
+		t.Fatalf("Was: %v responses but should be %v", len(resps), len(msgs))
+	}
+	diffs := 0
+	for _, r := range resps {
+		if r != "No (new) news" {
+			diffs++
+		}
+	}
+	// only the first diff shows the breaking news, the history never shows a
+	// change as there only is the initial snapshot
+	if diffs != 1 {
+		t.Fatalf("Was: %v diffs but should be %v\n%v", diffs, 1, resps)
+	}
+}
@@ -26,7 +26,7 @@ import (
 )
 
 func nopLog() *slog.Logger {
-	return slog.New(slog.HandlerOptions{Level: slog.LevelError}.NewTextHandler(io.Discard))
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
 }
 
 func loadZone() *time.Location {
@@ -41,7 +41,7 @@ var location *time.Location = loadZone()
 
 func TestFetcherNews(t *testing.T) {
 	log := nopLog()
-	news, err := NewNews(log, "./")
+	news, err := NewNews(log, "./", nil)
 	if err != nil {
 		panic(err)
 	}
@@ -131,7 +131,7 @@ func TestFetcherNews(t *testing.T) {
 
 func TestFetcherBreakingNeg(t *testing.T) {
 	log := nopLog()
-	news, err := NewNews(log, "./")
+	news, err := NewNews(log, "./", nil)
 	if err != nil {
 		panic(err)
 	}
@@ -153,7 +153,7 @@ func TestFetcherBreakingNeg(t *testing.T) {
 
 func TestFetcherBreakingPos(t *testing.T) {
 	log := nopLog()
-	news, err := NewNews(log, "./")
+	news, err := NewNews(log, "./", nil)
 	if err != nil {
 		panic(err)
 	}
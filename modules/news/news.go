@@ -22,8 +22,11 @@ import (
 	"path/filepath"
 	"signalbot_go/internal/differ"
 	"signalbot_go/internal/signalsender"
+	"signalbot_go/internal/state"
 	"signalbot_go/modules"
 	"signalbot_go/signalcli"
+	"sync"
+	"time"
 
 	"github.com/alexflint/go-arg"
 	"log/slog"
@@ -34,18 +37,23 @@ import (
 // data members are only global to be able to unmarshal them
 type News struct {
 	modules.Module
-	fetcher      Fetcher                                 `yaml:"-"`
-	LastBreaking differ.Differ[string, string, breaking] `yaml:"lastBreaking"` // stores last chat->user->sending
+	fetcher     Fetcher                    `yaml:"-"`
+	History     *modules.History[breaking] `yaml:"-"` // stores chat->user->sendings
+	HistorySize int                        `yaml:"historySize,omitempty"`
+	// deprecated, only read to migrate old configurations to the History
+	LastBreaking differ.Differ[string, string, breaking] `yaml:"lastBreaking,omitempty"`
+
+	mu sync.Mutex `yaml:"-"` // guards the History
 }
 
 // instanciates a new News from a configuration file
 // (cfgDir/news.yaml)
-func NewNews(log *slog.Logger, cfgDir string) (*News, error) {
+func NewNews(log *slog.Logger, cfgDir string, st state.Store) (*News, error) {
 	r := News{
-		Module:       modules.NewModule(log, cfgDir),
-		fetcher:      Fetcher{},
-		LastBreaking: make(differ.Differ[string, string, breaking]),
+		Module:  modules.NewModule(log, cfgDir),
+		fetcher: Fetcher{},
 	}
+	r.State = st
 
 	f, err := os.Open(filepath.Join(r.ConfigDir, "news.yaml"))
 	if err != nil {
@@ -60,6 +68,15 @@ func NewNews(log *slog.Logger, cfgDir string) (*News, error) {
 		return nil, err
 	}
 
+	r.History, err = modules.NewHistory[breaking](&r.Module, r.HistorySize)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.LastBreaking) > 0 {
+		r.History.Import(r.LastBreaking, time.Now())
+	}
+	r.LastBreaking = nil
+
 	// validation
 	if err := r.Validate(); err != nil {
 		return nil, err
@@ -82,6 +99,7 @@ type Args struct {
 	News     *struct{} `arg:"subcommand:news"`
 	Breaking *struct {
 		Diff bool `arg:"--diff" default:"false"`
+		modules.HistoryArgs
 	} `arg:"subcommand:breaking"`
 	Quiet bool `arg:"-q,--quiet" default:"false"`
 }
@@ -107,6 +125,11 @@ func (r *News) Handle(m *signalcli.Message, signal signalsender.SignalSender, vi
 	// execute the query
 	var resp string
 	switch {
+	case args.Breaking != nil && args.Breaking.History:
+		r.mu.Lock()
+		resp = r.History.ChangesString(m.Chat, m.Sender, args.Breaking.SinceTime(), loc)
+		r.mu.Unlock()
+
 	case args.Breaking != nil:
 		reader, err := r.fetcher.getBreakingReader()
 		if err != nil {
@@ -124,11 +147,18 @@ func (r *News) Handle(m *signalcli.Message, signal signalsender.SignalSender, vi
 		}
 
 		// respond
-		if args.Breaking.Diff {
-			resp = r.LastBreaking.DiffStore(m.Chat, m.Sender, b)
+		r.mu.Lock()
+		if args.Breaking.Since != 0 {
+			resp = r.History.DiffSince(m.Chat, m.Sender, args.Breaking.SinceTime(), b)
+			if args.Breaking.Diff {
+				r.History.DiffStore(m.Chat, m.Sender, b)
+			}
+		} else if args.Breaking.Diff {
+			resp = r.History.DiffStore(m.Chat, m.Sender, b)
 		} else {
 			resp = b.String()
 		}
+		r.mu.Unlock()
 
 	default: // args.News != nil:
 		reader, err := r.fetcher.getNewsReader()
@@ -165,22 +195,3 @@ func (r *News) Handle(m *signalcli.Message, signal signalsender.SignalSender, vi
 		return
 	}
 }
-
-// save config file in case something has changed (last with the differ might
-// have changed)
-func (r *News) Close(virtRcv func(*signalcli.Message)) {
-	r.Module.Close(virtRcv)
-
-	f, err := os.Create(filepath.Join(r.ConfigDir, "news.yaml"))
-	if err != nil {
-		r.Log.Error(fmt.Sprintf("Error opening 'news.yaml': %v", err))
-	}
-	defer f.Close()
-
-	e := yaml.NewEncoder(f)
-	defer e.Close()
-	err = e.Encode(r)
-	if err != nil {
-		r.Log.Error(fmt.Sprintf("Error endcoding to 'news.yaml': %v", err))
-	}
-}
@@ -19,14 +19,19 @@ package periodic
 import (
 	"context"
 	"fmt"
+	"io"
+	"math/rand/v2"
 	"os"
 	"path/filepath"
 	cmdsplit "signalbot_go/internal/cmdSplit"
 	"signalbot_go/internal/perioder"
 	"signalbot_go/internal/signalsender"
+	"signalbot_go/internal/state"
 	"signalbot_go/modules"
 	"signalbot_go/signalcli"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"log/slog"
@@ -38,14 +43,31 @@ import (
 type Periodic struct {
 	modules.Module
 	perioder perioder.Perioder[signalcli.Message] `yaml:"-"`
-	stop     context.CancelFunc                    `yaml:"-"`
+	stop     context.CancelFunc                   `yaml:"-"`
+	// key of the record of each event
+	keys map[perioder.ReocEvent[signalcli.Message]]string `yaml:"-"`
+	mu   sync.Mutex                                       `yaml:"-"` // guards keys
 }
 
-func NewPeriodic(log *slog.Logger, cfgDir string) (*Periodic, error) {
+// the events are stored in this table of the state. Each event is a record,
+// the group is the chat it was added in, the time is its start.
+const eventsTable string = "events"
+
+// key and schema version of the events in the state. Up to version 1 all
+// events were stored as a single value, since version 2 only the version is
+// stored to remember that the events were migrated to the eventsTable.
+const (
+	eventsKey     string = "events"
+	eventsVersion uint   = 2
+)
+
+func NewPeriodic(log *slog.Logger, cfgDir string, st state.Store) (*Periodic, error) {
 	r := Periodic{
 		Module:   modules.NewModule(log, cfgDir),
 		perioder: perioder.NewPerioderImpl[signalcli.Message](log.With()),
+		keys:     make(map[perioder.ReocEvent[signalcli.Message]]string),
 	}
+	r.State = st
 
 	// validation
 	if err := r.Validate(); err != nil {
@@ -142,6 +164,8 @@ func (r *Periodic) Add(add *addArgs, m signalcli.Message, signal signalsender.Si
 			virtRcv(&meta)
 		})
 	}
+	// save first, the perioder modifies the event once it runs
+	r.saveEvent(event, add.Start)
 	r.perioder.Add(event)
 	if _, err := signal.Respond(fmt.Sprintf("Added %v\n", event.String()), nil, &m, true); err != nil {
 		r.Log.Error(fmt.Sprintf("error sending add success msg: %v", err))
@@ -180,6 +204,7 @@ func (r *Periodic) Rm(rm *rmArgs, m signalcli.Message, signal signalsender.Signa
 	}
 	r.Log.Info(fmt.Sprintf("canceling event with ID: %d (%s)", rm.Id, event.String()))
 	r.perioder.Remove(rm.Id)
+	r.removeEvent(event)
 	if _, err := signal.Respond(fmt.Sprintf("Removed %v\n", event.String()), nil, &m, true); err != nil {
 		r.Log.Error(fmt.Sprintf("error sending rm success msg: %v", err))
 	}
@@ -194,53 +219,126 @@ func (r *Periodic) Start(virtRcv func(*signalcli.Message)) error {
 	ctx, r.stop = context.WithCancel(context.Background())
 	go r.perioder.Start(ctx)
 
-	// read saved events
-	f, err := os.Open(filepath.Join(r.ConfigDir, "events.yaml"))
-	if !os.IsNotExist(err) {
-		if err != nil {
-			// p.Log.Error(fmt.Sprintf("Error opening 'events.yaml': %v", err))
-			return err
+	// read the stored events
+	records, err := r.Records(eventsTable, state.Query{})
+	if err != nil {
+		return err
+	}
+	for _, rec := range records {
+		var event perioder.ReocEventImplDeadline[signalcli.Message]
+		if err := rec.Decode(&event); err != nil {
+			return fmt.Errorf("event %s: %v", rec.Key, err)
 		}
-		d := yaml.NewDecoder(f)
-		events := make(map[uint]perioder.ReocEventImplDeadline[signalcli.Message])
-		err = d.Decode(&events)
-		if err != nil {
-			// p.Log.Error(fmt.Sprintf("Error decoding to 'events.yaml': %v", err))
-			return err
+		r.restore(&event, rec.Key, virtRcv)
+	}
+
+	// migrate events stored in the old formats
+	events := make(map[uint]perioder.ReocEventImplDeadline[signalcli.Message])
+	version, err := r.LoadState(eventsKey, &events)
+	if err != nil {
+		return err
+	}
+	if version < eventsVersion {
+		if version == 0 {
+			// events used to be stored next to the configuration
+			if err := r.loadLegacyEvents(&events); err != nil {
+				return err
+			}
 		}
-		// add events
 		for _, vIter := range events {
 			v := vIter // force copy
-			v.Foo = func(time time.Time, event perioder.ReocEvent[signalcli.Message]) {
-				meta := event.Metadata()
-				virtRcv(&meta)
-			}
-			if v.Stop.IsZero() {
-				r.perioder.Add(&v.ReocEventImpl)
-			} else {
-				r.perioder.Add(&v)
+			key := newKey()
+			if err := r.PutRecord(eventsTable, v.Metadata_store.Chat, key, v.Start, &v); err != nil {
+				return err
 			}
+			r.restore(&v, key, virtRcv)
+		}
+		if err := r.SaveState(eventsKey, eventsVersion, nil); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
+// add a stored event to the perioder
+func (r *Periodic) restore(v *perioder.ReocEventImplDeadline[signalcli.Message], key string, virtRcv func(*signalcli.Message)) {
+	v.Foo = func(time time.Time, event perioder.ReocEvent[signalcli.Message]) {
+		meta := event.Metadata()
+		virtRcv(&meta)
+	}
+	var event perioder.ReocEvent[signalcli.Message] = v
+	if v.Stop.IsZero() {
+		event = &v.ReocEventImpl
+	}
+	r.mu.Lock()
+	r.keys[event] = key
+	r.mu.Unlock()
+	r.perioder.Add(event)
+}
+
+// read the events from the 'events.yaml' file in the config directory (where
+// they were stored before the state store existed)
+func (r *Periodic) loadLegacyEvents(events *map[uint]perioder.ReocEventImplDeadline[signalcli.Message]) error {
+	f, err := os.Open(filepath.Join(r.ConfigDir, "events.yaml"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	d := yaml.NewDecoder(f)
+	if err := d.Decode(events); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
+
+// generate the key of the record of a new event
+func newKey() string {
+	return strconv.FormatUint(rand.Uint64(), 36)
+}
+
+// store the added event starting at `start` in the state
+func (r *Periodic) saveEvent(event perioder.ReocEvent[signalcli.Message], start time.Time) {
+	key := newKey()
+	r.mu.Lock()
+	r.keys[event] = key
+	r.mu.Unlock()
+	if err := r.PutRecord(eventsTable, event.Metadata().Chat, key, start, event); err != nil {
+		r.Log.Error(fmt.Sprintf("Error saving the event: %v", err))
+	}
+}
+
+// remove the event from the state
+func (r *Periodic) removeEvent(event perioder.ReocEvent[signalcli.Message]) {
+	r.mu.Lock()
+	key, ok := r.keys[event]
+	delete(r.keys, event)
+	r.mu.Unlock()
+	if !ok {
+		return
+	}
+	if err := r.RemoveRecords(eventsTable, state.Query{Group: event.Metadata().Chat, Key: key}); err != nil {
+		r.Log.Error(fmt.Sprintf("Error removing the event: %v", err))
+	}
+}
+
 func (r *Periodic) Close(virtRcv func(*signalcli.Message)) {
 	r.Module.Close(virtRcv)
 
 	r.Log.Info("closing periodic stuff")
-	f, err := os.Create(filepath.Join(r.ConfigDir, "events.yaml"))
-	if err != nil {
-		r.Log.Error(fmt.Sprintf("Error opening 'events.yaml': %v", err))
+	// events which reached their deadline are not restored anymore
+	r.mu.Lock()
+	stopped := make([]perioder.ReocEvent[signalcli.Message], 0)
+	for event := range r.keys {
+		if event.Stopped() {
+			stopped = append(stopped, event)
+		}
 	}
-	defer f.Close()
-
-	e := yaml.NewEncoder(f)
-	defer e.Close()
-	events := r.perioder.Events()
-	err = e.Encode(events)
-	if err != nil {
-		r.Log.Error(fmt.Sprintf("Error endcoding to 'events.yaml': %v", err))
+	r.mu.Unlock()
+	for _, event := range stopped {
+		r.removeEvent(event)
 	}
 
 	r.stop()
@@ -0,0 +1,112 @@
+package periodic
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"io"
+	"log/slog"
+	"signalbot_go/internal/modtest"
+	"signalbot_go/internal/perioder"
+	"signalbot_go/internal/state"
+	"signalbot_go/signalcli"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// wait until the perioder runs `n` events
+func waitEvents(t *testing.T, r *Periodic, n int) map[uint]perioder.ReocEvent[signalcli.Message] {
+	for range 100 {
+		if events := r.perioder.Events(); len(events) == n {
+			return events
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("Was: %v events but should be %v", len(r.perioder.Events()), n)
+	return nil
+}
+
+func TestEventRecords(t *testing.T) {
+	st, err := state.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	// the events used to be stored as a single value
+	start := time.Date(2024, 5, 1, 8, 0, 0, 0, time.UTC)
+	old := map[uint]perioder.ReocEvent[signalcli.Message]{
+		3: perioder.NewReocEventImpl(start, 24*time.Hour, "old", signalcli.Message{Chat: "chatA", Sender: "+49123"}, nil),
+	}
+	if err := st.Save(eventsKey, 1, old); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	virtRcv := func(*signalcli.Message) {}
+	r, err := NewPeriodic(log, t.TempDir(), st)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if err := r.Start(virtRcv); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	waitEvents(t, r, 1)
+
+	sender := &modtest.Sender{}
+	for _, m := range modtest.Messages(1, "chatB", "+49456", "add --every 1h new") {
+		r.Handle(m, sender, virtRcv)
+	}
+	events := waitEvents(t, r, 2)
+
+	records, err := st.Records(eventsTable, state.Query{Group: "chatA"})
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if len(records) != 1 || !records[0].Time.Equal(start) {
+		t.Fatalf("Was: %v but should be one record at %v", records, start)
+	}
+	records, err = st.Records(eventsTable, state.Query{})
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("Was: %v records but should be %v", len(records), 2)
+	}
+
+	// the old event is removed
+	for id, e := range events {
+		if e.Metadata().Chat == "chatA" {
+			m := &signalcli.Message{Chat: "chatA", Sender: "+49123", Message: "rm -i " + strconv.FormatUint(uint64(id), 10)}
+			r.Handle(m, sender, virtRcv)
+		}
+	}
+	r.Close(virtRcv)
+
+	// only the new event is restored
+	r, err = NewPeriodic(log, t.TempDir(), st)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if err := r.Start(virtRcv); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	defer r.Close(virtRcv)
+	for _, e := range waitEvents(t, r, 1) {
+		if e.Metadata().Chat != "chatB" || e.Metadata().Message != "new" {
+			t.Fatalf("Was: %v but should be the new event", e)
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"errors"
 	"io"
 	"log/slog"
+	"signalbot_go/signalcli"
 	"strings"
 	"time"
 )
@@ -59,6 +60,23 @@ func (c Category) String() string {
 	return string(c)
 }
 
+// name of the category (for output without emoji)
+func (c Category) Name() string {
+	switch c {
+	case BEEF:
+		return "Rind"
+	case PORK:
+		return "Schwein"
+	case VEGGY:
+		return "vegetarisch"
+	case VEGAN:
+		return "vegan"
+	case FISH:
+		return "Fisch"
+	}
+	return string(c)
+}
+
 // enum with the different Co2 grades
 type Co2 rune
 
@@ -111,7 +129,7 @@ func (m Meal) Compare(other Meal) int {
 func (m Meal) String() string {
 	builder := strings.Builder{}
 
-	builder.WriteString(m.Name)
+	builder.WriteString(signalcli.EscapeMarkup(m.Name))
 	builder.WriteRune(' ')
 	for _, c := range m.Categories {
 		builder.WriteString(c.String())
@@ -120,6 +138,21 @@ func (m Meal) String() string {
 	return builder.String()
 }
 
+// rows of a table with the meals (type, name, categories)
+func (m Menu) Rows() [][]string {
+	ret := [][]string{}
+	for _, t := range m.ordering {
+		for _, meal := range m.meals[t] {
+			cats := make([]string, 0, len(meal.Categories))
+			for _, c := range meal.Categories {
+				cats = append(cats, c.Name())
+			}
+			ret = append(ret, []string{t, meal.Name, strings.Join(cats, ", ")})
+		}
+	}
+	return ret
+}
+
 // a menu is an enumeration of all available meals
 type Menu struct {
 	meals    map[string][]Meal
@@ -135,9 +168,9 @@ func (m Menu) String() string {
 		if !ok {
 			continue
 		}
-		builder.WriteRune('*')
-		builder.WriteString(t)
-		builder.WriteRune('*')
+		builder.WriteString("**")
+		builder.WriteString(signalcli.EscapeMarkup(t))
+		builder.WriteString("**")
 		builder.WriteRune(':')
 		builder.WriteRune('\n')
 		for _, meal := range ms {
@@ -19,6 +19,7 @@ package refectory
 import (
 	"io"
 	"os"
+	"reflect"
 	"testing"
 	"time"
 
@@ -367,3 +368,24 @@ func TestFetcherAll(t *testing.T) {
 		t.Fatalf("formatting is wrong")
 	}
 }
+
+func TestMenuRows(t *testing.T) {
+	menu := Menu{
+		meals: map[string][]Meal{
+			"Pasta": {Meal{Name: "Pasta", Categories: []Category{VEGAN}}},
+			"Grill": {
+				Meal{Name: "Steak", Categories: []Category{BEEF, PORK}},
+				Meal{Name: "Wurst"},
+			},
+		},
+		ordering: []string{"Pasta", "Grill"},
+	}
+	want := [][]string{
+		{"Pasta", "Pasta", "vegan"},
+		{"Grill", "Steak", "Rind, Schwein"},
+		{"Grill", "Wurst", ""},
+	}
+	if !reflect.DeepEqual(menu.Rows(), want) {
+		t.Fatalf("expected %v, got %v", want, menu.Rows())
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"signalbot_go/internal/render"
 	"signalbot_go/internal/signalsender"
 	cmdsplit "signalbot_go/internal/cmdSplit"
 	"signalbot_go/modules"
@@ -109,6 +110,8 @@ type Args struct {
 	Where string `arg:"positional"`
 	When  string `arg:"-d,--day" default:"0"`
 	Quiet bool   `arg:"-q,--quiet" default:"false"`
+	// send the menus of all days as one image per refectory
+	Image bool `arg:"-i,--image" default:"false"`
 }
 
 // Handle a message from the signalcli. Parses the message, executes the query
@@ -136,48 +139,46 @@ func (r *Refectory[U,T]) Handle(m *signalcli.Message, signal signalsender.Signal
 		r.SendError(m, signal, errMsg)
 		return
 	}
-	for _, when := range days {
-		date := time.Now().Add(time.Hour * 24 * time.Duration(when))
-		date = time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
-
-		args.Where = strings.ToLower(args.Where)
-
-		resolvedL, ok := r.Aliases[args.Where]
-		if !ok {
-			errMsg := fmt.Sprintf("Error: %v is unknown", args.Where)
-			r.Log.Error(errMsg)
-			builder := strings.Builder{}
-			builder.WriteString(errMsg)
-			builder.WriteRune('\n')
-			builder.WriteString("Available refectories: ")
-			sorted := make(sort.StringSlice, 0, len(r.Aliases))
-			for k := range r.Aliases {
-				sorted = append(sorted, k)
-			}
-			sorted.Sort()
-			builder.WriteString(strings.Join(sorted, ", "))
+	args.Where = strings.ToLower(args.Where)
+	resolvedL, ok := r.Aliases[args.Where]
+	if !ok {
+		errMsg := fmt.Sprintf("Error: %v is unknown", args.Where)
+		r.Log.Error(errMsg)
+		builder := strings.Builder{}
+		builder.WriteString(errMsg)
+		builder.WriteRune('\n')
+		builder.WriteString("Available refectories: ")
+		sorted := make(sort.StringSlice, 0, len(r.Aliases))
+		for k := range r.Aliases {
+			sorted = append(sorted, k)
+		}
+		sorted.Sort()
+		builder.WriteString(strings.Join(sorted, ", "))
+		// once for each day as before
+		for range days {
 			r.SendError(m, signal, builder.String())
-			continue
 		}
+		return
+	}
+
+	if args.Image {
+		r.handleImage(m, signal, resolvedL, days, args.Quiet)
+		return
+	}
+
+	for _, when := range days {
+		date := dayDate(when)
 
 		for _, ref := range resolvedL {
 			// execute the query
-			reader, err := r.fetcher.getReader(r.Refectories[ref], date)
-			if err != nil {
-				var errMsg string
-				if err == ErrNotOpenThatDay {
-					errMsg = err.Error()
-				} else {
-					errMsg = fmt.Sprintf("Error: %v", err)
-				}
-				r.Log.Error(errMsg)
-				if !args.Quiet || err != ErrNotOpenThatDay {
-					r.SendError(m, signal, errMsg)
+			menu, err := r.menu(ref, date)
+			if err == ErrNotOpenThatDay {
+				r.Log.Error(err.Error())
+				if !args.Quiet {
+					r.SendError(m, signal, err.Error())
 				}
 				continue
 			}
-			defer reader.Close()
-			menu, err := r.fetcher.getFromReader(reader)
 			if err != nil {
 				errMsg := fmt.Sprintf("Error: %v", err)
 				r.Log.Error(errMsg)
@@ -185,8 +186,8 @@ func (r *Refectory[U,T]) Handle(m *signalcli.Message, signal signalsender.Signal
 				continue
 			}
 			// respond
-			menuS := fmt.Sprintf("%s on %s\n", ref, date.Format("Mon 2006-01-02")) + menu.String()
-			_, err = signal.Respond(menuS, []string{}, m, true)
+			menuS := fmt.Sprintf("**%s** on %s\n", signalcli.EscapeMarkup(ref), date.Format("Mon 2006-01-02")) + menu.String()
+			_, err = signal.RespondStyled(menuS, []string{}, m, true)
 			if err != nil {
 				errMsg := fmt.Sprintf("Error: %v", err)
 				r.Log.Error(errMsg)
@@ -196,3 +197,68 @@ func (r *Refectory[U,T]) Handle(m *signalcli.Message, signal signalsender.Signal
 		}
 	}
 }
+
+// the date (UTC) `when` days from now
+func dayDate(when int) time.Time {
+	date := time.Now().Add(time.Hour * 24 * time.Duration(when))
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// fetch the menu of the refectory on the date
+func (r *Refectory[U,T]) menu(ref string, date time.Time) (Menu, error) {
+	reader, err := r.fetcher.getReader(r.Refectories[ref], date)
+	if err != nil {
+		return Menu{}, err
+	}
+	defer reader.Close()
+	return r.fetcher.getFromReader(reader)
+}
+
+// respond with one image per refectory which lists the menus of all days
+func (r *Refectory[U,T]) handleImage(m *signalcli.Message, signal signalsender.SignalSender, refs []string, days []int, quiet bool) {
+	for _, ref := range refs {
+		table := render.Table{
+			Title:  ref,
+			Header: []string{"Day", "Type", "Meal", "Info"},
+		}
+		for _, when := range days {
+			date := dayDate(when)
+			menu, err := r.menu(ref, date)
+			if err == ErrNotOpenThatDay {
+				r.Log.Error(err.Error())
+				if !quiet {
+					table.Rows = append(table.Rows, []string{date.Format("Mon 02.01"), "", err.Error()})
+				}
+				continue
+			}
+			if err != nil {
+				errMsg := fmt.Sprintf("Error: %v", err)
+				r.Log.Error(errMsg)
+				r.SendError(m, signal, errMsg)
+				return
+			}
+			for _, row := range menu.Rows() {
+				table.Rows = append(table.Rows, append([]string{date.Format("Mon 02.01")}, row...))
+			}
+		}
+		if len(table.Rows) == 0 {
+			continue
+		}
+
+		ofile, err := table.PNG()
+		if err != nil {
+			errMsg := fmt.Sprintf("Error: %v", err)
+			r.Log.Error(errMsg)
+			r.SendError(m, signal, errMsg)
+			return
+		}
+		_, err = signal.Respond("", []string{ofile.Path()}, m, true)
+		ofile.Close()
+		if err != nil {
+			errMsg := fmt.Sprintf("Error: %v", err)
+			r.Log.Error(errMsg)
+			r.SendError(m, signal, errMsg)
+			return
+		}
+	}
+}
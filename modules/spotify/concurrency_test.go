@@ -0,0 +1,77 @@
+package spotify
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"signalbot_go/internal/modtest"
+	"signalbot_go/internal/state"
+	"testing"
+)
+
+const testCfg string = `
+queries:
+  a: artistA
+  b: artistB
+querySize: 20
+clientId: id
+clientSecret: secret
+`
+
+// run with -race
+func TestConcurrentDiff(t *testing.T) {
+	modtest.MockHTTP(t, modtest.Transport{
+		"https://accounts.spotify.com/api/token":            `={"access_token": "token", "token_type": "Bearer", "expires_in": 3600}`,
+		"https://api.spotify.com/v1/artists/artistA/albums": `={"total": 1, "items": [{"name": "A", "album_type": "album", "total_tracks": 3, "release_date": "2024-01-01", "artists": [{"name": "artist A"}]}]}`,
+		"https://api.spotify.com/v1/artists/artistB/albums": `={"total": 1, "items": [{"name": "B", "album_type": "single", "total_tracks": 1, "release_date": "2024-02-01", "artists": [{"name": "artist B"}]}]}`,
+	})
+	cfgDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(cfgDir, "spotify.yaml"), []byte(testCfg), 0o600); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	st, err := state.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	log := slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+	spotify, err := NewSpotify(log, cfgDir, st)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	msgs := modtest.Messages(10, "+49123", "+49123", "all --diff")
+	msgs = append(msgs, modtest.Messages(5, "+49123", "+49123", "all --history")...)
+	sender := &modtest.Sender{}
+	modtest.Fire(spotify, sender, msgs...)
+
+	resps := sender.Get()
+	if len(resps) != len(msgs) {
+		t.Fatalf("Was: %v responses but should be %v", len(resps), len(msgs))
+	}
+	diffs := 0
+	for _, r := range resps {
+		if r != "No data/changes" && r != "No changes" {
+			diffs++
+		}
+	}
+	if diffs != 1 {
+		t.Fatalf("Was: %v diffs but should be %v\n%v", diffs, 1, resps)
+	}
+}
@@ -22,10 +22,13 @@ import (
 	"path/filepath"
 	"signalbot_go/internal/differ"
 	"signalbot_go/internal/signalsender"
+	"signalbot_go/internal/state"
 	"signalbot_go/modules"
 	"signalbot_go/signalcli"
 	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/alexflint/go-arg"
 	"gopkg.in/yaml.v3"
@@ -37,22 +40,27 @@ import (
 type Spotify struct {
 	modules.Module
 
-	fetcher   *Fetcher                             `yaml:"-"`
-	Queries   map[string]string                    `yaml:"queries"`
-	Aliases   map[string][]string                  `yaml:"aliases"`
-	Lasts     differ.Differ[string, string, album] `yaml:"lasts"` // stores last chat->user->sending
-	QuerySize uint                                 `yaml:"querySize"`
-	ClientId string                                 `yaml:"clientId"`
-	ClientSecret string                                 `yaml:"clientSecret"`
+	fetcher      *Fetcher                `yaml:"-"`
+	Queries      map[string]string       `yaml:"queries"`
+	Aliases      map[string][]string     `yaml:"aliases"`
+	History      *modules.History[album] `yaml:"-"` // stores chat->user->albums
+	HistorySize  int                     `yaml:"historySize,omitempty"`
+	QuerySize    uint                    `yaml:"querySize"`
+	ClientId     string                  `yaml:"clientId"`
+	ClientSecret string                  `yaml:"clientSecret"`
+	// deprecated, only read to migrate old configurations to the History
+	Lasts differ.Differ[string, string, album] `yaml:"lasts,omitempty"`
+
+	mu sync.Mutex `yaml:"-"` // guards the History
 }
 
 // instanciates a new Spotify from a configuration file
 // (cfgDir/spotify.yaml)
-func NewSpotify(log *slog.Logger, cfgDir string) (*Spotify, error) {
+func NewSpotify(log *slog.Logger, cfgDir string, st state.Store) (*Spotify, error) {
 	r := Spotify{
 		Module: modules.NewModule(log, cfgDir),
-		Lasts:  make(differ.Differ[string, string, album]),
 	}
+	r.State = st
 
 	f, err := os.Open(filepath.Join(r.ConfigDir, "spotify.yaml"))
 	if err != nil {
@@ -67,6 +75,15 @@ func NewSpotify(log *slog.Logger, cfgDir string) (*Spotify, error) {
 		return nil, err
 	}
 
+	r.History, err = modules.NewHistory[album](&r.Module, r.HistorySize)
+	if err != nil {
+		return nil, err
+	}
+	if len(r.Lasts) > 0 {
+		r.History.Import(r.Lasts, time.Now())
+	}
+	r.Lasts = nil
+
 	if r.Aliases == nil {
 		r.Aliases = make(map[string][]string)
 	}
@@ -116,6 +133,7 @@ type Args struct {
 	Which string `arg:"positional"`
 	Quiet bool   `arg:"-q,--quiet" default:"false"`
 	Diff  bool   `arg:"--diff" default:"false"`
+	modules.HistoryArgs
 }
 
 // Handle a message from the signalcli. Parses the message, executes the query
@@ -169,6 +187,24 @@ func (r *Spotify) Handle(m *signalcli.Message, signal signalsender.SignalSender,
 		}
 	}
 
+	if args.History {
+		r.mu.Lock()
+		respS := r.History.ChangesString(chat, m.Sender, args.SinceTime(), time.Local)
+		r.mu.Unlock()
+		if respS == "" {
+			if args.Quiet {
+				return
+			}
+			respS = "No changes"
+		}
+		if _, err := signal.Respond(respS, []string{}, m, true); err != nil {
+			errMsg := fmt.Sprintf("Error: %v", err)
+			r.Log.Error(errMsg)
+			r.SendError(m, signal, errMsg)
+		}
+		return
+	}
+
 	// execute the query
 	items, err := r.fetcher.get(queries)
 	if err != nil {
@@ -180,15 +216,26 @@ func (r *Spotify) Handle(m *signalcli.Message, signal signalsender.SignalSender,
 
 	// respond
 	resp := strings.Builder{}
-	if !args.Diff {
+	r.mu.Lock()
+	if args.Since != 0 {
+		d := r.History.DiffSince(chat, m.Sender, args.SinceTime(), items)
+		if args.Diff {
+			r.History.DiffStore(chat, m.Sender, items)
+		}
+		if d != "" {
+			resp.WriteString("Diff:\n")
+			resp.WriteString(d)
+		}
+	} else if !args.Diff {
 		resp.WriteString(items.String())
 	} else {
-		d := r.Lasts.DiffStore(chat, m.Sender, items)
+		d := r.History.DiffStore(chat, m.Sender, items)
 		if d != "" {
 			resp.WriteString("Diff:\n")
 			resp.WriteString(d)
 		}
 	}
+	r.mu.Unlock()
 	respS := resp.String()
 
 	if respS == "" {
@@ -206,23 +253,3 @@ func (r *Spotify) Handle(m *signalcli.Message, signal signalsender.SignalSender,
 		return
 	}
 }
-
-// save config file in case something has changed (module allows to add new
-// series during runtime)
-func (r *Spotify) Close(virtRcv func(*signalcli.Message)) {
-	r.Module.Close(virtRcv)
-
-	delete(r.Aliases, "all") // "all" alias is always a generated one
-	f, err := os.Create(filepath.Join(r.ConfigDir, "spotify.yaml"))
-	if err != nil {
-		r.Log.Error(fmt.Sprintf("Error opening 'spotify.yaml': %v", err))
-	}
-	defer f.Close()
-
-	e := yaml.NewEncoder(f)
-	defer e.Close()
-	err = e.Encode(r)
-	if err != nil {
-		r.Log.Error(fmt.Sprintf("Error endcoding to 'spotify.yaml': %v", err))
-	}
-}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"signalbot_go/internal/render"
 	"signalbot_go/internal/signalsender"
 	"signalbot_go/modules"
 	"signalbot_go/modules/tv/internal/show"
@@ -87,6 +88,8 @@ func (t *Tv) Validate() error {
 type Args struct {
 	When string `arg:"positional"`
 	Post uint   `arg:"-p,--post" default:"1"`
+	// send the shows as image
+	Image bool `arg:"-i,--image" default:"false"`
 }
 
 func (r *Tv) Handle(m *signalcli.Message, signal signalsender.SignalSender, virtRcv func(*signalcli.Message)) {
@@ -116,6 +119,25 @@ func (r *Tv) Handle(m *signalcli.Message, signal signalsender.SignalSender, virt
 		return
 	}
 
+	if args.Image {
+		table := r.table(target, args.Post)
+		ofile, err := table.PNG()
+		if err != nil {
+			errMsg := fmt.Sprintf("Error: %v", err)
+			r.Log.Error(errMsg)
+			r.SendError(m, signal, errMsg)
+			return
+		}
+		defer ofile.Close()
+		_, err = signal.Respond("", []string{ofile.Path()}, m, true)
+		if err != nil {
+			errMsg := fmt.Sprintf("Error: %v", err)
+			r.Log.Error(errMsg)
+			r.SendError(m, signal, errMsg)
+		}
+		return
+	}
+
 	out, err := r.format(target, args.Post)
 	if err != nil {
 		errMsg := fmt.Sprintf("Error: %v", err)
@@ -124,7 +146,7 @@ func (r *Tv) Handle(m *signalcli.Message, signal signalsender.SignalSender, virt
 		return
 	}
 
-	_, err = signal.Respond(out, []string{}, m, true)
+	_, err = signal.RespondStyled(out, []string{}, m, true)
 	if err != nil {
 		errMsg := fmt.Sprintf("Error: %v", err)
 		r.Log.Error(errMsg)
@@ -133,24 +155,24 @@ func (r *Tv) Handle(m *signalcli.Message, signal signalsender.SignalSender, virt
 	}
 }
 
-func (t *Tv) format(target time.Time, postOrig uint) (string, error) {
-	g := t.fetcher.Get()
+// the shows selected for one sender
+type senderShows struct {
+	sender string
+	shows  []*show.Show
+}
 
-	builder := strings.Builder{}
+// select the show running at target and the following post-1 shows of each
+// sender
+func (t *Tv) selectShows(target time.Time, postOrig uint) []senderShows {
+	g := t.fetcher.Get()
 
-	first := true
+	ret := []senderShows{}
 	for _, sender := range t.SenderOrder {
 		shows, ok := g[sender]
 		if !ok {
 			continue
 		}
-		if !first {
-			builder.WriteRune('\n')
-		} else {
-			first = false
-		}
-		builder.WriteString(sender)
-		builder.WriteRune('\n')
+		sel := senderShows{sender: sender}
 		var last *show.Show = nil
 		post := postOrig
 		for _, s := range shows {
@@ -158,17 +180,54 @@ func (t *Tv) format(target time.Time, postOrig uint) (string, error) {
 				break
 			}
 			if s.Date.Compare(target) == +1 {
-				builder.WriteString(last.String())
-				builder.WriteRune('\n')
+				sel.shows = append(sel.shows, last)
 				post--
 			}
 			sNew := s
 			last = &sNew
 		}
 		if post != 0 {
-			builder.WriteString(last.String())
+			sel.shows = append(sel.shows, last)
+		}
+		ret = append(ret, sel)
+	}
+	return ret
+}
+
+func (t *Tv) format(target time.Time, postOrig uint) (string, error) {
+	builder := strings.Builder{}
+
+	for i, sel := range t.selectShows(target, postOrig) {
+		if i != 0 {
+			builder.WriteRune('\n')
+		}
+		builder.WriteString("**")
+		builder.WriteString(signalcli.EscapeMarkup(sel.sender))
+		builder.WriteString("**\n")
+		for _, s := range sel.shows {
+			builder.WriteString(signalcli.EscapeMarkup(s.String()))
 			builder.WriteRune('\n')
 		}
 	}
 	return builder.String(), nil
 }
+
+// the selected shows as table
+func (t *Tv) table(target time.Time, postOrig uint) render.Table {
+	table := render.Table{
+		Title:  target.Format("Mon 02.01 15:04"),
+		Header: []string{"Sender", "Time", "Show"},
+	}
+	for _, sel := range t.selectShows(target, postOrig) {
+		sender := sel.sender
+		for _, s := range sel.shows {
+			if s == nil {
+				continue
+			}
+			table.Rows = append(table.Rows, []string{sender, s.Date.In(t.loc).Format("15:04"), s.Name})
+			// only name the sender in its first row
+			sender = ""
+		}
+	}
+	return table
+}
@@ -0,0 +1,173 @@
+package weather
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// the air quality and pollen forecast of open-meteo (independent of the
+// provider of the weather). Pollen are only available in Europe.
+const airUrl string = "https://air-quality-api.open-meteo.com/v1/air-quality?"
+
+// the limit of the air quality API
+const maxAirDays = 7
+
+// the variables requested from the API
+var airVars []string = []string{"european_aqi", "pm2_5", "pm10", "ozone", "birch_pollen", "grass_pollen"}
+
+// values of the API which may be null
+type airVals struct {
+	European_aqi *float64
+	Pm2_5        *float64
+	Pm10         *float64
+	Ozone        *float64
+	Birch_pollen *float64
+	Grass_pollen *float64
+}
+
+type airResp struct {
+	Timezone string `json:"timezone"`
+	Current  struct {
+		Time string `json:"time"`
+		airVals
+	} `json:"current"`
+	Hourly struct {
+		Time         []string   `json:"time"`
+		European_aqi []*float64 `json:"european_aqi"`
+		Pm2_5        []*float64 `json:"pm2_5"`
+		Pm10         []*float64 `json:"pm10"`
+		Ozone        []*float64 `json:"ozone"`
+		Birch_pollen []*float64 `json:"birch_pollen"`
+		Grass_pollen []*float64 `json:"grass_pollen"`
+	} `json:"hourly"`
+}
+
+// the query needs the air quality API
+func (q query) needsAir() bool {
+	return slices.Contains(q.fields, fieldAir) || slices.Contains(q.fields, fieldPollen)
+}
+
+// the parameters of the API request
+func airParams(pos Position, q query) url.Values {
+	return url.Values{
+		"latitude":      {strconv.FormatFloat(float64(pos.Lat), 'f', 6, 32)},
+		"longitude":     {strconv.FormatFloat(float64(pos.Lon), 'f', 6, 32)},
+		"timezone":      {"auto"},
+		"current":       airVars,
+		"hourly":        airVars,
+		"forecast_days": {strconv.Itoa(min(q.days, maxAirDays))},
+	}
+}
+
+// parse the response of the air quality API
+func airFromReader(r io.Reader) (*airResp, error) {
+	resp := &airResp{}
+	if err := json.NewDecoder(r).Decode(resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// the i-th value, NaN if unknown
+func nullValue(vals []*float64, i int) float64 {
+	if i < 0 || i >= len(vals) {
+		return orNaN(nil)
+	}
+	return orNaN(vals[i])
+}
+
+func (v airVals) air() *Air {
+	return &Air{
+		Aqi:   orNaN(v.European_aqi),
+		Pm25:  orNaN(v.Pm2_5),
+		Pm10:  orNaN(v.Pm10),
+		Ozone: orNaN(v.Ozone),
+		Birch: orNaN(v.Birch_pollen),
+		Grass: orNaN(v.Grass_pollen),
+	}
+}
+
+// the hourly values by the unix time of the hour
+func (o *airResp) hours() map[int64]*Air {
+	loc, err := time.LoadLocation(o.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	h := o.Hourly
+	ret := make(map[int64]*Air, len(h.Time))
+	for i, s := range h.Time {
+		t, err := time.ParseInLocation(weatherDateTimeFormat, s, loc)
+		if err != nil {
+			continue
+		}
+		ret[t.Unix()] = &Air{
+			Aqi:   nullValue(h.European_aqi, i),
+			Pm25:  nullValue(h.Pm2_5, i),
+			Pm10:  nullValue(h.Pm10, i),
+			Ozone: nullValue(h.Ozone, i),
+			Birch: nullValue(h.Birch_pollen, i),
+			Grass: nullValue(h.Grass_pollen, i),
+		}
+	}
+	return ret
+}
+
+// fetch the air quality for the position
+func (f *Fetcher) air(pos Position, q query) (*airResp, error) {
+	reader, err := get(airUrl + airParams(pos, q).Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return airFromReader(reader)
+}
+
+// set the air quality of the forecast. The days get the maximum of their
+// hours.
+func (o *Forecast) setAir(air *airResp) {
+	o.Current.Air = air.Current.air()
+	o.Source += ", air quality: CAMS (open-meteo.com)"
+	hours := air.hours()
+	for i, h := range o.Hourly {
+		o.Hourly[i].Air = hours[h.Time.Unix()]
+	}
+	for i, d := range o.Daily {
+		start, end := d.Date.Unix(), d.Date.AddDate(0, 0, 1).Unix()
+		for t, h := range hours {
+			if t < start || t >= end {
+				continue
+			}
+			a := o.Daily[i].Air
+			if a == nil {
+				a = unknownAir()
+				o.Daily[i].Air = a
+			}
+			a.Aqi = maxKnown(a.Aqi, h.Aqi)
+			a.Pm25 = maxKnown(a.Pm25, h.Pm25)
+			a.Pm10 = maxKnown(a.Pm10, h.Pm10)
+			a.Ozone = maxKnown(a.Ozone, h.Ozone)
+			a.Birch = maxKnown(a.Birch, h.Birch)
+			a.Grass = maxKnown(a.Grass, h.Grass)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package weather
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"signalbot_go/internal/modtest"
+)
+
+func TestAir(t *testing.T) {
+	f, err := os.Open("test1.json")
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	resp, err := openMeteo{}.getFromReader(f, query{}, time.Now())
+	if err != nil {
+		panic(err)
+	}
+	fa, err := os.Open("air.json")
+	if err != nil {
+		panic(err)
+	}
+	defer fa.Close()
+	air, err := airFromReader(fa)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	resp.setAir(air)
+
+	out := resp.report([]field{fieldAir, fieldPollen})
+	for _, want := range []string{
+		"Air: AQI 44 (moderate), PM2.5 9µg/m³, PM10 15µg/m³, O3 68µg/m³\n",
+		"Pollen: birch 12 (moderate), grass 3 (low)\n",
+		"Sun 06.10  AQI:53 Birch:80 Grass:3\n",
+		"16:00 06.10  AQI:46 Birch:80 Grass:3\n",
+		"Quelle: open-meteo.com, air quality: CAMS (open-meteo.com)",
+	} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("missing %q in:\n%s", want, out)
+		}
+	}
+	// no pollen on the third day, no air quality after it
+	if d := resp.Daily[2].Air; d == nil || d.Aqi != 73 || !math.IsNaN(d.Birch) {
+		t.Fatalf("wrong day: %+v", d)
+	}
+	if d := resp.Daily[3].Air; d != nil {
+		t.Fatalf("Was: %+v but should be nil", d)
+	}
+
+	c, err := parseCondition("birch>50")
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	alerts := resp.alerts("muc", []condition{c})
+	if len(alerts) != 1 || alerts[0].Text != "⚠️ muc: birch>50 from Sun 16:00 (up to 80.0)" {
+		t.Fatalf("Was: %+v", alerts)
+	}
+}
+
+func TestPollenArg(t *testing.T) {
+	modtest.MockHTTP(t, modtest.Transport{
+		"https://api.open-meteo.com/v1/forecast":                "test1.json",
+		"https://air-quality-api.open-meteo.com/v1/air-quality": "air.json",
+	})
+	weather, err := NewWeather(nopLog(), "./", nil)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	sender := &modtest.Sender{}
+	weather.Handle(message("muc --pollen"), sender, nil)
+	resps := sender.Get()
+	if len(resps) != 1 || !strings.Contains(resps[0], "Pollen: birch 12 (moderate), grass 3 (low)") || strings.Contains(resps[0], "AQI:") {
+		t.Fatalf("Was: %q", resps)
+	}
+}
@@ -0,0 +1,236 @@
+package weather
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// forecasts of the Deutscher Wetterdienst (DWD) via brightsky.dev. Only
+// covers Germany and has no daily forecast, so the days are aggregated from
+// the hours.
+type brightSky struct{}
+
+const brightSkyUrl string = "https://api.brightsky.dev/weather?"
+
+// the MOSMIX forecast of the DWD reaches 10 days ahead
+func (brightSky) limits() (int, int) {
+	return 10, 10 * 24
+}
+
+type brightSkyResp struct {
+	Weather []brightSkyHour `json:"weather"`
+}
+
+// a record of the hourly forecast. Values are nil if unknown.
+type brightSkyHour struct {
+	Timestamp     time.Time `json:"timestamp"`
+	Temperature   *float64  `json:"temperature"`
+	Precipitation *float64  `json:"precipitation"`
+	// minutes of the hour
+	Sunshine      *float64 `json:"sunshine"`
+	WindSpeed     *float64 `json:"wind_speed"`
+	WindDirection *float64 `json:"wind_direction"`
+	WindGustSpeed *float64 `json:"wind_gust_speed"`
+	CloudCover    *float64 `json:"cloud_cover"`
+	RelHumidity   *float64 `json:"relative_humidity"`
+	Condition     *string  `json:"condition"`
+	Icon          *string  `json:"icon"`
+}
+
+// the value, NaN if nil
+func orNaN(v *float64) float64 {
+	if v == nil {
+		return math.NaN()
+	}
+	return *v
+}
+
+// the parameters of the API request (all hours of the requested days)
+func (brightSky) params(pos Position, q query, now time.Time) url.Values {
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	return url.Values{
+		"lat":       {strconv.FormatFloat(float64(pos.Lat), 'f', 6, 32)},
+		"lon":       {strconv.FormatFloat(float64(pos.Lon), 'f', 6, 32)},
+		"date":      {today.Format(time.RFC3339)},
+		"last_date": {today.AddDate(0, 0, q.days).Format(time.RFC3339)},
+	}
+}
+
+func (o brightSky) getReader(pos Position, q query) (io.ReadCloser, error) {
+	return get(brightSkyUrl + o.params(pos, q, time.Now()).Encode())
+}
+
+func (brightSky) getFromReader(r io.Reader, q query, now time.Time) (*Forecast, error) {
+	resp := &brightSkyResp{}
+	if err := json.NewDecoder(r).Decode(resp); err != nil {
+		return nil, err
+	}
+	return resp.forecast(q, now), nil
+}
+
+// the WMO weather code of the condition and the icon
+func (h brightSkyHour) code() int {
+	precip := orNaN(h.Precipitation)
+	if h.Condition != nil {
+		switch *h.Condition {
+		case "thunderstorm":
+			return 95
+		case "hail":
+			return 96
+		case "snow":
+			return 73
+		case "sleet":
+			return 66
+		case "fog":
+			return 45
+		case "rain":
+			if precip < 0.5 {
+				return 61
+			} else if precip > 4 {
+				return 65
+			}
+			return 63
+		}
+	}
+	if h.Icon == nil {
+		return codeUnknown
+	}
+	switch *h.Icon {
+	case "clear-day", "clear-night":
+		return 0
+	case "partly-cloudy-day", "partly-cloudy-night":
+		return 2
+	case "cloudy", "wind":
+		return 3
+	case "fog":
+		return 45
+	}
+	return codeUnknown
+}
+
+// the normalized hour
+func (h brightSkyHour) hour(loc *time.Location) Hour {
+	ret := Hour{
+		Time:      h.Timestamp.In(loc),
+		Code:      h.code(),
+		Temp:      orNaN(h.Temperature),
+		Precip:    orNaN(h.Precipitation),
+		WindSpeed: orNaN(h.WindSpeed),
+		WindDir:   orNaN(h.WindDirection),
+		WindGusts: orNaN(h.WindGustSpeed),
+		Uv:        math.NaN(),
+		Sunshine:  orNaN(h.Sunshine) * 60,
+	}
+	if h.Condition != nil && *h.Condition == "snow" {
+		ret.Snow = ret.Precip * snowRatio
+	}
+	return ret
+}
+
+// normalize the response. The hours start at the current one, the days are
+// aggregated in the timezone of now.
+func (o *brightSkyResp) forecast(q query, now time.Time) *Forecast {
+	f := &Forecast{
+		Source:  "Deutscher Wetterdienst (brightsky.dev)",
+		Current: unknownCurrent(now),
+	}
+	loc := now.Location()
+	hour := now.Truncate(time.Hour)
+	for _, rec := range o.Weather {
+		h := rec.hour(loc)
+		if h.Time.Equal(hour) {
+			f.Current = Current{
+				Time:      now,
+				Code:      h.Code,
+				Temp:      h.Temp,
+				Humidity:  orNaN(rec.RelHumidity),
+				Clouds:    orNaN(rec.CloudCover),
+				WindSpeed: h.WindSpeed,
+				WindDir:   h.WindDir,
+				WindGusts: h.WindGusts,
+				Precip:    h.Precip,
+				Snow:      h.Snow,
+			}
+		}
+		if !h.Time.Before(hour) && (q.hours < 0 || len(f.Hourly) < q.hours) {
+			f.Hourly = append(f.Hourly, h)
+		}
+		f.addToDay(h, q.days)
+	}
+	return f
+}
+
+// aggregate the hour into its day (at most days days)
+func (o *Forecast) addToDay(h Hour, days int) {
+	y, m, d := h.Time.Date()
+	date := time.Date(y, m, d, 0, 0, 0, 0, h.Time.Location())
+	if len(o.Daily) == 0 || !o.Daily[len(o.Daily)-1].Date.Equal(date) {
+		if len(o.Daily) >= days {
+			return
+		}
+		o.Daily = append(o.Daily, Day{
+			Date:      date,
+			Code:      codeUnknown,
+			TempMin:   math.NaN(),
+			TempMax:   math.NaN(),
+			WindSpeed: math.NaN(),
+			WindDir:   math.NaN(),
+			Uv:        math.NaN(),
+			Daylight:  math.NaN(),
+		})
+	}
+	day := &o.Daily[len(o.Daily)-1]
+	// the most severe weather of the day (higher codes are more severe)
+	day.Code = max(day.Code, h.Code)
+	day.TempMin = minKnown(day.TempMin, h.Temp)
+	day.TempMax = maxKnown(day.TempMax, h.Temp)
+	if maxKnown(day.WindSpeed, h.WindSpeed) != day.WindSpeed {
+		day.WindSpeed, day.WindDir = h.WindSpeed, h.WindDir
+	}
+	if known(h.Precip) {
+		day.Precip += h.Precip
+	}
+	if known(h.Snow) {
+		day.Snow += h.Snow
+	}
+	if known(h.Sunshine) {
+		day.Sunshine += h.Sunshine
+	}
+}
+
+// the minimum of the known values (NaN if none is known)
+func minKnown(a float64, b float64) float64 {
+	if !known(a) || b < a {
+		return b
+	}
+	return a
+}
+
+// the maximum of the known values (NaN if none is known)
+func maxKnown(a float64, b float64) float64 {
+	if !known(a) || b > a {
+		return b
+	}
+	return a
+}
@@ -0,0 +1,73 @@
+package weather
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBrightSky(t *testing.T) {
+	f, err := os.Open("brightsky.json")
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	cest := time.FixedZone("CEST", 2*60*60)
+	now := time.Date(2024, 10, 6, 14, 30, 0, 0, cest)
+	resp, err := brightSky{}.getFromReader(f, query{days: 2, hours: 5, fields: allFields}, now)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	if resp.Current.Temp != 14 || resp.Current.Humidity != 81 || resp.Current.Code != 0 {
+		t.Fatalf("wrong current weather: %+v", resp.Current)
+	}
+	if len(resp.Hourly) != 5 || !resp.Hourly[0].Time.Equal(time.Date(2024, 10, 6, 14, 0, 0, 0, cest)) {
+		t.Fatalf("wrong hours: %+v", resp.Hourly)
+	}
+	if resp.Hourly[0].Sunshine != 3600 || !math.IsNaN(resp.Hourly[0].Uv) {
+		t.Fatalf("wrong hour: %+v", resp.Hourly[0])
+	}
+	if len(resp.Daily) != 2 {
+		t.Fatalf("expected 2 days, got %d", len(resp.Daily))
+	}
+	today := resp.Daily[0]
+	if today.Code != 63 || today.TempMax != 14 || math.Abs(today.Precip-3.6) > 1e-9 || today.Sunshine != 10*3600 || !math.IsNaN(today.Daylight) {
+		t.Fatalf("wrong aggregation of today: %+v", today)
+	}
+	tomorrow := resp.Daily[1]
+	if tomorrow.Code != 73 || math.Abs(tomorrow.Snow-4.2) > 1e-9 || tomorrow.WindSpeed != 30.8 || tomorrow.Sunshine != 10*1800 {
+		t.Fatalf("wrong aggregation of tomorrow: %+v", tomorrow)
+	}
+
+	out := resp.report(allFields)
+	if !strings.HasSuffix(out, "Quelle: Deutscher Wetterdienst (brightsky.dev)") {
+		t.Fatalf("missing source:\n%s", out)
+	}
+	if _, err := resp.day(1, allFields); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	params := brightSky{}.params(Position{Lat: 48.1374, Lon: 11.5755}, query{days: 2}, now)
+	if params.Get("date") != "2024-10-06T00:00:00+02:00" || params.Get("last_date") != "2024-10-08T00:00:00+02:00" {
+		t.Fatalf("wrong range: %v", params)
+	}
+}
@@ -0,0 +1,92 @@
+package weather
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"image/color"
+	"math"
+
+	"signalbot_go/internal/render"
+)
+
+// colors of the series of the charts
+var (
+	colorTemp = color.NRGBA{0xd0, 0x40, 0x30, 0xff}
+	colorRain = color.NRGBA{0x30, 0x70, 0xd0, 0xc0}
+	colorSun  = color.NRGBA{0xff, 0xcc, 0x30, 0x70}
+)
+
+// the hourly forecast as chart (temperature, precipitation and sunshine)
+func (o *Forecast) HourlyChart(title string) render.Chart {
+	n := len(o.Hourly)
+	labels := make([]string, n)
+	temp := make([]float64, n)
+	precip := make([]float64, n)
+	sun := make([]float64, n)
+	for i, h := range o.Hourly {
+		if h.Time.Hour() == 0 {
+			labels[i] = h.Time.Format("Mon 02.01")
+		} else if h.Time.Hour()%6 == 0 {
+			labels[i] = h.Time.Format("15:04")
+		}
+		temp[i], precip[i] = h.Temp, h.Precip
+		sun[i] = h.Sunshine / 3600
+	}
+	return render.Chart{
+		Title:  title,
+		Labels: labels,
+		Series: []render.Series{
+			{Name: "Sonne", Kind: render.Shade, Values: sun, Color: colorSun},
+			{Name: "Niederschlag", Kind: render.Bars, Values: precip, Color: colorRain, Right: true},
+			{Name: "Temperatur", Kind: render.Line, Values: temp, Color: colorTemp},
+		},
+		LeftUnit:  unitTemp,
+		RightUnit: unitRain,
+		Footer:    "Quelle: " + o.Source,
+	}
+}
+
+// the daily forecast as chart (temperature range, precipitation and
+// sunshine relative to the daylight)
+func (o *Forecast) DailyChart(title string) render.Chart {
+	n := len(o.Daily)
+	labels := make([]string, n)
+	low := make([]float64, n)
+	high := make([]float64, n)
+	precip := make([]float64, n)
+	sun := make([]float64, n)
+	for i, d := range o.Daily {
+		labels[i] = d.Date.Format("Mon 02.01")
+		low[i], high[i], precip[i] = d.TempMin, d.TempMax, d.Precip
+		sun[i] = math.NaN()
+		if d.Daylight > 0 {
+			sun[i] = d.Sunshine / d.Daylight
+		}
+	}
+	return render.Chart{
+		Title:  title,
+		Labels: labels,
+		Series: []render.Series{
+			{Name: "Sonne", Kind: render.Shade, Values: sun, Color: colorSun},
+			{Name: "Niederschlag", Kind: render.Bars, Values: precip, Color: colorRain, Right: true},
+			{Name: "Temperatur", Kind: render.Band, Values: low, Upper: high, Color: colorTemp},
+		},
+		LeftUnit:  unitTemp,
+		RightUnit: unitRain,
+		Footer:    "Quelle: " + o.Source,
+	}
+}
@@ -0,0 +1,54 @@
+package weather
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+
+import (
+	"signalbot_go/internal/modtest"
+	"signalbot_go/internal/state"
+	"testing"
+)
+
+// run with -race
+func TestConcurrentQuota(t *testing.T) {
+	st, err := state.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	weather, err := NewWeather(nopLog(), "./", st)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	// unknown location -> the quota is checked but nothing is fetched (the
+	// geocoding fails)
+	modtest.MockHTTP(t, modtest.Transport{})
+	msgs := modtest.Messages(20, "+49123", "+49123", "nowhere")
+	sender := &modtest.Sender{}
+	modtest.Fire(weather, sender, msgs...)
+
+	if resps := sender.Get(); len(resps) != len(msgs) {
+		t.Fatalf("Was: %v responses but should be %v", len(resps), len(msgs))
+	}
+	var c calls
+	if _, err := st.Load(quotaKey, &c); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if c.MonthCalls != uint(len(msgs)) {
+		t.Fatalf("Was: %v calls but should be %v", c.MonthCalls, len(msgs))
+	}
+}
@@ -17,62 +17,123 @@ package weather
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
 import (
-	"encoding/json"
+	"cmp"
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
-	"net/url"
-	"strconv"
+	"time"
 )
 
 var (
-	ErrNetwork error = errors.New("Error retreiving from network")
-	ErrLang    error = errors.New("Invalid language selected")
-	ErrUnit    error = errors.New("Invalid unitsystem selected")
-	ErrKey     error = errors.New("Invalid API-key. Expected to have 32 chars")
+	ErrNetwork  error = errors.New("Error retreiving from network")
+	ErrKey      error = errors.New("Invalid API-key. Expected to have 32 chars")
+	ErrProvider error = errors.New("unknown provider")
 )
 
-// fetches stuff. (e.g. if caching might be implemented at this level)
-// data members are only public to be able to (un)marshal them
+// selects the provider of a location and holds the settings of the
+// providers. Data members are only public to be able to (un)marshal them
 type Fetcher struct {
+	// provider of the locations without one (open-meteo if empty)
+	Default string `yaml:"default,omitempty"`
+	// API key of OpenWeatherMap (only needed if it is used)
+	OwmKey string `yaml:"owmKey,omitempty"`
 }
 
 // validate the fetcher
 func (f *Fetcher) Validate() error {
-	return nil
+	if f.OwmKey != "" && len(f.OwmKey) != 32 {
+		return ErrKey
+	}
+	_, err := f.provider("")
+	return err
+}
+
+// fetches the forecast from a weather service and normalizes it
+type Provider interface {
+	// the maximum amount of days and hours the provider forecasts
+	limits() (days int, hours int)
+	// get the content from the internet
+	getReader(pos Position, q query) (io.ReadCloser, error)
+	// parse the content from an arbitrary reader (can be a file, a network
+	// response body or something else). now is the time of the request.
+	getFromReader(r io.Reader, q query, now time.Time) (*Forecast, error)
 }
 
-// get the content from the internet
-const baseUrl string = "https://api.open-meteo.com/v1/forecast?"
+// names of the providers
+const (
+	providerOpenMeteo      = "open-meteo"
+	providerBrightSky      = "brightsky"
+	providerOpenWeatherMap = "openweathermap"
+)
 
-func (f *Fetcher) getReader(loc Position) (io.ReadCloser, error) {
-	params := url.Values{
-		"latitude":     {strconv.FormatFloat(float64(loc.Lat), 'f', 6, 32)},
-		"longitude":     {strconv.FormatFloat(float64(loc.Lon), 'f', 6, 32)},
-		"daily": {"temperature_2m_max", "temperature_2m_min", "precipitation_sum", "weather_code", "sunshine_duration", "wind_speed_10m_max", "wind_direction_10m_dominant", "uv_index_max"},
-		"hourly": {"temperature_2m", "precipitation", "snowfall", "weather_code"},
-		"current": {"temperature_2m", "relative_humidity_2m", "dew_point_2m", "cloud_cover", "wind_speed_10m", "wind_direction_10m", "wind_gusts_10m", "precipitation", "precipitation_probability", "snowfall", "weather_code"},
-		"forecast_days": {"7"},
-		"forecast_hours": {"5"},
+// the provider with the name (the default one if empty)
+func (f *Fetcher) provider(name string) (Provider, error) {
+	if name == "" {
+		name = f.Default
 	}
-	resp, err := http.Get(baseUrl + params.Encode())
+	switch name {
+	case "", providerOpenMeteo:
+		return openMeteo{}, nil
+	case providerBrightSky:
+		return brightSky{}, nil
+	case providerOpenWeatherMap:
+		if f.OwmKey == "" {
+			return nil, fmt.Errorf("%w (needed for %s)", ErrKey, name)
+		}
+		return openWeatherMap{key: f.OwmKey}, nil
+	}
+	return nil, fmt.Errorf("%w: %s (available: %s, %s, %s)", ErrProvider, name, providerOpenMeteo, providerBrightSky, providerOpenWeatherMap)
+}
+
+// fetch the forecast for the position from its provider
+func (f *Fetcher) forecast(pos Position, q query) (*Forecast, error) {
+	p, err := f.provider(pos.Provider)
 	if err != nil {
 		return nil, err
 	}
-	if resp.StatusCode != http.StatusOK {
-		return nil, ErrNetwork
+	days, hours := p.limits()
+	q, limited := q.limit(days, hours)
+	reader, err := p.getReader(pos, q)
+	if err != nil {
+		return nil, err
 	}
-	return resp.Body, nil
+	defer reader.Close()
+	resp, err := p.getFromReader(reader, q, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if limited {
+		name := cmp.Or(pos.Provider, f.Default, providerOpenMeteo)
+		resp.Notice = fmt.Sprintf("%s only forecasts %d days and %d hours", name, days, hours)
+	}
+
+	// the air quality is from open-meteo for all providers
+	if q.needsAir() {
+		air, err := f.air(pos, q)
+		if err != nil {
+			return nil, err
+		}
+		resp.setAir(air)
+	}
+	return resp, nil
 }
 
-// parse the content from an arbitrary reader (can be a file, a network
-// response body or something else)
-func (f *Fetcher) getFromReader(r io.ReadCloser) (*weatherResp, error) {
-	d := json.NewDecoder(r)
-	resp := &weatherResp{}
-	err := d.Decode(resp)
+// timeout of the requests to the APIs (including reading the response)
+const requestTimeout time.Duration = 30 * time.Second
+
+// get the body of the response to a GET request
+func get(url string) (io.ReadCloser, error) {
+	// the transport of the default client is used, so it can be replaced
+	// like with http.Get
+	client := http.Client{Transport: http.DefaultClient.Transport, Timeout: requestTimeout}
+	resp, err := client.Get(url)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return resp, nil
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, ErrNetwork
+	}
+	return resp.Body, nil
 }
@@ -17,11 +17,16 @@ package weather
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
 import (
+	"errors"
 	"io"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"log/slog"
+	"signalbot_go/internal/modtest"
+	"signalbot_go/internal/render"
 )
 
 func nopLog() *slog.Logger {
@@ -30,7 +35,7 @@ func nopLog() *slog.Logger {
 
 func TestFetcher(t *testing.T) {
 	log := nopLog()
-	weather, err := NewWeather(log, "./")
+	_, err := NewWeather(log, "./", nil)
 	if err != nil {
 		panic(err)
 	}
@@ -40,7 +45,7 @@ func TestFetcher(t *testing.T) {
 		panic(err)
 	}
 	defer f.Close()
-	resp, err := weather.Fetcher.getFromReader(f)
+	resp, err := openMeteo{}.getFromReader(f, query{}, time.Now())
 	if err != nil {
 		panic(err)
 	}
@@ -59,3 +64,116 @@ func TestFetcher(t *testing.T) {
 		t.Fatalf("formatting is wrong")
 	}
 }
+
+func TestTable(t *testing.T) {
+	_, err := NewWeather(nopLog(), "./", nil)
+	if err != nil {
+		panic(err)
+	}
+
+	f, err := os.Open("test1.json")
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	resp, err := openMeteo{}.getFromReader(f, query{}, time.Now())
+	if err != nil {
+		panic(err)
+	}
+
+	table := resp.Table("home")
+	if len(table.Rows) != 7 {
+		t.Fatalf("expected 7 rows, got %d", len(table.Rows))
+	}
+	want := []string{"Tue 08.10", "light shower rain", "13°C", "20°C", "3.0mm", "", "3"}
+	for i, w := range want {
+		if table.Rows[2][i] != w {
+			t.Fatalf("row 2 column %d: expected %q, got %q", i, w, table.Rows[2][i])
+		}
+	}
+}
+
+func TestCharts(t *testing.T) {
+	_, err := NewWeather(nopLog(), "./", nil)
+	if err != nil {
+		panic(err)
+	}
+
+	f, err := os.Open("test1.json")
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	resp, err := openMeteo{}.getFromReader(f, query{}, time.Now())
+	if err != nil {
+		panic(err)
+	}
+
+	hourly := resp.HourlyChart("home")
+	if len(hourly.Labels) != len(resp.Hourly) {
+		t.Fatalf("expected %d labels, got %d", len(resp.Hourly), len(hourly.Labels))
+	}
+	daily := resp.DailyChart("home")
+	if daily.Labels[0] != "Sun 06.10" {
+		t.Fatalf("expected label Sun 06.10, got %q", daily.Labels[0])
+	}
+	for _, c := range []render.Chart{hourly, daily} {
+		if _, err := c.Image(); err != nil {
+			t.Fatalf("Err: %v", err)
+		}
+	}
+}
+
+func TestProvider(t *testing.T) {
+	tests := []struct {
+		fetcher Fetcher
+		name    string
+		err     error
+	}{
+		{name: ""},
+		{name: providerBrightSky},
+		{fetcher: Fetcher{Default: providerBrightSky}, name: providerOpenMeteo},
+		{fetcher: Fetcher{Default: "dwd"}, name: "", err: ErrProvider},
+		{name: providerOpenWeatherMap, err: ErrKey},
+		{fetcher: Fetcher{OwmKey: strings.Repeat("k", 32)}, name: providerOpenWeatherMap},
+	}
+	for _, tt := range tests {
+		_, err := tt.fetcher.provider(tt.name)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%+v: Was: %v but should be: %v", tt, err, tt.err)
+		}
+	}
+	if err := (&Fetcher{OwmKey: "short"}).Validate(); !errors.Is(err, ErrKey) {
+		t.Fatalf("Was: %v but should be: %v", err, ErrKey)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	modtest.MockHTTP(t, modtest.Transport{"https://api.openweathermap.org/data/3.0/onecall": "owm.json"})
+	f := Fetcher{OwmKey: strings.Repeat("k", 32)}
+	pos := Position{Lat: 48.14, Lon: 11.58, Provider: providerOpenWeatherMap}
+
+	resp, err := f.forecast(pos, query{days: 16, hours: 100, fields: defaultFields})
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if resp.Notice != "openweathermap only forecasts 8 days and 48 hours" {
+		t.Fatalf("Was: %q", resp.Notice)
+	}
+	if len(resp.Daily) > 8 || len(resp.Hourly) > 48 {
+		t.Fatalf("Was: %d days and %d hours", len(resp.Daily), len(resp.Hourly))
+	}
+
+	// within the limits
+	resp, err = f.forecast(pos, query{days: 7, hours: 5, fields: defaultFields})
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if resp.Notice != "" {
+		t.Fatalf("Was: %q but should be empty", resp.Notice)
+	}
+
+	if q, limited := (query{days: 16, hours: -1}).limit(10, 240); !limited || q.days != 10 || q.hours != -1 {
+		t.Fatalf("Was: %+v %v", q, limited)
+	}
+}
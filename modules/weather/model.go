@@ -0,0 +1,513 @@
+package weather
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"signalbot_go/internal/render"
+)
+
+// the units of the values of a forecast (the providers convert to them)
+const (
+	unitTemp   = "°C"
+	unitRain   = "mm"
+	unitSnow   = "cm"
+	unitWind   = "km/h"
+	unitClouds = "%"
+	unitAir    = "µg/m³"
+	unitPollen = "grains/m³"
+)
+
+// unknown weather code
+const codeUnknown = -1
+
+// centimeters of snow per millimeter of precipitation (for providers only
+// reporting the precipitation)
+const snowRatio = 0.7
+
+// the forecast as normalized by the providers. Values the provider does not
+// know are NaN.
+type Forecast struct {
+	// printed below the forecast
+	Source string
+	// shown in front of the forecast (e.g. that the query was limited)
+	Notice  string
+	Current Current
+	Hourly  []Hour
+	Daily   []Day
+}
+
+// the current weather
+type Current struct {
+	Time time.Time
+	// WMO weather code
+	Code      int
+	Temp      float64
+	Humidity  float64
+	Clouds    float64
+	WindSpeed float64
+	WindDir   float64
+	WindGusts float64
+	Precip    float64
+	Snow      float64
+	// nil if not requested
+	Air *Air
+}
+
+// the current weather at the time with all values unknown
+func unknownCurrent(t time.Time) Current {
+	nan := math.NaN()
+	return Current{Time: t, Code: codeUnknown, Temp: nan, Humidity: nan, Clouds: nan, WindSpeed: nan, WindDir: nan, WindGusts: nan, Precip: nan, Snow: nan}
+}
+
+// the air quality and the pollen (the daily maximum for days)
+type Air struct {
+	// European air quality index
+	Aqi   float64
+	Pm25  float64
+	Pm10  float64
+	Ozone float64
+	Birch float64
+	Grass float64
+}
+
+// air with all values unknown
+func unknownAir() *Air {
+	nan := math.NaN()
+	return &Air{Aqi: nan, Pm25: nan, Pm10: nan, Ozone: nan, Birch: nan, Grass: nan}
+}
+
+// the category of the European air quality index
+func aqiLevel(v float64) string {
+	switch {
+	case v <= 20:
+		return "good"
+	case v <= 40:
+		return "fair"
+	case v <= 60:
+		return "moderate"
+	case v <= 80:
+		return "poor"
+	case v <= 100:
+		return "very poor"
+	}
+	return "extremely poor"
+}
+
+// a rough category of the pollen concentration
+func pollenLevel(v float64) string {
+	switch {
+	case v < 1:
+		return "none"
+	case v < 10:
+		return "low"
+	case v < 50:
+		return "moderate"
+	}
+	return "high"
+}
+
+// the air quality, empty if unknown
+func (a *Air) air() string {
+	if a == nil || !known(a.Aqi) {
+		return ""
+	}
+	ret := "AQI " + formatFloat(a.Aqi, 0) + " (" + aqiLevel(a.Aqi) + ")"
+	for _, v := range []struct {
+		name  string
+		value float64
+	}{{"PM2.5", a.Pm25}, {"PM10", a.Pm10}, {"O3", a.Ozone}} {
+		if known(v.value) {
+			ret += ", " + v.name + " " + formatFloat(v.value, 0) + unitAir
+		}
+	}
+	return ret
+}
+
+// the pollen, empty if unknown
+func (a *Air) pollen() string {
+	if a == nil {
+		return ""
+	}
+	ret := []string{}
+	for _, v := range []struct {
+		name  string
+		value float64
+	}{{"birch", a.Birch}, {"grass", a.Grass}} {
+		if known(v.value) {
+			ret = append(ret, v.name+" "+formatFloat(v.value, 0)+" ("+pollenLevel(v.value)+")")
+		}
+	}
+	return strings.Join(ret, ", ")
+}
+
+// the short form of the air quality and the pollen for the field
+func (a *Air) short(f field) []string {
+	ret := []string{}
+	if a == nil {
+		return ret
+	}
+	switch f {
+	case fieldAir:
+		if known(a.Aqi) {
+			ret = append(ret, "AQI:"+formatFloat(a.Aqi, 0))
+		}
+	case fieldPollen:
+		if known(a.Birch) {
+			ret = append(ret, "Birch:"+formatFloat(a.Birch, 0))
+		}
+		if known(a.Grass) {
+			ret = append(ret, "Grass:"+formatFloat(a.Grass, 0))
+		}
+	}
+	return ret
+}
+
+// the forecast of an hour
+type Hour struct {
+	Time      time.Time
+	Code      int
+	Temp      float64
+	Precip    float64
+	Snow      float64
+	WindSpeed float64
+	WindDir   float64
+	WindGusts float64
+	Uv        float64
+	// seconds of sunshine
+	Sunshine float64
+	// nil if not requested
+	Air *Air
+}
+
+// the forecast of a day
+type Day struct {
+	// midnight in the timezone of the location
+	Date      time.Time
+	Code      int
+	TempMin   float64
+	TempMax   float64
+	Precip    float64
+	Snow      float64
+	WindSpeed float64
+	WindDir   float64
+	Uv        float64
+	// seconds of sunshine and daylight
+	Sunshine float64
+	Daylight float64
+	// the maximum of the hours, nil if not requested
+	Air *Air
+}
+
+// the value is known
+func known(v float64) bool {
+	return !math.IsNaN(v)
+}
+
+func formatFloat(v float64, prec int) string {
+	return strconv.FormatFloat(v, 'f', prec, 64)
+}
+
+// the compass direction of the angle in degrees
+func windDir(deg float64) string {
+	return wind[int(math.Round(deg/360*float64(len(wind))))%len(wind)]
+}
+
+// icon of the weather code
+func icon(code int) string {
+	if code == codeUnknown {
+		return ""
+	}
+	return weatherCCs[uint(code)].icon
+}
+
+// description of the weather code
+func codeText(code int) string {
+	if code == codeUnknown {
+		return ""
+	}
+	return weatherCCs[uint(code)].text
+}
+
+// the selected fields of the day
+func (d Day) fields(fields []field) []string {
+	ret := []string{}
+	for _, f := range fields {
+		switch f {
+		case fieldTemp:
+			if known(d.TempMin) && known(d.TempMax) {
+				ret = append(ret, formatFloat(d.TempMin, 0)+unitTemp+" - "+formatFloat(d.TempMax, 0)+unitTemp)
+			}
+		case fieldRain:
+			if known(d.Precip) && d.Precip != 0 {
+				ret = append(ret, "R:"+formatFloat(d.Precip, 1)+unitRain)
+			}
+		case fieldSnow:
+			if known(d.Snow) && d.Snow != 0 {
+				ret = append(ret, "S:"+formatFloat(d.Snow, 1)+unitSnow)
+			}
+		case fieldWind:
+			if known(d.WindSpeed) && known(d.WindDir) {
+				ret = append(ret, "W:"+formatFloat(d.WindSpeed, 0)+unitWind+" "+windDir(d.WindDir))
+			}
+		case fieldUv:
+			if known(d.Uv) && d.Uv >= 0.5 {
+				ret = append(ret, "UV:"+formatFloat(d.Uv, 0))
+			}
+		case fieldSun:
+			if known(d.Sunshine) {
+				ret = append(ret, "Sun:"+formatFloat(d.Sunshine/3600, 1)+"h")
+			}
+		case fieldAir, fieldPollen:
+			ret = append(ret, d.Air.short(f)...)
+		}
+	}
+	return ret
+}
+
+// the selected fields of the hour
+func (h Hour) fields(fields []field) []string {
+	ret := []string{}
+	for _, f := range fields {
+		switch f {
+		case fieldTemp:
+			if known(h.Temp) {
+				ret = append(ret, formatFloat(h.Temp, 0)+unitTemp)
+			}
+		case fieldRain:
+			if known(h.Precip) && h.Precip != 0 {
+				ret = append(ret, "R:"+formatFloat(h.Precip, 1)+unitRain)
+			}
+		case fieldSnow:
+			if known(h.Snow) && h.Snow != 0 {
+				ret = append(ret, "S:"+formatFloat(h.Snow, 1)+unitSnow)
+			}
+		case fieldWind:
+			if known(h.WindSpeed) && known(h.WindDir) {
+				ret = append(ret, "W:"+formatFloat(h.WindSpeed, 0)+unitWind+" "+windDir(h.WindDir))
+			}
+		case fieldUv:
+			if known(h.Uv) && h.Uv >= 0.5 {
+				ret = append(ret, "UV:"+formatFloat(h.Uv, 0))
+			}
+		case fieldSun:
+			if known(h.Sunshine) {
+				ret = append(ret, "Sun:"+formatFloat(h.Sunshine/60, 0)+"min")
+			}
+		case fieldAir, fieldPollen:
+			ret = append(ret, h.Air.short(f)...)
+		}
+	}
+	return ret
+}
+
+// the current weather
+func (o *Forecast) writeCurrent(builder *strings.Builder) {
+	c := o.Current
+	builder.WriteString(icon(c.Code))
+	builder.WriteString(c.Time.Format(" Mon 02.01  "))
+	if known(c.Temp) {
+		builder.WriteString(strconv.FormatInt(int64(c.Temp), 10))
+		builder.WriteString(unitTemp)
+	}
+	builder.WriteRune('\n')
+
+	line := ""
+	if known(c.Humidity) {
+		line += "Humidity: " + formatFloat(c.Humidity, 0) + "% "
+	}
+	// TODO split into rain and shower?
+	if known(c.Precip) && c.Precip != 0 {
+		line += " P:" + formatFloat(c.Precip, 1) + unitRain
+	}
+	if known(c.Snow) && c.Snow != 0 {
+		line += " S:" + formatFloat(c.Snow, 1) + unitSnow
+	}
+	if line != "" {
+		builder.WriteString(line)
+		builder.WriteRune('\n')
+	}
+
+	if known(c.Clouds) {
+		builder.WriteString("Clouds: ")
+		builder.WriteString(formatFloat(c.Clouds, 0))
+		builder.WriteString(unitClouds)
+		builder.WriteRune('\n')
+	}
+
+	if known(c.WindSpeed) && known(c.WindDir) {
+		builder.WriteString("Wind: ")
+		builder.WriteString(formatFloat(c.WindSpeed, 0))
+		builder.WriteString(unitWind)
+		builder.WriteString(" ")
+		builder.WriteString(windDir(c.WindDir))
+		builder.WriteRune('\n')
+	}
+
+	if air := c.Air.air(); air != "" {
+		builder.WriteString("Air: ")
+		builder.WriteString(air)
+		builder.WriteRune('\n')
+	}
+	if pollen := c.Air.pollen(); pollen != "" {
+		builder.WriteString("Pollen: ")
+		builder.WriteString(pollen)
+		builder.WriteRune('\n')
+	}
+}
+
+// the hour
+func writeHour(builder *strings.Builder, h Hour, fields []field) {
+	builder.WriteString(icon(h.Code))
+	builder.WriteString(h.Time.Format(" 15:04 02.01  "))
+	builder.WriteString(strings.Join(h.fields(fields), " "))
+	builder.WriteRune('\n')
+}
+
+// the report with the default fields
+func (o *Forecast) String() string {
+	return o.report(defaultFields)
+}
+
+// the current weather, all days and all hours with the selected fields
+func (o *Forecast) report(fields []field) string {
+	builder := strings.Builder{}
+	o.writeCurrent(&builder)
+
+	builder.WriteRune('\n')
+	for _, d := range o.Daily {
+		builder.WriteString(icon(d.Code))
+		builder.WriteString(d.Date.Format(" Mon 02.01  "))
+		builder.WriteString(strings.Join(d.fields(fields), " "))
+		builder.WriteRune('\n')
+	}
+
+	builder.WriteRune('\n')
+	for _, h := range o.Hourly {
+		writeHour(&builder, h, fields)
+	}
+
+	builder.WriteRune('\n')
+	builder.WriteString("Quelle: ")
+	builder.WriteString(o.Source)
+	return builder.String()
+}
+
+// the n-th day (0 is today) in detail with its hours
+func (o *Forecast) day(n int, fields []field) (string, error) {
+	if n < 0 || n >= len(o.Daily) {
+		return "", fmt.Errorf("%w: day %d is not in the forecast", ErrDays, n)
+	}
+	d := o.Daily[n]
+
+	builder := strings.Builder{}
+	builder.WriteString(icon(d.Code))
+	builder.WriteString(d.Date.Format(" Mon 02.01 "))
+	builder.WriteString(codeText(d.Code))
+	builder.WriteRune('\n')
+	line := func(name string, value string) {
+		builder.WriteString(name)
+		builder.WriteString(": ")
+		builder.WriteString(value)
+		builder.WriteRune('\n')
+	}
+	for _, f := range fields {
+		switch f {
+		case fieldTemp:
+			if known(d.TempMin) && known(d.TempMax) {
+				line("Temperature", formatFloat(d.TempMin, 0)+unitTemp+" - "+formatFloat(d.TempMax, 0)+unitTemp)
+			}
+		case fieldRain:
+			if known(d.Precip) {
+				line("Rain", formatFloat(d.Precip, 1)+unitRain)
+			}
+		case fieldSnow:
+			if known(d.Snow) {
+				line("Snow", formatFloat(d.Snow, 1)+unitSnow)
+			}
+		case fieldWind:
+			if known(d.WindSpeed) && known(d.WindDir) {
+				line("Wind", formatFloat(d.WindSpeed, 0)+unitWind+" "+windDir(d.WindDir))
+			}
+		case fieldUv:
+			if known(d.Uv) {
+				line("UV", formatFloat(d.Uv, 0))
+			}
+		case fieldSun:
+			if known(d.Sunshine) && known(d.Daylight) {
+				line("Sun", formatFloat(d.Sunshine/3600, 1)+"h of "+formatFloat(d.Daylight/3600, 1)+"h")
+			} else if known(d.Sunshine) {
+				line("Sun", formatFloat(d.Sunshine/3600, 1)+"h")
+			}
+		case fieldAir:
+			if air := d.Air.air(); air != "" {
+				line("Air", air)
+			}
+		case fieldPollen:
+			if pollen := d.Air.pollen(); pollen != "" {
+				line("Pollen", pollen)
+			}
+		}
+	}
+
+	// the hours of the day
+	builder.WriteRune('\n')
+	y, m, dd := d.Date.Date()
+	for _, h := range o.Hourly {
+		hy, hm, hd := h.Time.Date()
+		if hy == y && hm == m && hd == dd {
+			writeHour(&builder, h, fields)
+		}
+	}
+
+	builder.WriteRune('\n')
+	builder.WriteString("Quelle: ")
+	builder.WriteString(o.Source)
+	return builder.String(), nil
+}
+
+// the daily forecast as table
+func (o *Forecast) Table(title string) render.Table {
+	table := render.Table{
+		Title:  title,
+		Header: []string{"Day", "Weather", "Min", "Max", "Rain", "Snow", "UV"},
+		Footer: "Quelle: " + o.Source,
+	}
+	value := func(v float64, prec int, u string, zero bool) string {
+		if !known(v) || (v == 0 && !zero) {
+			return ""
+		}
+		return formatFloat(v, prec) + u
+	}
+	for _, d := range o.Daily {
+		table.Rows = append(table.Rows, []string{
+			d.Date.Format("Mon 02.01"),
+			codeText(d.Code),
+			value(d.TempMin, 0, unitTemp, true),
+			value(d.TempMax, 0, unitTemp, true),
+			value(d.Precip, 1, unitRain, false),
+			value(d.Snow, 1, unitSnow, false),
+			value(d.Uv, 0, "", false),
+		})
+	}
+	return table
+}
@@ -0,0 +1,79 @@
+package weather
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"encoding/json"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// forecasts of open-meteo.com
+type openMeteo struct{}
+
+const baseUrl string = "https://api.open-meteo.com/v1/forecast?"
+
+func (openMeteo) limits() (int, int) {
+	return 16, 16 * 24
+}
+
+// the daily and hourly variables of the API needed for the field
+var fieldVars map[field]struct{ daily, hourly []string } = map[field]struct{ daily, hourly []string }{
+	fieldTemp: {daily: []string{"temperature_2m_max", "temperature_2m_min"}, hourly: []string{"temperature_2m"}},
+	fieldRain: {daily: []string{"precipitation_sum"}, hourly: []string{"precipitation"}},
+	fieldSnow: {daily: []string{"snowfall_sum"}, hourly: []string{"snowfall"}},
+	fieldWind: {daily: []string{"wind_speed_10m_max", "wind_direction_10m_dominant"}, hourly: []string{"wind_speed_10m", "wind_direction_10m", "wind_gusts_10m"}},
+	fieldUv:   {daily: []string{"uv_index_max"}, hourly: []string{"uv_index"}},
+	fieldSun:  {daily: []string{"sunshine_duration", "daylight_duration"}, hourly: []string{"sunshine_duration"}},
+}
+
+// the parameters of the API request
+func (openMeteo) params(pos Position, q query) url.Values {
+	daily := []string{"weather_code"}
+	hourly := []string{"weather_code"}
+	for _, f := range q.fields {
+		daily = append(daily, fieldVars[f].daily...)
+		hourly = append(hourly, fieldVars[f].hourly...)
+	}
+	params := url.Values{
+		"latitude":      {strconv.FormatFloat(float64(pos.Lat), 'f', 6, 32)},
+		"longitude":     {strconv.FormatFloat(float64(pos.Lon), 'f', 6, 32)},
+		"timezone":      {"auto"},
+		"daily":         daily,
+		"hourly":        hourly,
+		"current":       {"temperature_2m", "relative_humidity_2m", "dew_point_2m", "cloud_cover", "wind_speed_10m", "wind_direction_10m", "wind_gusts_10m", "precipitation", "precipitation_probability", "snowfall", "weather_code"},
+		"forecast_days": {strconv.Itoa(q.days)},
+	}
+	if q.hours >= 0 {
+		params.Set("forecast_hours", strconv.Itoa(q.hours))
+	}
+	return params
+}
+
+func (o openMeteo) getReader(pos Position, q query) (io.ReadCloser, error) {
+	return get(baseUrl + o.params(pos, q).Encode())
+}
+
+func (openMeteo) getFromReader(r io.Reader, q query, now time.Time) (*Forecast, error) {
+	resp := &weatherResp{}
+	if err := json.NewDecoder(r).Decode(resp); err != nil {
+		return nil, err
+	}
+	return resp.forecast(), nil
+}
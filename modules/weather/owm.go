@@ -0,0 +1,238 @@
+package weather
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"encoding/json"
+	"io"
+	"math"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// forecasts of OpenWeatherMap (One Call API 3.0, needs an API key). Has no
+// sunshine duration and at most 48 hours and 8 days.
+type openWeatherMap struct {
+	key string
+}
+
+const owmUrl string = "https://api.openweathermap.org/data/3.0/onecall?"
+
+func (openWeatherMap) limits() (int, int) {
+	return 8, 48
+}
+
+// m/s to km/h
+const msToKmh = 3.6
+
+type owmResp struct {
+	Timezone string     `json:"timezone"`
+	Current  owmCurrent `json:"current"`
+	Hourly   []owmHour  `json:"hourly"`
+	Daily    []owmDay   `json:"daily"`
+}
+
+type owmWeather struct {
+	Id int `json:"id"`
+}
+
+// precipitation of the last hour
+type owmPrecip struct {
+	H1 float64 `json:"1h"`
+}
+
+type owmCurrent struct {
+	Dt        int64        `json:"dt"`
+	Temp      float64      `json:"temp"`
+	Humidity  float64      `json:"humidity"`
+	Clouds    float64      `json:"clouds"`
+	WindSpeed float64      `json:"wind_speed"`
+	WindDeg   float64      `json:"wind_deg"`
+	WindGust  float64      `json:"wind_gust"`
+	Weather   []owmWeather `json:"weather"`
+	Rain      owmPrecip    `json:"rain"`
+	Snow      owmPrecip    `json:"snow"`
+}
+
+type owmHour struct {
+	Dt        int64        `json:"dt"`
+	Temp      float64      `json:"temp"`
+	Uvi       float64      `json:"uvi"`
+	WindSpeed float64      `json:"wind_speed"`
+	WindDeg   float64      `json:"wind_deg"`
+	WindGust  float64      `json:"wind_gust"`
+	Weather   []owmWeather `json:"weather"`
+	Rain      owmPrecip    `json:"rain"`
+	Snow      owmPrecip    `json:"snow"`
+}
+
+type owmDay struct {
+	Dt      int64 `json:"dt"`
+	Sunrise int64 `json:"sunrise"`
+	Sunset  int64 `json:"sunset"`
+	Temp    struct {
+		Min float64 `json:"min"`
+		Max float64 `json:"max"`
+	} `json:"temp"`
+	Uvi       float64      `json:"uvi"`
+	WindSpeed float64      `json:"wind_speed"`
+	WindDeg   float64      `json:"wind_deg"`
+	Weather   []owmWeather `json:"weather"`
+	Rain      float64      `json:"rain"`
+	Snow      float64      `json:"snow"`
+}
+
+// the parameters of the API request
+func (o openWeatherMap) params(pos Position) url.Values {
+	return url.Values{
+		"lat":     {strconv.FormatFloat(float64(pos.Lat), 'f', 6, 32)},
+		"lon":     {strconv.FormatFloat(float64(pos.Lon), 'f', 6, 32)},
+		"units":   {"metric"},
+		"exclude": {"minutely,alerts"},
+		"appid":   {o.key},
+	}
+}
+
+func (o openWeatherMap) getReader(pos Position, q query) (io.ReadCloser, error) {
+	return get(owmUrl + o.params(pos).Encode())
+}
+
+func (openWeatherMap) getFromReader(r io.Reader, q query, now time.Time) (*Forecast, error) {
+	resp := &owmResp{}
+	if err := json.NewDecoder(r).Decode(resp); err != nil {
+		return nil, err
+	}
+	return resp.forecast(q), nil
+}
+
+// the WMO weather code of the first OpenWeatherMap condition
+func owmCode(weather []owmWeather) int {
+	if len(weather) == 0 {
+		return codeUnknown
+	}
+	id := weather[0].Id
+	switch {
+	case id >= 200 && id < 300:
+		return 95
+	case id == 300:
+		return 51
+	case id == 301:
+		return 53
+	case id > 301 && id < 400:
+		return 55
+	case id == 500:
+		return 61
+	case id == 501:
+		return 63
+	case id > 501 && id < 510:
+		return 65
+	case id == 511:
+		return 66
+	case id == 520:
+		return 80
+	case id == 521:
+		return 81
+	case id > 521 && id < 600:
+		return 82
+	case id == 600:
+		return 71
+	case id == 601:
+		return 73
+	case id == 602:
+		return 75
+	case id > 602 && id < 620:
+		// sleet and rain and snow
+		return 67
+	case id == 620:
+		return 85
+	case id > 620 && id < 700:
+		return 86
+	case id == 701 || id == 711 || id == 721 || id == 741:
+		return 45
+	case id == 800:
+		return 0
+	case id == 801:
+		return 1
+	case id == 802:
+		return 2
+	case id == 803 || id == 804:
+		return 3
+	}
+	return codeUnknown
+}
+
+// normalize the response (to the requested amount of days and hours)
+func (o *owmResp) forecast(q query) *Forecast {
+	loc, err := time.LoadLocation(o.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+	c := o.Current
+	f := &Forecast{
+		Source: "OpenWeatherMap",
+		Current: Current{
+			Time:      time.Unix(c.Dt, 0).In(loc),
+			Code:      owmCode(c.Weather),
+			Temp:      c.Temp,
+			Humidity:  c.Humidity,
+			Clouds:    c.Clouds,
+			WindSpeed: c.WindSpeed * msToKmh,
+			WindDir:   c.WindDeg,
+			WindGusts: c.WindGust * msToKmh,
+			Precip:    c.Rain.H1 + c.Snow.H1,
+			Snow:      c.Snow.H1 * snowRatio,
+		},
+	}
+	for _, h := range o.Hourly {
+		if q.hours >= 0 && len(f.Hourly) >= q.hours {
+			break
+		}
+		f.Hourly = append(f.Hourly, Hour{
+			Time:      time.Unix(h.Dt, 0).In(loc),
+			Code:      owmCode(h.Weather),
+			Temp:      h.Temp,
+			Precip:    h.Rain.H1 + h.Snow.H1,
+			Snow:      h.Snow.H1 * snowRatio,
+			WindSpeed: h.WindSpeed * msToKmh,
+			WindDir:   h.WindDeg,
+			WindGusts: h.WindGust * msToKmh,
+			Uv:        h.Uvi,
+			Sunshine:  math.NaN(),
+		})
+	}
+	for _, d := range o.Daily {
+		if len(f.Daily) >= q.days {
+			break
+		}
+		y, m, day := time.Unix(d.Dt, 0).In(loc).Date()
+		f.Daily = append(f.Daily, Day{
+			Date:      time.Date(y, m, day, 0, 0, 0, 0, loc),
+			Code:      owmCode(d.Weather),
+			TempMin:   d.Temp.Min,
+			TempMax:   d.Temp.Max,
+			Precip:    d.Rain + d.Snow,
+			Snow:      d.Snow * snowRatio,
+			WindSpeed: d.WindSpeed * msToKmh,
+			WindDir:   d.WindDeg,
+			Uv:        d.Uvi,
+			Sunshine:  math.NaN(),
+			Daylight:  float64(d.Sunset - d.Sunrise),
+		})
+	}
+	return f
+}
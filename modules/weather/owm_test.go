@@ -0,0 +1,77 @@
+package weather
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"math"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenWeatherMap(t *testing.T) {
+	f, err := os.Open("owm.json")
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	resp, err := openWeatherMap{}.getFromReader(f, query{days: 7, hours: 5, fields: allFields}, time.Now())
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	c := resp.Current
+	if c.Code != 2 || c.Temp != 13.6 || math.Abs(c.WindSpeed-10.8) > 1e-9 || math.Abs(c.WindGusts-23.4) > 1e-9 {
+		t.Fatalf("wrong current weather: %+v", c)
+	}
+	if c.Time.Format("15:04 MST") != "14:30 CEST" {
+		t.Fatalf("wrong timezone: %v", c.Time)
+	}
+	if len(resp.Hourly) != 5 {
+		t.Fatalf("expected 5 hours, got %d", len(resp.Hourly))
+	}
+	if h := resp.Hourly[4]; h.Code != 63 || h.Precip != 1.5 || h.Snow != 0 || !math.IsNaN(h.Sunshine) {
+		t.Fatalf("wrong hour: %+v", h)
+	}
+	if len(resp.Daily) != 7 {
+		t.Fatalf("expected 7 days, got %d", len(resp.Daily))
+	}
+	if d := resp.Daily[0]; d.Date.Format(time.DateOnly) != "2024-10-06" || d.Code != 63 || d.Precip != 4.5 || d.Daylight != (11*60+27)*60 {
+		t.Fatalf("wrong day: %+v", d)
+	}
+	if d := resp.Daily[1]; d.Code != 73 || math.Abs(d.Snow-2.8) > 1e-9 || d.TempMin != 4 {
+		t.Fatalf("wrong day: %+v", d)
+	}
+
+	out := resp.report(allFields)
+	if !strings.HasSuffix(out, "Quelle: OpenWeatherMap") {
+		t.Fatalf("missing source:\n%s", out)
+	}
+}
+
+func TestOwmCode(t *testing.T) {
+	tests := map[int]int{211: 95, 302: 55, 502: 65, 511: 66, 522: 82, 602: 75, 613: 67, 622: 86, 741: 45, 781: codeUnknown, 800: 0, 804: 3}
+	for id, code := range tests {
+		if got := owmCode([]owmWeather{{Id: id}}); got != code {
+			t.Errorf("%d: expected %d, got %d", id, code, got)
+		}
+	}
+	if got := owmCode(nil); got != codeUnknown {
+		t.Errorf("expected %d, got %d", codeUnknown, got)
+	}
+}
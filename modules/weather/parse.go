@@ -18,8 +18,6 @@ package weather
 
 import (
 	"math"
-	"strconv"
-	"strings"
 	"time"
 )
 
@@ -40,6 +38,11 @@ type weatherHour struct {
 	Temperature_2m []float64
 	Precipitation []float64
 	Snowfall []float64
+	Sunshine_duration []float64
+	Wind_speed_10m []float64
+	Wind_direction_10m []float64
+	Wind_gusts_10m []float64
+	Uv_index []float64
 }
 type weatherHourU struct {
 	Time string
@@ -48,6 +51,11 @@ type weatherHourU struct {
 	Temperature_2m string
 	Precipitation string
 	Snowfall string
+	Sunshine_duration string
+	Wind_speed_10m string
+	Wind_direction_10m string
+	Wind_gusts_10m string
+	Uv_index string
 }
 
 type weatherDaily struct {
@@ -62,6 +70,7 @@ type weatherDaily struct {
 	Sunshine_duration []float64
 	Wind_speed_10m_max []float64
 	Wind_direction_10m_dominant []float64
+	Daylight_duration []float64
 }
 type weatherDailyU struct {
 	Time string
@@ -75,6 +84,7 @@ type weatherDailyU struct {
 	Sunshine_duration string
 	Wind_speed_10m_max string
 	Wind_direction_10m_dominant string
+	Daylight_duration string
 }
 
 type weatherCurr struct {
@@ -121,116 +131,90 @@ type weatherResp struct {
 // TODO len(weather) > 1 => warn + write json to file
 
 var weatherDateTimeFormat string = "2006-01-02T15:04"
+var weatherDateFormat string = "2006-01-02"
 
-func (o *weatherResp) String() string {
-	builder := strings.Builder{}
-	date, err := time.Parse(weatherDateTimeFormat, o.Current.Time)
-	if err != nil {
-		// TODO log warning about invalid timestamp
+// the i-th value, NaN if the API returned less values
+func value(vals []float64, i int) float64 {
+	if i < 0 || i >= len(vals) {
+		return math.NaN()
+	}
+	return vals[i]
+}
+
+// the i-th weather code, codeUnknown if the API returned less values
+func code(codes []int, i int) int {
+	if i < 0 || i >= len(codes) {
+		return codeUnknown
 	}
+	return codes[i]
+}
+
+// the timezone of the response (UTC if it is invalid)
+func (o *weatherResp) location() *time.Location {
 	tz, err := time.LoadLocation(o.Timezone)
-	if err == nil {
-		date = date.In(tz)
-	} else {
-		// TODO log warning about invalid TZ, default to UTC
+	if err != nil {
+		return time.UTC
 	}
+	return tz
+}
 
-	builder.WriteString(weatherCCs[uint(o.Current.Weather_code)].icon)
-	// builder.WriteString(date.Format(" Mon 02.01 15:04:05 "))
-	builder.WriteString(date.Format(" Mon 02.01  "))
-	builder.WriteString(strconv.FormatInt(int64(o.Current.Temperature_2m), 10))
-	builder.WriteString(o.CurrentU.Temperature_2m)
-	builder.WriteRune('\n')
-
-	builder.WriteString("Humidity: ")
-	builder.WriteString(strconv.FormatFloat(float64(o.Current.Relative_humidity_2m), 'f', 0, 32))
-	builder.WriteRune('%')
-	builder.WriteRune(' ')
-	// TODO split into rain and shower?
-	if o.Current.Precipitation != 0 {
-		builder.WriteString(" P:")
-		builder.WriteString(strconv.FormatFloat(float64(o.Current.Precipitation), 'f', 1, 32))
-		builder.WriteString(o.CurrentU.Precipitation)
+// parse a time of the response (which is in the timezone of the response)
+func (o *weatherResp) parseTime(layout string, s string) time.Time {
+	date, err := time.ParseInLocation(layout, s, o.location())
+	if err != nil {
+		// TODO log warning about invalid timestamp
 	}
-	if o.Current.Snowfall != 0 {
-		builder.WriteString(" S:")
-		builder.WriteString(strconv.FormatFloat(float64(o.Current.Snowfall), 'f', 1, 32))
-		builder.WriteString(o.CurrentU.Snowfall)
+	return date
+}
+
+// normalize the response
+func (o *weatherResp) forecast() *Forecast {
+	c := o.Current
+	f := &Forecast{
+		Source: "open-meteo.com",
+		Current: Current{
+			Time:      o.parseTime(weatherDateTimeFormat, c.Time),
+			Code:      c.Weather_code,
+			Temp:      c.Temperature_2m,
+			Humidity:  c.Relative_humidity_2m,
+			Clouds:    c.Cloud_cover,
+			WindSpeed: c.Wind_speed_10m,
+			WindDir:   c.Wind_direction_10m,
+			WindGusts: c.Wind_gusts_10m,
+			Precip:    c.Precipitation,
+			Snow:      c.Snowfall,
+		},
 	}
-	builder.WriteRune('\n')
-
-	builder.WriteString("Clouds: ")
-	builder.WriteString(strconv.FormatFloat(float64(o.Current.Cloud_cover), 'f', 0, 32))
-	builder.WriteString(o.CurrentU.Cloud_cover)
-	builder.WriteRune('\n')
-
-	builder.WriteString("Wind: ")
-	builder.WriteString(strconv.FormatFloat(float64(o.Current.Wind_speed_10m), 'f', 0, 32))
-	builder.WriteString(o.CurrentU.Wind_speed_10m)
-	builder.WriteString(" ")
-	builder.WriteString(wind[int(math.Round(o.Current.Wind_direction_10m/360*float64(len(wind))))])
-	builder.WriteRune('\n')
-
-	// daily (max 7)
-	builder.WriteRune('\n')
-	for i := 0; i < 7; i++ {
-		date, err := time.Parse(weatherDateTimeFormat, o.Daily.Time[i])
-		if err != nil {
-			// TODO log warning about invalid timestamp
-		}
-		date = date.In(tz)
-
-		builder.WriteString(weatherCCs[uint(o.Daily.Weather_code[i])].icon)
-		builder.WriteString(date.Format(" Mon 02.01  "))
-		builder.WriteString(strconv.FormatFloat(float64(o.Daily.Temperature_2m_min[i]), 'f', 0, 32))
-		builder.WriteString(o.DailyU.Temperature_2m_min)
-		builder.WriteString(" - ")
-		builder.WriteString(strconv.FormatFloat(float64(o.Daily.Temperature_2m_max[i]), 'f', 0, 32))
-		builder.WriteString(o.DailyU.Temperature_2m_max)
-		if len(o.Daily.Precipitation_sum) > i {
-			if o.Daily.Precipitation_sum[i] != 0 {
-				builder.WriteString(" R:")
-				builder.WriteString(strconv.FormatFloat(o.Daily.Precipitation_sum[i], 'f', 1, 32))
-				builder.WriteString(o.DailyU.Precipitation_sum)
-			}
-		}
-		if len(o.Daily.Snowfall_sum) > i {
-			if o.Daily.Snowfall_sum[i] != 0 {
-				builder.WriteString(" S:")
-				builder.WriteString(strconv.FormatFloat(o.Daily.Snowfall_sum[i], 'f', 1, 32))
-				builder.WriteString(o.DailyU.Snowfall_sum)
-			}
-		}
-		builder.WriteRune('\n')
+	h := o.Hourly
+	for i, t := range h.Time {
+		f.Hourly = append(f.Hourly, Hour{
+			Time:      o.parseTime(weatherDateTimeFormat, t),
+			Code:      code(h.Weather_code, i),
+			Temp:      value(h.Temperature_2m, i),
+			Precip:    value(h.Precipitation, i),
+			Snow:      value(h.Snowfall, i),
+			WindSpeed: value(h.Wind_speed_10m, i),
+			WindDir:   value(h.Wind_direction_10m, i),
+			WindGusts: value(h.Wind_gusts_10m, i),
+			Uv:        value(h.Uv_index, i),
+			Sunshine:  value(h.Sunshine_duration, i),
+		})
 	}
-
-	// hourly (max 5)
-	builder.WriteRune('\n')
-	for i := 0; i < 5; i++ {
-		date, err := time.Parse(weatherDateTimeFormat, o.Hourly.Time[i])
-		if err != nil {
-			// TODO log warning about invalid timestamp
-		}
-		date = date.In(tz)
-
-		builder.WriteString(weatherCCs[uint(o.Hourly.Weather_code[i])].icon)
-		builder.WriteString(date.Format(" 15:04 02.01  "))
-		builder.WriteString(strconv.FormatFloat(float64(o.Hourly.Temperature_2m[i]), 'f', 0, 32))
-		builder.WriteString(o.HourlyU.Temperature_2m)
-		if o.Hourly.Precipitation[i] != 0 {
-			builder.WriteString(" R:")
-			builder.WriteString(strconv.FormatFloat(o.Hourly.Precipitation[i], 'f', 1, 32))
-			builder.WriteString(o.HourlyU.Precipitation)
-		}
-		if o.Hourly.Snowfall[i] != 0 {
-			builder.WriteString(" S:")
-			builder.WriteString(strconv.FormatFloat(o.Hourly.Snowfall[i], 'f', 1, 32))
-			builder.WriteString(o.HourlyU.Snowfall)
-		}
-		builder.WriteRune('\n')
+	d := o.Daily
+	for i, t := range d.Time {
+		f.Daily = append(f.Daily, Day{
+			Date:      o.parseTime(weatherDateFormat, t),
+			Code:      code(d.Weather_code, i),
+			TempMin:   value(d.Temperature_2m_min, i),
+			TempMax:   value(d.Temperature_2m_max, i),
+			Precip:    value(d.Precipitation_sum, i),
+			Snow:      value(d.Snowfall_sum, i),
+			WindSpeed: value(d.Wind_speed_10m_max, i),
+			WindDir:   value(d.Wind_direction_10m_dominant, i),
+			Uv:        value(d.Uv_index_max, i),
+			Sunshine:  value(d.Sunshine_duration, i),
+			Daylight:  value(d.Daylight_duration, i),
+		})
 	}
-
-	builder.WriteRune('\n')
-	builder.WriteString("Quelle: open-meteo.com")
-	return builder.String()
+	return f
 }
@@ -0,0 +1,381 @@
+package weather
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"signalbot_go/internal/signalsender"
+	"signalbot_go/signalcli"
+
+	"github.com/alexflint/go-arg"
+)
+
+var ErrLocation error = errors.New("location is unknown")
+
+// a resolved location
+type place struct {
+	// name to show (e.g. the name found by the geocoding)
+	Name     string   `yaml:"name"`
+	Position Position `yaml:"pos"`
+}
+
+// coordinates like "48.14,11.58"
+var coordsRe *regexp.Regexp = regexp.MustCompile(`^\s*(-?\d{1,3}(?:\.\d+)?)\s*,\s*(-?\d{1,3}(?:\.\d+)?)\s*$`)
+
+// query parameters of maps links which contain the coordinates (e.g. the link
+// sent when sharing a location: "https://maps.google.com/maps?q=48.14%2C11.58")
+var mapsParams []string = []string{"q", "query", "ll"}
+
+// parse coordinates from the text. The text either only consists of the
+// coordinates or is a maps link containing them.
+func parseCoords(s string) (Position, bool) {
+	if u, err := url.Parse(strings.TrimSpace(s)); err == nil && (u.Scheme == "http" || u.Scheme == "https") {
+		for _, param := range mapsParams {
+			if pos, ok := parseCoords(u.Query().Get(param)); ok {
+				return pos, true
+			}
+		}
+		return Position{}, false
+	}
+	match := coordsRe.FindStringSubmatch(s)
+	if match == nil {
+		return Position{}, false
+	}
+	lat, err := strconv.ParseFloat(match[1], 32)
+	if err != nil || lat < -90 || lat > 90 {
+		return Position{}, false
+	}
+	lon, err := strconv.ParseFloat(match[2], 32)
+	if err != nil || lon < -180 || lon > 180 {
+		return Position{}, false
+	}
+	return Position{Lat: float32(lat), Lon: float32(lon)}, true
+}
+
+const geocodeUrl string = "https://geocoding-api.open-meteo.com/v1/search?"
+
+// response of the geocoding API
+type geocodeResp struct {
+	Results []struct {
+		Name      string
+		Latitude  float32
+		Longitude float32
+		Country   string
+		Admin1    string
+	}
+}
+
+// look up a place name with the geocoding API
+func (f *Fetcher) geocode(name string) (place, error) {
+	params := url.Values{
+		"name":     {name},
+		"count":    {"1"},
+		"language": {"de"},
+		"format":   {"json"},
+	}
+	body, err := get(geocodeUrl + params.Encode())
+	if err != nil {
+		return place{}, err
+	}
+	defer body.Close()
+	g := geocodeResp{}
+	if err := json.NewDecoder(body).Decode(&g); err != nil {
+		return place{}, err
+	}
+	if len(g.Results) == 0 {
+		return place{}, fmt.Errorf("%w: %v", ErrLocation, name)
+	}
+	res := g.Results[0]
+	details := []string{}
+	for _, d := range []string{res.Admin1, res.Country} {
+		if d != "" && d != res.Name {
+			details = append(details, d)
+		}
+	}
+	p := place{Name: res.Name, Position: Position{Lat: res.Latitude, Lon: res.Longitude}}
+	if len(details) > 0 {
+		p.Name += " (" + strings.Join(details, ", ") + ")"
+	}
+	return p, nil
+}
+
+// a shared location is a maps link with coordinates. Implements
+// signalserver.LocationHandler, so a shared location works without the prefix.
+func (r *Weather) IsLocation(line string) bool {
+	s := strings.TrimSpace(line)
+	if !strings.HasPrefix(s, "http://") && !strings.HasPrefix(s, "https://") {
+		return false
+	}
+	_, ok := parseCoords(s)
+	return ok
+}
+
+// key and schema version of the personal places and the geocoding cache in
+// the state
+const (
+	placesKey       string = "places"
+	placesVersion   uint   = 1
+	geocacheKey     string = "geocache"
+	geocacheVersion uint   = 2
+)
+
+// limits of the geocoding cache, older entries are dropped
+const (
+	geocacheSize int           = 500
+	geocacheTTL  time.Duration = 90 * 24 * time.Hour
+)
+
+// geocoded place in the cache
+type cachedPlace struct {
+	place `yaml:",inline"`
+	// when the place was geocoded (zero for the entries of version 1)
+	Time time.Time `yaml:"time,omitempty"`
+}
+
+// resolve a location given by the sender. Tries the places saved by the
+// sender, the configured locations, coordinates (also from a maps link) and
+// finally the geocoding (cached).
+func (r *Weather) resolve(sender string, where string) (place, error) {
+	r.placesMu.Lock()
+	p, ok := r.lookup(sender, where)
+	r.placesMu.Unlock()
+	if ok {
+		return p, nil
+	}
+	if where == "" {
+		return place{}, fmt.Errorf("location %v is unknown", where)
+	}
+
+	// don't block the other handlers during the request
+	p, err := r.Fetcher.geocode(where)
+	if errors.Is(err, ErrLocation) {
+		return place{}, fmt.Errorf("location %v is unknown", where)
+	} else if err != nil {
+		return place{}, fmt.Errorf("Error: %w", err)
+	}
+
+	r.placesMu.Lock()
+	r.cache(strings.ToLower(where), p)
+	err = r.SaveState(geocacheKey, geocacheVersion, r.geocache)
+	r.placesMu.Unlock()
+	if err != nil {
+		r.Log.Error(fmt.Sprintf("Error saving the geocoding cache: %v", err))
+	}
+	return p, nil
+}
+
+// resolve a location without the geocoding API. r.placesMu has to be held.
+func (r *Weather) lookup(sender string, where string) (place, bool) {
+	if pos, ok := r.places[sender][strings.ToLower(where)]; ok {
+		return place{Name: where, Position: pos}, true
+	}
+	if pos, ok := r.Locations[where]; ok {
+		return place{Name: where, Position: pos}, true
+	}
+	if pos, ok := parseCoords(where); ok {
+		return place{Name: fmt.Sprintf("%.4f, %.4f", pos.Lat, pos.Lon), Position: pos}, true
+	}
+	c, ok := r.geocache[strings.ToLower(where)]
+	if !ok || time.Since(c.Time) > geocacheTTL {
+		return place{}, false
+	}
+	return c.place, true
+}
+
+// add a geocoded place to the cache and drop the expired entries and the
+// oldest ones above geocacheSize. r.placesMu has to be held.
+func (r *Weather) cache(where string, p place) {
+	now := time.Now()
+	r.geocache[where] = cachedPlace{place: p, Time: now}
+	maps.DeleteFunc(r.geocache, func(_ string, c cachedPlace) bool {
+		return now.Sub(c.Time) > geocacheTTL
+	})
+	if over := len(r.geocache) - geocacheSize; over > 0 {
+		keys := slices.SortedFunc(maps.Keys(r.geocache), func(a, b string) int {
+			return r.geocache[a].Time.Compare(r.geocache[b].Time)
+		})
+		for _, k := range keys[:over] {
+			delete(r.geocache, k)
+		}
+	}
+}
+
+// load the personal places and the geocoding cache
+func (r *Weather) loadPlaces() error {
+	r.placesMu.Lock()
+	defer r.placesMu.Unlock()
+	if _, err := r.LoadState(placesKey, &r.places); err != nil {
+		return err
+	}
+	version, err := r.LoadState(geocacheKey, &r.geocache)
+	if err != nil {
+		return err
+	}
+	if r.places == nil {
+		r.places = make(map[string]map[string]Position)
+	}
+	if r.geocache == nil {
+		r.geocache = make(map[string]cachedPlace)
+	}
+	if version < 2 {
+		// the entries had no time, they expire like new ones
+		now := time.Now()
+		for k, c := range r.geocache {
+			c.Time = now
+			r.geocache[k] = c
+		}
+	}
+	return nil
+}
+
+// specifies the arguments of the save command
+type saveArgs struct {
+	Name  string `arg:"positional,required"`
+	Where string `arg:"positional,required"`
+}
+
+// specifies the arguments of the forget command
+type forgetArgs struct {
+	Name string `arg:"positional,required"`
+}
+
+// save a personal location of the sender
+func (r *Weather) handleSave(m *signalcli.Message, rest string, signal signalsender.SignalSender, virtRcv func(*signalcli.Message)) {
+	var args saveArgs
+	parser, err := arg.NewParser(arg.Config{}, &args)
+	if err != nil {
+		r.Log.Error(fmt.Sprintf("newParser -> %v", err))
+		return
+	}
+	mArgs := *m
+	mArgs.Message = rest
+	if err := r.Module.Handle(&mArgs, signal, virtRcv, parser); err != nil {
+		return
+	}
+
+	p, err := r.resolve(m.Sender, args.Where)
+	if err != nil {
+		errMsg := err.Error()
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+		return
+	}
+
+	r.placesMu.Lock()
+	if _, ok := r.places[m.Sender]; !ok {
+		r.places[m.Sender] = make(map[string]Position)
+	}
+	r.places[m.Sender][strings.ToLower(args.Name)] = p.Position
+	err = r.SaveState(placesKey, placesVersion, r.places)
+	r.placesMu.Unlock()
+	if err != nil {
+		errMsg := fmt.Sprintf("Error: %v", err)
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+		return
+	}
+
+	resp := fmt.Sprintf("Saved %s: %s", strings.ToLower(args.Name), p.Name)
+	if coords := fmt.Sprintf("%.4f, %.4f", p.Position.Lat, p.Position.Lon); coords != p.Name {
+		resp += " (" + coords + ")"
+	}
+	if _, err := signal.Respond(resp, nil, m, true); err != nil {
+		errMsg := fmt.Sprintf("Error: %v", err)
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+	}
+}
+
+// remove a personal location of the sender
+func (r *Weather) handleForget(m *signalcli.Message, rest string, signal signalsender.SignalSender, virtRcv func(*signalcli.Message)) {
+	var args forgetArgs
+	parser, err := arg.NewParser(arg.Config{}, &args)
+	if err != nil {
+		r.Log.Error(fmt.Sprintf("newParser -> %v", err))
+		return
+	}
+	mArgs := *m
+	mArgs.Message = rest
+	if err := r.Module.Handle(&mArgs, signal, virtRcv, parser); err != nil {
+		return
+	}
+
+	name := strings.ToLower(args.Name)
+	r.placesMu.Lock()
+	_, ok := r.places[m.Sender][name]
+	if ok {
+		delete(r.places[m.Sender], name)
+		if len(r.places[m.Sender]) == 0 {
+			delete(r.places, m.Sender)
+		}
+		err = r.SaveState(placesKey, placesVersion, r.places)
+	}
+	r.placesMu.Unlock()
+
+	switch {
+	case !ok:
+		err = fmt.Errorf("%v is not saved", name)
+	case err == nil:
+		_, err = signal.Respond(fmt.Sprintf("Forgot %s", name), nil, m, true)
+	}
+	if err != nil {
+		errMsg := fmt.Sprintf("Error: %v", err)
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+	}
+}
+
+// respond with the personal and the configured locations
+func (r *Weather) listPlaces(m *signalcli.Message, signal signalsender.SignalSender) {
+	r.placesMu.Lock()
+	own := make([]string, 0, len(r.places[m.Sender]))
+	for name := range r.places[m.Sender] {
+		own = append(own, name)
+	}
+	r.placesMu.Unlock()
+	configured := make([]string, 0, len(r.Locations))
+	for name := range r.Locations {
+		configured = append(configured, name)
+	}
+	slices.Sort(own)
+	slices.Sort(configured)
+
+	builder := strings.Builder{}
+	if len(own) > 0 {
+		builder.WriteString("Yours: ")
+		builder.WriteString(strings.Join(own, ", "))
+		builder.WriteRune('\n')
+	}
+	builder.WriteString("Available: ")
+	builder.WriteString(strings.Join(configured, ", "))
+	builder.WriteString("\nOr any place name, coordinates (lat,lon) or a maps link with coordinates (also a shared location)")
+	if _, err := signal.Respond(builder.String(), nil, m, true); err != nil {
+		errMsg := fmt.Sprintf("Error: %v", err)
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+	}
+}
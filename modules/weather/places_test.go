@@ -0,0 +1,207 @@
+package weather
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"fmt"
+	"net/http"
+	"signalbot_go/internal/modtest"
+	"signalbot_go/internal/state"
+	"testing"
+	"time"
+)
+
+func TestParseCoords(t *testing.T) {
+	tests := []struct {
+		in  string
+		pos Position
+		ok  bool
+	}{
+		{in: "48.14,11.58", pos: Position{Lat: 48.14, Lon: 11.58}, ok: true},
+		{in: "-33.9, 18.4", pos: Position{Lat: -33.9, Lon: 18.4}, ok: true},
+		{in: "https://maps.google.com/maps?q=48.137154%2C11.576124", pos: Position{Lat: 48.137154, Lon: 11.576124}, ok: true},
+		{in: "https://www.openstreetmap.org/search?query=48.14%2C11.58", pos: Position{Lat: 48.14, Lon: 11.58}, ok: true},
+		{in: "95.0,11.5"},
+		{in: "garching"},
+		// names containing numbers are no coordinates
+		{in: "A9,12"},
+		{in: "near 48.14,11.58"},
+		{in: "https://example.com/48.14,11.58"},
+	}
+	for _, tt := range tests {
+		pos, ok := parseCoords(tt.in)
+		if ok != tt.ok || pos != tt.pos {
+			t.Fatalf("%q: Was: %v %v but should be: %v %v", tt.in, pos, ok, tt.pos, tt.ok)
+		}
+	}
+}
+
+func TestIsLocation(t *testing.T) {
+	r := &Weather{}
+	for in, exp := range map[string]bool{
+		"https://maps.google.com/maps?q=48.137154%2C11.576124":       true,
+		" https://www.openstreetmap.org/search?query=48.14%2C11.58 ": true,
+		// only links, the prefix is needed otherwise
+		"48.14,11.58":                     false,
+		"https://example.com/48.14,11.58": false,
+		"Marienplatz, München":            false,
+	} {
+		if r.IsLocation(in) != exp {
+			t.Fatalf("%q: Was: %v but should be: %v", in, !exp, exp)
+		}
+	}
+}
+
+const garching = `={"results":[{"name":"Garching bei München","latitude":48.24896,"longitude":11.65101,"country":"Deutschland","admin1":"Bayern"}]}`
+
+func TestResolve(t *testing.T) {
+	modtest.MockHTTP(t, modtest.Transport{"https://geocoding-api.open-meteo.com/v1/search": garching})
+	st, err := state.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	weather, err := NewWeather(nopLog(), "./", st)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	p, err := weather.resolve("+49123", "Garching")
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if p.Name != "Garching bei München (Bayern, Deutschland)" || p.Position != (Position{Lat: 48.24896, Lon: 11.65101}) {
+		t.Fatalf("Was: %v", p)
+	}
+
+	// the geocoding is cached (also over restarts)
+	modtest.MockHTTP(t, modtest.Transport{})
+	restarted, err := NewWeather(nopLog(), "./", st)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if cached, err := restarted.resolve("+49123", "garching"); err != nil || cached != p {
+		t.Fatalf("Was: %v %v but should be: %v", cached, err, p)
+	}
+	if _, err := restarted.resolve("+49123", "nowhere"); err == nil {
+		t.Fatalf("nowhere should not be found")
+	}
+
+	// personal places of the sender
+	sender := &modtest.Sender{}
+	restarted.Handle(message("save work 48.26,11.67"), sender, nil)
+	restarted.Handle(message("places"), sender, nil)
+	resps := sender.Get()
+	if len(resps) != 2 || resps[0] != "Saved work: 48.2600, 11.6700" || resps[1][:11] != "Yours: work" {
+		t.Fatalf("Was: %q", resps)
+	}
+	if p, err := restarted.resolve("+49123", "Work"); err != nil || p.Position != (Position{Lat: 48.26, Lon: 11.67}) {
+		t.Fatalf("Was: %v %v", p, err)
+	}
+	if _, err := restarted.resolve("+49456", "work"); err == nil {
+		t.Fatalf("work of another sender should not be found")
+	}
+	restarted.Handle(message("forget work"), sender, nil)
+	restarted.Handle(message("forget work"), sender, nil)
+	resps = sender.Get()
+	if resps[2] != "Forgot work" || resps[3] != "Error: work is not saved" {
+		t.Fatalf("Was: %q", resps)
+	}
+}
+
+func TestGeocache(t *testing.T) {
+	modtest.MockHTTP(t, modtest.Transport{})
+	st, err := state.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	// version 1 had no times
+	old := map[string]place{"garching": {Name: "Garching", Position: Position{Lat: 48.25, Lon: 11.65}}}
+	if err := st.Save(geocacheKey, 1, old); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	weather, err := NewWeather(nopLog(), "./", st)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if p, err := weather.resolve("+49123", "Garching"); err != nil || p != old["garching"] {
+		t.Fatalf("Was: %v %v but should be: %v", p, err, old["garching"])
+	}
+
+	// expired entries are not used anymore
+	weather.placesMu.Lock()
+	c := weather.geocache["garching"]
+	c.Time = time.Now().Add(-geocacheTTL - time.Hour)
+	weather.geocache["garching"] = c
+	weather.placesMu.Unlock()
+	if _, err := weather.resolve("+49123", "Garching"); err == nil {
+		t.Fatalf("expired entry should not be used")
+	}
+
+	// the oldest entries are dropped
+	weather.placesMu.Lock()
+	defer weather.placesMu.Unlock()
+	for i := range geocacheSize + 10 {
+		weather.cache(fmt.Sprint(i), place{Name: fmt.Sprint(i)})
+	}
+	if len(weather.geocache) != geocacheSize {
+		t.Fatalf("Was: %d entries but should be %d", len(weather.geocache), geocacheSize)
+	}
+	if _, ok := weather.geocache["garching"]; ok {
+		t.Fatalf("expired entry should have been dropped")
+	}
+	if _, ok := weather.geocache[fmt.Sprint(geocacheSize+9)]; !ok {
+		t.Fatalf("newest entry should be kept")
+	}
+}
+
+// blocks the requests until released
+type blockingTransport struct {
+	modtest.Transport
+	release chan struct{}
+}
+
+func (t blockingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	<-t.release
+	return t.Transport.RoundTrip(req)
+}
+
+func TestResolveUnlocked(t *testing.T) {
+	rt := blockingTransport{
+		Transport: modtest.Transport{"https://geocoding-api.open-meteo.com/v1/search": garching},
+		release:   make(chan struct{}),
+	}
+	modtest.MockHTTP(t, rt)
+	weather, err := NewWeather(nopLog(), "./", nil)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	done := make(chan error)
+	go func() {
+		_, err := weather.resolve("+49123", "Garching")
+		done <- err
+	}()
+	// other places are resolved while the geocoding is running
+	time.Sleep(10 * time.Millisecond)
+	if _, err := weather.resolve("+49456", "48.14,11.58"); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	close(rt.release)
+	if err := <-done; err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+package weather
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrField error = errors.New("unknown field")
+	ErrDays  error = errors.New("invalid amount of days")
+	ErrHours error = errors.New("invalid amount of hours")
+)
+
+// a value which can be selected for the report
+type field string
+
+const (
+	fieldTemp field = "temp"
+	fieldRain field = "rain"
+	fieldSnow field = "snow"
+	fieldWind field = "wind"
+	fieldUv   field = "uv"
+	fieldSun  field = "sun"
+	// air quality and pollen (from the air quality API of open-meteo)
+	fieldAir    field = "air"
+	fieldPollen field = "pollen"
+)
+
+// all fields in the order in which they are printed
+var allFields []field = []field{fieldTemp, fieldRain, fieldSnow, fieldWind, fieldUv, fieldSun, fieldAir, fieldPollen}
+
+// fields printed if none are selected
+var defaultFields []field = []field{fieldTemp, fieldRain, fieldSnow, fieldUv}
+
+// the limits of the query over all providers. The providers have lower limits
+// (see `Provider.limits`), the query is limited to them when fetching.
+const (
+	maxDays  = 16
+	maxHours = maxDays * 24
+)
+
+// parse a comma separated list of fields (empty selects the default fields)
+func parseFields(s string) ([]field, error) {
+	if s == "" {
+		return defaultFields, nil
+	}
+	ret := []field{}
+	for _, f := range strings.Split(s, ",") {
+		f = strings.ToLower(strings.TrimSpace(f))
+		if !slices.Contains(allFields, field(f)) {
+			names := make([]string, 0, len(allFields))
+			for _, a := range allFields {
+				names = append(names, string(a))
+			}
+			return nil, fmt.Errorf("%w %q (available: %s)", ErrField, f, strings.Join(names, ", "))
+		}
+		if !slices.Contains(ret, field(f)) {
+			ret = append(ret, field(f))
+		}
+	}
+	// keep the order of allFields
+	slices.SortFunc(ret, func(a, b field) int {
+		return slices.Index(allFields, a) - slices.Index(allFields, b)
+	})
+	return ret, nil
+}
+
+// what to request from the API
+type query struct {
+	// amount of days of the daily forecast
+	days int
+	// amount of hours of the hourly forecast. All hours of the requested days
+	// if negative.
+	hours  int
+	fields []field
+}
+
+// check the limits of the query
+func (q query) validate() error {
+	if q.days < 1 || q.days > maxDays {
+		return fmt.Errorf("%w: %d (1-%d)", ErrDays, q.days, maxDays)
+	}
+	if q.hours > maxHours {
+		return fmt.Errorf("%w: %d (0-%d)", ErrHours, q.hours, maxHours)
+	}
+	return nil
+}
+
+// the query limited to `days` and `hours`. The bool is true if it had to be
+// limited.
+func (q query) limit(days int, hours int) (query, bool) {
+	ret := q
+	ret.days = min(q.days, days)
+	ret.hours = min(q.hours, hours)
+	return ret, ret.days != q.days || ret.hours != q.hours
+}
+
+// the query also containing the fields
+func (q query) with(fields ...field) query {
+	ret := q
+	ret.fields = slices.Clone(q.fields)
+	for _, f := range fields {
+		if !slices.Contains(ret.fields, f) {
+			ret.fields = append(ret.fields, f)
+		}
+	}
+	return ret
+}
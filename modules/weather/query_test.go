@@ -0,0 +1,121 @@
+package weather
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		in     string
+		fields []field
+		err    error
+	}{
+		{in: "", fields: defaultFields},
+		{in: "wind,temp", fields: []field{fieldTemp, fieldWind}},
+		{in: " UV , uv", fields: []field{fieldUv}},
+		{in: "temp,fog", err: ErrField},
+	}
+	for _, tt := range tests {
+		fields, err := parseFields(tt.in)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%q: Was: %v but should be: %v", tt.in, err, tt.err)
+		}
+		if err == nil && !reflect.DeepEqual(fields, tt.fields) {
+			t.Fatalf("%q: Was: %v but should be: %v", tt.in, fields, tt.fields)
+		}
+	}
+}
+
+func TestQuery(t *testing.T) {
+	tests := []struct {
+		args   Args
+		params map[string]string
+		err    error
+	}{
+		{
+			args:   Args{Day: -1, Days: 3, Hours: 5, Fields: "wind"},
+			params: map[string]string{"forecast_days": "3", "forecast_hours": "5", "daily": "weather_code,wind_speed_10m_max,wind_direction_10m_dominant"},
+		},
+		{
+			args:   Args{Day: 2, Days: 7, Hours: 5},
+			params: map[string]string{"forecast_days": "3", "forecast_hours": ""},
+		},
+		{
+			args:   Args{Day: -1, Days: 7, Hours: 5, Fields: "uv", Chart: true},
+			params: map[string]string{"forecast_hours": "48", "hourly": "weather_code,uv_index,temperature_2m,precipitation,sunshine_duration"},
+		},
+		{args: Args{Day: -1, Days: 0, Hours: 5}, err: ErrDays},
+		{args: Args{Day: 16, Days: 7, Hours: 5}, err: ErrDays},
+		{args: Args{Day: -1, Days: 7, Hours: -2}, err: ErrHours},
+		{args: Args{Day: -1, Days: 7, Hours: 1000}, err: ErrHours},
+	}
+	for i, tt := range tests {
+		q, err := tt.args.query()
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%d: Was: %v but should be: %v", i, err, tt.err)
+		}
+		if err != nil {
+			continue
+		}
+		params := openMeteo{}.params(Position{}, q)
+		for k, v := range tt.params {
+			if got := strings.Join(params[k], ","); got != v {
+				t.Fatalf("%d: %s was: %q but should be: %q", i, k, got, v)
+			}
+		}
+	}
+}
+
+func TestShortResponse(t *testing.T) {
+	f, err := os.Open("test1.json")
+	if err != nil {
+		panic(err)
+	}
+	defer f.Close()
+	raw := weatherResp{}
+	if err := json.NewDecoder(f).Decode(&raw); err != nil {
+		panic(err)
+	}
+
+	// the API returned less values than times
+	raw.Daily.Temperature_2m_min = raw.Daily.Temperature_2m_min[:2]
+	raw.Daily.Weather_code = nil
+	raw.Hourly.Precipitation = nil
+	resp := raw.forecast()
+	out := resp.report(allFields)
+	if !strings.Contains(out, "Sat 12.10") {
+		t.Fatalf("missing the last day:\n%s", out)
+	}
+
+	day, err := resp.day(2, allFields)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if !strings.HasPrefix(day, " Tue 08.10 \nRain: 3.0mm\n") {
+		t.Fatalf("wrong detail view:\n%s", day)
+	}
+	if _, err := resp.day(7, allFields); !errors.Is(err, ErrDays) {
+		t.Fatalf("Was: %v but should be: %v", err, ErrDays)
+	}
+}
@@ -0,0 +1,520 @@
+package weather
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"signalbot_go/internal/differ"
+	"signalbot_go/internal/perioder"
+	"signalbot_go/internal/signalsender"
+	"signalbot_go/signalcli"
+
+	"github.com/alexflint/go-arg"
+)
+
+var ErrCondition error = errors.New("invalid condition")
+
+// a value of the hourly forecast which can be watched
+type condVar struct {
+	field field
+	// units which may be appended to the threshold, the first one is printed
+	units []string
+	value func(h Hour) float64
+}
+
+var condVars map[string]condVar = map[string]condVar{
+	"temp":  {field: fieldTemp, units: []string{"°C", "C"}, value: func(h Hour) float64 { return h.Temp }},
+	"rain":  {field: fieldRain, units: []string{"mm"}, value: func(h Hour) float64 { return h.Precip }},
+	"snow":  {field: fieldSnow, units: []string{"cm"}, value: func(h Hour) float64 { return h.Snow }},
+	"wind":  {field: fieldWind, units: []string{"km/h", "kmh"}, value: func(h Hour) float64 { return h.WindSpeed }},
+	"gust":  {field: fieldWind, units: []string{"km/h", "kmh"}, value: func(h Hour) float64 { return h.WindGusts }},
+	"uv":    {field: fieldUv, units: []string{""}, value: func(h Hour) float64 { return h.Uv }},
+	"aqi":   {field: fieldAir, units: []string{""}, value: airValue(func(a *Air) float64 { return a.Aqi })},
+	"pm25":  {field: fieldAir, units: []string{"µg/m³", "ug/m3"}, value: airValue(func(a *Air) float64 { return a.Pm25 })},
+	"pm10":  {field: fieldAir, units: []string{"µg/m³", "ug/m3"}, value: airValue(func(a *Air) float64 { return a.Pm10 })},
+	"ozone": {field: fieldAir, units: []string{"µg/m³", "ug/m3"}, value: airValue(func(a *Air) float64 { return a.Ozone })},
+	"birch": {field: fieldPollen, units: []string{""}, value: airValue(func(a *Air) float64 { return a.Birch })},
+	"grass": {field: fieldPollen, units: []string{""}, value: airValue(func(a *Air) float64 { return a.Grass })},
+}
+
+// the value of the air of the hour, NaN if unknown
+func airValue(value func(a *Air) float64) func(h Hour) float64 {
+	return func(h Hour) float64 {
+		if h.Air == nil {
+			return math.NaN()
+		}
+		return value(h.Air)
+	}
+}
+
+// a threshold on a value of the hourly forecast, e.g. rain>2mm
+type condition struct {
+	// set for the named conditions (e.g. frost)
+	name  string
+	v     string
+	above bool
+	value float64
+}
+
+// conditions which can be given by name
+var namedConditions map[string]condition = map[string]condition{
+	"frost": {name: "frost", v: "temp", above: false, value: 0},
+	"heat":  {name: "heat", v: "temp", above: true, value: 30},
+	"storm": {name: "storm", v: "gust", above: true, value: 75},
+	"smog":  {name: "smog", v: "aqi", above: true, value: 60},
+}
+
+// parse a condition like rain>2mm, wind>60, temp<-5, birch>50 or frost
+func parseCondition(s string) (condition, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if c, ok := namedConditions[s]; ok {
+		return c, nil
+	}
+	i := strings.IndexAny(s, "<>")
+	if i < 0 {
+		names := make([]string, 0, len(namedConditions))
+		for n := range namedConditions {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return condition{}, fmt.Errorf("%w %q (expected e.g. rain>2mm or one of %s)", ErrCondition, s, strings.Join(names, ", "))
+	}
+	c := condition{v: s[:i], above: s[i] == '>'}
+	cv, ok := condVars[c.v]
+	if !ok {
+		names := make([]string, 0, len(condVars))
+		for n := range condVars {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		return condition{}, fmt.Errorf("%w %q (unknown value %q, available: %s)", ErrCondition, s, c.v, strings.Join(names, ", "))
+	}
+	value := s[i+1:]
+	for _, u := range cv.units {
+		if v, ok := strings.CutSuffix(value, strings.ToLower(u)); ok {
+			value = v
+			break
+		}
+	}
+	var err error
+	if c.value, err = strconv.ParseFloat(value, 64); err != nil {
+		return condition{}, fmt.Errorf("%w %q (%v)", ErrCondition, s, err)
+	}
+	return c, nil
+}
+
+// stringer
+func (c condition) String() string {
+	if c.name != "" {
+		return c.name
+	}
+	op := "<"
+	if c.above {
+		op = ">"
+	}
+	return c.v + op + strconv.FormatFloat(c.value, 'f', -1, 64) + condVars[c.v].units[0]
+}
+
+// check if the value meets the condition
+func (c condition) matches(v float64) bool {
+	if c.above {
+		return v > c.value
+	}
+	return v < c.value
+}
+
+// a location watched in a chat. Only public to be able to store it.
+type watch struct {
+	// the message which added the watch (the alerts respond to it)
+	Msg        signalcli.Message `yaml:"msg"`
+	Conditions []string          `yaml:"conditions"`
+	// how many hours of the forecast are checked
+	Hours int `yaml:"hours"`
+}
+
+// stringer
+func (w watch) String() string {
+	return fmt.Sprintf("%s (next %dh)", strings.Join(w.Conditions, " "), w.Hours)
+}
+
+// a condition forecast at a location. Alerts for the same condition on the
+// same day are considered to be the same event. Only public to be able to
+// store it.
+type alert struct {
+	Location  string `yaml:"location"`
+	Condition string `yaml:"condition"`
+	Day       string `yaml:"day"`
+	Text      string `yaml:"text"`
+}
+
+func (a alert) Equals(other alert) bool {
+	return a.Location == other.Location && a.Condition == other.Condition && a.Day == other.Day
+}
+
+func (a alert) AddString() string {
+	return a.Text
+}
+
+// nothing is sent if the event is not forecast anymore
+func (a alert) RemString() string {
+	return ""
+}
+
+// the alerts of the conditions met by the hourly forecast
+func (o *Forecast) alerts(where string, conds []condition) []alert {
+	ret := []alert{}
+	for _, c := range conds {
+		first := -1
+		extreme := 0.0
+		for i, h := range o.Hourly {
+			v := condVars[c.v].value(h)
+			if !c.matches(v) {
+				continue
+			}
+			if first < 0 {
+				first, extreme = i, v
+			} else if c.above {
+				extreme = max(extreme, v)
+			} else {
+				extreme = min(extreme, v)
+			}
+		}
+		if first < 0 {
+			continue
+		}
+		start := o.Hourly[first].Time
+		bound := "down to"
+		if c.above {
+			bound = "up to"
+		}
+		ret = append(ret, alert{
+			Location:  where,
+			Condition: c.String(),
+			Day:       start.Format(time.DateOnly),
+			Text:      fmt.Sprintf("⚠️ %s: %s from %s (%s %s%s)", where, c, start.Format("Mon 15:04"), bound, formatFloat(extreme, 1), condVars[c.v].units[0]),
+		})
+	}
+	return ret
+}
+
+// key and schema version of the watches and alerts in the state
+const (
+	watchesKey     string = "watches"
+	watchesVersion uint   = 1
+	alertsKey      string = "alerts"
+	alertsVersion  uint   = 1
+)
+
+// specifies the arguments of the watch command
+type watchArgs struct {
+	// list the watches of the chat if empty
+	Where      string   `arg:"positional"`
+	Conditions []string `arg:"positional"`
+	Hours      int      `arg:"--hours" default:"12"`
+	// check the watch now (used by the background job, Where is escaped
+	// with url.PathEscape)
+	Check bool `arg:"--check" default:"false"`
+}
+
+// specifies the arguments of the unwatch command
+type unwatchArgs struct {
+	Where string `arg:"positional,required"`
+}
+
+// watch a location for alerts, list the watches or check a watch
+func (r *Weather) handleWatch(m *signalcli.Message, rest string, signal signalsender.SignalSender, virtRcv func(*signalcli.Message)) {
+	var args watchArgs
+	parser, err := arg.NewParser(arg.Config{}, &args)
+	if err != nil {
+		r.Log.Error(fmt.Sprintf("newParser -> %v", err))
+		return
+	}
+	mArgs := *m
+	mArgs.Message = rest
+	if err := r.Module.Handle(&mArgs, signal, virtRcv, parser); err != nil {
+		return
+	}
+
+	switch {
+	case args.Where == "":
+		r.listWatches(m, signal)
+		return
+	case args.Check:
+		where, err := url.PathUnescape(args.Where)
+		if err != nil {
+			r.Log.Error(fmt.Sprintf("Error: %v", err))
+			return
+		}
+		r.checkWatch(m, where, signal)
+		return
+	}
+
+	if _, err := r.resolve(m.Sender, args.Where); err != nil {
+		errMsg := err.Error()
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+		return
+	}
+	if len(args.Conditions) == 0 {
+		errMsg := "Error: no condition given (e.g. rain>2mm frost wind>60)"
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+		return
+	}
+	if args.Hours < 1 || args.Hours > maxHours {
+		errMsg := fmt.Sprintf("Error: %v: %d (1-%d)", ErrHours, args.Hours, maxHours)
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+		return
+	}
+	w := watch{Hours: args.Hours}
+	for _, s := range args.Conditions {
+		c, err := parseCondition(s)
+		if err != nil {
+			errMsg := fmt.Sprintf("Error: %v", err)
+			r.Log.Error(errMsg)
+			r.SendError(m, signal, errMsg)
+			return
+		}
+		w.Conditions = append(w.Conditions, c.String())
+	}
+	w.Msg = *m
+	w.Msg.Message = ""
+	w.Msg.Attachments = nil
+	w.Msg.AttachmentMeta = nil
+
+	r.watchMu.Lock()
+	if _, ok := r.watches[m.Chat]; !ok {
+		r.watches[m.Chat] = make(map[string]watch)
+	}
+	r.watches[m.Chat][args.Where] = w
+	r.saveWatches()
+	r.watchMu.Unlock()
+
+	resp := fmt.Sprintf("Watching %s: %v, checked every %v", args.Where, w, r.WatchInterval)
+	if _, err := signal.Respond(resp, nil, m, true); err != nil {
+		errMsg := fmt.Sprintf("Error: %v", err)
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+	}
+}
+
+// stop watching a location
+func (r *Weather) handleUnwatch(m *signalcli.Message, rest string, signal signalsender.SignalSender, virtRcv func(*signalcli.Message)) {
+	var args unwatchArgs
+	parser, err := arg.NewParser(arg.Config{}, &args)
+	if err != nil {
+		r.Log.Error(fmt.Sprintf("newParser -> %v", err))
+		return
+	}
+	mArgs := *m
+	mArgs.Message = rest
+	if err := r.Module.Handle(&mArgs, signal, virtRcv, parser); err != nil {
+		return
+	}
+
+	r.watchMu.Lock()
+	_, ok := r.watches[m.Chat][args.Where]
+	if ok {
+		delete(r.watches[m.Chat], args.Where)
+		if len(r.watches[m.Chat]) == 0 {
+			delete(r.watches, m.Chat)
+		}
+		delete(r.alerts[m.Chat], args.Where)
+		r.saveWatches()
+		r.saveAlerts()
+	}
+	r.watchMu.Unlock()
+
+	if !ok {
+		errMsg := fmt.Sprintf("Error: %v is not watched", args.Where)
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+		return
+	}
+	if _, err := signal.Respond(fmt.Sprintf("Stopped watching %s", args.Where), nil, m, true); err != nil {
+		errMsg := fmt.Sprintf("Error: %v", err)
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+	}
+}
+
+// respond with the watches of the chat
+func (r *Weather) listWatches(m *signalcli.Message, signal signalsender.SignalSender) {
+	r.watchMu.Lock()
+	locs := make([]string, 0, len(r.watches[m.Chat]))
+	for loc := range r.watches[m.Chat] {
+		locs = append(locs, loc)
+	}
+	slices.Sort(locs)
+	builder := strings.Builder{}
+	for _, loc := range locs {
+		builder.WriteString(loc)
+		builder.WriteString(": ")
+		builder.WriteString(r.watches[m.Chat][loc].String())
+		builder.WriteRune('\n')
+	}
+	r.watchMu.Unlock()
+
+	resp := strings.TrimSuffix(builder.String(), "\n")
+	if resp == "" {
+		resp = "No watches"
+	}
+	if _, err := signal.Respond(resp, nil, m, true); err != nil {
+		errMsg := fmt.Sprintf("Error: %v", err)
+		r.Log.Error(errMsg)
+		r.SendError(m, signal, errMsg)
+	}
+}
+
+// fetch the forecast of a watched location and respond with the new alerts.
+// Errors are only logged, the check runs again later.
+func (r *Weather) checkWatch(m *signalcli.Message, where string, signal signalsender.SignalSender) {
+	r.watchMu.Lock()
+	w, ok := r.watches[m.Chat][where]
+	r.watchMu.Unlock()
+	if !ok {
+		// removed in the meantime
+		return
+	}
+
+	conds := make([]condition, 0, len(w.Conditions))
+	q := query{days: min(w.Hours/24+2, maxDays), hours: w.Hours}
+	for _, s := range w.Conditions {
+		c, err := parseCondition(s)
+		if err != nil {
+			r.Log.Error(fmt.Sprintf("Error: %v", err))
+			continue
+		}
+		conds = append(conds, c)
+		q = q.with(condVars[c.v].field)
+	}
+
+	resp, _, err := r.fetch(m.Sender, where, "", q)
+	if err != nil {
+		r.Log.Error(err.Error())
+		return
+	}
+
+	r.watchMu.Lock()
+	diff := r.alerts.DiffStore(m.Chat, where, resp.alerts(where, conds))
+	r.saveAlerts()
+	r.watchMu.Unlock()
+
+	if diff == "" {
+		return
+	}
+	if _, err := signal.Respond(diff, nil, m, true); err != nil {
+		r.Log.Error(fmt.Sprintf("Error: %v", err))
+	}
+}
+
+// let the background job check all watches (via virtually received
+// messages, so the access control applies and the alerts can be sent)
+func (r *Weather) pollWatches(virtRcv func(*signalcli.Message)) {
+	r.watchMu.Lock()
+	if r.prefix == "" {
+		r.watchMu.Unlock()
+		r.Log.Warn("Cannot check the watches without a prefix")
+		return
+	}
+	msgs := []signalcli.Message{}
+	for _, locs := range r.watches {
+		for loc, w := range locs {
+			m := w.Msg
+			// escaped, the location might contain spaces, quotes or
+			// command separators
+			m.Message = fmt.Sprintf("%s watch --check %s", r.prefix, url.PathEscape(loc))
+			msgs = append(msgs, m)
+		}
+	}
+	r.watchMu.Unlock()
+
+	for _, m := range msgs {
+		virtRcv(&m)
+	}
+}
+
+// the prefix used to check the watches from the background job
+func (r *Weather) SetPrefix(prefix string) {
+	r.watchMu.Lock()
+	defer r.watchMu.Unlock()
+	r.prefix = prefix
+}
+
+// persist the watches in the state store (watchMu has to be held)
+func (r *Weather) saveWatches() {
+	if err := r.SaveState(watchesKey, watchesVersion, r.watches); err != nil {
+		r.Log.Error(fmt.Sprintf("Error saving the watches: %v", err))
+	}
+}
+
+// persist the alerts in the state store (watchMu has to be held)
+func (r *Weather) saveAlerts() {
+	if err := r.SaveState(alertsKey, alertsVersion, r.alerts); err != nil {
+		r.Log.Error(fmt.Sprintf("Error saving the alerts: %v", err))
+	}
+}
+
+// load the watches and start checking them periodically
+func (r *Weather) Start(virtRcv func(*signalcli.Message)) error {
+	if err := r.Module.Start(virtRcv); err != nil {
+		return err
+	}
+
+	r.watchMu.Lock()
+	_, err := r.LoadState(watchesKey, &r.watches)
+	if err == nil {
+		_, err = r.LoadState(alertsKey, &r.alerts)
+	}
+	if r.watches == nil {
+		r.watches = make(map[string]map[string]watch)
+	}
+	if r.alerts == nil {
+		r.alerts = make(differ.Differ[string, string, alert])
+	}
+	r.watchMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	var ctx context.Context
+	ctx, r.stop = context.WithCancel(context.Background())
+	go r.perioder.Start(ctx)
+	r.perioder.Add(perioder.NewReocEventImpl(time.Now(), r.WatchInterval, "weather watches", struct{}{}, func(time.Time, perioder.ReocEvent[struct{}]) {
+		r.pollWatches(virtRcv)
+	}))
+	return nil
+}
+
+// stop checking the watches
+func (r *Weather) Close(virtRcv func(*signalcli.Message)) {
+	r.Module.Close(virtRcv)
+	if r.stop != nil {
+		r.stop()
+	}
+}
@@ -0,0 +1,165 @@
+package weather
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+
+import (
+	"errors"
+	"signalbot_go/internal/modtest"
+	"signalbot_go/internal/state"
+	"signalbot_go/signalcli"
+	"strings"
+	"testing"
+)
+
+func TestParseCondition(t *testing.T) {
+	tests := []struct {
+		in  string
+		out string
+		err error
+	}{
+		{in: "rain>2mm", out: "rain>2mm"},
+		{in: "rain>2", out: "rain>2mm"},
+		{in: "Wind>60km/h", out: "wind>60km/h"},
+		{in: "temp<-5", out: "temp<-5°C"},
+		{in: "frost", out: "frost"},
+		{in: "birch>50", out: "birch>50"},
+		{in: "pm25>25ug/m3", out: "pm25>25µg/m³"},
+		{in: "uv>6", out: "uv>6"},
+		{in: "smog", out: "smog"},
+		{in: "fog>1", err: ErrCondition},
+		{in: "rain", err: ErrCondition},
+		{in: "rain>much", err: ErrCondition},
+	}
+	for _, tt := range tests {
+		c, err := parseCondition(tt.in)
+		if !errors.Is(err, tt.err) {
+			t.Fatalf("%q: Was: %v but should be: %v", tt.in, err, tt.err)
+		}
+		if err == nil && c.String() != tt.out {
+			t.Fatalf("%q: Was: %v but should be: %v", tt.in, c, tt.out)
+		}
+	}
+}
+
+// message sent by the user to the module (without the prefix)
+func message(msg string) *signalcli.Message {
+	return &signalcli.Message{Sender: "+49123", Chat: "+49123", Message: msg}
+}
+
+func TestWatch(t *testing.T) {
+	modtest.MockHTTP(t, modtest.Transport{"https://api.open-meteo.com/v1/forecast": "watch.json"})
+	st, err := state.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	weather, err := NewWeather(nopLog(), "./", st)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	weather.SetPrefix("weather")
+	sender := &modtest.Sender{}
+
+	// the background job checks via virtually received messages
+	poll := func() []string {
+		virt := []*signalcli.Message{}
+		weather.pollWatches(func(m *signalcli.Message) {
+			virt = append(virt, m)
+		})
+		before := len(sender.Get())
+		for _, m := range virt {
+			msg, ok := strings.CutPrefix(m.Message, "weather ")
+			if !ok {
+				t.Fatalf("virtual message without prefix: %q", m.Message)
+			}
+			weather.Handle(message(msg), sender, nil)
+		}
+		return sender.Get()[before:]
+	}
+
+	weather.Handle(message("watch muc rain>2mm frost storm"), sender, nil)
+	if resps := sender.Get(); len(resps) != 1 || !strings.HasPrefix(resps[0], "Watching muc: rain>2mm frost storm (next 12h)") {
+		t.Fatalf("Was: %v", resps)
+	}
+
+	alerts := poll()
+	want := []string{"⚠️ muc: rain>2mm from Sun 21:00 (up to 3.5mm)\n⚠️ muc: frost from Mon 02:00 (down to -1.5°C)"}
+	if strings.Join(alerts, "|") != strings.Join(want, "|") {
+		t.Fatalf("Was: %q but should be: %q", alerts, want)
+	}
+	// the same events are not alerted again
+	if alerts := poll(); len(alerts) != 0 {
+		t.Fatalf("Was: %q but should be empty", alerts)
+	}
+
+	// the watches survive a restart
+	restarted, err := NewWeather(nopLog(), "./", st)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if err := restarted.Start(func(*signalcli.Message) {}); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	restarted.Close(nil)
+	if len(restarted.watches["+49123"]) != 1 || len(restarted.alerts["+49123"]["muc"]) != 2 {
+		t.Fatalf("Was: %v %v", restarted.watches, restarted.alerts)
+	}
+
+	weather.Handle(message("unwatch muc"), sender, nil)
+	if resps := sender.Get(); resps[len(resps)-1] != "Stopped watching muc" {
+		t.Fatalf("Was: %v", resps)
+	}
+	if alerts := poll(); len(alerts) != 0 {
+		t.Fatalf("Was: %q but should be empty", alerts)
+	}
+	weather.Handle(message("watch"), sender, nil)
+	if resps := sender.Get(); resps[len(resps)-1] != "No watches" {
+		t.Fatalf("Was: %v", resps)
+	}
+}
+
+func TestWatchQuoted(t *testing.T) {
+	modtest.MockHTTP(t, modtest.Transport{
+		"https://api.open-meteo.com/v1/forecast":         "watch.json",
+		"https://geocoding-api.open-meteo.com/v1/search": garching,
+	})
+	weather, err := NewWeather(nopLog(), "./", nil)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	weather.SetPrefix("weather")
+	sender := &modtest.Sender{}
+
+	weather.Handle(message(`watch "Bad Tölz" rain>2mm`), sender, nil)
+	if resps := sender.Get(); len(resps) != 1 || !strings.HasPrefix(resps[0], "Watching Bad Tölz: rain>2mm") {
+		t.Fatalf("Was: %v", resps)
+	}
+
+	virt := []*signalcli.Message{}
+	weather.pollWatches(func(m *signalcli.Message) {
+		virt = append(virt, m)
+	})
+	if len(virt) != 1 || strings.ContainsAny(virt[0].Message, "|\n\"'") {
+		t.Fatalf("Was: %v", virt)
+	}
+	msg, _ := strings.CutPrefix(virt[0].Message, "weather ")
+	weather.Handle(message(msg), sender, nil)
+	resps := sender.Get()
+	if want := "⚠️ Bad Tölz: rain>2mm from Sun 21:00 (up to 3.5mm)"; resps[len(resps)-1] != want {
+		t.Fatalf("Was: %q but should be: %q", resps[len(resps)-1], want)
+	}
+}
@@ -17,10 +17,19 @@ package weather
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
+	"signalbot_go/internal/differ"
+	"signalbot_go/internal/perioder"
+	"signalbot_go/internal/render"
 	"signalbot_go/internal/signalsender"
+	"signalbot_go/internal/state"
 	"signalbot_go/modules"
 	"signalbot_go/signalcli"
 	"time"
@@ -30,9 +39,13 @@ import (
 	"gopkg.in/yaml.v3"
 )
 
+var ErrQuota error = errors.New("Quota exceeded.")
+
 type Position struct {
 	Lon float32 `yaml:"lon"`
 	Lat float32 `yaml:"lat"`
+	// the provider of the forecasts for this position (default if empty)
+	Provider string `yaml:"provider,omitempty"`
 }
 
 // Weather module. Should be instanciated with `NewWeather`.
@@ -46,15 +59,36 @@ type Weather struct {
 	MonthLimit  uint `yaml:"monthLimit"`
 
 	Locations map[string]Position `yaml:"locations"`
+	// how often the watched locations are checked for alerts
+	WatchInterval time.Duration `yaml:"watchInterval,omitempty"`
+
+	quotaMu sync.Mutex `yaml:"-"`
+
+	// guards the watches and the alerts
+	watchMu  sync.Mutex                           `yaml:"-"`
+	watches  map[string]map[string]watch          `yaml:"-"` // chat->location->watch
+	alerts   differ.Differ[string, string, alert] `yaml:"-"` // chat->location->alerts
+	prefix   string                               `yaml:"-"`
+	perioder perioder.Perioder[struct{}]          `yaml:"-"`
+	stop     context.CancelFunc                   `yaml:"-"`
+
+	// guards the places and the geocoding cache
+	placesMu sync.Mutex                     `yaml:"-"`
+	places   map[string]map[string]Position `yaml:"-"` // sender->name->position
+	geocache map[string]cachedPlace         `yaml:"-"`
 }
 
 // instanciates a new Weather from a configuration file
 // (cfgDir/weather.yaml)
-func NewWeather(log *slog.Logger, cfgDir string) (*Weather, error) {
+func NewWeather(log *slog.Logger, cfgDir string, st state.Store) (*Weather, error) {
 	r := Weather{
-		Module:  modules.NewModule(log, cfgDir),
-		Fetcher: Fetcher{},
+		Module:   modules.NewModule(log, cfgDir),
+		Fetcher:  Fetcher{},
+		watches:  make(map[string]map[string]watch),
+		alerts:   make(differ.Differ[string, string, alert]),
+		perioder: perioder.NewPerioderImpl[struct{}](log.With()),
 	}
+	r.State = st
 
 	f, err := os.Open(filepath.Join(r.ConfigDir, "weather.yaml"))
 	if err != nil {
@@ -69,6 +103,14 @@ func NewWeather(log *slog.Logger, cfgDir string) (*Weather, error) {
 		return nil, err
 	}
 
+	if err := r.loadPlaces(); err != nil {
+		return nil, err
+	}
+
+	if r.WatchInterval == 0 {
+		r.WatchInterval = time.Hour
+	}
+
 	// validation
 	if err := r.Validate(); err != nil {
 		return nil, err
@@ -87,18 +129,95 @@ func (r *Weather) Validate() error {
 	if err := r.Fetcher.Validate(); err != nil {
 		return err
 	}
+	// validate the providers of the locations
+	for name, pos := range r.Locations {
+		if _, err := r.Fetcher.provider(pos.Provider); err != nil {
+			return fmt.Errorf("location %v: %w", name, err)
+		}
+	}
 	return nil
 }
 
 // specifies the arguments when handling a request to this module
 type Args struct {
 	Where string `arg:"positional"`
-	// When  int    `arg:"-d,--day" default:"0"`
+	// show this day (0 is today) in detail
+	Day int `arg:"-d,--day" default:"-1"`
+	// amount of days and hours of the forecast
+	Days  int `arg:"--days" default:"7"`
+	Hours int `arg:"--hours" default:"5"`
+	// comma separated list of temp, rain, snow, wind, uv, sun, air and pollen
+	Fields string `arg:"-f,--fields" default:""`
+	// send the daily forecast as image
+	Image bool `arg:"-i,--image" default:"false"`
+	// send charts of the next 48 hours (at least) and the days
+	Chart bool `arg:"-c,--chart" default:"false"`
+	// use this provider instead of the one of the location
+	Provider string `arg:"-p,--provider" default:""`
+	// add the air quality and the pollen to the fields
+	Air    bool `arg:"--air" default:"false"`
+	Pollen bool `arg:"--pollen" default:"false"`
+}
+
+// amount of hours shown in the chart (at least)
+const chartHours = 48
+
+// build the query for the API from the arguments
+func (a Args) query() (query, error) {
+	fields, err := parseFields(a.Fields)
+	if err != nil {
+		return query{}, err
+	}
+	if a.Hours < 0 {
+		return query{}, fmt.Errorf("%w: %d (0-%d)", ErrHours, a.Hours, maxHours)
+	}
+	q := query{days: a.Days, hours: a.Hours, fields: fields}
+	if a.Air {
+		q = q.with(fieldAir)
+	}
+	if a.Pollen {
+		q = q.with(fieldPollen)
+	}
+	switch {
+	case a.Chart:
+		q = q.with(fieldTemp, fieldRain, fieldSun)
+		q.hours = max(q.hours, chartHours)
+	case a.Image:
+		q = q.with(fieldTemp, fieldRain, fieldSnow)
+	case a.Day >= 0:
+		// all hours of the day
+		q.days = a.Day + 1
+		q.hours = -1
+		if q.days > maxDays {
+			return query{}, fmt.Errorf("%w: day %d (0-%d)", ErrDays, a.Day, maxDays-1)
+		}
+	}
+	return q, q.validate()
 }
 
 // Handle a message from the signalcli. Parses the message, executes the query
 // and responds to signal.
 func (r *Weather) Handle(m *signalcli.Message, signal signalsender.SignalSender, virtRcv func(*signalcli.Message)) {
+	// the watch and place commands have their own arguments
+	cmd, rest, _ := strings.Cut(strings.TrimSpace(m.Message), " ")
+	switch cmd {
+	case "watch":
+		r.handleWatch(m, rest, signal, virtRcv)
+		return
+	case "unwatch":
+		r.handleUnwatch(m, rest, signal, virtRcv)
+		return
+	case "save":
+		r.handleSave(m, rest, signal, virtRcv)
+		return
+	case "forget":
+		r.handleForget(m, rest, signal, virtRcv)
+		return
+	case "places":
+		r.listPlaces(m, signal)
+		return
+	}
+
 	// parse the message
 	var args Args
 	parser, err := arg.NewParser(arg.Config{}, &args)
@@ -114,51 +233,123 @@ func (r *Weather) Handle(m *signalcli.Message, signal signalsender.SignalSender,
 		return
 	}
 
-	// check quota
-	if fine, err := r.incQuota(); err != nil {
-		errMsg := fmt.Sprintf("Error checking quota. %v", err)
-		r.Log.Error(errMsg)
-		r.SendError(m, signal, errMsg)
-		return
-	} else if !fine {
-		errMsg := "Quota exceeded."
+	q, err := args.query()
+	if err != nil {
+		errMsg := fmt.Sprintf("Error: %v", err)
 		r.Log.Error(errMsg)
 		r.SendError(m, signal, errMsg)
 		return
 	}
 
-	loc, ok := r.Locations[args.Where]
-	if !ok {
-		errMsg := fmt.Sprintf("location %v is unknown", args.Where)
+	resp, p, err := r.fetch(m.Sender, args.Where, args.Provider, q)
+	if err != nil {
+		errMsg := err.Error()
 		r.Log.Error(errMsg)
 		r.SendError(m, signal, errMsg)
 		return
 	}
 
-	// execute the query
-	reader, err := r.Fetcher.getReader(loc)
-	if err != nil {
-		errMsg := fmt.Sprintf("Error: %v", err)
-		r.Log.Error(errMsg)
-		r.SendError(m, signal, errMsg)
+	// respond
+	if args.Chart {
+		r.respondCharts(m, signal, resp, p.Name)
+		return
+	}
+	if args.Image {
+		table := resp.Table(p.Name)
+		ofile, err := table.PNG()
+		if err != nil {
+			errMsg := fmt.Sprintf("Error: %v", err)
+			r.Log.Error(errMsg)
+			r.SendError(m, signal, errMsg)
+			return
+		}
+		defer ofile.Close()
+		_, err = signal.Respond(resp.Notice, []string{ofile.Path()}, m, true)
+		if err != nil {
+			errMsg := fmt.Sprintf("Error: %v", err)
+			r.Log.Error(errMsg)
+			r.SendError(m, signal, errMsg)
+		}
 		return
 	}
-	defer reader.Close()
-	resp, err := r.Fetcher.getFromReader(reader)
+	out := resp.report(q.fields)
+	if args.Day >= 0 {
+		out, err = resp.day(args.Day, q.fields)
+		if err != nil && resp.Notice != "" {
+			err = fmt.Errorf("%w (%s)", err, resp.Notice)
+		}
+		if err != nil {
+			errMsg := fmt.Sprintf("Error: %v", err)
+			r.Log.Error(errMsg)
+			r.SendError(m, signal, errMsg)
+			return
+		}
+	}
+	if resp.Notice != "" {
+		out = "⚠️ " + resp.Notice + "\n" + out
+	}
+	if p.Name != args.Where {
+		// show what the location was resolved to
+		out = "📍 " + p.Name + "\n" + out
+	}
+	_, err = signal.Respond(out, []string{}, m, true)
 	if err != nil {
 		errMsg := fmt.Sprintf("Error: %v", err)
 		r.Log.Error(errMsg)
 		r.SendError(m, signal, errMsg)
 		return
 	}
+}
 
-	// respond
-	_, err = signal.Respond(resp.String(), []string{}, m, true)
+// check the quota, resolve the location given by the sender and fetch the
+// forecast for it (from the provider of the location if provider is empty)
+func (r *Weather) fetch(sender string, where string, provider string, q query) (*Forecast, place, error) {
+	if fine, err := r.incQuota(); err != nil {
+		return nil, place{}, fmt.Errorf("Error checking quota. %v", err)
+	} else if !fine {
+		return nil, place{}, ErrQuota
+	}
+
+	p, err := r.resolve(sender, where)
+	if err != nil {
+		return nil, place{}, err
+	}
+
+	// execute the query
+	pos := p.Position
+	if provider != "" {
+		pos.Provider = provider
+	}
+	resp, err := r.Fetcher.forecast(pos, q)
+	if err != nil {
+		return nil, place{}, fmt.Errorf("Error: %w", err)
+	}
+	return resp, p, nil
+}
+
+// respond with the charts of the hourly and the daily forecast
+func (r *Weather) respondCharts(m *signalcli.Message, signal signalsender.SignalSender, resp *Forecast, where string) {
+	charts := []render.Chart{
+		resp.HourlyChart(fmt.Sprintf("%s: %dh", where, len(resp.Hourly))),
+		resp.DailyChart(fmt.Sprintf("%s: %dd", where, len(resp.Daily))),
+	}
+	atts := make([]string, 0, len(charts))
+	for _, c := range charts {
+		ofile, err := c.PNG()
+		if err != nil {
+			errMsg := fmt.Sprintf("Error: %v", err)
+			r.Log.Error(errMsg)
+			r.SendError(m, signal, errMsg)
+			return
+		}
+		defer ofile.Close()
+		atts = append(atts, ofile.Path())
+	}
+	_, err := signal.Respond(resp.Notice, atts, m, true)
 	if err != nil {
 		errMsg := fmt.Sprintf("Error: %v", err)
 		r.Log.Error(errMsg)
 		r.SendError(m, signal, errMsg)
-		return
 	}
 }
 
@@ -174,28 +365,28 @@ type calls struct {
 	MonthCalls uint      `yaml:"monthCalls"`
 }
 
+// key and schema version of the calls in the state
+const (
+	quotaKey     string = "quota"
+	quotaVersion uint   = 1
+)
+
 // check and increase the quota of the current minute, day and month
 func (w *Weather) incQuota() (bool, error) {
-	fn := filepath.Join(w.ConfigDir, "openweather.calls")
-
-	c, err := func() (*calls, error) {
-		f, err := os.Open(fn)
-		if err != nil {
-			return nil, err
-		}
-		defer f.Close()
+	w.quotaMu.Lock()
+	defer w.quotaMu.Unlock()
 
-		d := yaml.NewDecoder(f)
-		c := &calls{}
-		err = d.Decode(c)
-		if err != nil {
-			return nil, err
-		}
-		return c, nil
-	}()
+	c := &calls{}
+	version, err := w.LoadState(quotaKey, c)
 	if err != nil {
 		return false, err
 	}
+	if version == 0 {
+		// the calls used to be stored next to the configuration
+		if err := w.loadLegacyQuota(c); err != nil {
+			return false, err
+		}
+	}
 
 	// increment
 	nowMi := time.Now().Truncate(time.Minute)
@@ -235,17 +426,27 @@ func (w *Weather) incQuota() (bool, error) {
 	ret := c.MonthCalls < w.MonthLimit && c.DayCalls < w.DayLimit && c.MinuteCalls < w.MinuteLimit
 
 	// write back
-	f, err := os.Create(fn)
-	if err != nil {
-		return false, err
-	}
-	defer f.Close()
-	e := yaml.NewEncoder(f)
-	defer e.Close()
-	err = e.Encode(c)
-	if err != nil {
+	if err := w.SaveState(quotaKey, quotaVersion, c); err != nil {
 		return false, err
 	}
 
 	return ret, nil
 }
+
+// read the calls from the 'openweather.calls' file in the config directory
+// (where they were stored before the state store existed)
+func (w *Weather) loadLegacyQuota(c *calls) error {
+	f, err := os.Open(filepath.Join(w.ConfigDir, "openweather.calls"))
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	d := yaml.NewDecoder(f)
+	if err := d.Decode(c); err != nil && err != io.EOF {
+		return err
+	}
+	return nil
+}
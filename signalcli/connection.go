@@ -0,0 +1,114 @@
+package signalcli
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+var ErrNotConnected error = errors.New("Not connected to signal-cli")
+
+// state of the connection of a driver to signal-cli
+type ConnectionState string
+
+const (
+	StateConnecting   ConnectionState = "connecting"
+	StateConnected    ConnectionState = "connected"
+	StateDisconnected ConnectionState = "disconnected"
+	StateClosed       ConnectionState = "closed"
+)
+
+// keeps track of the connection state, safe for concurrent use. Can be
+// embedded by drivers to implement `Driver.State`.
+type StateTracker struct {
+	mu    sync.RWMutex
+	state ConnectionState
+	since time.Time
+}
+
+// returns the current state
+func (t *StateTracker) State() ConnectionState {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.state == "" {
+		return StateConnecting
+	}
+	return t.state
+}
+
+// returns the current state and since when the connection is in this state
+func (t *StateTracker) StateSince() (ConnectionState, time.Time) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.state == "" {
+		return StateConnecting, t.since
+	}
+	return t.state, t.since
+}
+
+// update the state. Returns whether the state changed.
+func (t *StateTracker) SetState(s ConnectionState) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.state == s {
+		return false
+	}
+	t.state = s
+	t.since = time.Now()
+	return true
+}
+
+// exponential backoff. Not safe for concurrent use.
+type Backoff struct {
+	Min  time.Duration
+	Max  time.Duration
+	next time.Duration
+}
+
+// returns the duration to wait for and doubles it for the next time
+func (b *Backoff) Next() time.Duration {
+	if b.next == 0 {
+		b.next = b.Min
+	}
+	ret := b.next
+	b.next *= 2
+	if b.next > b.Max {
+		b.next = b.Max
+	}
+	return ret
+}
+
+// start over with the minimum duration
+func (b *Backoff) Reset() {
+	b.next = 0
+}
+
+// wait for the next backoff duration. Returns false if the context was
+// canceled in the meantime.
+func (b *Backoff) Wait(ctx context.Context) bool {
+	t := time.NewTimer(b.Next())
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-t.C:
+		return true
+	}
+}
@@ -0,0 +1,60 @@
+package signalcli
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	b := Backoff{Min: time.Second, Max: 5 * time.Second}
+	exp := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, e := range exp {
+		if d := b.Next(); d != e {
+			t.Fatalf("Was: %v but should be %v (step %d)", d, e, i)
+		}
+	}
+	b.Reset()
+	if d := b.Next(); d != time.Second {
+		t.Fatalf("Was: %v but should be %v after reset", d, time.Second)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	b = Backoff{Min: time.Hour, Max: time.Hour}
+	if b.Wait(ctx) {
+		t.Fatalf("Wait should be aborted by the context")
+	}
+}
+
+func TestStateTracker(t *testing.T) {
+	var s StateTracker
+	if s.State() != StateConnecting {
+		t.Fatalf("Was: %v but should be %v", s.State(), StateConnecting)
+	}
+	if !s.SetState(StateConnected) {
+		t.Fatalf("State should have changed")
+	}
+	if s.SetState(StateConnected) {
+		t.Fatalf("State should not have changed")
+	}
+	if st, since := s.StateSince(); st != StateConnected || since.IsZero() {
+		t.Fatalf("Was: %v (%v) but should be %v", st, since, StateConnected)
+	}
+}
@@ -0,0 +1,153 @@
+package signalcli
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"sync"
+	"time"
+)
+
+// a contact of the account
+type Contact struct {
+	Number  string
+	Name    string
+	Blocked bool
+}
+
+// part of a Driver for managing the contacts and the profile of the account
+type ContactDriver interface {
+	ListContacts() ([]Contact, error)
+	GetContactName(number string) (string, error)
+	SetContactName(number string, name string) error
+	SetContactBlocked(number string, block bool) error
+	// expiration is the number of seconds before messages in the chat with
+	// `number` disappear (0 to disable)
+	SetExpirationTimer(number string, expiration int32) error
+	// avatar is a filename, if remove is set the avatar is removed. Strings
+	// set to "" leave the property unchanged.
+	UpdateProfile(name string, about string, aboutEmoji string, avatar string, remove bool) error
+}
+
+// can be embedded by drivers which don't support managing contacts
+type NoContacts struct{}
+
+func (NoContacts) ListContacts() ([]Contact, error)                  { return nil, ErrNotSupported }
+func (NoContacts) GetContactName(number string) (string, error)      { return "", ErrNotSupported }
+func (NoContacts) SetContactName(number string, name string) error   { return ErrNotSupported }
+func (NoContacts) SetContactBlocked(number string, block bool) error { return ErrNotSupported }
+func (NoContacts) SetExpirationTimer(number string, exp int32) error { return ErrNotSupported }
+func (NoContacts) UpdateProfile(name string, about string, aboutEmoji string, avatar string, remove bool) error {
+	return ErrNotSupported
+}
+
+// how long resolved contact names are cached
+var contactNameTTL = time.Hour
+
+type cachedName struct {
+	name    string
+	fetched time.Time
+}
+
+// cache for the names of contacts, safe for concurrent use
+type contactNames struct {
+	mu    sync.Mutex
+	names map[string]cachedName
+}
+
+func newContactNames() *contactNames {
+	return &contactNames{names: make(map[string]cachedName)}
+}
+
+func (c *contactNames) get(number string) (string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	n, ok := c.names[number]
+	if !ok || time.Since(n.fetched) > contactNameTTL {
+		return "", false
+	}
+	return n.name, true
+}
+
+func (c *contactNames) set(number string, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.names[number] = cachedName{name: name, fetched: time.Now()}
+}
+
+func (s *Account) ListContacts() ([]Contact, error) {
+	contacts, err := s.driver.ListContacts()
+	if err != nil {
+		return nil, err
+	}
+	for _, c := range contacts {
+		s.contactNames.set(c.Number, c.Name)
+	}
+	return contacts, nil
+}
+
+func (s *Account) GetContactName(number string) (string, error) {
+	name, err := s.driver.GetContactName(number)
+	if err != nil {
+		return "", err
+	}
+	s.contactNames.set(number, name)
+	return name, nil
+}
+
+// the name of the contact (cached) or the number itself if the contact has no
+// name or the name can't be retrieved. Meant for log lines and output to users.
+func (s *Account) ContactName(number string) string {
+	if number == "" {
+		return ""
+	}
+	name, ok := s.contactNames.get(number)
+	if !ok {
+		var err error
+		name, err = s.driver.GetContactName(number)
+		if err != nil {
+			// don't ask the driver over and over again
+			name = ""
+		}
+		s.contactNames.set(number, name)
+	}
+	if name == "" {
+		return number
+	}
+	return name
+}
+
+func (s *Account) SetContactName(number string, name string) error {
+	if err := s.driver.SetContactName(number, name); err != nil {
+		return err
+	}
+	s.contactNames.set(number, name)
+	return nil
+}
+
+func (s *Account) SetContactBlocked(number string, block bool) error {
+	return s.driver.SetContactBlocked(number, block)
+}
+
+// let messages in the chat with `number` disappear after `expiration`
+// (0 to disable)
+func (s *Account) SetExpirationTimer(number string, expiration time.Duration) error {
+	return s.driver.SetExpirationTimer(number, int32(expiration/time.Second))
+}
+
+func (s *Account) UpdateProfile(name string, about string, aboutEmoji string, avatar string, remove bool) error {
+	return s.driver.UpdateProfile(name, about, aboutEmoji, avatar, remove)
+}
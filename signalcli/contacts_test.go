@@ -0,0 +1,83 @@
+package signalcli
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"errors"
+	"testing"
+)
+
+// driver which knows the names of some contacts
+type namesDriver struct {
+	chanDriver
+	names map[string]string
+	calls int
+}
+
+func (d *namesDriver) GetContactName(number string) (string, error) {
+	d.calls++
+	n, ok := d.names[number]
+	if !ok {
+		return "", errors.New("unknown number")
+	}
+	return n, nil
+}
+
+func (d *namesDriver) SetContactName(number string, name string) error {
+	d.names[number] = name
+	return nil
+}
+
+func TestContactName(t *testing.T) {
+	d := &namesDriver{names: map[string]string{"+49a": "Alice", "+49b": ""}}
+	acc, err := NewAccount(nopLog(), d, testQueueCfg, DefaultDeliveryCfg, nil)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	tests := []struct {
+		number string
+		want   string
+	}{
+		{"+49a", "Alice"},
+		{"+49a", "Alice"},
+		{"+49b", "+49b"},
+		{"+49c", "+49c"},
+		{"+49c", "+49c"},
+		{"", ""},
+	}
+	for _, tc := range tests {
+		if got := acc.ContactName(tc.number); got != tc.want {
+			t.Errorf("ContactName(%q) = %q, want %q", tc.number, got, tc.want)
+		}
+	}
+	// failed lookups are cached as well
+	if d.calls != 3 {
+		t.Errorf("Driver was asked %d times, want 3", d.calls)
+	}
+
+	if err := acc.SetContactName("+49c", "Carol"); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if got := acc.ContactName("+49c"); got != "Carol" {
+		t.Errorf("ContactName after rename = %q, want Carol", got)
+	}
+
+	if _, err := acc.ListContacts(); !errors.Is(err, ErrNotSupported) {
+		t.Errorf("ListContacts err = %v, want ErrNotSupported", err)
+	}
+}
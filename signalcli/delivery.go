@@ -0,0 +1,189 @@
+package signalcli
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"fmt"
+	"maps"
+	"sync"
+	"time"
+)
+
+// how far a sent message got. Ordered, a later status implies the earlier
+// ones.
+type DeliveryStatus int
+
+const (
+	StatusSent DeliveryStatus = iota
+	StatusDelivered
+	StatusRead
+	StatusViewed
+)
+
+var deliveryStatusNames = []string{"sent", "delivered", "read", "viewed"}
+
+func (s DeliveryStatus) String() string {
+	if s < 0 || int(s) >= len(deliveryStatusNames) {
+		return fmt.Sprintf("DeliveryStatus(%d)", int(s))
+	}
+	return deliveryStatusNames[s]
+}
+
+func (s DeliveryStatus) MarshalText() ([]byte, error) {
+	return []byte(s.String()), nil
+}
+
+func (k ReceiptKind) status() DeliveryStatus {
+	switch k {
+	case ReceiptRead:
+		return StatusRead
+	case ReceiptViewed:
+		return StatusViewed
+	default:
+		return StatusDelivered
+	}
+}
+
+// configuration of the delivery tracking. Can be parsed from yaml
+type DeliveryCfg struct {
+	// how long the status of a sent message is kept
+	Retention time.Duration `yaml:"retention"`
+	// messages which were not delivered after this time are reported as
+	// Undelivered event (0 disables)
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+var DefaultDeliveryCfg DeliveryCfg = DeliveryCfg{
+	Retention: 24 * time.Hour,
+	Timeout:   15 * time.Minute,
+}
+
+// delivery status of a sent message
+type Delivery struct {
+	// as returned when sending
+	Timestamp int64     `json:"timestamp"`
+	Chat      string    `json:"chat"`
+	Sent      time.Time `json:"sent"`
+	// most advanced status reported by any recipient
+	Status  DeliveryStatus `json:"status"`
+	Updated time.Time      `json:"updated"`
+	// status per recipient which sent a receipt (several ones for groups)
+	Recipients map[string]DeliveryStatus `json:"recipients,omitempty"`
+}
+
+// event which is emitted if a message was not delivered within
+// DeliveryCfg.Timeout
+type Undelivered struct {
+	Delivery
+}
+
+func (s *Account) AddUndeliveredHandlerFunc(h func(*Undelivered)) error {
+	return addEventHandler(s, h)
+}
+
+// status of the message sent with `timestamp`. Returns false if the message
+// is unknown (e.g. it was sent before the retention period).
+func (s *Account) DeliveryStatus(timestamp int64) (Delivery, bool) {
+	return s.deliveries.get(timestamp)
+}
+
+type trackedDelivery struct {
+	Delivery
+	reported bool
+}
+
+// tracks the delivery status of sent messages. Safe for concurrent use.
+type deliveries struct {
+	cfg DeliveryCfg
+	mu  sync.Mutex
+	m   map[int64]*trackedDelivery
+}
+
+func newDeliveries(cfg DeliveryCfg) *deliveries {
+	return &deliveries{
+		cfg: cfg,
+		m:   make(map[int64]*trackedDelivery),
+	}
+}
+
+// record that a message was sent
+func (d *deliveries) sent(timestamp int64, chat string, now time.Time) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.m[timestamp] = &trackedDelivery{Delivery: Delivery{
+		Timestamp: timestamp,
+		Chat:      chat,
+		Sent:      now,
+		Status:    StatusSent,
+		Updated:   now,
+	}}
+}
+
+// update the status of the messages the receipt is for
+func (d *deliveries) receipt(r *Receipt, now time.Time) {
+	status := r.Kind.status()
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, ts := range r.Timestamps {
+		t, ok := d.m[ts]
+		if !ok {
+			continue
+		}
+		if t.Recipients == nil {
+			t.Recipients = make(map[string]DeliveryStatus)
+		}
+		if status > t.Recipients[r.Sender] {
+			t.Recipients[r.Sender] = status
+		}
+		if status > t.Status {
+			t.Status = status
+			t.Updated = now
+		}
+	}
+}
+
+// returns a copy of the status of the message
+func (d *deliveries) get(timestamp int64) (Delivery, bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	t, ok := d.m[timestamp]
+	if !ok {
+		return Delivery{}, false
+	}
+	ret := t.Delivery
+	ret.Recipients = maps.Clone(t.Recipients)
+	return ret, true
+}
+
+// returns the messages which were not delivered within the timeout and were
+// not returned before. Forgets messages older than the retention period.
+func (d *deliveries) overdue(now time.Time) []Delivery {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var ret []Delivery
+	for ts, t := range d.m {
+		if now.Sub(t.Sent) > d.cfg.Retention {
+			delete(d.m, ts)
+			continue
+		}
+		if d.cfg.Timeout > 0 && !t.reported && t.Status == StatusSent && now.Sub(t.Sent) > d.cfg.Timeout {
+			t.reported = true
+			ret = append(ret, t.Delivery)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,61 @@
+package signalcli
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDeliveries(t *testing.T) {
+	d := newDeliveries(DeliveryCfg{Retention: time.Hour, Timeout: time.Minute})
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	d.sent(1, "0102", now)
+	d.sent(2, "+49a", now)
+
+	// group: the most advanced status of all members counts
+	d.receipt(&Receipt{Sender: "+49a", Kind: ReceiptDelivery, Timestamps: []int64{1}}, now)
+	d.receipt(&Receipt{Sender: "+49b", Kind: ReceiptRead, Timestamps: []int64{1, 3}}, now)
+	d.receipt(&Receipt{Sender: "+49a", Kind: ReceiptDelivery, Timestamps: []int64{1}}, now)
+	got, ok := d.get(1)
+	if !ok || got.Status != StatusRead {
+		t.Fatalf("Was: %v (%v) but should be %v", got.Status, ok, StatusRead)
+	}
+	if got.Recipients["+49a"] != StatusDelivered || got.Recipients["+49b"] != StatusRead {
+		t.Fatalf("Wrong status per recipient: %v", got.Recipients)
+	}
+	if _, ok := d.get(3); ok {
+		t.Fatalf("Receipts for unknown messages should be ignored")
+	}
+
+	// only the undelivered message is reported, and only once
+	if o := d.overdue(now.Add(30 * time.Second)); len(o) != 0 {
+		t.Fatalf("Was: %v but nothing should be overdue yet", o)
+	}
+	if o := d.overdue(now.Add(2 * time.Minute)); len(o) != 1 || o[0].Timestamp != 2 {
+		t.Fatalf("Was: %v but only message 2 should be overdue", o)
+	}
+	if o := d.overdue(now.Add(3 * time.Minute)); len(o) != 0 {
+		t.Fatalf("Was: %v but should have been reported already", o)
+	}
+
+	// forgotten after the retention period
+	d.overdue(now.Add(2 * time.Hour))
+	if _, ok := d.get(1); ok {
+		t.Fatalf("Should have been forgotten")
+	}
+}
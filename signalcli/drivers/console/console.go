@@ -21,84 +21,263 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"strings"
+	"sync"
+	"time"
 
 	"signalbot_go/signalcli"
 )
 
+// driver which reads messages from stdin (or a script) and prints what the
+// bot sends. Lines starting with "/" are commands for the console itself (see
+// /help).
 type SignalCliDriver struct {
+	signalcli.NoContacts
 	driverInter signalcli.InterDriverToAcc
 	selfNr      string
 	log         *slog.Logger
 	ctx     context.Context
 	cFunc       context.CancelFunc
+
+	in          io.Reader
+	out         io.Writer
+	interactive bool
+
+	mu sync.Mutex
+	// identity the messages are sent as
+	sender string
+	// current group (empty for the private chat with the bot)
+	group string
+	// attachments for the next message
+	attachments []string
+	// time of the last message sent by the bot
+	lastSend time.Time
 }
 
 const SELF_NR = "+4900"
 
+// time without a reply from the bot after which the next line of a script is
+// read
+var ScriptSettle = 300 * time.Millisecond
+
 func NewSignalJsonRpcDriver(log *slog.Logger, unixSocket string, selfNr string) (*SignalCliDriver, error) {
+	if selfNr == "" {
+		selfNr = SELF_NR
+	}
 	scd := &SignalCliDriver{
-		selfNr: SELF_NR,
+		selfNr: selfNr,
 		log:    log,
+		in:          os.Stdin,
+		out:         os.Stdout,
+		interactive: true,
+		sender:      selfNr,
 	}
 	scd.ctx, scd.cFunc = context.WithCancel(context.Background())
 	return scd, nil
 }
 
+// read the lines from `script` instead of stdin. Each line is only read when
+// the bot stopped replying to the previous one.
+func (scd *SignalCliDriver) SetScript(script io.Reader) {
+	scd.in = script
+	scd.interactive = false
+}
+
 func (scd *SignalCliDriver) GetSelfNumber() (string, error) {
 	return scd.selfNr, nil
 }
 
-var PROMPT = fmt.Sprintf("%s: ", SELF_NR)
+// the prompt showing who is writing where
+func (scd *SignalCliDriver) prompt() string {
+	scd.mu.Lock()
+	defer scd.mu.Unlock()
+	if scd.group != "" {
+		return fmt.Sprintf("%s@%s: ", scd.sender, scd.group)
+	}
+	return fmt.Sprintf("%s: ", scd.sender)
+}
 
 func (scd *SignalCliDriver) Start() {
-	reader := bufio.NewReader(os.Stdin)
+	scanner := bufio.NewScanner(scd.in)
 	for {
-		fmt.Print(PROMPT)
-		msg, err := reader.ReadString('\n')
-		if err != nil {
+		if scd.interactive {
+			fmt.Fprint(scd.out, scd.prompt())
+		}
+		if !scanner.Scan() {
+			if !scd.interactive {
+				scd.log.Info("script finished")
+			}
 			return
 		}
-		m := signalcli.Message{
-			Timestamp:   0,
-			Sender:      SELF_NR,
-			Receiver:    SELF_NR,
-			Message:     msg,
+		line := scanner.Text()
+		if strings.HasPrefix(line, "/") {
+			if err := scd.command(line); err != nil {
+				fmt.Fprintln(scd.out, "Error:", err)
+			}
+		} else if !scd.interactive && (strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#")) {
+			// empty lines and comments of a script
+		} else {
+			scd.driverInter.MessageChan <- scd.message(line)
 		}
-		m.Chat = m.Sender
-		scd.driverInter.MessageChan <- &m
 		select {
 		case <- scd.ctx.Done():
 			return
 		default:
 		}
+		if !scd.interactive {
+			scd.settle()
+		}
+	}
+}
+
+// the message of the current sender to the current chat
+func (scd *SignalCliDriver) message(text string) *signalcli.Message {
+	scd.mu.Lock()
+	defer scd.mu.Unlock()
+	m := signalcli.Message{
+		Timestamp:   time.Now().UnixMilli(),
+		Sender:      scd.sender,
+		Receiver:    scd.selfNr,
+		Message:     text,
+		Attachments: scd.attachments,
+	}
+	scd.attachments = nil
+	m.Chat = m.Sender
+	if scd.group != "" {
+		m.GroupId = []byte(scd.group)
+		m.Chat = hex.EncodeToString(m.GroupId)
+	}
+	return &m
+}
+
+// wait until the bot did not send anything for ScriptSettle
+func (scd *SignalCliDriver) settle() {
+	start := time.Now()
+	for {
+		select {
+		case <-scd.ctx.Done():
+			return
+		case <-time.After(ScriptSettle):
+		}
+		scd.mu.Lock()
+		last := scd.lastSend
+		scd.mu.Unlock()
+		if last.Before(start) || time.Since(last) >= ScriptSettle {
+			return
+		}
+	}
+}
+
+const help = `/as <number>    send as <number> (empty: as the bot account)
+/group <name>   write to the group <name> (empty: private chat)
+/attach <file>  attach <file> to the next message
+/wait <dur>     wait for <dur> (e.g. 2s)
+/help           show this help`
+
+// execute a command of the console
+func (scd *SignalCliDriver) command(line string) error {
+	cmd, arg, _ := strings.Cut(strings.TrimPrefix(line, "/"), " ")
+	arg = strings.TrimSpace(arg)
+	scd.mu.Lock()
+	defer scd.mu.Unlock()
+	switch cmd {
+	case "as":
+		if arg == "" {
+			arg = scd.selfNr
+		}
+		scd.sender = arg
+	case "group":
+		scd.group = arg
+	case "attach":
+		if arg == "" {
+			return fmt.Errorf("no file given")
+		}
+		abs, err := filepath.Abs(arg)
+		if err != nil {
+			return err
+		}
+		if _, err := os.Stat(abs); err != nil {
+			return err
+		}
+		scd.attachments = append(scd.attachments, abs)
+	case "wait":
+		d, err := time.ParseDuration(arg)
+		if err != nil {
+			return err
+		}
+		scd.mu.Unlock()
+		select {
+		case <-scd.ctx.Done():
+		case <-time.After(d):
+		}
+		scd.mu.Lock()
+	case "help":
+		fmt.Fprintln(scd.out, help)
+	default:
+		return fmt.Errorf("unknown command /%s (see /help)", cmd)
 	}
+	return nil
 }
 
 func (scd *SignalCliDriver) Close() {
 	scd.cFunc()
 }
 
+// there is nobody who could see it
+func (scd *SignalCliDriver) SendTypingIndicator(recipient string, groupId []byte, stop bool) error {
+	return nil
+}
+
+// stdin is always available
+func (scd *SignalCliDriver) State() signalcli.ConnectionState {
+	if scd.ctx.Err() != nil {
+		return signalcli.StateClosed
+	}
+	return signalcli.StateConnected
+}
+
+// print a message sent by the bot to `chat`
+func (scd *SignalCliDriver) print(chat string, message string, attachments []string) {
+	scd.mu.Lock()
+	scd.lastSend = time.Now()
+	scd.mu.Unlock()
+
+	b := strings.Builder{}
+	if scd.interactive {
+		// replace the prompt
+		b.WriteString("\x1b[2K\r")
+	}
+	prefix := fmt.Sprintf("> %s:", chat)
+	b.WriteString(prefix + " ")
+	b.WriteString(strings.ReplaceAll(message, "\n", "\n"+strings.Repeat(" ", len(prefix)+1)))
+	b.WriteString("\n")
+	for _, a := range attachments {
+		fmt.Fprintf(&b, "%s  [attachment] %s\n", strings.Repeat(" ", len(prefix)-1), a)
+	}
+	if scd.interactive {
+		b.WriteString(scd.prompt())
+	}
+	fmt.Fprint(scd.out, b.String())
+}
+
 func (scd *SignalCliDriver) SendMessage(message string, attachments []string, recipient string, notifySelf bool) (int64, error) {
-	fmt.Print("\x33[2K\r")
-	prefix := fmt.Sprintf("> %s:", recipient)
-	message = strings.ReplaceAll(message, "\n", "\n"+strings.Repeat(" ", len(prefix)+1))
-	fmt.Println(prefix, message)
-	fmt.Print(PROMPT)
+	scd.print(recipient, message, attachments)
 	return 0, nil
 }
 
 func (scd *SignalCliDriver) SendGroupMessage(message string, attachments []string, groupId []byte) (int64, error) {
 	gn, _ := scd.GetGroupName(groupId)
-	fmt.Println("\332K\331G", "=> ", gn, ": ", message)
-	fmt.Print(PROMPT)
+	scd.print("group "+gn, message, attachments)
 	return 0, nil
 }
 
+// the ids of the groups of the console are their names
 func (scd *SignalCliDriver) GetGroupName(groupId []byte) (string, error) {
-	return hex.EncodeToString(groupId), nil
+	return string(groupId), nil
 }
 
 func (scd *SignalCliDriver) SetInterface(inter signalcli.InterDriverToAcc) (err error) {
@@ -0,0 +1,90 @@
+package signalconsole
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"signalbot_go/signalcli"
+)
+
+func TestScript(t *testing.T) {
+	ScriptSettle = 10 * time.Millisecond
+	dir := t.TempDir()
+	file := filepath.Join(dir, "pic.png")
+	if err := os.WriteFile(file, []byte("png"), 0o600); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	d, err := NewSignalJsonRpcDriver(log, "", "+49100")
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	out := &bytes.Buffer{}
+	d.out = out
+	d.SetScript(strings.NewReader(`# comment
+hello
+/as +49123
+/group family
+
+/attach ` + file + `
+look
+/nope
+`))
+	msgs := make(chan *signalcli.Message, 5)
+	d.SetInterface(signalcli.InterDriverToAcc{MessageChan: msgs})
+	d.Start()
+	close(msgs)
+
+	exp := []signalcli.Message{
+		{Sender: "+49100", Receiver: "+49100", Chat: "+49100", Message: "hello"},
+		{Sender: "+49123", Receiver: "+49100", Chat: hex.EncodeToString([]byte("family")), GroupId: []byte("family"), Message: "look", Attachments: []string{file}},
+	}
+	i := 0
+	for m := range msgs {
+		if i >= len(exp) {
+			t.Fatalf("Unexpected message: %v", m)
+		}
+		m.Timestamp = 0
+		if !reflect.DeepEqual(*m, exp[i]) {
+			t.Fatalf("Was: %+v but should be: %+v", *m, exp[i])
+		}
+		i++
+	}
+	if i != len(exp) {
+		t.Fatalf("Got %d messages but should be %d", i, len(exp))
+	}
+	if !strings.Contains(out.String(), "unknown command /nope") {
+		t.Fatalf("Was: %q", out.String())
+	}
+
+	out.Reset()
+	d.SendGroupMessage("hi\nthere", []string{file}, []byte("family"))
+	if exp := "> group family: hi\n                there\n                [attachment] " + file + "\n"; out.String() != exp {
+		t.Fatalf("Was: %q but should be: %q", out.String(), exp)
+	}
+}
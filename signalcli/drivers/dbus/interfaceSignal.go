@@ -17,7 +17,9 @@ package signaldbus
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
 import (
+	"errors"
 	"fmt"
+	"signalbot_go/signalcli"
 
 	"github.com/godbus/dbus/v5"
 )
@@ -28,14 +30,11 @@ import (
 
 // Get the name correspnding to a number.
 func (s *SignalCliDriver) GetContactName(number string) (name string, err error) {
-	call := s.obj.Call("org.asamk.Signal.getContactName", 0, number)
+	call := s.object().Call("org.asamk.Signal.getContactName", 0, number)
 	if call.Err != nil {
-		err := call.Err.(dbus.Error) // panics if assertion does not succeed
-		switch err.Name {
-		default:
-			panic(fmt.Errorf("signal-cli: %v (%s)", err, err.Name))
-		}
+		return "", fmt.Errorf("signal-cli: %v", call.Err)
 	}
+
 	if err := call.Store(&name); err != nil {
 		return "", fmt.Errorf("signal-cli: %v", err)
 	}
@@ -46,14 +45,11 @@ func (s *SignalCliDriver) GetContactName(number string) (name string, err error)
 // of all known numbers. May result in e.g. two entries if a contact and
 // profile name is set.
 func (s *SignalCliDriver) GetContactNumber(name string) (numbers []string, err error) {
-	call := s.obj.Call("org.asamk.Signal.getContactNumber", 0, name)
+	call := s.object().Call("org.asamk.Signal.getContactNumber", 0, name)
 	if call.Err != nil {
-		err := call.Err.(dbus.Error) // panics if assertion does not succeed
-		switch err.Name {
-		default:
-			panic(fmt.Errorf("signal-cli: %v (%s)", err, err.Name))
-		}
+		return []string{}, fmt.Errorf("signal-cli: %v", call.Err)
 	}
+
 	if err := call.Store(&numbers); err != nil {
 		return []string{}, fmt.Errorf("signal-cli: %v", err)
 	}
@@ -62,13 +58,9 @@ func (s *SignalCliDriver) GetContactNumber(name string) (numbers []string, err e
 
 // For unknown numbers false is returned but no exception is raised.
 func (s *SignalCliDriver) GetSelfNumber() (number string, err error) {
-	call := s.obj.Call("org.asamk.Signal.getSelfNumber", 0)
+	call := s.object().Call("org.asamk.Signal.getSelfNumber", 0)
 	if call.Err != nil {
-		err := call.Err.(dbus.Error) // panics if assertion does not succeed
-		switch err.Name {
-		default:
-			panic(fmt.Errorf("signal-cli: %v (%s)", err, err.Name))
-		}
+		return "", fmt.Errorf("signal-cli: %v", call.Err)
 	}
 	if err := call.Store(&number); err != nil {
 		return "", fmt.Errorf("signal-cli: %v", err)
@@ -79,16 +71,11 @@ func (s *SignalCliDriver) GetSelfNumber() (number string, err error) {
 // For unknown numbers false is returned but no exception is raised.
 // Might rise `InvalidPhoneNumber` exception
 func (s *SignalCliDriver) IsContactBlocked(number string) (blocked bool, err error) {
-	call := s.obj.Call("org.asamk.Signal.isContactBlocked", 0, number)
+	call := s.object().Call("org.asamk.Signal.isContactBlocked", 0, number)
 	if call.Err != nil {
-		err := call.Err.(dbus.Error) // panics if assertion does not succeed
-		switch err.Name {
-		case "org.asamk.Signal.Error.InvalidPhoneNumber":
-			return false, fmt.Errorf("signal-cli: %v", err)
-		default:
-			panic(fmt.Errorf("signal-cli: %v (%s)", err, err.Name))
-		}
+		return false, fmt.Errorf("signal-cli: %v", call.Err)
 	}
+
 	if err := call.Store(&blocked); err != nil {
 		return false, fmt.Errorf("signal-cli: %v", err)
 	}
@@ -99,7 +86,7 @@ func (s *SignalCliDriver) IsContactBlocked(number string) (blocked bool, err err
 // If no number is given, returns true (indicating that you are registered).
 // Might rise `InvalidPhoneNumber` exception
 func (s *SignalCliDriver) IsRegistered() (result bool, err error) {
-	call := s.obj.Call("org.asamk.Signal.isRegistered", 0)
+	call := s.object().Call("org.asamk.Signal.isRegistered", 0)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -119,7 +106,7 @@ func (s *SignalCliDriver) IsRegistered() (result bool, err error) {
 // number is given, returns true (indicating that you are registered).
 // Might rise `InvalidPhoneNumber` exception
 func (s *SignalCliDriver) IsRegistered_num(number string) (result bool, err error) {
-	call := s.obj.Call("org.asamk.Signal.isRegistered", 0, number)
+	call := s.object().Call("org.asamk.Signal.isRegistered", 0, number)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -139,7 +126,7 @@ func (s *SignalCliDriver) IsRegistered_num(number string) (result bool, err erro
 // number is given, returns true (indicating that you are registered).
 // Might rise `InvalidPhoneNumber` exception
 func (s *SignalCliDriver) IsRegistered_nums(numbers []string) (results []bool, err error) {
-	call := s.obj.Call("org.asamk.Signal.isRegistered", 0, numbers)
+	call := s.object().Call("org.asamk.Signal.isRegistered", 0, numbers)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -158,14 +145,11 @@ func (s *SignalCliDriver) IsRegistered_nums(numbers []string) (results []bool, e
 // This is a concatenated list of all defined contacts as well of profiles
 // known (e.g. peer group members or sender of received messages)
 func (s *SignalCliDriver) ListNumbers() (numbers []string, err error) {
-	call := s.obj.Call("org.asamk.Signal.listNumbers", 0)
+	call := s.object().Call("org.asamk.Signal.listNumbers", 0)
 	if call.Err != nil {
-		err := call.Err.(dbus.Error) // panics if assertion does not succeed
-		switch err.Name {
-		default:
-			panic(fmt.Errorf("signal-cli: %v (%s)", err, err.Name))
-		}
+		return []string{}, fmt.Errorf("signal-cli: %v", call.Err)
 	}
+
 	if err := call.Store(&numbers); err != nil {
 		return []string{}, fmt.Errorf("signal-cli: %v", err)
 	}
@@ -175,7 +159,7 @@ func (s *SignalCliDriver) ListNumbers() (numbers []string, err error) {
 // Removes registration PIN protection.
 // Might raise `Failure` exception
 func (s *SignalCliDriver) RemovePin() (err error) {
-	call := s.obj.Call("org.asamk.Signal.removePin", 0)
+	call := s.object().Call("org.asamk.Signal.removePin", 0)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -190,7 +174,7 @@ func (s *SignalCliDriver) RemovePin() (err error) {
 
 // Might raise `Failure`, `InvalidNumber`, `UntrustedIdentity` exceptions
 func (s *SignalCliDriver) SendEndSessionMessage(recipients []string) (err error) {
-	call := s.obj.Call("org.asamk.Signal.sendEndSessionMessage", 0, recipients)
+	call := s.object().Call("org.asamk.Signal.sendEndSessionMessage", 0, recipients)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -214,20 +198,27 @@ func (s *SignalCliDriver) SendEndSessionMessage(recipients []string) (err error)
 // timestamp can be used to identify the corresponding signal reply.
 // Might raise `AttachmentInvalid`, `Failure`, `InvalidNumber`, `UntrustedIdentity` exceptions.`
 func (s *SignalCliDriver) SendMessage(message string, attachments []string, recipient string, notifySelf bool) (timestamp int64, err error) {
-	call := s.obj.Call("org.asamk.Signal.sendMessage", 0, message, attachments, recipient)
+	call := s.object().Call("org.asamk.Signal.sendMessage", 0, message, attachments, recipient)
 	if call.Err != nil {
-		err := call.Err.(dbus.Error) // panics if assertion does not succeed
+		var err dbus.Error
+		if !errors.As(call.Err, &err) {
+			// e.g. the connection to the bus was closed
+			return 0, fmt.Errorf("signal-cli: %w (%v)", signalcli.ErrNotConnected, call.Err)
+		}
 		switch err.Name {
 		case "org.asamk.Signal.Error.AttachmentInvalid":
-			return 0, fmt.Errorf("signal-cli: %v", err)
+			return 0, signalcli.Permanent(fmt.Errorf("signal-cli: %v", err))
 		case "org.asamk.Signal.Error.Failure":
 			return 0, fmt.Errorf("signal-cli: %v", err)
 		case "org.asamk.Signal.Error.InvalidNumber":
-			return 0, fmt.Errorf("signal-cli: %v", err)
+			return 0, signalcli.Permanent(fmt.Errorf("signal-cli: %v", err))
 		case "org.asamk.Signal.Error.UntrustedIdentity":
-			return 0, fmt.Errorf("signal-cli: %v", err)
+			return 0, signalcli.Permanent(fmt.Errorf("signal-cli: %v", err))
+		case "org.freedesktop.DBus.Error.ServiceUnknown":
+			// signal-cli is (re)starting and not available on the bus
+			return 0, fmt.Errorf("signal-cli: %w (%v)", signalcli.ErrNotConnected, err)
 		default:
-			panic(fmt.Errorf("signal-cli: %v (%s)", err, err.Name))
+			return 0, fmt.Errorf("signal-cli: %v (%s)", err, err.Name)
 		}
 	}
 	if err := call.Store(&timestamp); err != nil {
@@ -243,7 +234,7 @@ func (s *SignalCliDriver) SendMessage(message string, attachments []string, reci
 // returned timestamp can be used to identify the corresponding signal reply.
 // Might raise `AttachmentInvalid`, `Failure`, `InvalidNumber`, `UntrustedIdentity` exceptions.`
 func (s *SignalCliDriver) SendMessage_multi(message string, attachments []string, recipients []string, notifySelf bool) (timestamp int64, err error) {
-	call := s.obj.Call("org.asamk.Signal.sendMessage", 0, message, attachments, recipients)
+	call := s.object().Call("org.asamk.Signal.sendMessage", 0, message, attachments, recipients)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -274,7 +265,7 @@ func (s *SignalCliDriver) SendMessage_multi(message string, attachments []string
 // identify the correspnding signal reply.
 // Might raise `Failure`, `InvalidNumber` exceptions.`
 func (s *SignalCliDriver) SendMessageReaction(emoji string, remove bool, targetAuthor string, targetSentTimestamp int64, recipient string) (timestamp int64, err error) {
-	call := s.obj.Call("org.asamk.Signal.sendMessageReaction", 0, emoji, remove, targetAuthor, targetSentTimestamp, recipient)
+	call := s.object().Call("org.asamk.Signal.sendMessageReaction", 0, emoji, remove, targetAuthor, targetSentTimestamp, recipient)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -301,7 +292,7 @@ func (s *SignalCliDriver) SendMessageReaction(emoji string, remove bool, targetA
 // timestamp can be used to identify the correspnding signal reply.
 // Might raise `Failure`, `InvalidNumber` exceptions.`
 func (s *SignalCliDriver) SendMessageReaction_multi(emoji string, remove bool, targetAuthor string, targetSentTimestamp int64, recipients []string) (timestamp int64, err error) {
-	call := s.obj.Call("org.asamk.Signal.sendMessageReaction", 0, emoji, remove, targetAuthor, targetSentTimestamp, recipients)
+	call := s.object().Call("org.asamk.Signal.sendMessageReaction", 0, emoji, remove, targetAuthor, targetSentTimestamp, recipients)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -326,7 +317,7 @@ func (s *SignalCliDriver) SendMessageReaction_multi(emoji string, remove bool, t
 // signal reply.
 // Might raise `AttachmentInvalid`, `Failure` exceptions.`
 func (s *SignalCliDriver) SendNoteToSelfMessage(message string, attachments []string) (timestamp int64, err error) {
-	call := s.obj.Call("org.asamk.Signal.sendNoteToSelfMessage", 0, message, attachments)
+	call := s.object().Call("org.asamk.Signal.sendNoteToSelfMessage", 0, message, attachments)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -351,7 +342,7 @@ func (s *SignalCliDriver) SendNoteToSelfMessage(message string, attachments []st
 // signal-messages.
 // Might raise `Failure`, `UntrustedIdentity` exceptions.
 func (s *SignalCliDriver) SendReadReceipt(recipient string, targetSentTimestamps []int64) (err error) {
-	call := s.obj.Call("org.asamk.Signal.sendReadReceipt", 0, recipient, targetSentTimestamps)
+	call := s.object().Call("org.asamk.Signal.sendReadReceipt", 0, recipient, targetSentTimestamps)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -373,7 +364,7 @@ func (s *SignalCliDriver) SendReadReceipt(recipient string, targetSentTimestamps
 // signal-messages.
 // Might raise `Failure`, `UntrustedIdentity` exceptions.
 func (s *SignalCliDriver) SendViewedReceipt(recipient string, targetSentTimestamps []int64) (err error) {
-	call := s.obj.Call("org.asamk.Signal.sendViewedReceipt", 0, recipient, targetSentTimestamps)
+	call := s.object().Call("org.asamk.Signal.sendViewedReceipt", 0, recipient, targetSentTimestamps)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -394,7 +385,7 @@ func (s *SignalCliDriver) SendViewedReceipt(recipient string, targetSentTimestam
 // used to identify the corresponding signal reply.
 // Might raise `Failure`, `InvalidNumber` exceptions`
 func (s *SignalCliDriver) SendRemoteDeleteMessage(targetSentTimestamp int64, recipient string) (timestamp int64, err error) {
-	call := s.obj.Call("org.asamk.Signal.sendRemoteDeleteMessage", 0, targetSentTimestamp, recipient)
+	call := s.object().Call("org.asamk.Signal.sendRemoteDeleteMessage", 0, targetSentTimestamp, recipient)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -418,7 +409,7 @@ func (s *SignalCliDriver) SendRemoteDeleteMessage(targetSentTimestamp int64, rec
 // timestamp can be used to identify the corresponding signal reply.
 // Might raise `Failure`, `InvalidNumber` exceptions`
 func (s *SignalCliDriver) SendRemoteDeleteMessage_multi(targetSentTimestamp int64, recipients []string) (timestamp int64, err error) {
-	call := s.obj.Call("org.asamk.Signal.sendRemoteDeleteMessage", 0, targetSentTimestamp, recipients)
+	call := s.object().Call("org.asamk.Signal.sendRemoteDeleteMessage", 0, targetSentTimestamp, recipients)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -441,37 +432,31 @@ func (s *SignalCliDriver) SendRemoteDeleteMessage_multi(targetSentTimestamp int6
 // typing indicator is removed.
 // Might raise `Failure`, `UntrustedIdentity` exceptions.`
 func (s *SignalCliDriver) SendTyping(recipient string, stop bool) (err error) {
-	call := s.obj.Call("org.asamk.Signal.sendTyping", 0, recipient, stop)
+	call := s.object().Call("org.asamk.Signal.sendTyping", 0, recipient, stop)
 	if call.Err != nil {
-		err := call.Err.(dbus.Error) // panics if assertion does not succeed
-		switch err.Name {
-		case "org.asamk.Signal.Error.UntrustedIdentity":
-			return fmt.Errorf("signal-cli: %v", err)
-		case "org.asamk.Signal.Error.Failure":
-			return fmt.Errorf("signal-cli: %v", err)
-		default:
-			panic(fmt.Errorf("signal-cli: %v (%s)", err, err.Name))
-		}
+		return fmt.Errorf("signal-cli: %v", call.Err)
 	}
 	return nil
 }
 
+func (s *SignalCliDriver) SendTypingIndicator(recipient string, groupId []byte, stop bool) error {
+	if len(groupId) > 0 {
+		return s.SendGroupTyping(groupId, stop)
+	}
+	return s.SendTyping(recipient, stop)
+}
+
 // (Un)block a phoneNumber.
 // number is the phoneNumber to block, if block is false the phoneNumber is
 // being unblocked. Messages from blocked numbers won't appear on the DBus
 // anymore.
 // Might raise `InvalidNumber` exception
 func (s *SignalCliDriver) SetContactBlocked(number string, block bool) (err error) {
-	call := s.obj.Call("org.asamk.Signal.setContactBlocked", 0, number, block)
+	call := s.object().Call("org.asamk.Signal.setContactBlocked", 0, number, block)
 	if call.Err != nil {
-		err := call.Err.(dbus.Error) // panics if assertion does not succeed
-		switch err.Name {
-		case "org.asamk.Signal.Error.InvalidNumber":
-			return fmt.Errorf("signal-cli: %v", err)
-		default:
-			panic(fmt.Errorf("signal-cli: %v (%s)", err, err.Name))
-		}
+		return fmt.Errorf("signal-cli: %v", call.Err)
 	}
+
 	return nil
 }
 
@@ -480,18 +465,11 @@ func (s *SignalCliDriver) SetContactBlocked(number string, block bool) (err erro
 // (in local storage with signal-cli).
 // Might raise `InvalidNumber`, `Failure` exceptions.
 func (s *SignalCliDriver) SetContactName(number string, name string) (err error) {
-	call := s.obj.Call("org.asamk.Signal.setContactName", 0, number, name)
+	call := s.object().Call("org.asamk.Signal.setContactName", 0, number, name)
 	if call.Err != nil {
-		err := call.Err.(dbus.Error) // panics if assertion does not succeed
-		switch err.Name {
-		case "org.asamk.Signal.Error.InvalidNumber":
-			return fmt.Errorf("signal-cli: %v", err)
-		case "org.asamk.Signal.Error.Failure":
-			return fmt.Errorf("signal-cli: %v", err)
-		default:
-			panic(fmt.Errorf("signal-cli: %v (%s)", err, err.Name))
-		}
+		return fmt.Errorf("signal-cli: %v", call.Err)
 	}
+
 	return nil
 }
 
@@ -499,7 +477,7 @@ func (s *SignalCliDriver) SetContactName(number string, name string) (err error)
 // number is the phoneNumber to delete.
 // Might raise `Failure` exception.
 func (s *SignalCliDriver) DeleteContact(number string) (err error) {
-	call := s.obj.Call("org.asamk.Signal.deleteContact", 0, number)
+	call := s.object().Call("org.asamk.Signal.deleteContact", 0, number)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -516,7 +494,7 @@ func (s *SignalCliDriver) DeleteContact(number string) (err error) {
 // number is the phoneNumber.
 // Might raise `Failure` exception.
 func (s *SignalCliDriver) DeleteRecipient(number string) (err error) {
-	call := s.obj.Call("org.asamk.Signal.deleteRecipient", 0, number)
+	call := s.object().Call("org.asamk.Signal.deleteRecipient", 0, number)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -534,18 +512,11 @@ func (s *SignalCliDriver) DeleteRecipient(number string) (err error) {
 // before messages disappear (set to 0 to disable).
 // Might raise `Failure`, `InvalidNumber` exceptions.
 func (s *SignalCliDriver) SetExpirationTimer(number string, expiration int32) (err error) {
-	call := s.obj.Call("org.asamk.Signal.setExpirationTimer", 0, number, expiration)
+	call := s.object().Call("org.asamk.Signal.setExpirationTimer", 0, number, expiration)
 	if call.Err != nil {
-		err := call.Err.(dbus.Error) // panics if assertion does not succeed
-		switch err.Name {
-		case "org.asamk.Signal.Error.InvalidNumber":
-			return fmt.Errorf("signal-cli: %v", err)
-		case "org.asamk.Signal.Error.Failure":
-			return fmt.Errorf("signal-cli: %v", err)
-		default:
-			panic(fmt.Errorf("signal-cli: %v (%s)", err, err.Name))
-		}
+		return fmt.Errorf("signal-cli: %v", call.Err)
 	}
+
 	return nil
 }
 
@@ -555,7 +526,7 @@ func (s *SignalCliDriver) SetExpirationTimer(number string, expiration int32) (e
 // Might raise `Failure` exception.
 func (s *SignalCliDriver) SetPin(pin string) (err error) {
 	// TODO arg pin: can only contain numbers?
-	call := s.obj.Call("org.asamk.Signal.setPin", 0, pin)
+	call := s.object().Call("org.asamk.Signal.setPin", 0, pin)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -573,7 +544,7 @@ func (s *SignalCliDriver) SetPin(pin string) (err error) {
 // used to lift some rate-limits by solving a captcha).
 // Might raise `IOErrorException` exception
 func (s *SignalCliDriver) SubmitRateLimitChallenge(challenge string, captcha string) (err error) {
-	call := s.obj.Call("org.asamk.Signal.submitRateLimitChallenge", 0, challenge, captcha)
+	call := s.object().Call("org.asamk.Signal.submitRateLimitChallenge", 0, challenge, captcha)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -593,16 +564,11 @@ func (s *SignalCliDriver) SubmitRateLimitChallenge(challenge string, captcha str
 // being unchanged.
 // Might raise: `Failure` exception.
 func (s *SignalCliDriver) UpdateProfile(name string, about string, aboutEmoji string, avatar string, remove bool) (err error) {
-	call := s.obj.Call("org.asamk.Signal.updateProfile", 0, name, about, aboutEmoji, avatar, remove)
+	call := s.object().Call("org.asamk.Signal.updateProfile", 0, name, about, aboutEmoji, avatar, remove)
 	if call.Err != nil {
-		err := call.Err.(dbus.Error) // panics if assertion does not succeed
-		switch err.Name {
-		case "org.asamk.Signal.Error.Failure":
-			return fmt.Errorf("signal-cli: %v", err)
-		default:
-			panic(fmt.Errorf("signal-cli: %v (%s)", err, err.Name))
-		}
+		return fmt.Errorf("signal-cli: %v", call.Err)
 	}
+
 	return nil
 }
 
@@ -613,16 +579,11 @@ func (s *SignalCliDriver) UpdateProfile(name string, about string, aboutEmoji st
 // Strings set to "" result in this property being unchanged.
 // Might raise: `Failure` exception.
 func (s *SignalCliDriver) UpdateProfile_firstLastName(givenName string, familyName string, about string, aboutEmoji string, avatar string, remove bool) (err error) {
-	call := s.obj.Call("org.asamk.Signal.updateProfile", 0, givenName, familyName, about, aboutEmoji, avatar, remove)
+	call := s.object().Call("org.asamk.Signal.updateProfile", 0, givenName, familyName, about, aboutEmoji, avatar, remove)
 	if call.Err != nil {
-		err := call.Err.(dbus.Error) // panics if assertion does not succeed
-		switch err.Name {
-		case "org.asamk.Signal.Error.Failure":
-			return fmt.Errorf("signal-cli: %v", err)
-		default:
-			panic(fmt.Errorf("signal-cli: %v (%s)", err, err.Name))
-		}
+		return fmt.Errorf("signal-cli: %v", call.Err)
 	}
+
 	return nil
 }
 
@@ -632,7 +593,7 @@ func (s *SignalCliDriver) UpdateProfile_firstLastName(givenName string, familyNa
 // upload.
 // Might raise `Failure` exception.
 func (s *SignalCliDriver) UploadStickerPack(stickerPackPath string) (url string, err error) {
-	call := s.obj.Call("org.asamk.Signal.uploadStickerPack", 0, stickerPackPath)
+	call := s.object().Call("org.asamk.Signal.uploadStickerPack", 0, stickerPackPath)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -651,7 +612,7 @@ func (s *SignalCliDriver) UploadStickerPack(stickerPackPath string) (url string,
 // Get the version of signal-cli
 // The returned version is the version-string if signal-cli.
 func (s *SignalCliDriver) Version() (version string, err error) {
-	call := s.obj.Call("org.asamk.Signal.version", 0)
+	call := s.object().Call("org.asamk.Signal.version", 0)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -664,3 +625,26 @@ func (s *SignalCliDriver) Version() (version string, err error) {
 	}
 	return version, nil
 }
+
+// List the known numbers together with their name and whether they are
+// blocked.
+func (s *SignalCliDriver) ListContacts() ([]signalcli.Contact, error) {
+	numbers, err := s.ListNumbers()
+	if err != nil {
+		return nil, err
+	}
+	ret := make([]signalcli.Contact, 0, len(numbers))
+	for _, n := range numbers {
+		c := signalcli.Contact{Number: n}
+		if c.Name, err = s.GetContactName(n); err != nil {
+			return nil, err
+		}
+		if c.Blocked, err = s.IsContactBlocked(n); err != nil {
+			return nil, err
+		}
+		ret = append(ret, c)
+	}
+	return ret, nil
+}
+
+var _ signalcli.ContactDriver = &SignalCliDriver{}
@@ -17,7 +17,9 @@ package signaldbus
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
 import (
+	"errors"
 	"fmt"
+	"signalbot_go/signalcli"
 
 	"github.com/godbus/dbus/v5"
 )
@@ -33,20 +35,9 @@ import (
 // group.
 // Might raise `AttachmentInvalid`, `Failure`, `InvalidNumber` exceptions.
 func (s *SignalCliDriver) CreateGroup(groupName string, members []string, avatar string) (groupId []byte, err error) {
-	// TODO arg members: phone numbers or names?
-	call := s.obj.Call("org.asamk.Signal.createGroup", 0, groupName, members, avatar)
+	call := s.object().Call("org.asamk.Signal.createGroup", 0, groupName, members, avatar)
 	if call.Err != nil {
-		err := call.Err.(dbus.Error) // panics if assertion does not succeed
-		switch err.Name {
-		case "org.asamk.Signal.Error.AttachmentInvalid":
-			return []byte{}, fmt.Errorf("signal-cli: %v", err)
-		case "org.asamk.Signal.Error.Failure":
-			return []byte{}, fmt.Errorf("signal-cli: %v", err)
-		case "org.asamk.Signal.Error.InvalidNumber":
-			return []byte{}, fmt.Errorf("signal-cli: %v", err)
-		default:
-			panic(fmt.Errorf("signal-cli: %v (%s)", err, err.Name))
-		}
+		return []byte{}, fmt.Errorf("signal-cli: %v", call.Err)
 	}
 	if err := call.Store(&groupId); err != nil {
 		return []byte{}, fmt.Errorf("signal-cli: %v", err)
@@ -54,34 +45,124 @@ func (s *SignalCliDriver) CreateGroup(groupName string, members []string, avatar
 	return groupId, nil
 }
 
-// TODO how to return an invalid objectpath and we shouldn't expose dbus stuff here
-// func (s *SignalCliDriver) GetGroup(groupId []byte) (objectPath dbus.ObjectPath, err error) {
-// 	call := s.obj.Call("org.asamk.Signal.getGroup", 0, groupId)
-// 	if call.Err != nil {
-// 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
-// 		switch err.Name {
-// 			default:
-// 				panic(fmt.Errorf("signal-cli: %v (%s)", err, err.Name))
-// 		}
-// 	}
-// 	if err := call.Store(&objectPath); err != nil {
-// 		return nil, fmt.Errorf("signal-cli: %v", err)
-// 	}
-// 	return objectPath, nil
-// }
+// object of the group on the bus (implements org.asamk.Signal.Group)
+func (s *SignalCliDriver) groupObject(groupId []byte) (dbus.BusObject, error) {
+	var path dbus.ObjectPath
+	call := s.object().Call("org.asamk.Signal.getGroup", 0, groupId)
+	if call.Err != nil {
+		return nil, fmt.Errorf("signal-cli: %v", call.Err)
+	}
+	if err := call.Store(&path); err != nil {
+		return nil, fmt.Errorf("signal-cli: %v", err)
+	}
+	s.connMu.RLock()
+	defer s.connMu.RUnlock()
+	return s.conn.Object(signalName, path), nil
+}
+
+// read the property `name` of the group object into `v`
+func groupProperty(obj dbus.BusObject, name string, v any) error {
+	prop, err := obj.GetProperty("org.asamk.Signal.Group." + name)
+	if err != nil {
+		return fmt.Errorf("signal-cli: %v", err)
+	}
+	if err := prop.Store(v); err != nil {
+		return fmt.Errorf("signal-cli: %v", err)
+	}
+	return nil
+}
+
+// Get the name, description, members and admins of a group.
+func (s *SignalCliDriver) GetGroup(groupId []byte) (signalcli.Group, error) {
+	obj, err := s.groupObject(groupId)
+	if err != nil {
+		return signalcli.Group{}, err
+	}
+	g := signalcli.Group{Id: groupId}
+	for name, v := range map[string]any{
+		"Name":        &g.Name,
+		"Description": &g.Description,
+		"Members":     &g.Members,
+		"Admins":      &g.Admins,
+	} {
+		if err := groupProperty(obj, name, v); err != nil {
+			return signalcli.Group{}, err
+		}
+	}
+	return g, nil
+}
+
+// List all groups the account knows.
+func (s *SignalCliDriver) ListGroups() ([]signalcli.Group, error) {
+	var groups []struct {
+		Path dbus.ObjectPath
+		Id   []byte
+		Name string
+	}
+	call := s.object().Call("org.asamk.Signal.listGroups", 0)
+	if call.Err != nil {
+		return nil, fmt.Errorf("signal-cli: %v", call.Err)
+	}
+	if err := call.Store(&groups); err != nil {
+		return nil, fmt.Errorf("signal-cli: %v", err)
+	}
+	ret := make([]signalcli.Group, 0, len(groups))
+	for _, g := range groups {
+		grp, err := s.GetGroup(g.Id)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, grp)
+	}
+	return ret, nil
+}
+
+// Change the name, description, avatar or members of a group.
+func (s *SignalCliDriver) UpdateGroup(groupId []byte, update signalcli.GroupUpdate) error {
+	obj, err := s.groupObject(groupId)
+	if err != nil {
+		return err
+	}
+	set := func(name string, v any) error {
+		if err := obj.SetProperty("org.asamk.Signal.Group."+name, dbus.MakeVariant(v)); err != nil {
+			return fmt.Errorf("signal-cli: %v", err)
+		}
+		return nil
+	}
+	if update.Name != nil {
+		if err := set("Name", *update.Name); err != nil {
+			return err
+		}
+	}
+	if update.Description != nil {
+		if err := set("Description", *update.Description); err != nil {
+			return err
+		}
+	}
+	if update.Avatar != "" {
+		if err := set("Avatar", update.Avatar); err != nil {
+			return err
+		}
+	}
+	if len(update.AddMembers) > 0 {
+		if call := obj.Call("org.asamk.Signal.Group.addMembers", 0, update.AddMembers); call.Err != nil {
+			return fmt.Errorf("signal-cli: %v", call.Err)
+		}
+	}
+	if len(update.RemoveMembers) > 0 {
+		if call := obj.Call("org.asamk.Signal.Group.removeMembers", 0, update.RemoveMembers); call.Err != nil {
+			return fmt.Errorf("signal-cli: %v", call.Err)
+		}
+	}
+	return nil
+}
 
 // Translate a groupId to the name of the group.
 // Might raise InvalidGroupId exception
 func (s *SignalCliDriver) GetGroupName(groupId []byte) (name string, err error) {
-	call := s.obj.Call("org.asamk.Signal.getGroupName", 0, groupId)
+	call := s.object().Call("org.asamk.Signal.getGroupName", 0, groupId)
 	if call.Err != nil {
-		err := call.Err.(dbus.Error) // panics if assertion does not succeed
-		switch err.Name {
-		case "org.asamk.Signal.Error.InvalidGroupId":
-			return "", fmt.Errorf("signal-cli: %v", err)
-		default:
-			panic(fmt.Errorf("signal-cli: %v (%s)", err, err.Name))
-		}
+		return "", fmt.Errorf("signal-cli: %v", call.Err)
 	}
 	if err := call.Store(&name); err != nil {
 		return "", fmt.Errorf("signal-cli: %v", err)
@@ -94,13 +175,9 @@ func (s *SignalCliDriver) GetGroupName(groupId []byte) (name string, err error)
 // array with the phoneNumbers of all active members (if the group wasn't found
 // this array is empty).
 func (s *SignalCliDriver) GetGroupMembers(groupId []byte) (members []string, err error) {
-	call := s.obj.Call("org.asamk.Signal.getGroupMembers", 0, groupId)
+	call := s.object().Call("org.asamk.Signal.getGroupMembers", 0, groupId)
 	if call.Err != nil {
-		err := call.Err.(dbus.Error) // panics if assertion does not succeed
-		switch err.Name {
-		default:
-			panic(fmt.Errorf("signal-cli: %v (%s)", err, err.Name))
-		}
+		return []string{}, fmt.Errorf("signal-cli: %v", call.Err)
 	}
 	if err := call.Store(&members); err != nil {
 		return []string{}, fmt.Errorf("signal-cli: %v", err)
@@ -112,24 +189,13 @@ func (s *SignalCliDriver) GetGroupMembers(groupId []byte) (members []string, err
 // inviteURI is the URI of the invitation.
 // Might raise `Failure` exception.
 func (s *SignalCliDriver) JoinGroup(inviteURI string) (err error) {
-	call := s.obj.Call("org.asamk.Signal.joinGroup", 0, inviteURI)
+	call := s.object().Call("org.asamk.Signal.joinGroup", 0, inviteURI)
 	if call.Err != nil {
-		err := call.Err.(dbus.Error) // panics if assertion does not succeed
-		switch err.Name {
-		case "org.asamk.Signal.Error.Failure":
-			return fmt.Errorf("signal-cli: %v", err)
-		default:
-			panic(fmt.Errorf("signal-cli: %v (%s)", err, err.Name))
-		}
+		return fmt.Errorf("signal-cli: %v", call.Err)
 	}
 	return nil
 }
 
-// TODO how to return an invalid objectpath and we shouldn't expose dbus stuff here
-// func (s *SignalCliDriver) ListGroups() (groups<a(oays)>, err error) {
-// 	listGroups
-// }
-
 // Send a message to a group.
 // message is the test to send (can be UTF8), attachments is a string array of
 // filenames to attach (files must be accessible for signal-cli), groupID
@@ -137,20 +203,27 @@ func (s *SignalCliDriver) JoinGroup(inviteURI string) (err error) {
 // identify the corresponding signal reply.
 // Might raise `GroupNotFound`, `Failure`, `AttachmentInvalid`, `InvalidGroupId` exceptions.`
 func (s *SignalCliDriver) SendGroupMessage(message string, attachments []string, groupId []byte) (timestamp int64, err error) {
-	call := s.obj.Call("org.asamk.Signal.sendGroupMessage", 0, message, attachments, groupId)
+	call := s.object().Call("org.asamk.Signal.sendGroupMessage", 0, message, attachments, groupId)
 	if call.Err != nil {
-		err := call.Err.(dbus.Error) // panics if assertion does not succeed
+		var err dbus.Error
+		if !errors.As(call.Err, &err) {
+			// e.g. the connection to the bus was closed
+			return 0, fmt.Errorf("signal-cli: %w (%v)", signalcli.ErrNotConnected, call.Err)
+		}
 		switch err.Name {
 		case "org.asamk.Signal.Error.GroupNotFound":
-			return 0, fmt.Errorf("signal-cli: %v", err)
+			return 0, signalcli.Permanent(fmt.Errorf("signal-cli: %v", err))
 		case "org.asamk.Signal.Error.Failure":
 			return 0, fmt.Errorf("signal-cli: %v", err)
 		case "org.asamk.Signal.Error.AttachmentInvalid":
-			return 0, fmt.Errorf("signal-cli: %v", err)
+			return 0, signalcli.Permanent(fmt.Errorf("signal-cli: %v", err))
 		case "org.asamk.Signal.Error.InvalidGroupId":
-			return 0, fmt.Errorf("signal-cli: %v", err)
+			return 0, signalcli.Permanent(fmt.Errorf("signal-cli: %v", err))
+		case "org.freedesktop.DBus.Error.ServiceUnknown":
+			// signal-cli is (re)starting and not available on the bus
+			return 0, fmt.Errorf("signal-cli: %w (%v)", signalcli.ErrNotConnected, err)
 		default:
-			panic(fmt.Errorf("signal-cli: %v (%s)", err, err.Name))
+			return 0, fmt.Errorf("signal-cli: %v (%s)", err, err.Name)
 		}
 	}
 	if err := call.Store(&timestamp); err != nil {
@@ -164,19 +237,9 @@ func (s *SignalCliDriver) SendGroupMessage(message string, attachments []string,
 // indicator is stopped.
 // Might raise `Failure`, `GroupNotFound`, `UntrustedIdentity` exceptions.
 func (s *SignalCliDriver) SendGroupTyping(groupId []byte, stop bool) (err error) {
-	call := s.obj.Call("org.asamk.Signal.sendGroupTyping", 0, groupId, stop)
+	call := s.object().Call("org.asamk.Signal.sendGroupTyping", 0, groupId, stop)
 	if call.Err != nil {
-		err := call.Err.(dbus.Error) // panics if assertion does not succeed
-		switch err.Name {
-		case "org.asamk.Signal.Error.GroupNotFound":
-			return fmt.Errorf("signal-cli: %v", err)
-		case "org.asamk.Signal.Error.Failure":
-			return fmt.Errorf("signal-cli: %v", err)
-		case "org.asamk.Signal.Error.UntrustedIdentity":
-			return fmt.Errorf("signal-cli: %v", err)
-		default:
-			panic(fmt.Errorf("signal-cli: %v (%s)", err, err.Name))
-		}
+		return fmt.Errorf("signal-cli: %v", call.Err)
 	}
 	return nil
 }
@@ -188,7 +251,7 @@ func (s *SignalCliDriver) SendGroupTyping(groupId []byte, stop bool) (err error)
 // identifies the group wor work on.
 // Might raise `Failure`, `InvalidNumber`, `GroupNotFound`, `InvalidGroupId` exceptions.
 func (s *SignalCliDriver) SendGroupMessageReaction(emoji string, remove bool, targetAuthor string, targetSentTimestamp int64, groupId []byte) (timestamp int64, err error) {
-	call := s.obj.Call("org.asamk.Signal.sendGroupMessageReaction", 0, emoji, remove, targetAuthor, targetSentTimestamp, groupId)
+	call := s.object().Call("org.asamk.Signal.sendGroupMessageReaction", 0, emoji, remove, targetAuthor, targetSentTimestamp, groupId)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -215,7 +278,7 @@ func (s *SignalCliDriver) SendGroupMessageReaction(emoji string, remove bool, ta
 // group to work on. The returned Timestamp can be used to identify the correspnding signal reply.
 // Might raise `Failure`, `GroupNotFound`, `InvalidGroupId` exceptions.
 func (s *SignalCliDriver) SendGroupRemoteDeleteMessage(targetSentTimestamp int64, groupId []byte) (timestamp int64, err error) {
-	call := s.obj.Call("org.asamk.Signal.sendGroupRemoteDeleteMessage", 0, targetSentTimestamp, groupId)
+	call := s.object().Call("org.asamk.Signal.sendGroupRemoteDeleteMessage", 0, targetSentTimestamp, groupId)
 	if call.Err != nil {
 		err := call.Err.(dbus.Error) // panics if assertion does not succeed
 		switch err.Name {
@@ -234,3 +297,5 @@ func (s *SignalCliDriver) SendGroupRemoteDeleteMessage(targetSentTimestamp int64
 	}
 	return timestamp, nil
 }
+
+var _ signalcli.GroupDriver = &SignalCliDriver{}
@@ -17,9 +17,12 @@ package signaldbus
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
 import (
+	"context"
 	"encoding/hex"
 	"fmt"
 	"log/slog"
+	"sync"
+	"time"
 
 	"signalbot_go/signalcli"
 
@@ -34,14 +37,30 @@ const (
 	SystemBus  = "systemBus"
 )
 
+const signalName = "org.asamk.Signal"
+
+// bounds of the wait time between two attempts to connect to the bus
+var (
+	ReconnectMin = time.Second
+	ReconnectMax = time.Minute
+)
+
+// Tracks whether signal-cli is present on the bus (it may restart while the
+// bot is running) and reconnects to the bus if the connection is lost.
 type SignalCliDriver struct {
+	signalcli.StateTracker
+	busType DbusType
+
+	connMu sync.RWMutex
 	conn *dbus.Conn
 	obj  dbus.BusObject
-	driverInter signalcli.InterDriverToAcc
 	signals <-chan *dbus.Signal
+
+	driverInter signalcli.InterDriverToAcc
 	selfNr string
 	log *slog.Logger
-	stop                     chan struct{}
+	ctx context.Context
+	cancel context.CancelFunc
 }
 
 func NewSignalDbusDriver(log *slog.Logger, busType DbusType) (*SignalCliDriver, error) {
@@ -49,21 +68,13 @@ func NewSignalDbusDriver(log *slog.Logger, busType DbusType) (*SignalCliDriver,
 
 	ret := SignalCliDriver{
 		log: log,
-		stop:                     make(chan struct{}),
+		busType: busType,
 	}
+	ret.ctx, ret.cancel = context.WithCancel(context.Background())
 
-	switch busType {
-	case SessionBus:
-		ret.conn, err = dbus.ConnectSessionBus()
-	case SystemBus:
-		ret.conn, err = dbus.ConnectSystemBus()
-	default:
-		return nil, fmt.Errorf("signal-cli: wrong busType\n")
-	}
-	if err != nil {
+	if err = ret.connect(); err != nil {
 		return nil, err
 	}
-	ret.obj = ret.conn.Object("org.asamk.Signal", "/org/asamk/Signal")
 
 	ret.selfNr, err = ret.GetSelfNumber()
 
@@ -71,34 +82,127 @@ func NewSignalDbusDriver(log *slog.Logger, busType DbusType) (*SignalCliDriver,
 	return &ret, nil
 }
 
-func (d *SignalCliDriver) SetInterface(inter signalcli.InterDriverToAcc) (err error) {
-	d.driverInter = inter
+// connect to the bus and subscribe to the signals of signal-cli and to
+// signal-cli appearing on/vanishing from the bus
+func (d *SignalCliDriver) connect() error {
+	var (
+		conn *dbus.Conn
+		err  error
+	)
+	switch d.busType {
+	case SessionBus:
+		conn, err = dbus.ConnectSessionBus()
+	case SystemBus:
+		conn, err = dbus.ConnectSystemBus()
+	default:
+		return fmt.Errorf("signal-cli: wrong busType\n")
+	}
+	if err != nil {
+		return err
+	}
 
-	if err = d.conn.AddMatchSignal(
+	if err = conn.AddMatchSignal(
 		// TODO maybe only add signals for which to listen to here
-		dbus.WithMatchInterface("org.asamk.Signal"),
+		dbus.WithMatchInterface(signalName),
+	); err != nil {
+		conn.Close()
+		return err
+	}
+	if err = conn.AddMatchSignal(
+		dbus.WithMatchSender("org.freedesktop.DBus"),
+		dbus.WithMatchInterface("org.freedesktop.DBus"),
+		dbus.WithMatchMember("NameOwnerChanged"),
+		dbus.WithMatchArg(0, signalName),
 	); err != nil {
+		conn.Close()
 		return err
 	}
 	signals := make(chan *dbus.Signal, 20)
+	conn.Signal(signals)
+
+	var present bool
+	if err = conn.BusObject().Call("org.freedesktop.DBus.NameHasOwner", 0, signalName).Store(&present); err != nil {
+		conn.Close()
+		return err
+	}
+
+	d.connMu.Lock()
+	d.conn = conn
+	d.obj = conn.Object(signalName, "/org/asamk/Signal")
 	d.signals = signals
-	d.conn.Signal(signals)
+	d.connMu.Unlock()
+
+	d.setPresent(present)
+	return nil
+}
+
+// update the state according to signal-cli being present on the bus
+func (d *SignalCliDriver) setPresent(present bool) {
+	state := signalcli.StateDisconnected
+	if present {
+		state = signalcli.StateConnected
+	}
+	if d.SetState(state) {
+		d.log.Info("signal-cli connection state changed", "state", state)
+	}
+}
+
+// reconnect to the bus after the connection was lost. Returns false if the
+// driver was closed in the meantime.
+func (d *SignalCliDriver) reconnect() bool {
+	d.SetState(signalcli.StateDisconnected)
+	d.log.Warn("Lost connection to the bus")
+	backoff := signalcli.Backoff{Min: ReconnectMin, Max: ReconnectMax}
+	for backoff.Wait(d.ctx) {
+		err := d.connect()
+		if err == nil {
+			return true
+		}
+		d.log.Warn("Reconnecting to the bus failed", "err", err)
+	}
+	return false
+}
+
+// object of signal-cli on the current connection
+func (d *SignalCliDriver) object() dbus.BusObject {
+	d.connMu.RLock()
+	defer d.connMu.RUnlock()
+	return d.obj
+}
+
+func (d *SignalCliDriver) SetInterface(inter signalcli.InterDriverToAcc) (err error) {
+	d.driverInter = inter
 	return nil
 }
 
 func (d* SignalCliDriver) Start() {
+	defer d.SetState(signalcli.StateClosed)
+
+	for {
+		d.connMu.RLock()
+		signals := d.signals
+		d.connMu.RUnlock()
 
-	running := true
-	for running {
 		select {
-		case <-d.stop:
-			running = false
-		case ele := <-d.signals:
+		case <-d.ctx.Done():
+			return
+		case ele, ok := <-signals:
+			if !ok {
+				if d.ctx.Err() != nil || !d.reconnect() {
+					return
+				}
+				continue
+			}
 			if ele == nil {
 				continue
 			}
 			d.log.Debug(fmt.Sprintf("%v", ele))
 			switch ele.Name {
+			case "org.freedesktop.DBus.NameOwnerChanged":
+				if len(ele.Body) == 3 {
+					newOwner, _ := ele.Body[2].(string)
+					d.setPresent(newOwner != "")
+				}
 			case "org.asamk.Signal.SyncMessageReceived":
 				msg := NewSyncMessage(ele, d.selfNr)
 				d.log.Debug("driver syncMsg", "msg", msg, "chan", d.driverInter.SyncMessageChan)
@@ -108,10 +212,17 @@ func (d* SignalCliDriver) Start() {
 				d.log.Debug("driver Msg", "msg", msg, "chan", d.driverInter.MessageChan)
 				d.driverInter.MessageChan <- msg
 
+			case "org.asamk.Signal.ReceiptReceivedV2":
+				if r := NewReceipt(ele); r != nil {
+					d.driverInter.EventChan <- r
+				} else {
+					d.log.Info("Unknown receipt caught: ", ele.Name, ele)
+				}
+
 			// known, but currently not used
-			case "org.asamk.Signal.ReceiptReceived":
 			case "org.asamk.Signal.SyncMessageReceivedV2":
-			case "org.asamk.Signal.ReceiptReceivedV2":
+			// sent along with ReceiptReceivedV2 but without the kind
+			case "org.asamk.Signal.ReceiptReceived":
 			case "org.samk.Signal.MessageReceivedV2":
 			default:
 				d.log.Info("Unknown signal caught: ", ele.Name, ele)
@@ -121,7 +232,9 @@ func (d* SignalCliDriver) Start() {
 }
 
 func (d* SignalCliDriver) Close() {
-	d.stop <- struct{}{}
+	d.cancel()
+	d.connMu.RLock()
+	defer d.connMu.RUnlock()
 	d.conn.Close()
 }
 
@@ -151,13 +264,23 @@ func NewSyncMessage(v *dbus.Signal, self string) *signalcli.SyncMessage {
 	return &msg
 }
 
-// func NewReceipt(v *dbus.Signal, self string) *signalcli.Receipt {
-// 	msg := signalcli.Receipt{
-// 		Timestamp: v.Body[0].(int64),
-// 		Sender:    v.Body[1].(string),
-// 	}
-// 	return &msg
-// }
+// parse a ReceiptReceivedV2 signal (timestamp, sender, kind, extras). Returns
+// nil if the kind of the receipt is unknown.
+func NewReceipt(v *dbus.Signal) *signalcli.Receipt {
+	if len(v.Body) < 3 {
+		return nil
+	}
+	kind, ok := signalcli.ParseReceiptKind(fmt.Sprint(v.Body[2]))
+	if !ok {
+		return nil
+	}
+	msg := signalcli.Receipt{
+		Sender:     v.Body[1].(string),
+		Kind:       kind,
+		Timestamps: []int64{v.Body[0].(int64)},
+	}
+	return &msg
+}
 
 func NewMessage(v *dbus.Signal, self string) *signalcli.Message {
 	msg := signalcli.Message{
@@ -0,0 +1,165 @@
+package signaljsonrpc
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"signalbot_go/signalcli"
+	"sort"
+	"strings"
+)
+
+// names of the fields in the json object `raw` which are not decoded into
+// `v` (a pointer to a struct)
+func unknownFields(raw json.RawMessage, v any) []string {
+	var m map[string]json.RawMessage
+	if json.Unmarshal(raw, &m) != nil {
+		return nil
+	}
+	t := reflect.TypeOf(v).Elem()
+	var ret []string
+	for k := range m {
+		known := false
+		for i := 0; i < t.NumField(); i++ {
+			name := t.Field(i).Name
+			if tag, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); tag != "" {
+				name = tag
+			}
+			if strings.EqualFold(k, name) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			ret = append(ret, k)
+		}
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// decode the base64 encoded group id (empty if unset)
+func decodeGid(gid string) ([]byte, error) {
+	if gid == "" {
+		return nil, nil
+	}
+	return base64.StdEncoding.DecodeString(gid)
+}
+
+// hex representation of the group or the sender for private chats
+func chatOf(gid []byte, sender string) string {
+	if len(gid) > 0 {
+		return hex.EncodeToString(gid)
+	}
+	return sender
+}
+
+func newReceipt(env *jsonEnvelope) *signalcli.Receipt {
+	r := env.ReceiptMessage
+	ret := signalcli.Receipt{
+		Sender:     env.Source,
+		When:       int64(r.When),
+		Timestamps: make([]int64, 0, len(r.Timestamps)),
+	}
+	switch {
+	case r.IsViewed:
+		ret.Kind = signalcli.ReceiptViewed
+	case r.IsRead:
+		ret.Kind = signalcli.ReceiptRead
+	default:
+		ret.Kind = signalcli.ReceiptDelivery
+	}
+	for _, ts := range r.Timestamps {
+		ret.Timestamps = append(ret.Timestamps, int64(ts))
+	}
+	return &ret
+}
+
+func newTyping(env *jsonEnvelope) (*signalcli.Typing, error) {
+	gid, err := decodeGid(env.TypingMessage.GroupId)
+	if err != nil {
+		return nil, err
+	}
+	return &signalcli.Typing{
+		Timestamp: int64(env.TypingMessage.Timestamp),
+		Sender:    env.Source,
+		GroupId:   gid,
+		Chat:      chatOf(gid, env.Source),
+		Started:   env.TypingMessage.Action == "STARTED",
+	}, nil
+}
+
+func newEdit(env *jsonEnvelope, self string) (*signalcli.Edit, error) {
+	m, err := newMessage(env, &env.EditMessage.DataMessage, self)
+	if err != nil {
+		return nil, err
+	}
+	return &signalcli.Edit{
+		Message:         *m,
+		TargetTimestamp: int64(env.EditMessage.TargetSentTimestamp),
+	}, nil
+}
+
+func newSticker(env *jsonEnvelope, self string) (*signalcli.Sticker, error) {
+	m, err := newMessage(env, env.DataMessage, self)
+	if err != nil {
+		return nil, err
+	}
+	return &signalcli.Sticker{
+		Message:   *m,
+		PackId:    env.DataMessage.Sticker.PackId,
+		StickerId: env.DataMessage.Sticker.StickerId,
+	}, nil
+}
+
+func newRemoteDelete(env *jsonEnvelope) (*signalcli.RemoteDelete, error) {
+	gid, err := decodeGid(env.DataMessage.GroupInfo.GroupId)
+	if err != nil {
+		return nil, err
+	}
+	return &signalcli.RemoteDelete{
+		Timestamp:       int64(env.DataMessage.Timestamp),
+		Sender:          env.Source,
+		GroupId:         gid,
+		Chat:            chatOf(gid, env.Source),
+		TargetTimestamp: int64(env.DataMessage.RemoteDelete.Timestamp),
+	}, nil
+}
+
+func newStory(env *jsonEnvelope) (*signalcli.Story, error) {
+	s := env.StoryMessage
+	gid, err := decodeGid(s.GroupId)
+	if err != nil {
+		return nil, err
+	}
+	ret := signalcli.Story{
+		Timestamp:     int64(env.Timestamp),
+		Sender:        env.Source,
+		GroupId:       gid,
+		AllowsReplies: s.AllowsReplies,
+	}
+	if s.TextAttachment != nil {
+		ret.Text = s.TextAttachment.Text
+	}
+	if s.FileAttachment != nil {
+		ret.Attachments = []string{s.FileAttachment.Id}
+	}
+	return &ret, nil
+}
@@ -0,0 +1,133 @@
+package signaljsonrpc
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"signalbot_go/signalcli"
+	"testing"
+
+	"golang.org/x/exp/jsonrpc2"
+)
+
+// feed `params` to the driver and return what it passed to the account
+func receive(t *testing.T, params string) any {
+	t.Helper()
+	msgs := make(chan *signalcli.Message, 1)
+	syncs := make(chan *signalcli.SyncMessage, 1)
+	events := make(chan any, 2)
+	d := &SignalCliDriver{log: nopLog(), selfNr: "+49self"}
+	d.SetInterface(signalcli.InterDriverToAcc{MessageChan: msgs, SyncMessageChan: syncs, EventChan: events})
+
+	req, err := jsonrpc2.NewNotification("receive", json.RawMessage(params))
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	d.Handle(context.Background(), req)
+	select {
+	case m := <-msgs:
+		return m
+	case m := <-syncs:
+		return m
+	case e := <-events:
+		return e
+	default:
+		return nil
+	}
+}
+
+func TestEvents(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		params string
+		exp    any
+	}{
+		{
+			name:   "message with unknown fields",
+			params: `{"account":"+49self","envelope":{"source":"+49a","timestamp":5,"newField":1,"dataMessage":{"timestamp":5,"message":"hi","mentions":[]}}}`,
+			exp:    &signalcli.Message{Timestamp: 5, Sender: "+49a", Receiver: "+49self", Chat: "+49a", Message: "hi"},
+		},
+		{
+			name:   "receipt",
+			params: `{"envelope":{"source":"+49a","timestamp":7,"receiptMessage":{"when":7,"isRead":true,"timestamps":[1,2]}}}`,
+			exp:    &signalcli.Receipt{Sender: "+49a", When: 7, Kind: signalcli.ReceiptRead, Timestamps: []int64{1, 2}},
+		},
+		{
+			name:   "typing in group",
+			params: `{"envelope":{"source":"+49a","typingMessage":{"action":"STARTED","timestamp":3,"groupId":"AQI="}}}`,
+			exp:    &signalcli.Typing{Timestamp: 3, Sender: "+49a", GroupId: []byte{1, 2}, Chat: "0102", Started: true},
+		},
+		{
+			name:   "edit",
+			params: `{"envelope":{"source":"+49a","editMessage":{"targetSentTimestamp":1,"dataMessage":{"timestamp":9,"message":"fixed"}}}}`,
+			exp: &signalcli.Edit{
+				Message:         signalcli.Message{Timestamp: 9, Sender: "+49a", Receiver: "+49self", Chat: "+49a", Message: "fixed"},
+				TargetTimestamp: 1,
+			},
+		},
+		{
+			name:   "sticker",
+			params: `{"envelope":{"source":"+49a","dataMessage":{"timestamp":4,"sticker":{"packId":"p","stickerId":2}}}}`,
+			exp: &signalcli.Sticker{
+				Message: signalcli.Message{Timestamp: 4, Sender: "+49a", Receiver: "+49self", Chat: "+49a"},
+				PackId:  "p", StickerId: 2,
+			},
+		},
+		{
+			name:   "remote delete",
+			params: `{"envelope":{"source":"+49a","dataMessage":{"timestamp":6,"remoteDelete":{"timestamp":5}}}}`,
+			exp:    &signalcli.RemoteDelete{Timestamp: 6, Sender: "+49a", Chat: "+49a", TargetTimestamp: 5},
+		},
+		{
+			name:   "story",
+			params: `{"envelope":{"source":"+49a","timestamp":8,"storyMessage":{"allowsReplies":true,"textAttachment":{"text":"story"}}}}`,
+			exp:    &signalcli.Story{Timestamp: 8, Sender: "+49a", Text: "story", AllowsReplies: true},
+		},
+		{
+			name:   "exception",
+			params: `{"exception":{"message":"boom","type":"ProtocolException"},"envelope":{"source":"+49a"}}`,
+			exp:    &signalcli.Exception{Type: "ProtocolException", Message: "boom"},
+		},
+		{
+			name:   "unknown",
+			params: `{"envelope":{"source":"+49a","timestamp":2,"callMessage":{"offerMessage":{}}}}`,
+			exp: &signalcli.UnknownEvent{
+				Timestamp: 2, Sender: "+49a", Fields: []string{"callMessage"},
+				Raw: []byte(`{"source":"+49a","timestamp":2,"callMessage":{"offerMessage":{}}}`),
+			},
+		},
+		{
+			name:   "for another account",
+			params: `{"account":"+49other","envelope":{"source":"+49a","timestamp":5,"dataMessage":{"timestamp":5,"message":"hi"}}}`,
+			exp:    nil,
+		},
+		{
+			name: "wrong type of a field",
+			// the other fields are decoded nevertheless
+			params: `{"envelope":{"source":"+49a","dataMessage":{"timestamp":5,"message":"hi","viewOnce":"yes"}}}`,
+			exp:    &signalcli.Message{Timestamp: 5, Sender: "+49a", Receiver: "+49self", Chat: "+49a", Message: "hi"},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			if ev := receive(t, tc.params); !reflect.DeepEqual(ev, tc.exp) {
+				t.Fatalf("Was: %#v but should be %#v", ev, tc.exp)
+			}
+		})
+	}
+}
@@ -1,50 +1,301 @@
 package signaljsonrpc
 
 import (
-	"context"
 	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"signalbot_go/signalcli"
 )
 
+// error codes of signal-cli for which retrying makes sense
+const (
+	errCodeIO        int64 = -3
+	errCodeRateLimit int64 = -5
+)
+
+// errors sent by signal-cli are permanent (except for io errors and rate
+// limiting). All other errors (e.g. the connection broke) are transient.
+func classifyErr(err error) error {
+	// the error type of jsonrpc2 is not exported, but it can be marshalled
+	var wire struct {
+		Code *int64 `json:"code"`
+	}
+	b, mErr := json.Marshal(err)
+	if mErr != nil || json.Unmarshal(b, &wire) != nil || wire.Code == nil {
+		return err
+	}
+	switch *wire.Code {
+	case errCodeIO, errCodeRateLimit:
+		return err
+	}
+	return signalcli.Permanent(err)
+}
+
 type sendResult struct {
 	// Results []string
 	Timestamp int64
 }
 
 func (d *SignalCliDriver) SendMessage(message string, attachments []string, recipient string, notifySelf bool) (timestamp int64, err error) {
-	ctx := context.Background()
+	return d.SendStyledMessage(message, nil, attachments, recipient, notifySelf)
+}
+
+func (d *SignalCliDriver) SendGroupMessage(message string, attachments []string, groupId []byte) (timestamp int64, err error) {
+	return d.SendStyledGroupMessage(message, nil, attachments, groupId)
+}
+
+// styles in the format of signal-cli (start:length:STYLE)
+func textStyles(styles []signalcli.TextStyle) []string {
+	ret := make([]string, 0, len(styles))
+	for _, s := range styles {
+		ret = append(ret, fmt.Sprintf("%d:%d:%s", s.Start, s.Length, s.Style))
+	}
+	return ret
+}
+
+func (d *SignalCliDriver) SendStyledMessage(message string, styles []signalcli.TextStyle, attachments []string, recipient string, notifySelf bool) (timestamp int64, err error) {
 	var result sendResult
-	err = d.conn.Call(ctx, "send", map[string]any{"recipient": recipient, "message": message, "attachments": attachments, "notifySelf": notifySelf}).Await(ctx, &result)
+	params := map[string]any{"recipient": recipient, "message": message, "attachments": attachments, "notifySelf": notifySelf}
+	if len(styles) > 0 {
+		params["textStyle"] = textStyles(styles)
+	}
+	err = d.call("send", params, &result)
 	if err != nil {
 		d.log.Error("error sending message", "err", err)
-		return 0, err
+		return 0, classifyErr(err)
 	}
 	return result.Timestamp, nil
 }
 
-func (d *SignalCliDriver) SendGroupMessage(message string, attachments []string, groupId []byte) (timestamp int64, err error) {
+func (d *SignalCliDriver) SendStyledGroupMessage(message string, styles []signalcli.TextStyle, attachments []string, groupId []byte) (timestamp int64, err error) {
 	gid := base64.StdEncoding.EncodeToString(groupId)
-	ctx := context.Background()
 	var result sendResult
-	err = d.conn.Call(ctx, "send", map[string]any{"groupId": gid, "message": message, "attachments": attachments}).Await(ctx, &result)
+	params := map[string]any{"groupId": gid, "message": message, "attachments": attachments}
+	if len(styles) > 0 {
+		params["textStyle"] = textStyles(styles)
+	}
+	err = d.call("send", params, &result)
 	if err != nil {
 		d.log.Error("error sending group message", "err", err, "gid", gid, "res", result)
-		return 0, err
+		return 0, classifyErr(err)
 	}
 	return result.Timestamp, nil
 }
 
+var _ signalcli.StyleDriver = &SignalCliDriver{}
+
+type groupMember struct {
+	Number string
+	Uuid string
+}
+
 type groupResult struct {
+	Id string
 	Name string
 	Description string
+	Members []groupMember
+	Admins []groupMember
+}
+
+// phone numbers of the members (or the uuid if the number is unknown)
+func numbers(members []groupMember) []string {
+	ret := make([]string, 0, len(members))
+	for _, m := range members {
+		if m.Number != "" {
+			ret = append(ret, m.Number)
+		} else {
+			ret = append(ret, m.Uuid)
+		}
+	}
+	return ret
+}
+
+func (g *groupResult) group() (signalcli.Group, error) {
+	gid, err := base64.StdEncoding.DecodeString(g.Id)
+	if err != nil {
+		return signalcli.Group{}, err
+	}
+	return signalcli.Group{
+		Id: gid,
+		Name: g.Name,
+		Description: g.Description,
+		Members: numbers(g.Members),
+		Admins: numbers(g.Admins),
+	}, nil
 }
 
 func (d *SignalCliDriver) GetGroupName(groupId []byte) (name string, err error) {
-	gid := base64.StdEncoding.EncodeToString(groupId)
-	ctx := context.Background()
-	var result groupResult
-	err = d.conn.Call(ctx, "listGroups", map[string]any{"groupId": gid}).Await(ctx, &result)
+	g, err := d.GetGroup(groupId)
 	if err != nil {
 		return "", err
 	}
-	return result.Name, nil
+	return g.Name, nil
+}
+
+func (d *SignalCliDriver) ListGroups() ([]signalcli.Group, error) {
+	var result []groupResult
+	if err := d.call("listGroups", map[string]any{}, &result); err != nil {
+		return nil, err
+	}
+	ret := make([]signalcli.Group, 0, len(result))
+	for _, r := range result {
+		g, err := r.group()
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, g)
+	}
+	return ret, nil
+}
+
+func (d *SignalCliDriver) GetGroup(groupId []byte) (signalcli.Group, error) {
+	gid := base64.StdEncoding.EncodeToString(groupId)
+	var result []groupResult
+	if err := d.call("listGroups", map[string]any{"groupId": gid}, &result); err != nil {
+		return signalcli.Group{}, err
+	}
+	for _, r := range result {
+		if r.Id == gid {
+			return r.group()
+		}
+	}
+	return signalcli.Group{}, fmt.Errorf("Group %s not found", gid)
+}
+
+type updateGroupResult struct {
+	GroupId string
+}
+
+func (d *SignalCliDriver) CreateGroup(name string, members []string, avatar string) ([]byte, error) {
+	params := map[string]any{"name": name, "members": members}
+	if avatar != "" {
+		params["avatar"] = avatar
+	}
+	var result updateGroupResult
+	if err := d.call("updateGroup", params, &result); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(result.GroupId)
 }
+
+func (d *SignalCliDriver) UpdateGroup(groupId []byte, update signalcli.GroupUpdate) error {
+	params := map[string]any{"groupId": base64.StdEncoding.EncodeToString(groupId)}
+	if update.Name != nil {
+		params["name"] = *update.Name
+	}
+	if update.Description != nil {
+		params["description"] = *update.Description
+	}
+	if update.Avatar != "" {
+		params["avatar"] = update.Avatar
+	}
+	if len(update.AddMembers) > 0 {
+		params["members"] = update.AddMembers
+	}
+	if len(update.RemoveMembers) > 0 {
+		params["removeMembers"] = update.RemoveMembers
+	}
+	var result updateGroupResult
+	return d.call("updateGroup", params, &result)
+}
+
+func (d *SignalCliDriver) JoinGroup(inviteURI string) error {
+	var result json.RawMessage
+	return d.call("joinGroup", map[string]any{"uri": inviteURI}, &result)
+}
+
+func (d *SignalCliDriver) SendTypingIndicator(recipient string, groupId []byte, stop bool) error {
+	params := map[string]any{"stop": stop}
+	if len(groupId) > 0 {
+		params["groupId"] = base64.StdEncoding.EncodeToString(groupId)
+	} else {
+		params["recipient"] = recipient
+	}
+	var result json.RawMessage
+	return d.call("sendTyping", params, &result)
+}
+
+var _ signalcli.GroupDriver = &SignalCliDriver{}
+
+type contactResult struct {
+	Number string
+	Uuid string
+	Name string
+	IsBlocked bool
+}
+
+func (c *contactResult) contact() signalcli.Contact {
+	number := c.Number
+	if number == "" {
+		number = c.Uuid
+	}
+	return signalcli.Contact{Number: number, Name: c.Name, Blocked: c.IsBlocked}
+}
+
+func (d *SignalCliDriver) ListContacts() ([]signalcli.Contact, error) {
+	var result []contactResult
+	if err := d.call("listContacts", map[string]any{}, &result); err != nil {
+		return nil, err
+	}
+	ret := make([]signalcli.Contact, 0, len(result))
+	for _, r := range result {
+		ret = append(ret, r.contact())
+	}
+	return ret, nil
+}
+
+// returns "" if the number is not in the contacts
+func (d *SignalCliDriver) GetContactName(number string) (string, error) {
+	var result []contactResult
+	if err := d.call("listContacts", map[string]any{"recipient": []string{number}}, &result); err != nil {
+		return "", err
+	}
+	for _, r := range result {
+		if r.Number == number || r.Uuid == number {
+			return r.Name, nil
+		}
+	}
+	return "", nil
+}
+
+func (d *SignalCliDriver) SetContactName(number string, name string) error {
+	var result json.RawMessage
+	return d.call("updateContact", map[string]any{"recipient": number, "name": name}, &result)
+}
+
+func (d *SignalCliDriver) SetContactBlocked(number string, block bool) error {
+	method := "unblock"
+	if block {
+		method = "block"
+	}
+	var result json.RawMessage
+	return d.call(method, map[string]any{"recipient": []string{number}}, &result)
+}
+
+func (d *SignalCliDriver) SetExpirationTimer(number string, expiration int32) error {
+	var result json.RawMessage
+	return d.call("updateContact", map[string]any{"recipient": number, "expiration": expiration}, &result)
+}
+
+func (d *SignalCliDriver) UpdateProfile(name string, about string, aboutEmoji string, avatar string, remove bool) error {
+	params := map[string]any{}
+	if name != "" {
+		params["name"] = name
+	}
+	if about != "" {
+		params["about"] = about
+	}
+	if aboutEmoji != "" {
+		params["aboutEmoji"] = aboutEmoji
+	}
+	if avatar != "" {
+		params["avatar"] = avatar
+	}
+	if remove {
+		params["removeAvatar"] = true
+	}
+	var result json.RawMessage
+	return d.call("updateProfile", params, &result)
+}
+
+var _ signalcli.ContactDriver = &SignalCliDriver{}
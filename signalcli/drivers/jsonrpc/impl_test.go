@@ -0,0 +1,82 @@
+package signaljsonrpc
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"path/filepath"
+	"reflect"
+	"signalbot_go/signalcli"
+	"testing"
+)
+
+func TestTextStyle(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "socket")
+	listen, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	defer listen.Close()
+
+	d, err := NewSignalJsonRpcDriver(nopLog(), sock, "+49")
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	go d.Start()
+	defer d.Close()
+
+	c, err := listen.Accept()
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	defer c.Close()
+	styles := make(chan []string, 1)
+	go func() {
+		r := bufio.NewScanner(c)
+		for r.Scan() {
+			var req struct {
+				Id     json.RawMessage `json:"id"`
+				Params struct {
+					TextStyle []string `json:"textStyle"`
+				} `json:"params"`
+			}
+			if json.Unmarshal(r.Bytes(), &req) != nil {
+				continue
+			}
+			styles <- req.Params.TextStyle
+			fmt.Fprintf(c, `{"jsonrpc":"2.0","id":%s,"result":{"timestamp":1}}`+"\n", req.Id)
+		}
+	}()
+
+	waitState(t, d, signalcli.StateConnected)
+	msg, st := signalcli.ParseMarkup("**Pasta**: `x`")
+	if _, err := d.SendStyledGroupMessage(msg, st, nil, []byte{1}); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if was, exp := <-styles, []string{"0:5:BOLD", "7:1:MONOSPACE"}; !reflect.DeepEqual(was, exp) {
+		t.Fatalf("Was: %v but should be: %v", was, exp)
+	}
+	if _, err := d.SendMessage("plain", nil, "+49123", false); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if was := <-styles; was != nil {
+		t.Fatalf("Was: %v but should be unset", was)
+	}
+}
@@ -1,7 +1,12 @@
 package signaljsonrpc
 
+// Decoding is tolerant: fields which are not listed here are ignored (see
+// unknownFields).
+
 type jsonGroupInfo struct {
 	GroupId string
+	GroupName string
+	Revision uint
 	Type string
 }
 
@@ -28,7 +33,7 @@ type jsonReaction struct {
 	TargetAuthor string
 	TargetAuthorNumber string
 	TargetAuthorUuid string
-	TargetSentTimestamp string
+	TargetSentTimestamp uint64
 	IsRemove bool
 }
 
@@ -112,6 +117,35 @@ type jsonDataMsg struct {
 	Quote jsonQuote
 	Timestamp uint64
 	ViewOnce bool
+	Sticker *jsonSticker
+	RemoteDelete *jsonRemoteDelete
+	Reaction *jsonReaction
+	Previews []jsonPreview
+	TextStyles []jsonTextStyle
+	IsExpirationUpdate bool
+}
+
+type jsonSticker struct {
+	PackId string
+	StickerId uint
+}
+
+type jsonRemoteDelete struct {
+	Timestamp uint64
+}
+
+type jsonEditMsg struct {
+	TargetSentTimestamp uint64
+	DataMessage jsonDataMsg
+}
+
+type jsonStoryMsg struct {
+	AllowsReplies bool
+	GroupId string
+	FileAttachment *jsonAttachment
+	TextAttachment *struct {
+		Text string
+	}
 }
 
 type jsonReceiptMsg struct {
@@ -129,8 +163,12 @@ type jsonEnvelope struct {
 	SourceNumber string
 	SourceUuid string
 	Timestamp uint64
+	ServerReceivedTimestamp uint64
+	ServerDeliveredTimestamp uint64
 	SyncMessage *jsonSyncMsg
 	DataMessage *jsonDataMsg
+	EditMessage *jsonEditMsg
+	StoryMessage *jsonStoryMsg
 	TypingMessage *jsonTypingMsg
 	ReceiptMessage *jsonReceiptMsg
 }
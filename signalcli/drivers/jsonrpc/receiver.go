@@ -0,0 +1,181 @@
+package signaljsonrpc
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+
+	"signalbot_go/signalcli"
+)
+
+// decodes received messages/events (the params of the "receive"
+// notification, the REST API uses the same format) and passes them to the
+// account
+type Receiver struct {
+	Log   *slog.Logger
+	Inter signalcli.InterDriverToAcc
+	// number of the account, messages for other accounts are ignored
+	Self string
+	// returns the filename of a received attachment. If unset received
+	// attachments are left out.
+	Attachment func(id string, contentType string, filename string) (string, error)
+}
+
+// decode `params` and pass the message/event to the account
+func (r *Receiver) Receive(params json.RawMessage) {
+	var rcv jsonReceive
+	if err := json.Unmarshal(params, &rcv); err != nil {
+		var typeErr *json.UnmarshalTypeError
+		if !errors.As(err, &typeErr) {
+			r.Log.Warn("Decoding jsonRpc message params failed", "err", err, "rpc params", strings.ReplaceAll(string(params), "\"", "|"))
+			return
+		}
+		// the other fields are decoded nevertheless
+		r.Log.Warn("Decoding jsonRpc message params partially failed", "err", err, "rpc params", strings.ReplaceAll(string(params), "\"", "|"))
+	}
+	if rcv.Account != "" && rcv.Account != r.Self {
+		// for another account served by the same daemon
+		return
+	}
+	r.dispatch(params, &rcv)
+}
+
+// filenames and metadata of the received attachments
+func (r *Receiver) attachments(atts []jsonAttachment) ([]string, []signalcli.Attachment) {
+	if r.Attachment == nil || len(atts) == 0 {
+		return nil, nil
+	}
+	files := make([]string, 0, len(atts))
+	meta := make([]signalcli.Attachment, 0, len(atts))
+	for _, a := range atts {
+		fn, err := r.Attachment(a.Id, a.ContentType, a.Filename)
+		if err != nil {
+			r.Log.Warn("Error retrieving attachment", "id", a.Id, "err", err)
+			continue
+		}
+		files = append(files, fn)
+		meta = append(meta, signalcli.Attachment{
+			Id:          a.Id,
+			ContentType: a.ContentType,
+			Filename:    a.Filename,
+			Size:        int64(a.Size),
+			Width:       int(a.Width),
+			Height:      int(a.Height),
+			Caption:     a.Caption,
+		})
+	}
+	return files, meta
+}
+
+// pass the received message/event to the account
+func (r *Receiver) dispatch(params json.RawMessage, rcv *jsonReceive) {
+	parseErr := func(err error) {
+		switch err {
+		case ErrMsgUnset:
+		default:
+			r.Log.Warn("Error parsing message", "err", err, "msg", strings.ReplaceAll(string(params), "\"", "|"))
+		}
+	}
+
+	if rcv.Exception != nil {
+		r.Inter.EventChan <- &signalcli.Exception{
+			Type:    rcv.Exception.Type,
+			Message: rcv.Exception.Message,
+		}
+	}
+
+	var raw struct {
+		Envelope json.RawMessage
+	}
+	json.Unmarshal(params, &raw)
+	unknown := unknownFields(raw.Envelope, &rcv.Envelope)
+	if len(unknown) > 0 {
+		r.Log.Debug("Envelope contains unknown fields", "fields", unknown)
+	}
+
+	env := &rcv.Envelope
+	switch {
+	case env.TypingMessage != nil:
+		ev, err := newTyping(env)
+		if err != nil {
+			parseErr(err)
+			return
+		}
+		r.Inter.EventChan <- ev
+	case env.ReceiptMessage != nil:
+		r.Inter.EventChan <- newReceipt(env)
+	case env.EditMessage != nil:
+		ev, err := newEdit(env, r.Self)
+		if err != nil {
+			parseErr(err)
+			return
+		}
+		r.Inter.EventChan <- ev
+	case env.StoryMessage != nil:
+		ev, err := newStory(env)
+		if err != nil {
+			parseErr(err)
+			return
+		}
+		r.Inter.EventChan <- ev
+	case env.SyncMessage != nil:
+		m, err := NewSyncMessage(rcv, r.Self)
+		if err != nil {
+			parseErr(err)
+			return
+		}
+		r.Inter.SyncMessageChan <- m
+	case env.DataMessage != nil && env.DataMessage.Sticker != nil:
+		ev, err := newSticker(env, r.Self)
+		if err != nil {
+			parseErr(err)
+			return
+		}
+		r.Inter.EventChan <- ev
+	case env.DataMessage != nil && env.DataMessage.RemoteDelete != nil:
+		ev, err := newRemoteDelete(env)
+		if err != nil {
+			parseErr(err)
+			return
+		}
+		r.Inter.EventChan <- ev
+	case env.DataMessage != nil:
+		m, err := newMessage(env, env.DataMessage, r.Self)
+		if err != nil {
+			parseErr(err)
+			return
+		}
+		m.Attachments, m.AttachmentMeta = r.attachments(env.DataMessage.Attachments)
+		if m.Message == "" && len(m.Attachments) == 0 {
+			parseErr(ErrMsgUnset)
+			return
+		}
+		r.Inter.MessageChan <- m
+	case len(unknown) > 0:
+		r.Inter.EventChan <- &signalcli.UnknownEvent{
+			Timestamp: int64(env.Timestamp),
+			Sender:    env.Source,
+			Fields:    unknown,
+			Raw:       []byte(raw.Envelope),
+		}
+	case rcv.Exception == nil:
+		r.Log.Warn("Unknown message type", "rpc params", strings.ReplaceAll(string(params), "\"", "|"))
+	}
+}
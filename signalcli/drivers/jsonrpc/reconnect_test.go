@@ -0,0 +1,173 @@
+package signaljsonrpc
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"path/filepath"
+	"signalbot_go/signalcli"
+	"testing"
+	"time"
+)
+
+func nopLog() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+}
+
+// wait until the driver reaches `state`
+func waitState(t *testing.T, d *SignalCliDriver, state signalcli.ConnectionState) {
+	t.Helper()
+	for i := 0; i < 500; i++ {
+		if d.State() == state {
+			return
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	t.Fatalf("Was: %v but should be %v", d.State(), state)
+}
+
+// answer all "send" requests on `conn` with the timestamp `ts`
+func serveSend(conn net.Conn, ts int64) {
+	r := bufio.NewScanner(conn)
+	for r.Scan() {
+		var req struct {
+			Id json.RawMessage `json:"id"`
+		}
+		if json.Unmarshal(r.Bytes(), &req) != nil {
+			continue
+		}
+		fmt.Fprintf(conn, `{"jsonrpc":"2.0","id":%s,"result":{"timestamp":%d}}`+"\n", req.Id, ts)
+	}
+}
+
+func TestReconnect(t *testing.T) {
+	ReconnectMin, ReconnectMax = 50*time.Millisecond, 50*time.Millisecond
+	sock := filepath.Join(t.TempDir(), "socket")
+
+	d, err := NewSignalJsonRpcDriver(nopLog(), sock, "+49")
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	d.SetInterface(signalcli.InterDriverToAcc{
+		MessageChan:     make(chan *signalcli.Message, 1),
+		SyncMessageChan: make(chan *signalcli.SyncMessage, 1),
+		EventChan:       make(chan any, 1),
+	})
+	done := make(chan struct{})
+	go func() {
+		d.Start()
+		close(done)
+	}()
+
+	// signal-cli is not running yet
+	waitState(t, d, signalcli.StateDisconnected)
+	if _, err := d.SendMessage("a", nil, "+49123", false); !errors.Is(err, signalcli.ErrNotConnected) {
+		t.Fatalf("Should have returned %v but was %v", signalcli.ErrNotConnected, err)
+	}
+
+	listen, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	defer listen.Close()
+	conns := make(chan net.Conn)
+	go func() {
+		for {
+			c, err := listen.Accept()
+			if err != nil {
+				close(conns)
+				return
+			}
+			conns <- c
+		}
+	}()
+
+	// first connection, then signal-cli "restarts"
+	c := <-conns
+	waitState(t, d, signalcli.StateConnected)
+	c.Close()
+	waitState(t, d, signalcli.StateDisconnected)
+
+	c = <-conns
+	defer c.Close()
+	go serveSend(c, 42)
+	waitState(t, d, signalcli.StateConnected)
+	ts, err := d.SendMessage("a", nil, "+49123", false)
+	if err != nil || ts != 42 {
+		t.Fatalf("Was: %v (%v) but should be %v", ts, err, 42)
+	}
+
+	d.Close()
+	<-done
+	if d.State() != signalcli.StateClosed {
+		t.Fatalf("Was: %v but should be %v", d.State(), signalcli.StateClosed)
+	}
+}
+
+func TestMultiAccount(t *testing.T) {
+	sock := filepath.Join(t.TempDir(), "socket")
+	listen, err := net.Listen("unix", sock)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	defer listen.Close()
+
+	d, err := NewSignalJsonRpcDriver(nopLog(), sock, "+49b")
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	d.MultiAccount = true
+	go d.Start()
+	defer d.Close()
+
+	c, err := listen.Accept()
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	defer c.Close()
+	accounts := make(chan string, 1)
+	go func() {
+		r := bufio.NewScanner(c)
+		for r.Scan() {
+			var req struct {
+				Id     json.RawMessage `json:"id"`
+				Params struct {
+					Account string `json:"account"`
+				} `json:"params"`
+			}
+			if json.Unmarshal(r.Bytes(), &req) != nil {
+				continue
+			}
+			accounts <- req.Params.Account
+			fmt.Fprintf(c, `{"jsonrpc":"2.0","id":%s,"result":{"timestamp":1}}`+"\n", req.Id)
+		}
+	}()
+
+	waitState(t, d, signalcli.StateConnected)
+	if _, err := d.SendMessage("a", nil, "+49123", false); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if a := <-accounts; a != "+49b" {
+		t.Fatalf("Was: %q but should be %q", a, "+49b")
+	}
+}
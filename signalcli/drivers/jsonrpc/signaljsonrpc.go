@@ -17,16 +17,18 @@ package signaljsonrpc
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
 import (
-	"bytes"
 	"context"
 	"encoding/base64"
 	"encoding/hex"
-	"encoding/json"
 	"errors"
+	"fmt"
 	"log/slog"
 	"net"
-	"strings"
+	"path/filepath"
+	"sync"
+	"time"
 
+	attachments "signalbot_go/internal/attachment"
 	"signalbot_go/signalcli"
 
 	"golang.org/x/exp/jsonrpc2"
@@ -36,95 +38,156 @@ var (
 	ErrMsgUnset = errors.New("Message unset")
 )
 
+// bounds of the wait time between two attempts to connect to signal-cli
+var (
+	ReconnectMin = time.Second
+	ReconnectMax = time.Minute
+)
+
+// Connects to the unix socket of signal-cli once Start is called and
+// reconnects whenever the connection is lost.
+// Notifications for other accounts than selfNr are ignored, so several
+// drivers can share a signal-cli daemon which serves multiple accounts.
 type SignalCliDriver struct {
+	signalcli.StateTracker
+	// pass the account with each request (required if the daemon serves
+	// multiple accounts). Has to be set before Start is called.
+	MultiAccount bool
+	// directory in which signal-cli stores received attachments (empty
+	// leaves received attachments out). Has to be set before Start is
+	// called.
+	AttachmentDir string
 	driverInter signalcli.InterDriverToAcc
+	recv Receiver
 	selfNr string
+	unixSocket string
 	log *slog.Logger
-	conn *jsonrpc2.Connection
+
+	connMu sync.RWMutex
+	conn *jsonrpc2.Connection // nil while not connected
+
+	ctx context.Context
+	cancel context.CancelFunc
 }
 
+// create a new driver. Connecting happens in Start, so signal-cli does not
+// need to be running yet.
 func NewSignalJsonRpcDriver(log *slog.Logger, unixSocket string, selfNr string) (*SignalCliDriver, error) {
-	var err error
-
 	ret := SignalCliDriver{
 		log: log,
 		selfNr: selfNr,
+		unixSocket: unixSocket,
+		AttachmentDir: attachments.StorageDir(),
 	}
-
-	ret.conn, err = jsonrpc2.Dial(
-		context.Background(),
-		jsonrpc2.NetDialer("unix", unixSocket, net.Dialer{}),
-		jsonrpc2.ConnectionOptions{
-			Framer: RawFramerNewline(),
-			Handler: &ret,
-		},
-	)
-	if err != nil {
-		panic(err)
-	}
+	ret.ctx, ret.cancel = context.WithCancel(context.Background())
+	ret.SetState(signalcli.StateConnecting)
 
 	return &ret, nil
 }
 
-func (d *SignalCliDriver) GetSelfNumber() (string, error) {
-	return d.selfNr, nil
+// returns the current connection or ErrNotConnected
+func (d *SignalCliDriver) connection() (*jsonrpc2.Connection, error) {
+	d.connMu.RLock()
+	defer d.connMu.RUnlock()
+	if d.conn == nil {
+		return nil, signalcli.ErrNotConnected
+	}
+	return d.conn, nil
 }
 
-func (d *SignalCliDriver) Handle(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
-	var rcv jsonReceive
-	dec := json.NewDecoder(bytes.NewReader(req.Params))
-	dec.DisallowUnknownFields()
-
-	err := dec.Decode(&rcv)
+// call `method` on signal-cli and decode the result into `result`
+func (d *SignalCliDriver) call(method string, params any, result any) error {
+	conn, err := d.connection()
 	if err != nil {
-		d.log.Warn("Decoding jsonRpc message params failed", "rpc params", strings.ReplaceAll(string(req.Params), "\"", "|"))
-		return nil, jsonrpc2.ErrNotHandled
-	}
-
-	if rcv.Exception != nil {
-		// ignore
-	} else if rcv.Envelope.TypingMessage != nil {
-		// ignore
-	} else if rcv.Envelope.ReceiptMessage != nil {
-		// ignore
-	} else if rcv.Envelope.SyncMessage != nil {
-		m,err := NewSyncMessage(&rcv, d.selfNr)
-		if err != nil {
-			switch err {
-			case ErrMsgUnset:
-			default:
-				d.log.Warn("Error parsing message", "err", err, "method", req.Method, "msg", strings.ReplaceAll(string(req.Params), "\"", "|"))
-			}
-		} else {
-			d.driverInter.SyncMessageChan <- m
-		}
-	} else if rcv.Envelope.DataMessage != nil {
-		m,err := NewDataMessage(&rcv, d.selfNr)
-		if err != nil {
-			switch err {
-			case ErrMsgUnset:
-			default:
-				d.log.Warn("Error parsing message", "err", err, "method", req.Method, "msg", strings.ReplaceAll(string(req.Params), "\"", "|"))
-			}
-		} else {
-			d.driverInter.MessageChan <- m
+		return err
+	}
+	if p, ok := params.(map[string]any); ok && d.MultiAccount {
+		p["account"] = d.selfNr
+	}
+	err = conn.Call(d.ctx, method, params).Await(d.ctx, result)
+	if err != nil {
+		if _, cErr := d.connection(); cErr != nil {
+			// the connection broke while waiting for the result
+			return fmt.Errorf("%w (%v)", signalcli.ErrNotConnected, err)
 		}
-	} else {
-		d.log.Warn("Unknown message type", "rpc params", strings.ReplaceAll(string(req.Params), "\"", "|"))
 	}
+	return err
+}
+
+func (d *SignalCliDriver) GetSelfNumber() (string, error) {
+	return d.selfNr, nil
+}
 
+func (d *SignalCliDriver) Handle(ctx context.Context, req *jsonrpc2.Request) (interface{}, error) {
+	d.recv.Receive(req.Params)
 	return nil, jsonrpc2.ErrNotHandled
 }
 
 func (d *SignalCliDriver) SetInterface(inter signalcli.InterDriverToAcc) (err error) {
 	d.driverInter = inter
+	d.recv = Receiver{Log: d.log, Inter: inter, Self: d.selfNr}
+	d.recv.Attachment = func(id string, contentType string, filename string) (string, error) {
+		if d.AttachmentDir == "" {
+			return "", fmt.Errorf("no attachment directory set")
+		}
+		return filepath.Join(d.AttachmentDir, filepath.Base(id)), nil
+	}
 	return nil
 }
 
-func (d* SignalCliDriver) Start() {}
+// connect to signal-cli and keep the connection up until Close is called
+func (d* SignalCliDriver) Start() {
+	backoff := signalcli.Backoff{Min: ReconnectMin, Max: ReconnectMax}
+	for d.ctx.Err() == nil {
+		conn, err := jsonrpc2.Dial(
+			d.ctx,
+			jsonrpc2.NetDialer("unix", d.unixSocket, net.Dialer{}),
+			jsonrpc2.ConnectionOptions{
+				Framer: RawFramerNewline(),
+				Handler: d,
+			},
+		)
+		if err != nil {
+			if d.SetState(signalcli.StateDisconnected) {
+				d.log.Warn("Connecting to signal-cli failed", "socket", d.unixSocket, "err", err)
+			}
+			if !backoff.Wait(d.ctx) {
+				break
+			}
+			continue
+		}
+
+		d.connMu.Lock()
+		d.conn = conn
+		d.connMu.Unlock()
+		d.SetState(signalcli.StateConnected)
+		d.log.Info("Connected to signal-cli", "socket", d.unixSocket)
+		backoff.Reset()
+
+		err = conn.Wait()
+
+		d.connMu.Lock()
+		d.conn = nil
+		d.connMu.Unlock()
+		if d.ctx.Err() != nil {
+			break
+		}
+		d.SetState(signalcli.StateDisconnected)
+		d.log.Warn("Lost connection to signal-cli", "err", err)
+		if !backoff.Wait(d.ctx) {
+			break
+		}
+	}
+	d.SetState(signalcli.StateClosed)
+}
 
 func (d* SignalCliDriver) Close() {
-	d.conn.Close()
+	d.cancel()
+	d.connMu.RLock()
+	defer d.connMu.RUnlock()
+	if d.conn != nil {
+		d.conn.Close()
+	}
 }
 
 func NewSyncMessage(v *jsonReceive, self string) (*signalcli.SyncMessage, error) {
@@ -171,13 +234,25 @@ func NewSyncMessage(v *jsonReceive, self string) (*signalcli.SyncMessage, error)
 }
 
 func NewDataMessage(v *jsonReceive, self string) (*signalcli.Message,error) {
+	msg, err := newMessage(&v.Envelope, v.Envelope.DataMessage, self)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Message == "" {
+		return nil, ErrMsgUnset
+	}
+	return msg, nil
+}
+
+// the message in `dm` which was received in `env`. The text might be empty.
+func newMessage(env *jsonEnvelope, dm *jsonDataMsg, self string) (*signalcli.Message,error) {
 	msg := signalcli.Message{
-		Timestamp:   int64(v.Envelope.DataMessage.Timestamp),
-		Sender:      v.Envelope.Source,
-		Message:     v.Envelope.DataMessage.Message,
+		Timestamp:   int64(dm.Timestamp),
+		Sender:      env.Source,
+		Message:     dm.Message,
 	}
-	if len(v.Envelope.DataMessage.GroupInfo.GroupId) > 0 {
-		gid,err := base64.StdEncoding.DecodeString(v.Envelope.DataMessage.GroupInfo.GroupId)
+	if len(dm.GroupInfo.GroupId) > 0 {
+		gid,err := base64.StdEncoding.DecodeString(dm.GroupInfo.GroupId)
 		if err != nil {
 			return nil, err
 		}
@@ -188,12 +263,6 @@ func NewDataMessage(v *jsonReceive, self string) (*signalcli.Message,error) {
 	if msg.Sender == "" {
 		return nil, errors.New("Sender unset")
 	}
-	// if msg.Destination == "" {
-	// 	return nil, errors.New("Destination unset")
-	// }
-	if msg.Message == "" {
-		return nil, ErrMsgUnset
-	}
 
 	// fill chat
 	if len(msg.GroupId) > 0 {
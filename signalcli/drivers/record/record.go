@@ -0,0 +1,245 @@
+package signalrecord
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"signalbot_go/signalcli"
+)
+
+// kinds of the entries of a recording
+const (
+	KindSelf    = "self"
+	KindMessage = "message"
+	KindSync    = "sync"
+	KindSend    = "send"
+)
+
+// one line of a recording (JSONL)
+type Entry struct {
+	Time time.Time `json:"time"`
+	Kind string    `json:"kind"`
+	// number of the recorded account (KindSelf)
+	Self    string                 `json:"self,omitempty"`
+	Message *signalcli.Message     `json:"message,omitempty"`
+	Sync    *signalcli.SyncMessage `json:"sync,omitempty"`
+	Send    *Send                  `json:"send,omitempty"`
+}
+
+// a message sent by the bot
+type Send struct {
+	// set for messages to a single recipient
+	Recipient string `json:"recipient,omitempty"`
+	// set for group messages
+	GroupId     []byte                `json:"groupId,omitempty"`
+	Message     string                `json:"message"`
+	Styles      []signalcli.TextStyle `json:"styles,omitempty"`
+	Attachments []string              `json:"attachments,omitempty"`
+	// as returned by the driver (not set on replay)
+	Timestamp int64 `json:"timestamp,omitempty"`
+}
+
+// wraps a driver and appends every received message and every sent message
+// to a JSONL file. Create it with NewRecorder.
+type Recorder struct {
+	signalcli.Driver
+
+	mu  sync.Mutex
+	f   *os.File
+	enc *json.Encoder
+
+	log    *slog.Logger
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// record the traffic of `driver` to `file` (appends if the file exists)
+func NewRecorder(log *slog.Logger, driver signalcli.Driver, file string) (*Recorder, error) {
+	self, err := driver.GetSelfNumber()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(file, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	r := &Recorder{
+		Driver: driver,
+		f:      f,
+		enc:    json.NewEncoder(f),
+		log:    log,
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	r.write(Entry{Kind: KindSelf, Self: self})
+	return r, nil
+}
+
+func (r *Recorder) write(e Entry) {
+	e.Time = time.Now()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.enc.Encode(e); err != nil {
+		r.log.Error("Error recording", "kind", e.Kind, "err", err)
+	}
+}
+
+// interpose between the driver and the account to record the received
+// messages
+func (r *Recorder) SetInterface(inter signalcli.InterDriverToAcc) error {
+	msgs := make(chan *signalcli.Message, cap(inter.MessageChan))
+	syncs := make(chan *signalcli.SyncMessage, cap(inter.SyncMessageChan))
+	go func() {
+		for {
+			select {
+			case <-r.ctx.Done():
+				return
+			case m := <-msgs:
+				if m != nil {
+					r.write(Entry{Kind: KindMessage, Message: m})
+				}
+				select {
+				case inter.MessageChan <- m:
+				case <-r.ctx.Done():
+					return
+				}
+			case m := <-syncs:
+				if m != nil {
+					r.write(Entry{Kind: KindSync, Sync: m})
+				}
+				select {
+				case inter.SyncMessageChan <- m:
+				case <-r.ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return r.Driver.SetInterface(signalcli.InterDriverToAcc{
+		MessageChan:     msgs,
+		SyncMessageChan: syncs,
+		EventChan:       inter.EventChan,
+	})
+}
+
+func (r *Recorder) SendMessage(message string, attachments []string, recipient string, notifySelf bool) (int64, error) {
+	ts, err := r.Driver.SendMessage(message, attachments, recipient, notifySelf)
+	if err == nil {
+		r.write(Entry{Kind: KindSend, Send: &Send{Recipient: recipient, Message: message, Attachments: attachments, Timestamp: ts}})
+	}
+	return ts, err
+}
+
+func (r *Recorder) SendGroupMessage(message string, attachments []string, groupId []byte) (int64, error) {
+	ts, err := r.Driver.SendGroupMessage(message, attachments, groupId)
+	if err == nil {
+		r.write(Entry{Kind: KindSend, Send: &Send{GroupId: groupId, Message: message, Attachments: attachments, Timestamp: ts}})
+	}
+	return ts, err
+}
+
+func (r *Recorder) SendStyledMessage(message string, styles []signalcli.TextStyle, attachments []string, recipient string, notifySelf bool) (int64, error) {
+	sd, ok := r.Driver.(signalcli.StyleDriver)
+	if !ok {
+		return 0, signalcli.ErrNotSupported
+	}
+	ts, err := sd.SendStyledMessage(message, styles, attachments, recipient, notifySelf)
+	if err == nil {
+		r.write(Entry{Kind: KindSend, Send: &Send{Recipient: recipient, Message: message, Styles: styles, Attachments: attachments, Timestamp: ts}})
+	}
+	return ts, err
+}
+
+func (r *Recorder) SendStyledGroupMessage(message string, styles []signalcli.TextStyle, attachments []string, groupId []byte) (int64, error) {
+	sd, ok := r.Driver.(signalcli.StyleDriver)
+	if !ok {
+		return 0, signalcli.ErrNotSupported
+	}
+	ts, err := sd.SendStyledGroupMessage(message, styles, attachments, groupId)
+	if err == nil {
+		r.write(Entry{Kind: KindSend, Send: &Send{GroupId: groupId, Message: message, Styles: styles, Attachments: attachments, Timestamp: ts}})
+	}
+	return ts, err
+}
+
+func (r *Recorder) Close() {
+	r.Driver.Close()
+	r.cancel()
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err := r.f.Close(); err != nil {
+		r.log.Error("Error closing the recording", "err", err)
+	}
+}
+
+// the group management of the wrapped driver (if it supports it)
+func (r *Recorder) groups() (signalcli.GroupDriver, error) {
+	g, ok := r.Driver.(signalcli.GroupDriver)
+	if !ok {
+		return nil, signalcli.ErrNotSupported
+	}
+	return g, nil
+}
+
+func (r *Recorder) ListGroups() ([]signalcli.Group, error) {
+	g, err := r.groups()
+	if err != nil {
+		return nil, err
+	}
+	return g.ListGroups()
+}
+
+func (r *Recorder) GetGroup(groupId []byte) (signalcli.Group, error) {
+	g, err := r.groups()
+	if err != nil {
+		return signalcli.Group{}, err
+	}
+	return g.GetGroup(groupId)
+}
+
+func (r *Recorder) CreateGroup(name string, members []string, avatar string) ([]byte, error) {
+	g, err := r.groups()
+	if err != nil {
+		return nil, err
+	}
+	return g.CreateGroup(name, members, avatar)
+}
+
+func (r *Recorder) UpdateGroup(groupId []byte, update signalcli.GroupUpdate) error {
+	g, err := r.groups()
+	if err != nil {
+		return err
+	}
+	return g.UpdateGroup(groupId, update)
+}
+
+func (r *Recorder) JoinGroup(inviteURI string) error {
+	g, err := r.groups()
+	if err != nil {
+		return err
+	}
+	return g.JoinGroup(inviteURI)
+}
+
+var _ signalcli.Driver = &Recorder{}
+var _ signalcli.GroupDriver = &Recorder{}
+var _ signalcli.StyleDriver = &Recorder{}
@@ -0,0 +1,161 @@
+package signalrecord
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"signalbot_go/signalcli"
+)
+
+// driver which receives the messages pushed by the test
+type fakeDriver struct {
+	signalcli.StateTracker
+	signalcli.NoContacts
+	inter signalcli.InterDriverToAcc
+	sent  chan string
+}
+
+func (d *fakeDriver) SendMessage(message string, attachments []string, recipient string, notifySelf bool) (int64, error) {
+	d.sent <- message
+	return time.Now().UnixMilli(), nil
+}
+func (d *fakeDriver) SendGroupMessage(message string, attachments []string, groupId []byte) (int64, error) {
+	d.sent <- message
+	return time.Now().UnixMilli(), nil
+}
+func (d *fakeDriver) GetGroupName(groupId []byte) (string, error) { return "", nil }
+func (d *fakeDriver) GetSelfNumber() (string, error)              { return "+49", nil }
+func (d *fakeDriver) SetInterface(inter signalcli.InterDriverToAcc) error {
+	d.inter = inter
+	return nil
+}
+func (d *fakeDriver) SendTypingIndicator(recipient string, groupId []byte, stop bool) error {
+	return nil
+}
+func (d *fakeDriver) Start() { d.SetState(signalcli.StateConnected) }
+func (d *fakeDriver) Close() {}
+
+// account which answers every message with an echo
+func echoAccount(t *testing.T, log *slog.Logger, d signalcli.Driver) *signalcli.Account {
+	acc, err := signalcli.NewAccount(log, d, signalcli.DefaultQueueCfg, signalcli.DefaultDeliveryCfg, nil)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if err := acc.AddMessageHandlerFunc(func(m *signalcli.Message) {
+		go acc.Respond("echo: "+m.Message, nil, m, false)
+	}); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	acc.ListenForSignals()
+	return acc
+}
+
+func TestRecordReplay(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	file := filepath.Join(t.TempDir(), "conversation.jsonl")
+
+	d := &fakeDriver{sent: make(chan string, 1)}
+	r, err := NewRecorder(log, d, file)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	acc := echoAccount(t, log, r)
+	for _, msg := range []string{"hello", "world"} {
+		d.inter.MessageChan <- &signalcli.Message{Sender: "+49123", Chat: "+49123", Message: msg}
+		select {
+		case sent := <-d.sent:
+			if sent != "echo: "+msg {
+				t.Fatalf("Was: %q", sent)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("No reply to %q", msg)
+		}
+	}
+	// the send is recorded after the driver returned
+	time.Sleep(50 * time.Millisecond)
+	acc.Close()
+
+	entries, err := ReadRecording(file)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	kinds := []string{}
+	for _, e := range entries {
+		kinds = append(kinds, e.Kind)
+	}
+	if exp := []string{KindSelf, KindMessage, KindSend, KindMessage, KindSend}; !reflect.DeepEqual(kinds, exp) {
+		t.Fatalf("Was: %v but should be: %v", kinds, exp)
+	}
+	// the messages are recorded with their tagged field names
+	if raw, err := os.ReadFile(file); err != nil {
+		t.Fatalf("Err: %v", err)
+	} else if !strings.Contains(string(raw), `"msg":"hello"`) {
+		t.Fatalf("Was: %s", raw)
+	}
+
+	rp, err := NewReplayer(log, file)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	acc = echoAccount(t, log, rp)
+	defer acc.Close()
+	<-rp.Done()
+	exp := []Send{
+		{Recipient: "+49123", Message: "echo: hello"},
+		{Recipient: "+49123", Message: "echo: world"},
+	}
+	if !reflect.DeepEqual(rp.Expected(), exp) {
+		t.Fatalf("Was: %+v but should be: %+v", rp.Expected(), exp)
+	}
+	if !reflect.DeepEqual(rp.Sent(), exp) {
+		t.Fatalf("Was: %+v but should be: %+v", rp.Sent(), exp)
+	}
+	if extra := rp.Unexpected(100 * time.Millisecond); extra != nil {
+		t.Fatalf("Was: %+v but should be nil", extra)
+	}
+}
+
+func TestReplayUnexpected(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	file := filepath.Join(t.TempDir(), "conversation.jsonl")
+	// the reply to the message is missing in the recording
+	err := os.WriteFile(file, []byte(`{"kind":"self","self":"+49100"}
+{"kind":"message","message":{"ts":1,"sender":"+49123","chat":"+49123","msg":"hello"}}
+`), 0o600)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	rp, err := NewReplayer(log, file)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	acc := echoAccount(t, log, rp)
+	defer acc.Close()
+	<-rp.Done()
+	exp := []Send{{Recipient: "+49123", Message: "echo: hello"}}
+	if extra := rp.Unexpected(5 * time.Second); !reflect.DeepEqual(extra, exp) {
+		t.Fatalf("Was: %+v but should be: %+v", extra, exp)
+	}
+}
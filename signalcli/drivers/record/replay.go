@@ -0,0 +1,255 @@
+package signalrecord
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"signalbot_go/signalcli"
+)
+
+// how long to wait for the bot to send the recorded replies before the next
+// message is fed
+var ReplayTimeout = 5 * time.Second
+
+// read all entries of a recording
+func ReadRecording(file string) ([]Entry, error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var ret []Entry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", file, line, err)
+		}
+		ret = append(ret, e)
+	}
+	return ret, scanner.Err()
+}
+
+// driver which feeds the messages of a recording to the account and
+// captures what the bot sends. A message is only fed once the bot sent as
+// many messages as were recorded before it (or ReplayTimeout passed), so the
+// order of the conversation is kept. Create it with NewReplayer.
+type Replayer struct {
+	signalcli.StateTracker
+	signalcli.NoContacts
+	self    string
+	entries []Entry
+	inter   signalcli.InterDriverToAcc
+	log     *slog.Logger
+
+	mu     sync.Mutex
+	sent   []Send
+	notify chan struct{}
+	ts     atomic.Int64
+
+	done   chan struct{}
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// replay the recording in `file`. The number of the account is taken from
+// the recording.
+func NewReplayer(log *slog.Logger, file string) (*Replayer, error) {
+	entries, err := ReadRecording(file)
+	if err != nil {
+		return nil, err
+	}
+	r := &Replayer{
+		entries: entries,
+		log:     log,
+		notify:  make(chan struct{}, 1),
+		done:    make(chan struct{}),
+	}
+	for _, e := range entries {
+		if e.Kind == KindSelf {
+			r.self = e.Self
+			break
+		}
+	}
+	if r.self == "" {
+		return nil, fmt.Errorf("%s: the number of the recorded account is missing", file)
+	}
+	r.ctx, r.cancel = context.WithCancel(context.Background())
+	return r, nil
+}
+
+func (r *Replayer) GetSelfNumber() (string, error) {
+	return r.self, nil
+}
+
+func (r *Replayer) SetInterface(inter signalcli.InterDriverToAcc) error {
+	r.inter = inter
+	return nil
+}
+
+// wait until the bot sent at least n messages. Returns false on timeout.
+func (r *Replayer) waitSent(n int, timeout time.Duration) bool {
+	t := time.NewTimer(timeout)
+	defer t.Stop()
+	for {
+		r.mu.Lock()
+		cnt := len(r.sent)
+		r.mu.Unlock()
+		if cnt >= n {
+			return true
+		}
+		select {
+		case <-r.notify:
+		case <-t.C:
+			return false
+		case <-r.ctx.Done():
+			return false
+		}
+	}
+}
+
+// feed the recording to the account, then wait until Close is called
+func (r *Replayer) Start() {
+	r.SetState(signalcli.StateConnected)
+	expected := 0
+	for _, e := range r.entries {
+		switch e.Kind {
+		case KindSend:
+			expected++
+			continue
+		case KindMessage, KindSync:
+		default:
+			continue
+		}
+		if !r.waitSent(expected, ReplayTimeout) {
+			r.log.Warn("Bot did not send the recorded replies in time", "expected", expected)
+		}
+		if r.ctx.Err() != nil {
+			break
+		}
+		switch {
+		case e.Kind == KindMessage && e.Message != nil:
+			m := *e.Message
+			r.inter.MessageChan <- &m
+		case e.Kind == KindSync && e.Sync != nil:
+			m := *e.Sync
+			r.inter.SyncMessageChan <- &m
+		}
+	}
+	if r.ctx.Err() == nil && !r.waitSent(expected, ReplayTimeout) {
+		r.log.Warn("Bot did not send the recorded replies in time", "expected", expected)
+	}
+	close(r.done)
+	<-r.ctx.Done()
+	r.SetState(signalcli.StateClosed)
+}
+
+func (r *Replayer) Close() {
+	r.cancel()
+}
+
+// closed once all messages were fed and the bot sent its replies (or the
+// timeout passed)
+func (r *Replayer) Done() <-chan struct{} {
+	return r.done
+}
+
+// the messages sent by the bot so far
+func (r *Replayer) Sent() []Send {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Send(nil), r.sent...)
+}
+
+// wait up to `d` for messages the bot sends beyond the recorded ones and
+// return them. Call it after Done to check that the bot did not send more
+// than it did when recording.
+func (r *Replayer) Unexpected(d time.Duration) []Send {
+	n := len(r.Expected())
+	r.waitSent(n+1, d)
+	sent := r.Sent()
+	if len(sent) <= n {
+		return nil
+	}
+	return sent[n:]
+}
+
+// the messages which were sent when recording (without timestamps)
+func (r *Replayer) Expected() []Send {
+	var ret []Send
+	for _, e := range r.entries {
+		if e.Kind == KindSend && e.Send != nil {
+			s := *e.Send
+			s.Timestamp = 0
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+func (r *Replayer) record(s Send) int64 {
+	r.mu.Lock()
+	r.sent = append(r.sent, s)
+	r.mu.Unlock()
+	select {
+	case r.notify <- struct{}{}:
+	default:
+	}
+	return r.ts.Add(1)
+}
+
+func (r *Replayer) SendMessage(message string, attachments []string, recipient string, notifySelf bool) (int64, error) {
+	return r.record(Send{Recipient: recipient, Message: message, Attachments: attachments}), nil
+}
+
+func (r *Replayer) SendGroupMessage(message string, attachments []string, groupId []byte) (int64, error) {
+	return r.record(Send{GroupId: groupId, Message: message, Attachments: attachments}), nil
+}
+
+func (r *Replayer) SendStyledMessage(message string, styles []signalcli.TextStyle, attachments []string, recipient string, notifySelf bool) (int64, error) {
+	return r.record(Send{Recipient: recipient, Message: message, Styles: styles, Attachments: attachments}), nil
+}
+
+func (r *Replayer) SendStyledGroupMessage(message string, styles []signalcli.TextStyle, attachments []string, groupId []byte) (int64, error) {
+	return r.record(Send{GroupId: groupId, Message: message, Styles: styles, Attachments: attachments}), nil
+}
+
+func (r *Replayer) GetGroupName(groupId []byte) (string, error) {
+	return "", signalcli.ErrNotSupported
+}
+
+func (r *Replayer) SendTypingIndicator(recipient string, groupId []byte, stop bool) error {
+	return nil
+}
+
+var (
+	_ signalcli.Driver      = &Replayer{}
+	_ signalcli.StyleDriver = &Replayer{}
+)
@@ -0,0 +1,447 @@
+package signalrest
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"signalbot_go/signalcli"
+	signaljsonrpc "signalbot_go/signalcli/drivers/jsonrpc"
+
+	"golang.org/x/net/websocket"
+)
+
+// bounds of the wait time between two attempts to connect to the websocket
+var (
+	ReconnectMin = time.Second
+	ReconnectMax = time.Minute
+)
+
+// Talks to the REST API of signal-cli-rest-api
+// (https://github.com/bbernhard/signal-cli-rest-api). Messages are received
+// via the websocket, so the API has to run in json-rpc mode. The websocket is
+// reconnected whenever the connection is lost.
+type SignalCliDriver struct {
+	signalcli.StateTracker
+	baseURL *url.URL
+	selfNr  string
+	// received attachments are downloaded to this directory (empty to
+	// leave them out)
+	attachmentDir string
+	client        *http.Client
+	recv          signaljsonrpc.Receiver
+	log           *slog.Logger
+
+	connMu sync.Mutex
+	conn   *websocket.Conn // nil while not connected
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// create a new driver. Connecting to the websocket happens in Start.
+func NewSignalRestDriver(log *slog.Logger, baseURL string, selfNr string, attachmentDir string) (*SignalCliDriver, error) {
+	u, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("Invalid scheme of the REST API url: %q", u.Scheme)
+	}
+	if attachmentDir != "" {
+		if err := os.MkdirAll(attachmentDir, 0o755); err != nil {
+			return nil, err
+		}
+	}
+	ret := SignalCliDriver{
+		baseURL:       u,
+		selfNr:        selfNr,
+		attachmentDir: attachmentDir,
+		client:        &http.Client{Timeout: time.Minute},
+		log:           log,
+	}
+	ret.ctx, ret.cancel = context.WithCancel(context.Background())
+	ret.SetState(signalcli.StateConnecting)
+	return &ret, nil
+}
+
+// url of the endpoint, the segments are escaped
+func (d *SignalCliDriver) endpoint(segments ...string) string {
+	u := *d.baseURL
+	for _, s := range segments {
+		u.RawPath = strings.TrimSuffix(u.EscapedPath(), "/") + "/" + url.PathEscape(s)
+		u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s
+	}
+	return u.String()
+}
+
+// error reported by the API
+type apiError struct {
+	Error string `json:"error"`
+}
+
+// send `body` (encoded as json, may be nil) to the endpoint and decode the
+// response into `result` (may be nil)
+func (d *SignalCliDriver) do(method string, endpoint string, body any, result any) error {
+	var r io.Reader
+	if body != nil {
+		b, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(b)
+	}
+	req, err := http.NewRequestWithContext(d.ctx, method, endpoint, r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%w (%v)", signalcli.ErrNotConnected, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		b, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		msg := strings.TrimSpace(string(b))
+		var e apiError
+		if json.Unmarshal(b, &e) == nil && e.Error != "" {
+			msg = e.Error
+		}
+		err := fmt.Errorf("signal-cli-rest-api: %s (%s)", msg, resp.Status)
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests, resp.StatusCode == http.StatusRequestTimeout:
+			return err
+		case resp.StatusCode >= 400 && resp.StatusCode < 500:
+			return signalcli.Permanent(err)
+		}
+		return err
+	}
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(result)
+}
+
+// id of the group as used by the API
+func groupRecipient(groupId []byte) string {
+	internal := base64.StdEncoding.EncodeToString(groupId)
+	return "group." + base64.StdEncoding.EncodeToString([]byte(internal))
+}
+
+// the files as data uris
+func encodeAttachments(files []string) ([]string, error) {
+	ret := make([]string, 0, len(files))
+	for _, f := range files {
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, signalcli.Permanent(err)
+		}
+		mt := mime.TypeByExtension(filepath.Ext(f))
+		if mt == "" {
+			mt = http.DetectContentType(b)
+		}
+		// without parameters like the charset
+		mt, _, _ = strings.Cut(mt, ";")
+		ret = append(ret, fmt.Sprintf("data:%s;filename=%s;base64,%s", mt, filepath.Base(f), base64.StdEncoding.EncodeToString(b)))
+	}
+	return ret, nil
+}
+
+type sendRequest struct {
+	Message     string   `json:"message"`
+	Number      string   `json:"number"`
+	Recipients  []string `json:"recipients"`
+	Attachments []string `json:"base64_attachments,omitempty"`
+	NotifySelf  bool     `json:"notify_self,omitempty"`
+}
+
+type sendResult struct {
+	// depending on the version a string or a number
+	Timestamp json.RawMessage `json:"timestamp"`
+}
+
+func (d *SignalCliDriver) send(message string, attachments []string, recipient string, notifySelf bool) (int64, error) {
+	atts, err := encodeAttachments(attachments)
+	if err != nil {
+		return 0, err
+	}
+	var result sendResult
+	err = d.do(http.MethodPost, d.endpoint("v2", "send"), sendRequest{
+		Message:     message,
+		Number:      d.selfNr,
+		Recipients:  []string{recipient},
+		Attachments: atts,
+		NotifySelf:  notifySelf,
+	}, &result)
+	if err != nil {
+		return 0, err
+	}
+	ts, err := strconv.ParseInt(strings.Trim(string(result.Timestamp), `"`), 10, 64)
+	if err != nil {
+		// the message was sent nevertheless, don't send it again
+		d.log.Warn("Invalid timestamp in the response", "timestamp", string(result.Timestamp))
+		return 0, nil
+	}
+	return ts, nil
+}
+
+func (d *SignalCliDriver) SendMessage(message string, attachments []string, recipient string, notifySelf bool) (int64, error) {
+	return d.send(message, attachments, recipient, notifySelf)
+}
+
+func (d *SignalCliDriver) SendGroupMessage(message string, attachments []string, groupId []byte) (int64, error) {
+	return d.send(message, attachments, groupRecipient(groupId), false)
+}
+
+type groupResult struct {
+	Name string `json:"name"`
+}
+
+func (d *SignalCliDriver) GetGroupName(groupId []byte) (string, error) {
+	var result groupResult
+	if err := d.do(http.MethodGet, d.endpoint("v1", "groups", d.selfNr, groupRecipient(groupId)), nil, &result); err != nil {
+		return "", err
+	}
+	return result.Name, nil
+}
+
+func (d *SignalCliDriver) GetSelfNumber() (string, error) {
+	return d.selfNr, nil
+}
+
+func (d *SignalCliDriver) SendTypingIndicator(recipient string, groupId []byte, stop bool) error {
+	if len(groupId) > 0 {
+		recipient = groupRecipient(groupId)
+	}
+	method := http.MethodPut
+	if stop {
+		method = http.MethodDelete
+	}
+	return d.do(method, d.endpoint("v1", "typing-indicator", d.selfNr), map[string]string{"recipient": recipient}, nil)
+}
+
+type contactResult struct {
+	Number  string `json:"number"`
+	Uuid    string `json:"uuid"`
+	Name    string `json:"name"`
+	Blocked bool   `json:"blocked"`
+}
+
+func (d *SignalCliDriver) ListContacts() ([]signalcli.Contact, error) {
+	var result []contactResult
+	if err := d.do(http.MethodGet, d.endpoint("v1", "contacts", d.selfNr), nil, &result); err != nil {
+		return nil, err
+	}
+	ret := make([]signalcli.Contact, 0, len(result))
+	for _, c := range result {
+		number := c.Number
+		if number == "" {
+			number = c.Uuid
+		}
+		ret = append(ret, signalcli.Contact{Number: number, Name: c.Name, Blocked: c.Blocked})
+	}
+	return ret, nil
+}
+
+// returns "" if the number is not in the contacts
+func (d *SignalCliDriver) GetContactName(number string) (string, error) {
+	contacts, err := d.ListContacts()
+	if err != nil {
+		return "", err
+	}
+	for _, c := range contacts {
+		if c.Number == number {
+			return c.Name, nil
+		}
+	}
+	return "", nil
+}
+
+func (d *SignalCliDriver) SetContactName(number string, name string) error {
+	return d.do(http.MethodPut, d.endpoint("v1", "contacts", d.selfNr), map[string]any{"recipient": number, "name": name}, nil)
+}
+
+// not offered by the API
+func (d *SignalCliDriver) SetContactBlocked(number string, block bool) error {
+	return signalcli.ErrNotSupported
+}
+
+func (d *SignalCliDriver) SetExpirationTimer(number string, expiration int32) error {
+	return d.do(http.MethodPut, d.endpoint("v1", "contacts", d.selfNr), map[string]any{"recipient": number, "expiration_in_seconds": expiration}, nil)
+}
+
+type profileRequest struct {
+	Name   string `json:"name,omitempty"`
+	About  string `json:"about,omitempty"`
+	Avatar string `json:"base64_avatar,omitempty"`
+}
+
+// the API can neither set the about emoji nor remove the avatar
+func (d *SignalCliDriver) UpdateProfile(name string, about string, aboutEmoji string, avatar string, remove bool) error {
+	if aboutEmoji != "" || remove {
+		return signalcli.ErrNotSupported
+	}
+	req := profileRequest{Name: name, About: about}
+	if avatar != "" {
+		b, err := os.ReadFile(avatar)
+		if err != nil {
+			return err
+		}
+		req.Avatar = base64.StdEncoding.EncodeToString(b)
+	}
+	return d.do(http.MethodPut, d.endpoint("v1", "profiles", d.selfNr), req, nil)
+}
+
+// download the attachment to the attachment directory, returns the filename
+func (d *SignalCliDriver) attachment(id string, contentType string, filename string) (string, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodGet, d.endpoint("v1", "attachments", id), nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("signal-cli-rest-api: downloading attachment %s: %s", id, resp.Status)
+	}
+
+	fn := filepath.Join(d.attachmentDir, filepath.Base(id))
+	f, err := os.Create(fn)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		os.Remove(fn)
+		return "", err
+	}
+	return fn, nil
+}
+
+func (d *SignalCliDriver) SetInterface(inter signalcli.InterDriverToAcc) (err error) {
+	d.recv = signaljsonrpc.Receiver{Log: d.log, Inter: inter, Self: d.selfNr}
+	if d.attachmentDir != "" {
+		d.recv.Attachment = d.attachment
+	}
+	return nil
+}
+
+// connect to the websocket on which the API pushes the received messages
+func (d *SignalCliDriver) dial() (*websocket.Conn, error) {
+	u, err := url.Parse(d.endpoint("v1", "receive", d.selfNr))
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "https" {
+		u.Scheme = "wss"
+	} else {
+		u.Scheme = "ws"
+	}
+	cfg, err := websocket.NewConfig(u.String(), d.baseURL.String())
+	if err != nil {
+		return nil, err
+	}
+	return cfg.DialContext(d.ctx)
+}
+
+// receive messages until the connection is lost
+func (d *SignalCliDriver) receive(ws *websocket.Conn) error {
+	for {
+		var data []byte
+		if err := websocket.Message.Receive(ws, &data); err != nil {
+			return err
+		}
+		d.recv.Receive(data)
+	}
+}
+
+// receive messages until Close is called
+func (d *SignalCliDriver) Start() {
+	backoff := signalcli.Backoff{Min: ReconnectMin, Max: ReconnectMax}
+	for d.ctx.Err() == nil {
+		ws, err := d.dial()
+		if err != nil {
+			if d.SetState(signalcli.StateDisconnected) {
+				d.log.Warn("Connecting to the REST API failed", "url", d.baseURL.String(), "err", err)
+			}
+			if !backoff.Wait(d.ctx) {
+				break
+			}
+			continue
+		}
+
+		d.connMu.Lock()
+		d.conn = ws
+		d.connMu.Unlock()
+		if d.ctx.Err() != nil {
+			// Close was called while dialing
+			ws.Close()
+			break
+		}
+		d.SetState(signalcli.StateConnected)
+		d.log.Info("Connected to the REST API", "url", d.baseURL.String())
+		backoff.Reset()
+
+		err = d.receive(ws)
+
+		d.connMu.Lock()
+		d.conn = nil
+		d.connMu.Unlock()
+		ws.Close()
+		if d.ctx.Err() != nil {
+			break
+		}
+		d.SetState(signalcli.StateDisconnected)
+		d.log.Warn("Lost connection to the REST API", "err", err)
+		if !backoff.Wait(d.ctx) {
+			break
+		}
+	}
+	d.SetState(signalcli.StateClosed)
+}
+
+func (d *SignalCliDriver) Close() {
+	d.cancel()
+	d.connMu.Lock()
+	defer d.connMu.Unlock()
+	if d.conn != nil {
+		d.conn.Close()
+	}
+}
+
+var _ signalcli.Driver = &SignalCliDriver{}
@@ -0,0 +1,268 @@
+package signalrest
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"signalbot_go/signalcli"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+func nopLog() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+}
+
+// stand-in for signal-cli-rest-api
+type fakeAPI struct {
+	*httptest.Server
+	sent   chan sendRequest
+	typing chan string
+	// pushed to the client of the websocket
+	push chan string
+	// drops the connection to the websocket
+	drop chan struct{}
+	// number of connections to the websocket
+	conns atomic.Int32
+}
+
+func newFakeAPI(t *testing.T) *fakeAPI {
+	f := &fakeAPI{
+		sent:   make(chan sendRequest, 1),
+		typing: make(chan string, 1),
+		push:   make(chan string),
+		drop:   make(chan struct{}),
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /v2/send", func(w http.ResponseWriter, r *http.Request) {
+		var req sendRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, `{"error":"invalid body"}`, http.StatusBadRequest)
+			return
+		}
+		switch req.Message {
+		case "invalid":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":"invalid recipient"}`))
+			return
+		case "broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		f.sent <- req
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"timestamp":"42"}`))
+	})
+	mux.HandleFunc("GET /v1/groups/{number}/{gid}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("number") != "+49self" || r.PathValue("gid") != groupRecipient([]byte{0xff, 0xfe}) {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"name":"Family","id":"` + r.PathValue("gid") + `"}`))
+	})
+	mux.HandleFunc("/v1/typing-indicator/{number}", func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		f.typing <- r.Method + " " + req["recipient"]
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("GET /v1/contacts/{number}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"number":"+49a","name":"Alice","blocked":true},{"uuid":"u-1","name":""}]`))
+	})
+	mux.HandleFunc("GET /v1/attachments/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("content of " + r.PathValue("id")))
+	})
+	mux.Handle("/v1/receive/{number}", websocket.Handler(func(ws *websocket.Conn) {
+		// notice when the connection is gone, so a message is not taken
+		// from `push` by a stale handler
+		f.conns.Add(1)
+		gone := make(chan struct{})
+		go func() {
+			var b []byte
+			websocket.Message.Receive(ws, &b)
+			close(gone)
+		}()
+		for {
+			select {
+			case <-gone:
+				return
+			case <-f.drop:
+				ws.Close()
+				return
+			case msg := <-f.push:
+				if err := websocket.Message.Send(ws, msg); err != nil {
+					return
+				}
+			}
+		}
+	}))
+	f.Server = httptest.NewServer(mux)
+	t.Cleanup(f.Close)
+	return f
+}
+
+func newDriver(t *testing.T, f *fakeAPI) (*SignalCliDriver, chan *signalcli.Message) {
+	d, err := NewSignalRestDriver(nopLog(), f.URL, "+49self", t.TempDir())
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	msgs := make(chan *signalcli.Message, 1)
+	d.SetInterface(signalcli.InterDriverToAcc{
+		MessageChan:     msgs,
+		SyncMessageChan: make(chan *signalcli.SyncMessage, 1),
+		EventChan:       make(chan any, 1),
+	})
+	return d, msgs
+}
+
+func TestSend(t *testing.T) {
+	f := newFakeAPI(t)
+	d, _ := newDriver(t, f)
+
+	att := filepath.Join(t.TempDir(), "a.txt")
+	if err := os.WriteFile(att, []byte("hi"), 0o644); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	ts, err := d.SendMessage("hello", []string{att}, "+49a", false)
+	if err != nil || ts != 42 {
+		t.Fatalf("Was: %v (%v) but should be 42", ts, err)
+	}
+	exp := sendRequest{
+		Message:     "hello",
+		Number:      "+49self",
+		Recipients:  []string{"+49a"},
+		Attachments: []string{"data:text/plain;filename=a.txt;base64,aGk="},
+	}
+	if req := <-f.sent; !reflect.DeepEqual(req, exp) {
+		t.Fatalf("Was: %+v but should be %+v", req, exp)
+	}
+
+	gid := []byte{0xff, 0xfe}
+	if _, err := d.SendGroupMessage("hello group", nil, gid); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if req := <-f.sent; len(req.Recipients) != 1 || req.Recipients[0] != groupRecipient(gid) {
+		t.Fatalf("Was: %v but should be %v", req.Recipients, groupRecipient(gid))
+	}
+	if name, err := d.GetGroupName(gid); err != nil || name != "Family" {
+		t.Fatalf("Was: %q (%v) but should be Family", name, err)
+	}
+
+	if err := d.SendTypingIndicator("+49a", nil, false); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if got := <-f.typing; got != "PUT +49a" {
+		t.Fatalf("Was: %q but should be %q", got, "PUT +49a")
+	}
+	if err := d.SendTypingIndicator("", gid, true); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if got := <-f.typing; got != "DELETE "+groupRecipient(gid) {
+		t.Fatalf("Was: %q but should be %q", got, "DELETE "+groupRecipient(gid))
+	}
+
+	contacts, err := d.ListContacts()
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	expContacts := []signalcli.Contact{{Number: "+49a", Name: "Alice", Blocked: true}, {Number: "u-1"}}
+	if !reflect.DeepEqual(contacts, expContacts) {
+		t.Fatalf("Was: %+v but should be %+v", contacts, expContacts)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	f := newFakeAPI(t)
+	d, _ := newDriver(t, f)
+
+	if _, err := d.SendMessage("invalid", nil, "+49a", false); !signalcli.IsPermanent(err) {
+		t.Fatalf("Should be permanent: %v", err)
+	}
+	if _, err := d.SendMessage("broken", nil, "+49a", false); err == nil || signalcli.IsPermanent(err) {
+		t.Fatalf("Should be temporary: %v", err)
+	}
+	if _, err := d.SendMessage("a", []string{"/does/not/exist"}, "+49a", false); !signalcli.IsPermanent(err) {
+		t.Fatalf("Should be permanent: %v", err)
+	}
+	if err := d.SetContactBlocked("+49a", true); !errors.Is(err, signalcli.ErrNotSupported) {
+		t.Fatalf("Should not be supported: %v", err)
+	}
+	f.Close()
+	if _, err := d.SendMessage("a", nil, "+49a", false); !errors.Is(err, signalcli.ErrNotConnected) {
+		t.Fatalf("Should not be connected: %v", err)
+	}
+}
+
+// wait until the driver reaches `state`
+func waitState(t *testing.T, d *SignalCliDriver, state signalcli.ConnectionState) {
+	t.Helper()
+	for i := 0; i < 500; i++ {
+		if d.State() == state {
+			return
+		}
+		time.Sleep(2 * time.Millisecond)
+	}
+	t.Fatalf("Was: %v but should be %v", d.State(), state)
+}
+
+func TestReceive(t *testing.T) {
+	ReconnectMin, ReconnectMax = 10*time.Millisecond, 10*time.Millisecond
+	f := newFakeAPI(t)
+	d, msgs := newDriver(t, f)
+	done := make(chan struct{})
+	go func() {
+		d.Start()
+		close(done)
+	}()
+
+	f.push <- `{"envelope":{"source":"+49a","timestamp":5,"dataMessage":{"timestamp":5,"message":"hi","attachments":[{"id":"att1","contentType":"image/png"}]}},"account":"+49self"}`
+	m := <-msgs
+	if m.Message != "hi" || m.Sender != "+49a" || len(m.Attachments) != 1 {
+		t.Fatalf("Unexpected message: %+v", m)
+	}
+	if b, err := os.ReadFile(m.Attachments[0]); err != nil || string(b) != "content of att1" {
+		t.Fatalf("Was: %q (%v) but should be the attachment", b, err)
+	}
+	waitState(t, d, signalcli.StateConnected)
+
+	// the server drops the connection, the driver reconnects
+	f.drop <- struct{}{}
+	f.push <- `{"envelope":{"source":"+49b","timestamp":6,"dataMessage":{"timestamp":6,"message":"again"}}}`
+	if m := <-msgs; m.Message != "again" {
+		t.Fatalf("Unexpected message: %+v", m)
+	}
+	if n := f.conns.Load(); n != 2 {
+		t.Fatalf("Was: %d connections but should be 2", n)
+	}
+
+	d.Close()
+	<-done
+	if d.State() != signalcli.StateClosed {
+		t.Fatalf("Was: %v but should be %v", d.State(), signalcli.StateClosed)
+	}
+}
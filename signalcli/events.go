@@ -0,0 +1,169 @@
+package signalcli
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Events besides (sync) messages which are received from signal-cli. Drivers
+// pass them as pointers on `InterDriverToAcc.EventChan`, handlers are
+// registered per kind of event.
+
+type ReceiptKind string
+
+const (
+	ReceiptDelivery ReceiptKind = "delivery"
+	ReceiptRead     ReceiptKind = "read"
+	ReceiptViewed   ReceiptKind = "viewed"
+)
+
+// the kind of receipt named `s` as reported by signal-cli (e.g. "READ" or
+// "read"). ok is false if the kind is unknown.
+func ParseReceiptKind(s string) (kind ReceiptKind, ok bool) {
+	switch k := ReceiptKind(strings.ToLower(s)); k {
+	case ReceiptDelivery, ReceiptRead, ReceiptViewed:
+		return k, true
+	}
+	return "", false
+}
+
+// This signal is sent by each recipient (e.g. each group member) after the
+// message was delivered to the device, read or viewed.
+type Receipt struct {
+	// Phone number of the sender of the receipt
+	Sender string `yaml:"sender"`
+	// when the receipt was created
+	When int64       `yaml:"when"`
+	Kind ReceiptKind `yaml:"kind"`
+	// timestamps of the messages the receipt is for (as returned when
+	// sending)
+	Timestamps []int64 `yaml:"ts,flow"`
+}
+
+// sent while somebody is typing in a chat
+type Typing struct {
+	Timestamp int64  `yaml:"ts"`
+	Sender    string `yaml:"sender"`
+	GroupId   []byte `yaml:"gid,flow"`
+	Chat      string `yaml:"chat"`
+	// false if the sender stopped typing
+	Started bool `yaml:"started"`
+}
+
+// a message was edited
+type Edit struct {
+	// the new content of the message. The timestamp is the one of the edit.
+	Message `yaml:",inline"`
+	// timestamp of the message which was edited
+	TargetTimestamp int64 `yaml:"target"`
+}
+
+// a sticker was sent
+type Sticker struct {
+	// meta data of the message (without text)
+	Message   `yaml:",inline"`
+	PackId    string `yaml:"pack"`
+	StickerId uint   `yaml:"id"`
+}
+
+// a message was deleted by its sender
+type RemoteDelete struct {
+	Timestamp int64  `yaml:"ts"`
+	Sender    string `yaml:"sender"`
+	GroupId   []byte `yaml:"gid,flow"`
+	Chat      string `yaml:"chat"`
+	// timestamp of the deleted message
+	TargetTimestamp int64 `yaml:"target"`
+}
+
+// a story was posted
+type Story struct {
+	Timestamp     int64  `yaml:"ts"`
+	Sender        string `yaml:"sender"`
+	GroupId       []byte `yaml:"gid,flow"`
+	Text          string `yaml:"text"`
+	AllowsReplies bool   `yaml:"allowsReplies"`
+	// filenames in the signal-cli storage
+	Attachments []string `yaml:"att,flow"`
+}
+
+// signal-cli reported an error while receiving
+type Exception struct {
+	Type    string `yaml:"type"`
+	Message string `yaml:"msg"`
+}
+
+// something was received which the driver does not know (e.g. a newer
+// signal-cli added a new kind of message)
+type UnknownEvent struct {
+	Timestamp int64  `yaml:"ts"`
+	Sender    string `yaml:"sender"`
+	// names of the fields which are not known
+	Fields []string `yaml:"fields,flow"`
+	// the raw event as received from signal-cli
+	Raw []byte `yaml:"raw"`
+}
+
+// gets called with all events, has to ignore events of other kinds
+type eventHandler func(ev any)
+
+// register `h` for all events of type E
+func addEventHandler[E any](s *Account, h func(*E)) error {
+	if h == nil {
+		return fmt.Errorf("signal-cli: trying to add a nil %T handler func", (*E)(nil))
+	}
+	s.eventHandlersChann <- func(ev any) {
+		if e, ok := ev.(*E); ok {
+			h(e)
+		}
+	}
+	return nil
+}
+
+func (s *Account) AddReceiptHandlerFunc(h func(*Receipt)) error {
+	return addEventHandler(s, h)
+}
+
+func (s *Account) AddTypingHandlerFunc(h func(*Typing)) error {
+	return addEventHandler(s, h)
+}
+
+func (s *Account) AddEditHandlerFunc(h func(*Edit)) error {
+	return addEventHandler(s, h)
+}
+
+func (s *Account) AddStickerHandlerFunc(h func(*Sticker)) error {
+	return addEventHandler(s, h)
+}
+
+func (s *Account) AddRemoteDeleteHandlerFunc(h func(*RemoteDelete)) error {
+	return addEventHandler(s, h)
+}
+
+func (s *Account) AddStoryHandlerFunc(h func(*Story)) error {
+	return addEventHandler(s, h)
+}
+
+func (s *Account) AddExceptionHandlerFunc(h func(*Exception)) error {
+	return addEventHandler(s, h)
+}
+
+func (s *Account) AddUnknownEventHandlerFunc(h func(*UnknownEvent)) error {
+	return addEventHandler(s, h)
+}
@@ -0,0 +1,114 @@
+package signalcli
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"testing"
+	"time"
+)
+
+// driver which hands out the channels to the account
+type chanDriver struct {
+	StateTracker
+	NoContacts
+	inter  InterDriverToAcc
+	typing chan bool
+}
+
+func (d *chanDriver) SendMessage(message string, attachments []string, recipient string, notifySelf bool) (int64, error) {
+	return 0, nil
+}
+func (d *chanDriver) SendGroupMessage(message string, attachments []string, groupId []byte) (int64, error) {
+	return 0, nil
+}
+func (d *chanDriver) GetGroupName(groupId []byte) (string, error) { return "", nil }
+func (d *chanDriver) GetSelfNumber() (string, error)              { return "+49", nil }
+func (d *chanDriver) SetInterface(inter InterDriverToAcc) error {
+	d.inter = inter
+	return nil
+}
+func (d *chanDriver) SendTypingIndicator(recipient string, groupId []byte, stop bool) error {
+	if d.typing != nil {
+		d.typing <- stop
+	}
+	return nil
+}
+func (d *chanDriver) Start() {}
+func (d *chanDriver) Close() {}
+
+func TestEventHandlers(t *testing.T) {
+	d := &chanDriver{}
+	acc, err := NewAccount(nopLog(), d, testQueueCfg, DefaultDeliveryCfg, nil)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	acc.ListenForSignals()
+	defer acc.Close()
+
+	receipts := make(chan *Receipt, 1)
+	typings := make(chan *Typing, 1)
+	if err := acc.AddReceiptHandlerFunc(func(r *Receipt) {
+		select {
+		case receipts <- r:
+		default:
+		}
+	}); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if err := acc.AddTypingHandlerFunc(func(r *Typing) { typings <- r }); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if err := acc.AddStoryHandlerFunc(nil); err == nil {
+		t.Fatalf("Adding a nil handler should fail")
+	}
+
+	// registering happens asynchronously, resend until the handler is called
+	var r *Receipt
+	for i := 0; i < 100 && r == nil; i++ {
+		d.inter.EventChan <- &Receipt{Sender: "+49a"}
+		select {
+		case r = <-receipts:
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if r == nil || r.Sender != "+49a" {
+		t.Fatalf("Was: %v but should be a receipt from %v", r, "+49a")
+	}
+	select {
+	case <-typings:
+		t.Fatalf("Typing handler was called for a receipt")
+	default:
+	}
+}
+
+func TestParseReceiptKind(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		kind ReceiptKind
+		ok   bool
+	}{
+		{"DELIVERY", ReceiptDelivery, true},
+		{"read", ReceiptRead, true},
+		{"Viewed", ReceiptViewed, true},
+		{"UNKNOWN", "", false},
+		{"", "", false},
+	} {
+		if kind, ok := ParseReceiptKind(tc.in); kind != tc.kind || ok != tc.ok {
+			t.Fatalf("%q: Was: %v %v but should be: %v %v", tc.in, kind, ok, tc.kind, tc.ok)
+		}
+	}
+}
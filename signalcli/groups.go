@@ -0,0 +1,132 @@
+package signalcli
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"errors"
+)
+
+var ErrNotSupported error = errors.New("Not supported by the signal-cli driver")
+
+// information about a group
+type Group struct {
+	Id          []byte
+	Name        string
+	Description string
+	// phone numbers of the members
+	Members []string
+	// phone numbers of the admins
+	Admins []string
+}
+
+// changes to a group. Unset fields are left untouched.
+type GroupUpdate struct {
+	Name        *string
+	Description *string
+	// filename of the new avatar
+	Avatar        string
+	AddMembers    []string
+	RemoveMembers []string
+}
+
+// optional part of a Driver for managing groups
+type GroupDriver interface {
+	ListGroups() ([]Group, error)
+	GetGroup(groupId []byte) (Group, error)
+	// create a group and invite the members. Avatar is a filename (empty if
+	// none). Returns the id of the new group.
+	CreateGroup(name string, members []string, avatar string) ([]byte, error)
+	UpdateGroup(groupId []byte, update GroupUpdate) error
+	// join the group the invite link points to
+	JoinGroup(inviteURI string) error
+}
+
+func (s *Account) groupDriver() (GroupDriver, error) {
+	g, ok := s.driver.(GroupDriver)
+	if !ok {
+		return nil, ErrNotSupported
+	}
+	return g, nil
+}
+
+// list the groups the account is a member of
+func (s *Account) ListGroups() ([]Group, error) {
+	g, err := s.groupDriver()
+	if err != nil {
+		return nil, err
+	}
+	return g.ListGroups()
+}
+
+func (s *Account) GetGroup(groupId []byte) (Group, error) {
+	g, err := s.groupDriver()
+	if err != nil {
+		return Group{}, err
+	}
+	return g.GetGroup(groupId)
+}
+
+// phone numbers of the members of the group
+func (s *Account) GetGroupMembers(groupId []byte) ([]string, error) {
+	grp, err := s.GetGroup(groupId)
+	if err != nil {
+		return nil, err
+	}
+	return grp.Members, nil
+}
+
+// create a group and invite the members. Returns the id of the new group.
+func (s *Account) CreateGroup(name string, members []string, avatar string) ([]byte, error) {
+	g, err := s.groupDriver()
+	if err != nil {
+		return nil, err
+	}
+	return g.CreateGroup(name, members, avatar)
+}
+
+func (s *Account) UpdateGroup(groupId []byte, update GroupUpdate) error {
+	g, err := s.groupDriver()
+	if err != nil {
+		return err
+	}
+	return g.UpdateGroup(groupId, update)
+}
+
+func (s *Account) AddGroupMembers(groupId []byte, members ...string) error {
+	return s.UpdateGroup(groupId, GroupUpdate{AddMembers: members})
+}
+
+func (s *Account) RemoveGroupMembers(groupId []byte, members ...string) error {
+	return s.UpdateGroup(groupId, GroupUpdate{RemoveMembers: members})
+}
+
+func (s *Account) SetGroupName(groupId []byte, name string) error {
+	return s.UpdateGroup(groupId, GroupUpdate{Name: &name})
+}
+
+// set the avatar of the group to the image in the file `avatar`
+func (s *Account) SetGroupAvatar(groupId []byte, avatar string) error {
+	return s.UpdateGroup(groupId, GroupUpdate{Avatar: avatar})
+}
+
+func (s *Account) JoinGroup(inviteURI string) error {
+	g, err := s.groupDriver()
+	if err != nil {
+		return err
+	}
+	return g.JoinGroup(inviteURI)
+}
@@ -16,26 +16,148 @@ package signalcli
 // You should have received a copy of the GNU General Public License
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
 // send message to arbitrary recipient.If groupID is empty, send to
 // recipient. If groupID is set, the message is sent to the group (and the
 // recipient is ignored)
+// The message is queued and sent after all messages queued earlier for the
+// same chat. Blocks until the message is sent (transient errors are retried)
+// or sending failed permanently.
+// Messages longer than the configured maximum are split into numbered parts.
+// If paging is enabled, only the first parts are sent, the others on request
+// (see More). The timestamp of the first part is returned.
+// The message is sent as is, see SendStyled for messages with markup.
 func (s *Account) SendGeneric(message string, attachments []string, recipient string, groupID []byte, notify bool) (timestamp int64, err error) {
-	if len(groupID) > 0 {
-		// send group message ignoring recipient
-		return s.driver.SendGroupMessage(message, attachments, groupID)
-	} else {
-		// send normal personal message
-		return s.driver.SendMessage(message, attachments, recipient, notify)
+	return s.send(OutMessage{
+		Message:     message,
+		Attachments: attachments,
+		Recipient:   recipient,
+		GroupId:     groupID,
+		Notify:      notify,
+	})
+}
+
+// like SendGeneric but the markup of the message (see ParseMarkup) is
+// converted into text styles
+func (s *Account) SendStyled(message string, attachments []string, recipient string, groupID []byte, notify bool) (timestamp int64, err error) {
+	text, styles := ParseMarkup(message)
+	return s.send(OutMessage{
+		Message:     text,
+		Styles:      styles,
+		Attachments: attachments,
+		Recipient:   recipient,
+		GroupId:     groupID,
+		Notify:      notify,
+	})
+}
+
+// split, queue and send the message (see SendGeneric)
+func (s *Account) send(m OutMessage) (timestamp int64, err error) {
+	parts := s.split(m)
+	if len(parts) == 1 {
+		res := <-s.outbox.enqueue(parts[0])
+		return res.timestamp, res.err
+	}
+	if n := s.outbox.cfg.MaxParts; s.moreCommand != "" && n > 0 && len(parts) > n {
+		s.pages.set(m.chat(), parts[n:])
+		return s.sendParts(parts[:n], len(parts)-n)
 	}
+	return s.sendParts(parts, 0)
+}
+
+// amount of outbound messages which are not sent yet
+func (s *Account) QueueDepth() int {
+	return s.outbox.len()
+}
+
+// directly send the message via the driver and start tracking its delivery
+func (s *Account) sendNow(m *OutMessage) (ts int64, err error) {
+	err = ErrNotSupported
+	if sd, ok := s.driver.(StyleDriver); ok && len(m.Styles) > 0 {
+		if len(m.GroupId) > 0 {
+			ts, err = sd.SendStyledGroupMessage(m.Message, m.Styles, m.Attachments, m.GroupId)
+		} else {
+			ts, err = sd.SendStyledMessage(m.Message, m.Styles, m.Attachments, m.Recipient, m.Notify)
+		}
+	}
+	if errors.Is(err, ErrNotSupported) {
+		// without styles
+		if len(m.GroupId) > 0 {
+			// send group message ignoring recipient
+			ts, err = s.driver.SendGroupMessage(m.Message, m.Attachments, m.GroupId)
+		} else {
+			// send normal personal message
+			ts, err = s.driver.SendMessage(m.Message, m.Attachments, m.Recipient, m.Notify)
+		}
+	}
+	// some drivers (e.g. the console) don't return timestamps
+	if err == nil && ts != 0 {
+		s.deliveries.sent(ts, m.chat(), time.Now())
+	}
+	return ts, err
 }
 
 // respond to a certain message. The recipient/groupID will be extracted from the message
 func (s *Account) Respond(message string, attachments []string, m *Message, notify bool) (timestamp int64, err error) {
-	dst := m.Sender
+	return s.SendGeneric(message, attachments, s.respondTo(m), m.GroupId, notify)
+}
+
+// respond to a certain message with a message containing markup (see
+// SendStyled)
+func (s *Account) RespondStyled(message string, attachments []string, m *Message, notify bool) (timestamp int64, err error) {
+	return s.SendStyled(message, attachments, s.respondTo(m), m.GroupId, notify)
+}
+
+// recipient of a response to `m` (ignored for group messages)
+func (s *Account) respondTo(m *Message) string {
 	if m.Sender == s.SelfNr {
-		dst = m.Receiver
+		return m.Receiver
+	}
+	return m.Sender
+}
+
+// signal clients hide the typing indicator after 15s
+var typingRefresh = 10 * time.Second
+
+// show the typing indicator in the chat of `m` until `stop` is called. stop
+// returns once the indicator was stopped, so a reply sent afterwards can't be
+// overtaken by it. Calling stop more than once is fine. Errors are only
+// logged, the indicator is just cosmetics.
+func (s *Account) StartTyping(m *Message) (stop func()) {
+	recipient := s.respondTo(m)
+	gid := m.GroupId
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		t := time.NewTicker(typingRefresh)
+		defer t.Stop()
+		for {
+			if s.driver.State() == StateConnected {
+				if err := s.driver.SendTypingIndicator(recipient, gid, false); err != nil {
+					s.log.Debug("Error sending typing indicator", "error", err)
+				}
+			}
+			select {
+			case <-done:
+				if err := s.driver.SendTypingIndicator(recipient, gid, true); err != nil {
+					s.log.Debug("Error stopping typing indicator", "error", err)
+				}
+				return
+			case <-t.C:
+			}
+		}
+	}()
+	once := sync.Once{}
+	return func() {
+		once.Do(func() { close(done) })
+		<-stopped
 	}
-	return s.SendGeneric(message, attachments, dst, m.GroupId, notify)
 }
 
 func (s *Account) GetGroupName(groupId []byte) (string, error) {
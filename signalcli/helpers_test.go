@@ -0,0 +1,58 @@
+package signalcli
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStartTyping(t *testing.T) {
+	old := typingRefresh
+	typingRefresh = 5 * time.Millisecond
+	defer func() { typingRefresh = old }()
+
+	d := &chanDriver{typing: make(chan bool, 100)}
+	d.SetState(StateConnected)
+	acc, err := NewAccount(nopLog(), d, testQueueCfg, DefaultDeliveryCfg, nil)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	stop := acc.StartTyping(&Message{Sender: "+49a"})
+	// started and refreshed
+	for i := 0; i < 2; i++ {
+		if s := <-d.typing; s {
+			t.Fatalf("Typing should have been started")
+		}
+	}
+	stop()
+	// the indicator is stopped when stop returns
+	last := false
+	for len(d.typing) > 0 {
+		last = <-d.typing
+	}
+	if !last {
+		t.Fatalf("Typing should have been stopped")
+	}
+	stop()
+	select {
+	case s := <-d.typing:
+		t.Fatalf("Was: %v but nothing should be sent after stopping", s)
+	case <-time.After(20 * time.Millisecond):
+	}
+}
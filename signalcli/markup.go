@@ -0,0 +1,187 @@
+package signalcli
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"strings"
+	"unicode/utf16"
+)
+
+// text styles supported by signal
+type Style string
+
+const (
+	StyleBold          Style = "BOLD"
+	StyleItalic        Style = "ITALIC"
+	StyleMonospace     Style = "MONOSPACE"
+	StyleSpoiler       Style = "SPOILER"
+	StyleStrikethrough Style = "STRIKETHROUGH"
+)
+
+// a styled range of a message. Offsets are in UTF-16 code units (as
+// expected by signal).
+type TextStyle struct {
+	Start  int   `yaml:"start" json:"start"`
+	Length int   `yaml:"length" json:"length"`
+	Style  Style `yaml:"style" json:"style"`
+}
+
+// implemented by drivers which can send styled text. Drivers without it get
+// the text without markup.
+type StyleDriver interface {
+	SendStyledMessage(message string, styles []TextStyle, attachments []string, recipient string, notifySelf bool) (int64, error)
+	SendStyledGroupMessage(message string, styles []TextStyle, attachments []string, groupId []byte) (int64, error)
+}
+
+// markup which can be used in messages. Inline markup has to be closed on the
+// same line, the content of raw markup is not parsed further.
+var markup = []struct {
+	delim     string
+	style     Style
+	multiline bool
+	raw       bool
+}{
+	{"```", StyleMonospace, true, true},
+	{"**", StyleBold, false, false},
+	{"__", StyleItalic, false, false},
+	{"~~", StyleStrikethrough, false, false},
+	{"||", StyleSpoiler, false, false},
+	{"`", StyleMonospace, false, true},
+}
+
+// characters which can be escaped with a backslash
+const markupChars = "*_~|`\\"
+
+// escape `s` so that it is sent as is (e.g. text from websites or commands)
+func EscapeMarkup(s string) string {
+	b := strings.Builder{}
+	for _, r := range s {
+		if strings.ContainsRune(markupChars, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// convert the markup in `text` (**bold**, __italic__, ~~strikethrough~~,
+// ||spoiler||, `monospace` and ```monospace blocks```) into text styles.
+// Returns the text without markup. Unclosed markup is left as is.
+func ParseMarkup(text string) (string, []TextStyle) {
+	p := markupParser{}
+	p.parse(text)
+	return p.b.String(), p.styles
+}
+
+type markupParser struct {
+	b strings.Builder
+	// length of the output in UTF-16 code units
+	n      int
+	styles []TextStyle
+}
+
+// write `s` without the escaping backslashes
+func (p *markupParser) write(s string) {
+	escaped := false
+	for _, r := range s {
+		if r == '\\' && !escaped {
+			escaped = true
+			continue
+		}
+		if escaped && !strings.ContainsRune(markupChars, r) {
+			// not an escape sequence
+			p.b.WriteRune('\\')
+			p.n++
+		}
+		escaped = false
+		p.b.WriteRune(r)
+		p.n += utf16.RuneLen(r)
+	}
+	if escaped {
+		p.b.WriteRune('\\')
+		p.n++
+	}
+}
+
+func (p *markupParser) parse(s string) {
+	for len(s) > 0 {
+		start, end, k := nextMarkup(s)
+		if k < 0 {
+			p.write(s)
+			return
+		}
+		m := markup[k]
+		p.write(s[:start])
+		content := s[start+len(m.delim) : end]
+		if m.multiline {
+			content = strings.TrimPrefix(content, "\n")
+			content = strings.TrimSuffix(content, "\n")
+		}
+		from := p.n
+		if m.raw {
+			p.write(content)
+		} else {
+			p.parse(content)
+		}
+		if p.n > from {
+			p.styles = append(p.styles, TextStyle{Start: from, Length: p.n - from, Style: m.style})
+		}
+		s = s[end+len(m.delim):]
+	}
+}
+
+// the first markup in `s` which is closed. Returns the position of the
+// opening and the closing delimiter and the index of the markup (-1 if there
+// is none).
+func nextMarkup(s string) (start int, end int, k int) {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			// skip the escaped character
+			i++
+			continue
+		}
+		for k, m := range markup {
+			if !strings.HasPrefix(s[i:], m.delim) {
+				continue
+			}
+			rest := s[i+len(m.delim):]
+			if !m.multiline {
+				if nl := strings.IndexByte(rest, '\n'); nl >= 0 {
+					rest = rest[:nl]
+				}
+			}
+			if j := indexUnescaped(rest, m.delim); j > 0 {
+				return i, i + len(m.delim) + j, k
+			}
+		}
+	}
+	return -1, -1, -1
+}
+
+// index of the first occurrence of `delim` in `s` which is not escaped
+func indexUnescaped(s string, delim string) int {
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' {
+			i++
+			continue
+		}
+		if strings.HasPrefix(s[i:], delim) {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,112 @@
+package signalcli
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseMarkup(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		in     string
+		out    string
+		styles []TextStyle
+	}{
+		{"plain", "hello world", "hello world", nil},
+		{"bold", "**Mensa** on Mon", "Mensa on Mon", []TextStyle{{0, 5, StyleBold}}},
+		{"nested", "a **b __c__** d", "a b c d", []TextStyle{{4, 1, StyleItalic}, {2, 3, StyleBold}}},
+		{"mono raw", "`**x**` y", "**x** y", []TextStyle{{0, 5, StyleMonospace}}},
+		{"block", "```\na  b\nc  d\n```", "a  b\nc  d", []TextStyle{{0, 9, StyleMonospace}}},
+		{"spoiler strike", "||s|| ~~t~~", "s t", []TextStyle{{0, 1, StyleSpoiler}, {2, 1, StyleStrikethrough}}},
+		{"unclosed", "2 ** 3 and a_b", "2 ** 3 and a_b", nil},
+		{"not across lines", "**a\nb**", "**a\nb**", nil},
+		{"empty", "****", "****", nil},
+		{"escaped", `\*\*a\*\* c:\dir`, `**a** c:\dir`, nil},
+		// offsets are counted in UTF-16 code units
+		{"utf16", "🌧️ **Grill**", "🌧️ Grill", []TextStyle{{4, 5, StyleBold}}},
+	} {
+		out, styles := ParseMarkup(tc.in)
+		if out != tc.out || !reflect.DeepEqual(styles, tc.styles) {
+			t.Fatalf("%s: Was: %q %v but should be: %q %v", tc.name, out, styles, tc.out, tc.styles)
+		}
+	}
+
+	for _, s := range []string{"a **b** `c` ||d|| __e__ ~~f~~", `c:\dir\*`, "```x```"} {
+		if out, styles := ParseMarkup(EscapeMarkup(s)); out != s || styles != nil {
+			t.Fatalf("Was: %q %v but should be: %q", out, styles, s)
+		}
+	}
+}
+
+// driver which supports styles and records what it sends
+type styleDriver struct {
+	chanDriver
+	sent   chan string
+	styles chan []TextStyle
+}
+
+func (d *styleDriver) SendMessage(message string, attachments []string, recipient string, notifySelf bool) (int64, error) {
+	d.sent <- message
+	d.styles <- nil
+	return 0, nil
+}
+func (d *styleDriver) SendStyledMessage(message string, styles []TextStyle, attachments []string, recipient string, notifySelf bool) (int64, error) {
+	d.sent <- message
+	d.styles <- styles
+	return 0, nil
+}
+func (d *styleDriver) SendStyledGroupMessage(message string, styles []TextStyle, attachments []string, groupId []byte) (int64, error) {
+	return 0, ErrNotSupported
+}
+
+func TestSendStyled(t *testing.T) {
+	d := &styleDriver{sent: make(chan string, 1), styles: make(chan []TextStyle, 1)}
+	acc, err := NewAccount(nopLog(), d, testQueueCfg, DefaultDeliveryCfg, nil)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	acc.ListenForSignals()
+	defer acc.Close()
+
+	for _, tc := range []struct {
+		msg    string
+		out    string
+		styles []TextStyle
+	}{
+		{"**Pasta**:", "Pasta:", []TextStyle{{0, 5, StyleBold}}},
+		{"plain", "plain", nil},
+	} {
+		if _, err := acc.SendStyled(tc.msg, nil, "+49123", nil, false); err != nil {
+			t.Fatalf("Err: %v", err)
+		}
+		if out, styles := <-d.sent, <-d.styles; out != tc.out || !reflect.DeepEqual(styles, tc.styles) {
+			t.Fatalf("Was: %q %v but should be: %q %v", out, styles, tc.out, tc.styles)
+		}
+	}
+
+	// without SendStyled the text is sent unchanged
+	for _, msg := range []string{"foo__init__bar", `a\*b`, "**Pasta**"} {
+		if _, err := acc.SendGeneric(msg, nil, "+49123", nil, false); err != nil {
+			t.Fatalf("Err: %v", err)
+		}
+		if out, styles := <-d.sent, <-d.styles; out != msg || styles != nil {
+			t.Fatalf("Was: %q %v but should be: %q", out, styles, msg)
+		}
+	}
+}
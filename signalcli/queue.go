@@ -0,0 +1,338 @@
+package signalcli
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"signalbot_go/internal/state"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrQueueClosed error = errors.New("Outbound queue closed before the message was sent")
+var ErrQueueNotRunning error = errors.New("Outbound queue was not started, call ListenForSignals first")
+
+// marks an error returned by a driver as permanent, retrying to send the
+// message won't help. All other errors are considered to be transient.
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// wrap `err` in a PermanentError
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// check if `err` is (or wraps) a PermanentError
+func IsPermanent(err error) bool {
+	var p *PermanentError
+	return errors.As(err, &p)
+}
+
+// configuration of the outbound queue. Can be parsed from yaml
+type QueueCfg struct {
+	// how often sending a message is retried on transient errors
+	MaxRetries uint `yaml:"maxRetries"`
+	// wait time before the first retry, doubled with each retry
+	MinBackoff time.Duration `yaml:"minBackoff"`
+	// upper bound of the wait time between two retries
+	MaxBackoff time.Duration `yaml:"maxBackoff"`
+	// longer messages are split into numbered parts (in characters, 0
+	// disables splitting)
+	MaxLength int `yaml:"maxLength"`
+	// amount of parts sent at once if paging is enabled, the others are sent
+	// on request (0 sends all parts)
+	MaxParts int `yaml:"maxParts"`
+}
+
+var DefaultQueueCfg QueueCfg = QueueCfg{
+	MaxRetries: 5,
+	MinBackoff: time.Second,
+	MaxBackoff: time.Minute,
+	MaxLength:  2000,
+	MaxParts:   3,
+}
+
+// message waiting in the outbound queue
+type OutMessage struct {
+	Message     string      `yaml:"msg"`
+	Styles      []TextStyle `yaml:"styles,omitempty"` // the markup is already removed from Message
+	Attachments []string    `yaml:"att,flow"`
+	Recipient   string      `yaml:"recipient"`
+	GroupId     []byte      `yaml:"gid,flow"`
+	Notify      bool        `yaml:"notify"`
+	Queued      time.Time   `yaml:"queued"`
+}
+
+// identifies the chat the message is sent to (like `Message.Chat`)
+func (m *OutMessage) chat() string {
+	if len(m.GroupId) > 0 {
+		return hex.EncodeToString(m.GroupId)
+	}
+	return m.Recipient
+}
+
+type sendResult struct {
+	timestamp int64
+	err       error
+}
+
+type outItem struct {
+	OutMessage
+	seq uint64
+	// nil if nobody waits for the result
+	done chan sendResult
+}
+
+// key and schema version of the unsent messages in the state
+const (
+	outboxKey     string = "outbox"
+	outboxVersion uint   = 1
+)
+
+// queue of outbound messages. Messages to the same chat are sent in the
+// order they were queued (one worker per chat), different chats don't block
+// each other. Unsent messages are persisted in the state store and sent after
+// a restart.
+// Create with `newOutbox`.
+type outbox struct {
+	cfg   QueueCfg
+	send  func(m *OutMessage) (int64, error)
+	store state.Store
+	log   *slog.Logger
+
+	mu      sync.Mutex
+	chats   map[string][]*outItem
+	running map[string]bool
+	seq     uint64
+	depth   int
+	started bool
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+// create a new queue which uses `send` to deliver the messages. Messages
+// persisted in `store` (might be nil) are queued again.
+func newOutbox(log *slog.Logger, cfg QueueCfg, store state.Store, send func(m *OutMessage) (int64, error)) (*outbox, error) {
+	o := &outbox{
+		cfg:     cfg,
+		send:    send,
+		store:   store,
+		log:     log,
+		chats:   make(map[string][]*outItem),
+		running: make(map[string]bool),
+	}
+	o.ctx, o.cancel = context.WithCancel(context.Background())
+
+	if store == nil {
+		return o, nil
+	}
+	var pending []OutMessage
+	if _, err := store.Load(outboxKey, &pending); err != nil && err != state.ErrNotFound {
+		return nil, err
+	}
+	for _, m := range pending {
+		o.push(m, nil)
+	}
+	if len(pending) > 0 {
+		o.log.Info("Restored unsent messages", "count", len(pending))
+	}
+	return o, nil
+}
+
+// append the message to the queue of its chat. o.mu has to be held.
+func (o *outbox) push(m OutMessage, done chan sendResult) {
+	o.seq++
+	chat := m.chat()
+	o.chats[chat] = append(o.chats[chat], &outItem{OutMessage: m, seq: o.seq, done: done})
+	o.depth++
+	if o.started && !o.running[chat] {
+		o.running[chat] = true
+		o.wg.Add(1)
+		go o.work(chat)
+	}
+}
+
+// queue a message. The returned channel receives the result once the message
+// was sent or sending failed permanently. Fails right away if the queue was
+// not started, nobody would send the message.
+func (o *outbox) enqueue(m OutMessage) <-chan sendResult {
+	done := make(chan sendResult, 1)
+	m.Queued = time.Now()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.ctx.Err() != nil {
+		done <- sendResult{err: ErrQueueClosed}
+		return done
+	}
+	if !o.started {
+		done <- sendResult{err: ErrQueueNotRunning}
+		return done
+	}
+	o.push(m, done)
+	o.persist()
+	return done
+}
+
+// start sending the queued messages
+func (o *outbox) start() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.started = true
+	for chat := range o.chats {
+		if !o.running[chat] {
+			o.running[chat] = true
+			o.wg.Add(1)
+			go o.work(chat)
+		}
+	}
+}
+
+// amount of messages which are not sent yet
+func (o *outbox) len() int {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.depth
+}
+
+// stop sending messages. Call `wait` afterwards to wait for the workers to
+// finish. Messages which are not sent yet stay persisted.
+func (o *outbox) stop() {
+	o.cancel()
+}
+
+// wait until all workers finished after calling `stop`
+func (o *outbox) wait() {
+	o.wg.Wait()
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	for _, q := range o.chats {
+		for _, item := range q {
+			if item.done != nil {
+				item.done <- sendResult{err: ErrQueueClosed}
+				item.done = nil
+			}
+		}
+	}
+}
+
+// write the unsent messages to the state store. o.mu has to be held.
+func (o *outbox) persist() {
+	if o.store == nil {
+		return
+	}
+	items := make([]*outItem, 0, o.depth)
+	for _, q := range o.chats {
+		items = append(items, q...)
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].seq < items[j].seq })
+	pending := make([]OutMessage, 0, len(items))
+	for _, item := range items {
+		pending = append(pending, item.OutMessage)
+	}
+	if err := o.store.Save(outboxKey, outboxVersion, pending); err != nil {
+		o.log.Error("Error persisting the outbound queue", "error", err)
+	}
+}
+
+// send the messages queued for `chat` one after another until the queue of
+// the chat is empty
+func (o *outbox) work(chat string) {
+	defer o.wg.Done()
+	for {
+		o.mu.Lock()
+		q := o.chats[chat]
+		if len(q) == 0 || o.ctx.Err() != nil {
+			delete(o.running, chat)
+			o.mu.Unlock()
+			return
+		}
+		item := q[0]
+		o.mu.Unlock()
+
+		ts, err := o.deliver(item)
+		if err != nil && o.ctx.Err() != nil {
+			// closed while retrying, the message stays queued
+			o.mu.Lock()
+			delete(o.running, chat)
+			o.mu.Unlock()
+			return
+		}
+
+		o.mu.Lock()
+		if len(o.chats[chat]) <= 1 {
+			delete(o.chats, chat)
+		} else {
+			o.chats[chat] = o.chats[chat][1:]
+		}
+		o.depth--
+		o.persist()
+		o.mu.Unlock()
+
+		if err != nil {
+			o.log.Error("Dropping message which could not be sent", "chat", chat, "error", err)
+		}
+		if item.done != nil {
+			item.done <- sendResult{timestamp: ts, err: err}
+		}
+	}
+}
+
+// try to send the message, retry with exponential backoff on transient
+// errors. While the driver is not connected, retrying doesn't count towards
+// MaxRetries.
+func (o *outbox) deliver(item *outItem) (int64, error) {
+	backoff := Backoff{Min: o.cfg.MinBackoff, Max: o.cfg.MaxBackoff}
+	try := uint(0)
+	for {
+		ts, err := o.send(&item.OutMessage)
+		if err == nil || IsPermanent(err) {
+			return ts, err
+		}
+		if !errors.Is(err, ErrNotConnected) {
+			if try >= o.cfg.MaxRetries {
+				return ts, fmt.Errorf("giving up after %d retries: %v", try, err)
+			}
+			try++
+		}
+		o.log.Warn("Sending message failed, retrying", "chat", item.chat(), "try", try, "error", err)
+
+		if !backoff.Wait(o.ctx) {
+			return 0, err
+		}
+	}
+}
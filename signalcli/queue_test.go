@@ -0,0 +1,208 @@
+package signalcli
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"signalbot_go/internal/state"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testQueueCfg QueueCfg = QueueCfg{
+	MaxRetries: 3,
+	MinBackoff: time.Millisecond,
+	MaxBackoff: 5 * time.Millisecond,
+}
+
+func nopLog() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.LevelError}))
+}
+
+// records the sent messages, fails the first `fails` tries of each message
+// with `err`
+type fakeSend struct {
+	mu    sync.Mutex
+	fails int
+	err   error
+	tries map[string]int
+	sent  []string
+}
+
+func (f *fakeSend) send(m *OutMessage) (int64, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.tries == nil {
+		f.tries = make(map[string]int)
+	}
+	f.tries[m.Message]++
+	if f.tries[m.Message] <= f.fails {
+		return 0, f.err
+	}
+	f.sent = append(f.sent, m.Message)
+	return int64(len(f.sent)), nil
+}
+
+func TestOutboxOrderRetry(t *testing.T) {
+	f := &fakeSend{fails: 2, err: errors.New("transient")}
+	o, err := newOutbox(nopLog(), testQueueCfg, nil, f.send)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	o.start()
+
+	msgs := []string{"1", "2", "3", "4", "5"}
+	dones := make([]<-chan sendResult, 0, len(msgs))
+	for _, m := range msgs {
+		dones = append(dones, o.enqueue(OutMessage{Message: m, Recipient: "+49123"}))
+	}
+	for _, d := range dones {
+		if res := <-d; res.err != nil {
+			t.Fatalf("Err: %v", res.err)
+		}
+	}
+
+	for i, m := range msgs {
+		if f.sent[i] != m {
+			t.Fatalf("Was: %v but should be %v", f.sent, msgs)
+		}
+	}
+	if o.len() != 0 {
+		t.Fatalf("Queue should be empty but has %d messages", o.len())
+	}
+	o.stop()
+	o.wait()
+}
+
+func TestOutboxFailure(t *testing.T) {
+	// permanent errors are not retried
+	f := &fakeSend{fails: 10, err: Permanent(errors.New("permanent"))}
+	o, err := newOutbox(nopLog(), testQueueCfg, nil, f.send)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	o.start()
+	if res := <-o.enqueue(OutMessage{Message: "a", Recipient: "+49123"}); !IsPermanent(res.err) {
+		t.Fatalf("Should have returned a permanent error but was %v", res.err)
+	}
+	if f.tries["a"] != 1 {
+		t.Fatalf("Was tried %d times but should be %d", f.tries["a"], 1)
+	}
+
+	// transient errors are retried until giving up
+	f.err = errors.New("transient")
+	if res := <-o.enqueue(OutMessage{Message: "b", Recipient: "+49123"}); res.err == nil {
+		t.Fatalf("Should have failed")
+	}
+	if f.tries["b"] != int(testQueueCfg.MaxRetries)+1 {
+		t.Fatalf("Was tried %d times but should be %d", f.tries["b"], testQueueCfg.MaxRetries+1)
+	}
+
+	// while not connected, retrying doesn't count
+	f.err = fmt.Errorf("wrapped: %w", ErrNotConnected)
+	if res := <-o.enqueue(OutMessage{Message: "c", Recipient: "+49123"}); res.err != nil {
+		t.Fatalf("Err: %v", res.err)
+	}
+	if f.tries["c"] != f.fails+1 {
+		t.Fatalf("Was tried %d times but should be %d", f.tries["c"], f.fails+1)
+	}
+	o.stop()
+	o.wait()
+}
+
+func TestOutboxPersist(t *testing.T) {
+	st, err := state.NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	// never started -> nothing is queued
+	f := &fakeSend{}
+	o, err := newOutbox(nopLog(), testQueueCfg, st, f.send)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	select {
+	case res := <-o.enqueue(OutMessage{Message: "0", Recipient: "+49123"}):
+		if res.err != ErrQueueNotRunning {
+			t.Fatalf("Should have returned %v but was %v", ErrQueueNotRunning, res.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("Should not block if the queue was not started")
+	}
+	if o.len() != 0 {
+		t.Fatalf("Was: %d but should be %d", o.len(), 0)
+	}
+
+	// not connected -> nothing is sent
+	f.fails = 1000
+	f.err = ErrNotConnected
+	o.start()
+	dones := []<-chan sendResult{
+		o.enqueue(OutMessage{Message: "1", Recipient: "+49123"}),
+		o.enqueue(OutMessage{Message: "2", GroupId: []byte{1, 2}}),
+		o.enqueue(OutMessage{Message: "3", Recipient: "+49123"}),
+	}
+	o.stop()
+	o.wait()
+	for _, d := range dones {
+		if res := <-d; res.err != ErrQueueClosed {
+			t.Fatalf("Should have returned %v but was %v", ErrQueueClosed, res.err)
+		}
+	}
+
+	// restart
+	f.mu.Lock()
+	f.fails = 0
+	f.mu.Unlock()
+	o, err = newOutbox(nopLog(), testQueueCfg, st, f.send)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if o.len() != 3 {
+		t.Fatalf("Was: %d but should be %d", o.len(), 3)
+	}
+	o.start()
+	for i := 0; i < 100 && o.len() > 0; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	o.stop()
+	o.wait()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if len(f.sent) != 3 {
+		t.Fatalf("Was: %v but should have sent 3 messages", f.sent)
+	}
+	// order within a chat is kept
+	idx := make(map[string]int)
+	for i, m := range f.sent {
+		idx[m] = i
+	}
+	if idx["1"] > idx["3"] {
+		t.Fatalf("Wrong order: %v", f.sent)
+	}
+	var pending []OutMessage
+	if _, err := st.Load(outboxKey, &pending); err != nil || len(pending) != 0 {
+		t.Fatalf("Was: %v (%v) but should be empty", pending, err)
+	}
+}
@@ -17,7 +17,10 @@ package signalcli
 // along with this program.  If not, see <http://www.gnu.org/licenses/>.
 
 import (
+	"fmt"
 	"log/slog"
+	"signalbot_go/internal/state"
+	"time"
 )
 
 type Driver interface {
@@ -25,19 +28,31 @@ type Driver interface {
 	SendGroupMessage(message string, attachments []string, groupId []byte) (timestamp int64, err error)
 	GetGroupName(groupId []byte) (string, error)
 	GetSelfNumber() (number string, err error)
+	// show (or hide if `stop` is set) the typing indicator in the chat. If
+	// groupId is set, the recipient is ignored.
+	SendTypingIndicator(recipient string, groupId []byte, stop bool) error
 	SetInterface(inter InterDriverToAcc) (err error)
+	// receive messages until Close is called. Reconnects to signal-cli if
+	// the connection is lost.
 	Start()
 	Close()
+	// current state of the connection to signal-cli. Safe for concurrent
+	// use.
+	State() ConnectionState
+	ContactDriver
 }
 
 type InterAccToDriver struct {
 	MessageChan <-chan *Message
 	SyncMessageChan <-chan *SyncMessage
+	EventChan <-chan any
 }
 
 type InterDriverToAcc struct {
 	MessageChan chan<- *Message
 	SyncMessageChan chan<- *SyncMessage
+	// receives pointers to the other events (e.g. *Receipt)
+	EventChan chan<- any
 }
 
 // an Interface to the signal-cli dbus -- signle account mode
@@ -54,12 +69,21 @@ type Account struct {
 
 	messageHandlersChann     chan *MessageHandler
 	syncMessageHandlersChann chan *SyncMessageHandler
+	eventHandlersChann       chan eventHandler
 	stop                     chan interface{}
 
 	SelfNr string
 
 	driverInter InterAccToDriver
 
+	outbox *outbox
+	deliveries *deliveries
+	contactNames *contactNames
+	// parts of long messages sent on request
+	pages *pages
+	// command which sends the next parts (empty disables paging)
+	moreCommand string
+
 	log *slog.Logger
 }
 
@@ -68,13 +92,20 @@ type Account struct {
 // Might block if ListenForSignals was never called!
 func (s *Account) Close() {
 	s.stop <- true
+	// closing the driver aborts sends which are in progress, the messages
+	// stay in the (persisted) queue
+	s.outbox.stop()
 	s.driver.Close()
+	s.outbox.wait()
 }
 
-// create a new Account object.
-func NewAccount(log *slog.Logger, c Driver) (acc *Account, err error) {
+// create a new Account object. Outbound messages are queued, `st` is used to
+// persist messages which are not sent yet (might be nil). The delivery status
+// of sent messages is tracked according to `deliveryCfg`.
+func NewAccount(log *slog.Logger, c Driver, queueCfg QueueCfg, deliveryCfg DeliveryCfg, st state.Store) (acc *Account, err error) {
 	msgChan := make(chan *Message, 5)
 	syncMsgChan := make(chan *SyncMessage, 5)
+	eventChan := make(chan any, 5)
 
 	acc = &Account{
 		driver: c,
@@ -83,13 +114,19 @@ func NewAccount(log *slog.Logger, c Driver) (acc *Account, err error) {
 
 		messageHandlersChann:     make(chan *MessageHandler, 5),
 		syncMessageHandlersChann: make(chan *SyncMessageHandler, 5),
+		eventHandlersChann:       make(chan eventHandler, 5),
 		stop:                     make(chan interface{}),
 
 		driverInter: InterAccToDriver{
 			MessageChan: msgChan,
 			SyncMessageChan: syncMsgChan,
+			EventChan: eventChan,
 		},
 
+		deliveries: newDeliveries(deliveryCfg),
+		contactNames: newContactNames(),
+		pages: newPages(),
+
 		log:                      log,
 	}
 
@@ -98,9 +135,15 @@ func NewAccount(log *slog.Logger, c Driver) (acc *Account, err error) {
 		return nil, err
 	}
 
+	acc.outbox, err = newOutbox(log.With("component", "outbox"), queueCfg, st, acc.sendNow)
+	if err != nil {
+		return nil, err
+	}
+
 	i := InterDriverToAcc{
 		MessageChan: msgChan,
 		SyncMessageChan: syncMsgChan,
+		EventChan: eventChan,
 	}
 	acc.log.Debug("init channels", "driver", i.MessageChan, "acc", acc.driverInter.MessageChan)
 	acc.driver.SetInterface(i)
@@ -128,13 +171,38 @@ func (s *Account) ListenForSignalsWithSync(sync chan<- struct{}) {
 
 	messageHandlers := []*MessageHandler{}
 	syncMessageHandlers := []*SyncMessageHandler{}
+	eventHandlers := []eventHandler{}
 
 	var (
 		hm *MessageHandler
 		hs *SyncMessageHandler
 	)
 
+	// take the handlers which were added before listening, so that they see
+	// the first messages from the driver
+	for pending := true; pending; {
+		select {
+		case hs = <-s.syncMessageHandlersChann:
+			syncMessageHandlers = append(syncMessageHandlers, hs)
+		case hm = <-s.messageHandlersChann:
+			messageHandlers = append(messageHandlers, hm)
+		case he := <-s.eventHandlersChann:
+			eventHandlers = append(eventHandlers, he)
+		default:
+			pending = false
+		}
+	}
+
 	go s.driver.Start()
+	s.outbox.start()
+
+	// check for undelivered messages and forget old ones
+	checkInterval := s.deliveries.cfg.Timeout / 4
+	if checkInterval <= 0 {
+		checkInterval = time.Minute
+	}
+	checkDeliveries := time.NewTicker(max(checkInterval, time.Second))
+	defer checkDeliveries.Stop()
 
 	s.log.Info("signal-cli: listening")
 	sync <- struct{}{}
@@ -150,12 +218,16 @@ func (s *Account) ListenForSignalsWithSync(sync chan<- struct{}) {
 		case hm = <-s.messageHandlersChann:
 			s.log.Info("Message handler registered")
 			messageHandlers = append(messageHandlers, hm)
+		case he := <-s.eventHandlersChann:
+			s.log.Info("event handler registered")
+			eventHandlers = append(eventHandlers, he)
 
 		case ele := <-s.driverInter.MessageChan:
 			s.log.Info("message from driver", "msg", ele)
 			if ele == nil {
 				continue
 			}
+			ele.Account = s.SelfNr
 			s.log.Info("Message", "body", ele.String())
 			for _, h := range messageHandlers {
 				(*h).handle(ele)
@@ -165,15 +237,41 @@ func (s *Account) ListenForSignalsWithSync(sync chan<- struct{}) {
 			if ele == nil {
 				continue
 			}
+			ele.Account = s.SelfNr
 			s.log.Info("Sync", "body", ele.String())
 			for _, h := range syncMessageHandlers {
 				(*h).handle(ele)
 			}
+		case now := <-checkDeliveries.C:
+			for _, d := range s.deliveries.overdue(now) {
+				s.log.Warn("Message was not delivered", "ts", d.Timestamp, "chat", d.Chat, "sent", d.Sent)
+				ev := &Undelivered{Delivery: d}
+				for _, h := range eventHandlers {
+					h(ev)
+				}
+			}
+
+		case ele := <-s.driverInter.EventChan:
+			if ele == nil {
+				continue
+			}
+			if r, ok := ele.(*Receipt); ok {
+				s.deliveries.receipt(r, time.Now())
+			}
+			s.log.Debug("Event", "kind", fmt.Sprintf("%T", ele), "body", ele)
+			for _, h := range eventHandlers {
+				h(ele)
+			}
 
 		}
 	}
 }
 
+// current state of the connection to signal-cli
+func (s *Account) ConnectionState() ConnectionState {
+	return s.driver.State()
+}
+
 // TODO Signal.Control interface
 // TODO Signal.Group interface
 // TODO Signal.Device interface
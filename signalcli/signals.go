@@ -74,7 +74,7 @@ func (s *Account) AddSyncMessageHandlerFunc(handler func(*SyncMessage)) error {
 type SyncMessage struct {
 	Message `yaml:",inline"`
 	// Phonenumber of the destination
-	Destination string `yaml:"dst"`
+	Destination string `yaml:"dst" json:"dst"`
 }
 
 func (m *SyncMessage) String() string {
@@ -89,36 +89,47 @@ func (m *SyncMessage) String() string {
 	return builder.String()
 }
 
-// This signal is sent by each recipient (e.g. each group member) after the
-// message was successfully delivered to the device.
-type Receipt struct {
-	// Integer value that can be used to associate this e.g. with a
-	// sendMessage()
-	Timestamp int64 `yaml:"ts"`
-	// Phone number of the sender
-	Sender string `yaml:"sender"`
-}
-
 // This signal is received whenever we get a private message or a message is
 // posted in a group we are an active member.
 type Message struct {
 	// Integer value that is used by the system to send a ReceiptReceived reply
-	Timestamp int64 `yaml:"ts"`
+	Timestamp int64 `yaml:"ts" json:"ts"`
 	// Phone number of the sender
-	Sender string `yaml:"sender"`
+	Sender string `yaml:"sender" json:"sender"`
 	// Phone number of the reveicer
-	Receiver string `yaml:"receiver"`
+	Receiver string `yaml:"receiver" json:"receiver"`
+	// Phone number of the bot account which received the message (set by
+	// the Account)
+	Account string `yaml:"account" json:"account"`
 	// Byte array representing the internal group identifier (empty when
 	// private message)
-	GroupId []byte `yaml:"gid,flow"`
+	GroupId []byte `yaml:"gid,flow" json:"gid,omitempty"`
 	// Either the hex representation of the chat or the phonenumber identifying
 	// the chat (usually the sender, or on sync messages the receiver)
-	Chat string `yaml:"chat"`
+	Chat string `yaml:"chat" json:"chat"`
 	// Message text
-	Message string `yaml:"msg"`
+	Message string `yaml:"msg" json:"msg"`
 	// String array of filenames in the signal-cli storage
 	// (~/.local/share/signal-cli/attachments/)
-	Attachments []string `yaml:"att,flow"`
+	Attachments []string `yaml:"att,flow" json:"att,omitempty"`
+	// metadata of the attachments in the same order as Attachments (empty if
+	// the driver does not receive metadata)
+	AttachmentMeta []Attachment `yaml:"attMeta,omitempty" json:"attMeta,omitempty"`
+}
+
+// metadata of a received attachment as far as signal-cli knows it
+type Attachment struct {
+	// id in the signal-cli storage
+	Id string `yaml:"id" json:"id"`
+	// as sent by the other client (might be wrong)
+	ContentType string `yaml:"contentType" json:"contentType"`
+	// name of the file on the device of the sender (might be empty)
+	Filename string `yaml:"filename" json:"filename"`
+	Size     int64  `yaml:"size" json:"size"`
+	// dimensions of images (0 if unknown)
+	Width   int    `yaml:"width" json:"width"`
+	Height  int    `yaml:"height" json:"height"`
+	Caption string `yaml:"caption" json:"caption"`
 }
 
 func (m *Message) String() string {
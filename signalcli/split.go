@@ -0,0 +1,255 @@
+package signalcli
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+var ErrNoMore error = errors.New("Nothing more to show")
+
+// room left for the paging hint
+const moreHintLen = 64
+
+// length of the "[i/n] " numbering of a message split into `n` parts
+func partPrefixLen(n int) int {
+	return 2*len(strconv.Itoa(n)) + len("[/] ")
+}
+
+// a part of a message (byte offsets)
+type span struct {
+	start, end int
+}
+
+// split `msg` into parts of at most `max` characters. Splits at paragraphs
+// if possible, otherwise at lines, then at spaces.
+func splitMessage(msg string, max int) []string {
+	spans := splitSpans(msg, max)
+	ret := make([]string, 0, len(spans))
+	for _, sp := range spans {
+		ret = append(ret, msg[sp.start:sp.end])
+	}
+	return ret
+}
+
+// like splitMessage but returns where the parts are in `msg`
+func splitSpans(msg string, max int) []span {
+	if max <= 0 || utf8.RuneCountInString(msg) <= max {
+		return []span{{0, len(msg)}}
+	}
+	spans := splitAt(msg, 0, max, []string{"\n\n", "\n", " "})
+	ret := spans[:0]
+	for _, sp := range spans {
+		if strings.TrimSpace(msg[sp.start:sp.end]) != "" {
+			ret = append(ret, sp)
+		}
+	}
+	return ret
+}
+
+// split `msg` (which starts at `off` in the whole message)
+func splitAt(msg string, off int, max int, seps []string) []span {
+	if utf8.RuneCountInString(msg) <= max {
+		return []span{{off, off + len(msg)}}
+	}
+	if len(seps) == 0 {
+		var ret []span
+		start, n := 0, 0
+		for i := range msg {
+			if n == max {
+				ret = append(ret, span{off + start, off + i})
+				start, n = i, 0
+			}
+			n++
+		}
+		return append(ret, span{off + start, off + len(msg)})
+	}
+
+	sep := seps[0]
+	var ret []span
+	cur, curLen, started := span{}, 0, false
+	pos := 0
+	for _, piece := range strings.Split(msg, sep) {
+		p := span{off + pos, off + pos + len(piece)}
+		pos += len(piece) + len(sep)
+		pieceLen := utf8.RuneCountInString(piece)
+		switch {
+		case pieceLen > max:
+			if started {
+				ret = append(ret, cur)
+			}
+			ret = append(ret, splitAt(piece, p.start, max, seps[1:])...)
+			cur, curLen, started = span{}, 0, false
+		case !started:
+			cur, curLen, started = p, pieceLen, true
+		case curLen+len(sep)+pieceLen <= max:
+			cur.end = p.end
+			curLen += len(sep) + pieceLen
+		default:
+			ret = append(ret, cur)
+			cur, curLen = p, pieceLen
+		}
+	}
+	if started {
+		ret = append(ret, cur)
+	}
+	return ret
+}
+
+// length of `s` in UTF-16 code units (the unit of the style offsets)
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		n += utf16.RuneLen(r)
+	}
+	return n
+}
+
+// the styles within [start, end) (in UTF-16 code units) clipped to it and
+// moved by `shift - start`
+func clipStyles(styles []TextStyle, start int, end int, shift int) []TextStyle {
+	var ret []TextStyle
+	for _, st := range styles {
+		from, to := max(st.Start, start), min(st.Start+st.Length, end)
+		if from >= to {
+			continue
+		}
+		ret = append(ret, TextStyle{Start: from - start + shift, Length: to - from, Style: st.Style})
+	}
+	return ret
+}
+
+// parts of long messages which were not sent yet (per chat)
+type pages struct {
+	mu sync.Mutex
+	m  map[string][]OutMessage
+}
+
+func newPages() *pages {
+	return &pages{m: make(map[string][]OutMessage)}
+}
+
+// store the remaining parts for the chat (replaces older ones)
+func (p *pages) set(chat string, rest []OutMessage) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(rest) == 0 {
+		delete(p.m, chat)
+		return
+	}
+	p.m[chat] = rest
+}
+
+// take up to n parts of the chat
+func (p *pages) take(chat string, n int) (parts []OutMessage, remaining int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	rest := p.m[chat]
+	if n <= 0 || n > len(rest) {
+		n = len(rest)
+	}
+	parts, rest = rest[:n], rest[n:]
+	if len(rest) == 0 {
+		delete(p.m, chat)
+	} else {
+		p.m[chat] = rest
+	}
+	return parts, len(rest)
+}
+
+// enable paging of long messages. Only MaxParts parts are sent at once, the
+// others when `command` (which has to call More) is sent to the chat.
+func (s *Account) EnablePaging(command string) {
+	s.moreCommand = command
+}
+
+// split the message into numbered parts according to the configuration. The
+// styles are split along with the text.
+func (s *Account) split(m OutMessage) []OutMessage {
+	limit := s.outbox.cfg.MaxLength
+	if limit > 0 && s.moreCommand != "" && s.outbox.cfg.MaxParts > 0 {
+		limit -= moreHintLen
+	}
+	spans := splitSpans(m.Message, limit)
+	// leave room for the numbering, it gets longer with the amount of parts
+	for reserved := 0; limit > 0 && len(spans) > 1 && reserved < partPrefixLen(len(spans)); {
+		reserved = partPrefixLen(len(spans))
+		spans = splitSpans(m.Message, max(limit-reserved, 1))
+	}
+	ret := make([]OutMessage, 0, len(spans))
+	for i, sp := range spans {
+		part := m
+		prefix := ""
+		if len(spans) > 1 {
+			prefix = fmt.Sprintf("[%d/%d] ", i+1, len(spans))
+		}
+		part.Message = prefix + m.Message[sp.start:sp.end]
+		if len(m.Styles) > 0 {
+			start := utf16Len(m.Message[:sp.start])
+			end := start + utf16Len(m.Message[sp.start:sp.end])
+			part.Styles = clipStyles(m.Styles, start, end, utf16Len(prefix))
+		}
+		if i > 0 {
+			// the attachments are sent with the first part
+			part.Attachments = nil
+		}
+		ret = append(ret, part)
+	}
+	return ret
+}
+
+// send the parts one after the other and return the timestamp of the first
+func (s *Account) sendParts(parts []OutMessage, remaining int) (int64, error) {
+	if remaining > 0 {
+		last := &parts[len(parts)-1]
+		last.Message += fmt.Sprintf("\n(%d more, send \"%s\")", remaining, s.moreCommand)
+	}
+	results := make([]<-chan sendResult, 0, len(parts))
+	for _, p := range parts {
+		results = append(results, s.outbox.enqueue(p))
+	}
+	var (
+		ts  int64
+		err error
+	)
+	for i, r := range results {
+		res := <-r
+		if i == 0 {
+			ts = res.timestamp
+		}
+		if err == nil {
+			err = res.err
+		}
+	}
+	return ts, err
+}
+
+// send the next parts of the last long message to the chat of `m`. Returns
+// ErrNoMore if everything was sent already.
+func (s *Account) More(m *Message) (int64, error) {
+	parts, remaining := s.pages.take(m.Chat, s.outbox.cfg.MaxParts)
+	if len(parts) == 0 {
+		return 0, ErrNoMore
+	}
+	return s.sendParts(parts, remaining)
+}
@@ -0,0 +1,183 @@
+package signalcli
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSplitMessage(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		msg  string
+		max  int
+		exp  []string
+	}{
+		{"short", "hello", 10, []string{"hello"}},
+		{"disabled", "hello world", 0, []string{"hello world"}},
+		{"paragraphs", "aaa\n\nbbb\n\nccc", 8, []string{"aaa\n\nbbb", "ccc"}},
+		{"lines", "aaaa\nbbbb\ncccc", 9, []string{"aaaa\nbbbb", "cccc"}},
+		{"long paragraph", "aa\n\nbbbb bbbb bbbb", 9, []string{"aa", "bbbb bbbb", "bbbb"}},
+		{"words", "aaaaaaaaaaaa", 5, []string{"aaaaa", "aaaaa", "aa"}},
+		{"runes", "äöüäöü", 3, []string{"äöü", "äöü"}},
+		{"empty parts", "aaa\n\n\n\n\n\nbbb", 4, []string{"aaa", "bbb"}},
+	} {
+		was := splitMessage(tc.msg, tc.max)
+		if !reflect.DeepEqual(was, tc.exp) {
+			t.Fatalf("%s: Was: %q but should be: %q", tc.name, was, tc.exp)
+		}
+	}
+}
+
+func TestPaging(t *testing.T) {
+	f := &fakeSend{}
+	cfg := testQueueCfg
+	cfg.MaxLength = 160
+	cfg.MaxParts = 2
+	o, err := newOutbox(nopLog(), cfg, nil, f.send)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	o.start()
+	defer func() {
+		o.stop()
+		o.wait()
+	}()
+	s := &Account{outbox: o, pages: newPages(), log: nopLog()}
+
+	// 5 parts without paging
+	paragraphs := []string{}
+	for _, c := range "abcde" {
+		paragraphs = append(paragraphs, strings.Repeat(string(c), 80))
+	}
+	long := strings.Join(paragraphs, "\n\n")
+	if _, err := s.SendGeneric(long, nil, "+49123", nil, false); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if len(f.sent) != 5 || !strings.HasPrefix(f.sent[0], "[1/5] aaa") || !strings.HasPrefix(f.sent[4], "[5/5] eee") {
+		t.Fatalf("Was: %q", f.sent)
+	}
+	for _, m := range f.sent {
+		if utf8.RuneCountInString(m) > cfg.MaxLength {
+			t.Fatalf("Too long: %q", m)
+		}
+	}
+
+	f.sent = nil
+	s.EnablePaging("more")
+	if _, err := s.SendGeneric(long, nil, "+49123", nil, false); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if len(f.sent) != 2 || !strings.HasSuffix(f.sent[1], "(3 more, send \"more\")") {
+		t.Fatalf("Was: %q", f.sent)
+	}
+	m := &Message{Sender: "+49123", Chat: "+49123"}
+	for _, exp := range []int{4, 5} {
+		if _, err := s.More(m); err != nil {
+			t.Fatalf("Err: %v", err)
+		}
+		if len(f.sent) != exp {
+			t.Fatalf("Was: %q", f.sent)
+		}
+	}
+	if !strings.HasPrefix(f.sent[4], "[5/5] eee") || strings.Contains(f.sent[4], "more") {
+		t.Fatalf("Was: %q", f.sent[4])
+	}
+	if _, err := s.More(m); !errors.Is(err, ErrNoMore) {
+		t.Fatalf("Was: %v but should be: %v", err, ErrNoMore)
+	}
+	// other chats have nothing pending
+	if _, err := s.More(&Message{Sender: "+49456", Chat: "+49456"}); !errors.Is(err, ErrNoMore) {
+		t.Fatalf("Was: %v but should be: %v", err, ErrNoMore)
+	}
+}
+
+func TestSplitManyParts(t *testing.T) {
+	cfg := testQueueCfg
+	cfg.MaxLength = 20
+	o, err := newOutbox(nopLog(), cfg, nil, (&fakeSend{}).send)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	s := &Account{outbox: o, pages: newPages(), log: nopLog()}
+
+	// the numbering of 100 and more parts is longer (the words fill a part
+	// with the shorter numbering)
+	for _, words := range []int{90, 300} {
+		msg := strings.TrimSpace(strings.Repeat("abcdefghijkl ", words))
+		parts := s.split(OutMessage{Message: msg})
+		joined := ""
+		for i, p := range parts {
+			body, ok := strings.CutPrefix(p.Message, fmt.Sprintf("[%d/%d] ", i+1, len(parts)))
+			if !ok || utf8.RuneCountInString(p.Message) > cfg.MaxLength {
+				t.Fatalf("%d words: Wrong part: %q", words, p.Message)
+			}
+			joined += body
+		}
+		if exp := strings.ReplaceAll(msg, " ", ""); strings.ReplaceAll(joined, " ", "") != exp {
+			t.Fatalf("%d words: Was: %q but should be: %q", words, joined, exp)
+		}
+	}
+}
+
+func TestSplitStyled(t *testing.T) {
+	cfg := testQueueCfg
+	cfg.MaxLength = 2000
+	o, err := newOutbox(nopLog(), cfg, nil, (&fakeSend{}).send)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	s := &Account{outbox: o, pages: newPages(), log: nopLog()}
+
+	// long command output as sent by the cmd module
+	lines := []string{}
+	for i := 0; i < 100; i++ {
+		lines = append(lines, fmt.Sprintf("%03d  lib/__init__.py  a\\*b  %s", i, strings.Repeat("x", 20)))
+	}
+	output := strings.Join(lines, "\n") + "\n"
+	text, styles := ParseMarkup("```\n" + EscapeMarkup(output) + "```")
+	parts := s.split(OutMessage{Message: text, Styles: styles})
+	if len(parts) < 2 {
+		t.Fatalf("Expected several parts, got %d", len(parts))
+	}
+	joined := ""
+	for i, p := range parts {
+		prefix := fmt.Sprintf("[%d/%d] ", i+1, len(parts))
+		body, ok := strings.CutPrefix(p.Message, prefix)
+		if !ok || utf8.RuneCountInString(p.Message) > cfg.MaxLength || strings.Contains(p.Message, "```") {
+			t.Fatalf("Wrong part: %q", p.Message)
+		}
+		exp := []TextStyle{{Start: len(prefix), Length: utf16Len(body), Style: StyleMonospace}}
+		if !reflect.DeepEqual(p.Styles, exp) {
+			t.Fatalf("part %d: Was: %v but should be: %v", i, p.Styles, exp)
+		}
+		joined += body + "\n"
+	}
+	if joined != output {
+		t.Fatalf("Was: %q but should be: %q", joined, output)
+	}
+
+	// styles crossing the boundary are clipped
+	if was := clipStyles([]TextStyle{{2, 6, StyleBold}, {9, 1, StyleItalic}}, 5, 9, 3); !reflect.DeepEqual(was, []TextStyle{{3, 3, StyleBold}}) {
+		t.Fatalf("Was: %v", was)
+	}
+}
@@ -0,0 +1,266 @@
+package signalserver
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"signalbot_go/modules/contacts"
+	"signalbot_go/modules/group"
+	"signalbot_go/signalcli"
+	signalconsole "signalbot_go/signalcli/drivers/console"
+	signaldbus "signalbot_go/signalcli/drivers/dbus"
+	signaljsonrpc "signalbot_go/signalcli/drivers/jsonrpc"
+	signalrecord "signalbot_go/signalcli/drivers/record"
+	signalrest "signalbot_go/signalcli/drivers/rest"
+	"strings"
+)
+
+// an account the server runs with
+type account struct {
+	AccountCfg
+	acc *signalcli.Account
+	// handlers enabled for this account, nil enables all
+	enabled map[string]bool
+	// modules which act on this account (e.g. the group management)
+	modules map[string]Handler
+}
+
+// whether the handler is enabled for this account
+func (a *account) handles(name string) bool {
+	return a.enabled == nil || a.enabled[name]
+}
+
+// the name of the contact with the number (the number itself if unknown)
+func (a *account) contactName(number string) string {
+	if a == nil || a.acc == nil {
+		return number
+	}
+	return a.acc.ContactName(number)
+}
+
+// the configuration of the handlers enabled for this account
+func (a *account) handlerCfgs(all map[string]HandlerCfg) map[string]HandlerCfg {
+	ret := make(map[string]HandlerCfg, len(all))
+	for name, h := range all {
+		if a.handles(name) {
+			ret[name] = h
+		}
+	}
+	return ret
+}
+
+// the account with the number. Returns the first account if nr is empty and
+// nil if there is no such account.
+func (s *SignalServer) account(nr string) *account {
+	if len(s.accounts) == 0 {
+		return nil
+	}
+	if nr == "" {
+		return s.accounts[0]
+	}
+	for _, a := range s.accounts {
+		if a.acc != nil && a.acc.SelfNr == nr {
+			return a
+		}
+	}
+	return nil
+}
+
+// the account with the number, the first account if there is no such
+// account (e.g. for messages which were not received from signal)
+func (s *SignalServer) accountOrDefault(nr string) *account {
+	if a := s.account(nr); a != nil {
+		return a
+	}
+	return s.account("")
+}
+
+// create the driver for the account. `multi` is set if the signal-cli
+// daemon serves several accounts, received attachments are stored in
+// `attachmentDir` if the driver has to download them.
+func newDriver(log *slog.Logger, cfg AccountCfg, multi bool, attachmentDir string) (signalcli.Driver, error) {
+	switch cfg.UsedDriver {
+	case DriverDbus:
+		return signaldbus.NewSignalDbusDriver(log, cfg.Dbus)
+	case DriverJsonRpc:
+		d, err := signaljsonrpc.NewSignalJsonRpcDriver(log, cfg.UnixSocket, cfg.SelfNr)
+		if err != nil {
+			return nil, err
+		}
+		d.MultiAccount = multi
+		if cfg.SignalCliAttachments != "" {
+			d.AttachmentDir = cfg.SignalCliAttachments
+		}
+		return d, nil
+	case DriverConsole:
+		d, err := signalconsole.NewSignalJsonRpcDriver(log, cfg.UnixSocket, cfg.SelfNr)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Script == "" {
+			return d, nil
+		}
+		script, err := os.ReadFile(cfg.Script)
+		if err != nil {
+			return nil, err
+		}
+		d.SetScript(bytes.NewReader(script))
+		return d, nil
+	case DriverRest:
+		return signalrest.NewSignalRestDriver(log, cfg.RestUrl, cfg.SelfNr, attachmentDir)
+	case DriverReplay:
+		return signalrecord.NewReplayer(log, cfg.Replay)
+	}
+	return nil, fmt.Errorf("Invalid driver set")
+}
+
+// create the driver for the account and wrap it with a recorder if
+// configured
+func newRecordingDriver(log *slog.Logger, cfg AccountCfg, multi bool, attachmentDir string) (signalcli.Driver, error) {
+	d, err := newDriver(log, cfg, multi, attachmentDir)
+	if err != nil || cfg.Record == "" {
+		return d, err
+	}
+	r, err := signalrecord.NewRecorder(log, d, cfg.Record)
+	if err != nil {
+		d.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// name of the state store of the i-th account. The first account uses the
+// name from the time only one account was supported.
+func accountStateName(i int, cfg AccountCfg) string {
+	if i == 0 {
+		return "account"
+	}
+	return "account_" + strings.TrimPrefix(cfg.SelfNr, "+")
+}
+
+// set up the accounts and the modules which act on them
+func (s *SignalServer) initAccounts(log *slog.Logger, cfgDir string, dataDir string) error {
+	cfgs := s.AccountCfgs()
+	sockets := make(map[string]int)
+	for _, c := range cfgs {
+		if c.UsedDriver == DriverJsonRpc {
+			sockets[c.UnixSocket]++
+		}
+	}
+
+	for i, c := range cfgs {
+		aLog := log
+		if len(cfgs) > 1 {
+			aLog = log.With("account", c.SelfNr)
+		}
+		attachmentDir := filepath.Join(dataDir, "attachments", strings.TrimPrefix(c.SelfNr, "+"))
+		driver, err := newRecordingDriver(aLog.With(), c, sockets[c.UnixSocket] > 1, attachmentDir)
+		if err != nil {
+			return err
+		}
+		st, err := s.state.Sub(accountStateName(i, c))
+		if err != nil {
+			return err
+		}
+		a := &account{
+			AccountCfg: c,
+			modules:    make(map[string]Handler),
+		}
+		a.acc, err = signalcli.NewAccount(aLog.With(), driver, s.Outbox, s.Delivery, st)
+		if err != nil {
+			return err
+		}
+		if len(c.Handlers) > 0 {
+			a.enabled = make(map[string]bool, len(c.Handlers))
+			for _, h := range c.Handlers {
+				a.enabled[h] = true
+			}
+		}
+		s.accounts = append(s.accounts, a)
+
+		// register functions for handling the messages
+		// run the handler in a new goroutine so that new messages can be received
+		if err := a.acc.AddMessageHandlerFunc(func(m *signalcli.Message) { go s.handle(m) }); err != nil {
+			return err
+		}
+		if err := a.acc.AddSyncMessageHandlerFunc(func(m *signalcli.SyncMessage) { go s.handle(&m.Message) }); err != nil {
+			return err
+		}
+		if s.DeliveryAlert != "" {
+			if err := a.acc.AddUndeliveredHandlerFunc(func(d *signalcli.Undelivered) { go s.alertUndelivered(a, d) }); err != nil {
+				return err
+			}
+		}
+
+		if err := s.initAccountModules(aLog, cfgDir, a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// create the modules which act on the account
+func (s *SignalServer) initAccountModules(log *slog.Logger, cfgDir string, a *account) error {
+	var err error
+	if _, ok := s.Handlers["help"]; ok && a.handles("help") {
+		if a.modules["help"], err = NewHelp(log.With("module", "help"), filepath.Join(cfgDir, "help"), a.handlerCfgs(s.Handlers), a.acc.SelfNr, a.contactName); err != nil {
+			return fmt.Errorf("'help' module: %v", err)
+		}
+	}
+	if h, ok := s.Handlers["more"]; ok && a.handles("more") {
+		if a.modules["more"], err = NewMore(log.With("module", "more"), filepath.Join(cfgDir, "more"), a.acc); err != nil {
+			return fmt.Errorf("'more' module: %v", err)
+		}
+		if len(h.Prefixes) > 0 {
+			a.acc.EnablePaging(h.Prefixes[0])
+		}
+	}
+	if _, ok := s.Handlers["contacts"]; ok && a.handles("contacts") {
+		if a.modules["contacts"], err = contacts.NewContacts(log.With("module", "contacts"), filepath.Join(cfgDir, "contacts"), a.acc); err != nil {
+			return fmt.Errorf("'contacts' module: %v", err)
+		}
+	}
+	if _, ok := s.Handlers["profile"]; ok && a.handles("profile") {
+		if a.modules["profile"], err = contacts.NewProfile(log.With("module", "profile"), filepath.Join(cfgDir, "profile"), a.acc); err != nil {
+			return fmt.Errorf("'profile' module: %v", err)
+		}
+	}
+	if _, ok := s.Handlers["group"]; ok && a.handles("group") {
+		if a.modules["group"], err = group.NewGroup(log.With("module", "group"), filepath.Join(cfgDir, "group"), a.acc); err != nil {
+			return fmt.Errorf("'group' module: %v", err)
+		}
+	}
+	return nil
+}
+
+// the modules shared by all accounts and the ones acting on a single account
+func (s *SignalServer) allModules() []Handler {
+	ret := make([]Handler, 0, len(s.modules))
+	for _, mod := range s.modules {
+		ret = append(ret, mod)
+	}
+	for _, a := range s.accounts {
+		for _, mod := range a.modules {
+			ret = append(ret, mod)
+		}
+	}
+	return ret
+}
@@ -0,0 +1,179 @@
+package signalserver
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"io"
+	"log/slog"
+	"signalbot_go/internal/signalsender"
+	"signalbot_go/signalcli"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// driver of the account with the number
+type numberDriver struct {
+	stateDriver
+	nr string
+}
+
+func (d *numberDriver) GetSelfNumber() (string, error) { return d.nr, nil }
+
+// handler which records the sender it was called with
+type senderHandler struct {
+	senders chan signalsender.SignalSender
+}
+
+func (h *senderHandler) Handle(m *signalcli.Message, signal signalsender.SignalSender, virtRcv func(*signalcli.Message)) {
+	h.senders <- signal
+}
+func (h *senderHandler) Start(virtRcv func(*signalcli.Message)) error { return nil }
+func (h *senderHandler) Close(virtRcv func(*signalcli.Message))       {}
+
+func TestAccounts(t *testing.T) {
+	var cfg SignalServerCfg
+	err := yaml.Unmarshal([]byte(`
+driver: jsonrpc
+unixSocket: /run/signal-cli/socket
+stateBackend: yaml
+accounts:
+  - selfNr: "+49a"
+  - selfNr: "+49b"
+    handlers: [echo]
+    access:
+      default: Block
+      children:
+        "+49123":
+          default: Allow
+handlers:
+  echo:
+    prefixes: [echo]
+    access:
+      default: Allow
+  other:
+    prefixes: [other]
+    access:
+      default: Allow
+`), &cfg)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	cfgs := cfg.AccountCfgs()
+	if len(cfgs) != 2 || cfgs[1].UsedDriver != DriverJsonRpc || cfgs[1].UnixSocket != "/run/signal-cli/socket" {
+		t.Fatalf("Defaults were not applied: %+v", cfgs)
+	}
+
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	s := SignalServer{
+		SignalServerCfg: cfg,
+		log:             log,
+		modules:         map[string]Handler{},
+	}
+	echo := &senderHandler{senders: make(chan signalsender.SignalSender, 1)}
+	other := &senderHandler{senders: make(chan signalsender.SignalSender, 1)}
+	s.modules["echo"] = echo
+	s.modules["other"] = other
+	for _, c := range cfgs {
+		acc, err := signalcli.NewAccount(log, &numberDriver{nr: c.SelfNr}, signalcli.DefaultQueueCfg, signalcli.DefaultDeliveryCfg, nil)
+		if err != nil {
+			t.Fatalf("Err: %v", err)
+		}
+		a := &account{AccountCfg: c, acc: acc}
+		if len(c.Handlers) > 0 {
+			a.enabled = map[string]bool{}
+			for _, h := range c.Handlers {
+				a.enabled[h] = true
+			}
+		}
+		s.accounts = append(s.accounts, a)
+	}
+	s.initHandlers()
+
+	for _, tc := range []struct {
+		account string
+		sender  string
+		msg     string
+		h       *senderHandler
+		// index of the account which should reply, -1 if not handled
+		exp int
+	}{
+		{account: "+49a", sender: "+49123", msg: "echo", h: echo, exp: 0},
+		{account: "+49b", sender: "+49123", msg: "echo", h: echo, exp: 1},
+		{account: "", sender: "+49123", msg: "echo", h: echo, exp: 0},
+		// not enabled for +49b
+		{account: "+49b", sender: "+49123", msg: "other", h: other, exp: -1},
+		{account: "+49a", sender: "+49123", msg: "other", h: other, exp: 0},
+		// blocked by the access control of +49b
+		{account: "+49b", sender: "+49456", msg: "echo", h: echo, exp: -1},
+		{account: "+49a", sender: "+49456", msg: "echo", h: echo, exp: 0},
+	} {
+		s.handle(&signalcli.Message{Sender: tc.sender, Chat: tc.sender, Account: tc.account, Message: tc.msg})
+		select {
+		case sender := <-tc.h.senders:
+			if tc.exp < 0 {
+				t.Fatalf("%+v: should not have been handled", tc)
+			}
+			if sender != s.accounts[tc.exp].acc {
+				t.Fatalf("%+v: replied from the wrong account", tc)
+			}
+		default:
+			if tc.exp >= 0 {
+				t.Fatalf("%+v: should have been handled", tc)
+			}
+		}
+	}
+
+	// the receiver on the send port selects the account
+	for receiver, exp := range map[string]int{"+49b": 1, "+49a": 0, "": 0, "+49unknown": 0, "anything": 0} {
+		if a := s.sendAccount(receiver); a != s.accounts[exp] {
+			t.Fatalf("%q: sent from the wrong account", receiver)
+		}
+	}
+	if a := (&SignalServer{log: log}).sendAccount("+49a"); a != nil {
+		t.Fatalf("Was: %v but should be nil", a)
+	}
+}
+
+func TestAccountsCfg(t *testing.T) {
+	for _, tc := range []struct {
+		name string
+		cfg  string
+		ok   bool
+	}{
+		{"single", "driver: jsonrpc\nunixSocket: s\nselfNr: \"+49a\"\nstateBackend: yaml", true},
+		{"duplicate number", "driver: jsonrpc\nunixSocket: s\nstateBackend: yaml\naccounts: [{selfNr: \"+49a\"}, {selfNr: \"+49a\"}]", false},
+		{"unknown handler", "driver: jsonrpc\nunixSocket: s\nstateBackend: yaml\naccounts: [{selfNr: \"+49a\", handlers: [nope]}]", false},
+		{"two dbus", "driver: dbus\ndbus: systemBus\nstateBackend: yaml\naccounts: [{selfNr: \"+49a\"}, {selfNr: \"+49b\"}]", false},
+		{"rest", "driver: rest\nrestUrl: http://localhost:8080\nselfNr: \"+49a\"\nstateBackend: yaml", true},
+		{"rest without url", "driver: rest\nselfNr: \"+49a\"\nstateBackend: yaml", false},
+		{"replay", "driver: replay\nreplay: testdata/echo.jsonl\nstateBackend: yaml", true},
+		{"replay without recording", "driver: replay\nstateBackend: yaml", false},
+		{"dbus and jsonrpc", "driver: dbus\ndbus: systemBus\nstateBackend: yaml\naccounts: [{selfNr: \"+49a\"}, {selfNr: \"+49b\", driver: jsonrpc, unixSocket: s}]", true},
+	} {
+		var cfg SignalServerCfg
+		if err := yaml.Unmarshal([]byte(tc.cfg), &cfg); err != nil {
+			t.Fatalf("%s: Err: %v", tc.name, err)
+		}
+		if err := cfg.Validate(); (err == nil) != tc.ok {
+			t.Fatalf("%s: Was: %v but should be ok: %v", tc.name, err, tc.ok)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package signalserver
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"maps"
+	"slices"
+
+	attachments "signalbot_go/internal/attachment"
+	"signalbot_go/signalcli"
+)
+
+// the module which handles `name` for the account (nil uses the shared
+// modules only)
+func (s *SignalServer) module(a *account, name string) (Handler, bool) {
+	if a != nil && a.modules[name] != nil {
+		return a.modules[name], true
+	}
+	mod, ok := s.modules[name]
+	return mod, ok
+}
+
+// resolve the attachments of `m`, unreadable ones are logged and left out
+func (s *SignalServer) resolveAttachments(m *signalcli.Message) []attachments.Received {
+	received, err := attachments.Resolve(m)
+	if err != nil {
+		s.log.Warn("Error resolving attachments", "err", err)
+	}
+	return received
+}
+
+// route a message which only consists of attachments to the first handler
+// (by name) which accepts one of them by setting the message to its prefix.
+// Returns false if no handler accepts the attachments.
+func (s *SignalServer) routeAttachments(m *signalcli.Message) bool {
+	received := s.resolveAttachments(m)
+	a := s.accountOrDefault(m.Account)
+	for _, name := range slices.Sorted(maps.Keys(s.Handlers)) {
+		cfg := s.Handlers[name]
+		if len(cfg.Prefixes) == 0 || (a != nil && !a.handles(name)) {
+			continue
+		}
+		mod, _ := s.module(a, name)
+		h, ok := mod.(AttachmentHandler)
+		if !ok {
+			continue
+		}
+		for _, r := range received {
+			if r.Matches(h.Accepts()) {
+				m.Message = cfg.Prefixes[0]
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// only keep the attachments of `m` which `h` accepts. The attachments are
+// replaced by readable files with completed metadata.
+func (s *SignalServer) filterAttachments(m *signalcli.Message, h AttachmentHandler) {
+	received := s.resolveAttachments(m)
+	m.Attachments, m.AttachmentMeta = nil, nil
+	for _, r := range received {
+		if r.Matches(h.Accepts()) {
+			m.Attachments = append(m.Attachments, r.Path)
+			m.AttachmentMeta = append(m.AttachmentMeta, r.Attachment)
+		}
+	}
+}
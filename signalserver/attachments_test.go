@@ -0,0 +1,112 @@
+package signalserver
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"signalbot_go/internal/signalsender"
+	"signalbot_go/signalcli"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// handler which accepts calendar files and records the messages
+type icsHandler struct {
+	msgs chan *signalcli.Message
+}
+
+func (h *icsHandler) Handle(m *signalcli.Message, signal signalsender.SignalSender, virtRcv func(*signalcli.Message)) {
+	h.msgs <- m
+}
+func (h *icsHandler) Start(virtRcv func(*signalcli.Message)) error { return nil }
+func (h *icsHandler) Close(virtRcv func(*signalcli.Message))       {}
+func (h *icsHandler) Accepts() []string                            { return []string{"text/calendar"} }
+
+func TestAttachments(t *testing.T) {
+	dir := t.TempDir()
+	ics := filepath.Join(dir, "event.ics")
+	png := filepath.Join(dir, "photo.png")
+	for _, f := range []string{ics, png} {
+		if err := os.WriteFile(f, []byte("content"), 0o600); err != nil {
+			t.Fatalf("Err: %v", err)
+		}
+	}
+
+	var cfg SignalServerCfg
+	err := yaml.Unmarshal([]byte(`
+handlers:
+  calendar:
+    prefixes: [cal]
+    access:
+      default: Allow
+`), &cfg)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	h := &icsHandler{msgs: make(chan *signalcli.Message, 1)}
+	s := SignalServer{
+		SignalServerCfg: cfg,
+		log:             slog.New(slog.NewTextHandler(io.Discard, nil)),
+		modules:         map[string]Handler{"calendar": h},
+	}
+	s.initHandlers()
+
+	for _, tc := range []struct {
+		name string
+		msg  string
+		atts []string
+		// attachments passed to the handler, nil if not handled
+		exp []string
+	}{
+		{"routed by type", "", []string{png, ics}, []string{ics}},
+		{"not accepted", "", []string{png}, nil},
+		{"with prefix", "cal import", []string{png, ics}, []string{ics}},
+		{"prefix without accepted attachment", "cal", []string{png}, []string{}},
+	} {
+		m := &signalcli.Message{Sender: "+49123", Chat: "+49123", Message: tc.msg, Attachments: tc.atts}
+		for _, a := range tc.atts {
+			// as sent by the other client
+			contentType := "image/png"
+			if a == ics {
+				contentType = "text/calendar"
+			}
+			m.AttachmentMeta = append(m.AttachmentMeta, signalcli.Attachment{ContentType: contentType})
+		}
+		s.handle(m)
+		select {
+		case m := <-h.msgs:
+			if tc.exp == nil {
+				t.Fatalf("%s: should not have been handled", tc.name)
+			}
+			if len(m.Attachments) != len(tc.exp) || (len(tc.exp) > 0 && !reflect.DeepEqual(m.Attachments, tc.exp)) {
+				t.Fatalf("%s: Was: %v but should be: %v", tc.name, m.Attachments, tc.exp)
+			}
+			if len(m.AttachmentMeta) != len(m.Attachments) {
+				t.Fatalf("%s: metadata is missing: %+v", tc.name, m.AttachmentMeta)
+			}
+		default:
+			if tc.exp != nil {
+				t.Fatalf("%s: should have been handled", tc.name)
+			}
+		}
+	}
+}
@@ -23,19 +23,50 @@ import (
 	"strings"
 )
 
-// can handle a signal-message
+// can handle a signal-message. Handle is called concurrently (each message
+// is handled in its own goroutine), so implementations have to guard their
+// state, unless the handler is configured to be serialized (see
+// `HandlerCfg.Serialize`).
 type Handler interface {
 	Handle(m *signalcli.Message, signal signalsender.SignalSender, virtRcv func(*signalcli.Message))
 	Start(virtRcv func(*signalcli.Message)) error
 	Close(virtRcv func(*signalcli.Message))
 }
 
+// implemented by handlers which process received attachments. Only the
+// attachments with one of the accepted MIME types (e.g. "text/calendar" or
+// "image/*") are passed to the handler. Messages without text are routed to
+// the first handler (by name) which accepts one of their attachments.
+type AttachmentHandler interface {
+	Handler
+	Accepts() []string
+}
+
+// implemented by handlers which handle shared locations. A line which starts
+// with no prefix but is a location share (e.g. a maps link, see IsLocation)
+// is passed to the first handler (by name) which recognizes it.
+type LocationHandler interface {
+	Handler
+	IsLocation(line string) bool
+}
+
+// implemented by handlers which send commands to themselves via virtRcv
+// (e.g. from a background job). The first configured prefix is passed before
+// the handler is started.
+type PrefixHandler interface {
+	Handler
+	SetPrefix(prefix string)
+}
+
 // config for a handler. Can be parsed from yaml
-// TODO note on concurrency
 type HandlerCfg struct {
 	Prefixes []string      `yaml:"prefixes"`
 	Help     string        `yaml:"help"`
 	Access   Accesscontrol `yaml:"access"`
+	// only handle one message at a time with this handler
+	Serialize bool `yaml:"serialize"`
+	// show the typing indicator until the handler sends its first reply
+	Typing bool `yaml:"typing"`
 }
 
 // validate the stored data
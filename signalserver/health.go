@@ -0,0 +1,132 @@
+package signalserver
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"signalbot_go/signalcli"
+	"strconv"
+	"time"
+)
+
+// reported on the health endpoint
+type healthStatus struct {
+	State      signalcli.ConnectionState `json:"state"`
+	QueueDepth int                       `json:"queueDepth"`
+	// status of each account if there are multiple ones
+	Accounts map[string]healthStatus `json:"accounts,omitempty"`
+}
+
+// answers with the connection state to signal-cli and the amount of queued
+// outbound messages. The status code is 200 only if all accounts are
+// connected.
+func (s *SignalServer) serveHealth(w http.ResponseWriter, r *http.Request) {
+	status := healthStatus{State: signalcli.StateConnected}
+	for _, a := range s.accounts {
+		st := healthStatus{
+			State:      a.acc.ConnectionState(),
+			QueueDepth: a.acc.QueueDepth(),
+		}
+		if status.State == signalcli.StateConnected {
+			status.State = st.State
+		}
+		status.QueueDepth += st.QueueDepth
+		if len(s.accounts) > 1 {
+			if status.Accounts == nil {
+				status.Accounts = make(map[string]healthStatus, len(s.accounts))
+			}
+			status.Accounts[a.acc.SelfNr] = st
+		}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if status.State != signalcli.StateConnected {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(status); err != nil {
+		s.log.Error("Health: Error writing response", "error", err)
+	}
+}
+
+// answers with the delivery status of the message sent with the timestamp
+// given in the path (by any of the accounts)
+func (s *SignalServer) serveDelivery(w http.ResponseWriter, r *http.Request) {
+	ts, err := strconv.ParseInt(r.PathValue("ts"), 10, 64)
+	if err != nil {
+		http.Error(w, "invalid timestamp", http.StatusBadRequest)
+		return
+	}
+	var (
+		d  signalcli.Delivery
+		ok bool
+	)
+	for _, a := range s.accounts {
+		if d, ok = a.acc.DeliveryStatus(ts); ok {
+			break
+		}
+	}
+	if !ok {
+		http.Error(w, "unknown timestamp", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(d); err != nil {
+		s.log.Error("Health: Error writing response", "error", err)
+	}
+}
+
+// routes of the health endpoint
+func (s *SignalServer) healthMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /health", s.serveHealth)
+	mux.HandleFunc("GET /delivery/{ts}", s.serveDelivery)
+	return mux
+}
+
+// serve the health endpoint on localhost until Close is called
+func (s *SignalServer) startPortHealth() error {
+	listen, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", s.PortHealth))
+	if err != nil {
+		return err
+	}
+	s.health = &http.Server{
+		Handler:           s.healthMux(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+	go func() {
+		if err := s.health.Serve(listen); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.log.Error("Health: Error serving", "error", err)
+		}
+	}()
+	return nil
+}
+
+// stop the health endpoint (if started)
+func (s *SignalServer) stopPortHealth() {
+	if s.health == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if err := s.health.Shutdown(ctx); err != nil {
+		s.log.Error("Health: Error shutting down", "error", err)
+	}
+}
@@ -0,0 +1,129 @@
+package signalserver
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"signalbot_go/signalcli"
+	"testing"
+)
+
+// driver which only reports a connection state
+type stateDriver struct {
+	signalcli.StateTracker
+	signalcli.NoContacts
+}
+
+func (d *stateDriver) SendMessage(message string, attachments []string, recipient string, notifySelf bool) (int64, error) {
+	if d.State() != signalcli.StateConnected {
+		return 0, signalcli.ErrNotConnected
+	}
+	return 42, nil
+}
+func (d *stateDriver) SendGroupMessage(message string, attachments []string, groupId []byte) (int64, error) {
+	return 0, signalcli.ErrNotConnected
+}
+func (d *stateDriver) GetGroupName(groupId []byte) (string, error)         { return "", nil }
+func (d *stateDriver) GetSelfNumber() (string, error)                      { return "+49", nil }
+func (d *stateDriver) SetInterface(inter signalcli.InterDriverToAcc) error { return nil }
+func (d *stateDriver) SendTypingIndicator(recipient string, groupId []byte, stop bool) error {
+	return nil
+}
+func (d *stateDriver) Start() {}
+func (d *stateDriver) Close() {}
+
+func TestHealth(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	d := &stateDriver{}
+	acc, err := signalcli.NewAccount(log, d, signalcli.DefaultQueueCfg, signalcli.DefaultDeliveryCfg, nil)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	s := SignalServer{accounts: []*account{{acc: acc}}, log: log}
+
+	for _, tc := range []struct {
+		state signalcli.ConnectionState
+		code  int
+	}{
+		{signalcli.StateConnected, http.StatusOK},
+		{signalcli.StateDisconnected, http.StatusServiceUnavailable},
+	} {
+		d.SetState(tc.state)
+		rec := httptest.NewRecorder()
+		s.serveHealth(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+		if rec.Code != tc.code {
+			t.Fatalf("Was: %d but should be %d", rec.Code, tc.code)
+		}
+		var status healthStatus
+		if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+			t.Fatalf("Err: %v", err)
+		}
+		if status.State != tc.state || status.QueueDepth != 0 {
+			t.Fatalf("Was: %+v but should be %v with an empty queue", status, tc.state)
+		}
+	}
+}
+
+func TestDelivery(t *testing.T) {
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	d := &stateDriver{}
+	d.SetState(signalcli.StateConnected)
+	acc, err := signalcli.NewAccount(log, d, signalcli.DefaultQueueCfg, signalcli.DefaultDeliveryCfg, nil)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	acc.ListenForSignals()
+	defer acc.Close()
+	s := SignalServer{accounts: []*account{{acc: acc}}, log: log}
+	mux := s.healthMux()
+
+	if _, err := acc.SendGeneric("hi", nil, "+49123", nil, false); err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	for _, tc := range []struct {
+		path string
+		code int
+	}{
+		{"/delivery/42", http.StatusOK},
+		{"/delivery/43", http.StatusNotFound},
+		{"/delivery/abc", http.StatusBadRequest},
+	} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		if rec.Code != tc.code {
+			t.Fatalf("%s: Was: %d but should be %d", tc.path, rec.Code, tc.code)
+		}
+		if tc.code != http.StatusOK {
+			continue
+		}
+		var status struct {
+			Chat   string
+			Status string
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&status); err != nil {
+			t.Fatalf("Err: %v", err)
+		}
+		if status.Chat != "+49123" || status.Status != "sent" {
+			t.Fatalf("Was: %+v but should be sent to %v", status, "+49123")
+		}
+	}
+}
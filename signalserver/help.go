@@ -30,14 +30,17 @@ type Help struct {
 	ConfigDir string                `yaml:"-"`
 	Handlers  map[string]HandlerCfg `yaml:"-"`
 	self      string                `yaml:"-"`
+	// resolves phone numbers to contact names
+	names func(string) string `yaml:"-"`
 }
 
-func NewHelp(log *slog.Logger, cfgDir string, handlers map[string]HandlerCfg, self string) (*Help, error) {
+func NewHelp(log *slog.Logger, cfgDir string, handlers map[string]HandlerCfg, self string, names func(string) string) (*Help, error) {
 	r := Help{
 		log:       log,
 		ConfigDir: cfgDir,
 		Handlers:  handlers,
 		self:      self,
+		names:     names,
 	}
 
 	// f, err := os.Open(filepath.Join(r.ConfigDir, "help.yaml"))
@@ -74,6 +77,7 @@ func (r *Help) sendError(m *signalcli.Message, signal signalsender.SignalSender,
 func (r *Help) Handle(m *signalcli.Message, signal signalsender.SignalSender, virtRcv func(*signalcli.Message)) {
 	var err error
 	builder := strings.Builder{}
+	builder.WriteString(fmt.Sprintf("Commands available to %s:\n", r.names(m.Sender)))
 	for _, handler := range r.Handlers {
 		if err := handler.Access.Check(m.Sender, m.Chat); err != nil {
 			continue
@@ -0,0 +1,40 @@
+package signalserver
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"maps"
+	"slices"
+
+	"signalbot_go/signalcli"
+)
+
+// the handler (by name) which handles the line of `m` as a shared location.
+// Returns false if no handler recognizes it.
+func (s *SignalServer) routeLocation(m *signalcli.Message) (string, bool) {
+	a := s.accountOrDefault(m.Account)
+	for _, name := range slices.Sorted(maps.Keys(s.Handlers)) {
+		if len(s.Handlers[name].Prefixes) == 0 || (a != nil && !a.handles(name)) {
+			continue
+		}
+		mod, _ := s.module(a, name)
+		if h, ok := mod.(LocationHandler); ok && h.IsLocation(m.Message) {
+			return name, true
+		}
+	}
+	return "", false
+}
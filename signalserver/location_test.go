@@ -0,0 +1,91 @@
+package signalserver
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"io"
+	"log/slog"
+	"signalbot_go/internal/signalsender"
+	"signalbot_go/signalcli"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+// handler which recognizes links as locations and records the messages
+type locationHandler struct {
+	msgs chan string
+}
+
+func (h *locationHandler) Handle(m *signalcli.Message, signal signalsender.SignalSender, virtRcv func(*signalcli.Message)) {
+	h.msgs <- m.Message
+}
+func (h *locationHandler) Start(virtRcv func(*signalcli.Message)) error { return nil }
+func (h *locationHandler) Close(virtRcv func(*signalcli.Message))       {}
+func (h *locationHandler) IsLocation(line string) bool {
+	return strings.HasPrefix(line, "https://maps.")
+}
+
+func TestLocations(t *testing.T) {
+	var cfg SignalServerCfg
+	err := yaml.Unmarshal([]byte(`
+handlers:
+  weather:
+    prefixes: [wx]
+    access:
+      default: Allow
+`), &cfg)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	h := &locationHandler{msgs: make(chan string, 2)}
+	s := SignalServer{
+		SignalServerCfg: cfg,
+		log:             slog.New(slog.NewTextHandler(io.Discard, nil)),
+		modules:         map[string]Handler{"weather": h},
+	}
+	s.initHandlers()
+
+	link := "https://maps.google.com/maps?q=48.14%2C11.58"
+	for _, tc := range []struct {
+		name string
+		msg  string
+		// message passed to the handler, empty if not handled
+		exp string
+	}{
+		{"shared location", link, link},
+		{"with address", "Marienplatz 1\n" + link, link},
+		{"with prefix", "wx " + link, link},
+		{"no location", "hello", ""},
+	} {
+		s.handle(&signalcli.Message{Sender: "+49123", Chat: "+49123", Message: tc.msg})
+		select {
+		case msg := <-h.msgs:
+			if msg != tc.exp {
+				t.Fatalf("%s: Was: %q but should be: %q", tc.name, msg, tc.exp)
+			}
+		default:
+			if tc.exp != "" {
+				t.Fatalf("%s: should have been handled", tc.name)
+			}
+		}
+		if len(h.msgs) > 0 {
+			t.Fatalf("%s: handled more than once", tc.name)
+		}
+	}
+}
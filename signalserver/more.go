@@ -0,0 +1,69 @@
+package signalserver
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"signalbot_go/internal/signalsender"
+	"signalbot_go/signalcli"
+)
+
+// sends the next parts of long messages
+type pager interface {
+	More(m *signalcli.Message) (int64, error)
+}
+
+// sends the next parts of the last long reply to the chat
+type More struct {
+	log       *slog.Logger
+	ConfigDir string
+	pager     pager
+}
+
+func NewMore(log *slog.Logger, cfgDir string, p pager) (*More, error) {
+	return &More{
+		log:       log,
+		ConfigDir: cfgDir,
+		pager:     p,
+	}, nil
+}
+
+func (r *More) Handle(m *signalcli.Message, signal signalsender.SignalSender, virtRcv func(*signalcli.Message)) {
+	_, err := r.pager.More(m)
+	if errors.Is(err, signalcli.ErrNoMore) {
+		if _, err := signal.Respond(err.Error(), nil, m, false); err != nil {
+			r.log.Error(fmt.Sprintf("Error responding to %v", m))
+		}
+		return
+	}
+	if err != nil {
+		errMsg := fmt.Sprintf("Error: %v", err)
+		r.log.Error(errMsg)
+		if _, err := signal.Respond(errMsg, nil, m, false); err != nil {
+			r.log.Error(fmt.Sprintf("Error responding to %v", m))
+		}
+	}
+}
+
+func (r *More) Start(virtRcv func(*signalcli.Message)) error {
+	return nil
+}
+
+func (r *More) Close(virtRcv func(*signalcli.Message)) {
+}
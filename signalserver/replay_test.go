@@ -0,0 +1,96 @@
+package signalserver
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+import (
+	"io"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"signalbot_go/internal/signalsender"
+	"signalbot_go/signalcli"
+	signalrecord "signalbot_go/signalcli/drivers/record"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// handler which replies with the message
+type echoHandler struct{}
+
+func (h *echoHandler) Handle(m *signalcli.Message, signal signalsender.SignalSender, virtRcv func(*signalcli.Message)) {
+	signal.Respond(m.Message, nil, m, false)
+}
+func (h *echoHandler) Start(virtRcv func(*signalcli.Message)) error { return nil }
+func (h *echoHandler) Close(virtRcv func(*signalcli.Message))       {}
+
+// replay the conversations in testdata and check that the bot still gives
+// the recorded replies
+func TestReplay(t *testing.T) {
+	var cfg SignalServerCfg
+	err := yaml.Unmarshal([]byte(`
+handlers:
+  echo:
+    prefixes: [echo]
+    access:
+      default: Allow
+      children:
+        "+49666":
+          default: Block
+`), &cfg)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join("testdata", "*.jsonl"))
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+	log := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, file := range files {
+		t.Run(filepath.Base(file), func(t *testing.T) {
+			rp, err := signalrecord.NewReplayer(log, file)
+			if err != nil {
+				t.Fatalf("Err: %v", err)
+			}
+			acc, err := signalcli.NewAccount(log, rp, signalcli.DefaultQueueCfg, signalcli.DefaultDeliveryCfg, nil)
+			if err != nil {
+				t.Fatalf("Err: %v", err)
+			}
+			s := SignalServer{
+				SignalServerCfg: cfg,
+				log:             log,
+				modules:         map[string]Handler{"echo": &echoHandler{}},
+				accounts:        []*account{{acc: acc}},
+			}
+			s.initHandlers()
+			if err := acc.AddMessageHandlerFunc(func(m *signalcli.Message) { go s.handle(m) }); err != nil {
+				t.Fatalf("Err: %v", err)
+			}
+			acc.ListenForSignals()
+			defer acc.Close()
+
+			<-rp.Done()
+			if extra := rp.Unexpected(200 * time.Millisecond); extra != nil {
+				t.Fatalf("Unexpected replies: %+v", extra)
+			}
+			if !reflect.DeepEqual(rp.Sent(), rp.Expected()) {
+				t.Fatalf("Was: %+v but should be: %+v", rp.Sent(), rp.Expected())
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package signalserver
+
+// signalbot
+// Copyright (C) 2024  Lukas Heindl
+//
+// This program is free software: you can redistribute it and/or modify
+// it under the terms of the GNU General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// This program is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU General Public License for more details.
+//
+// You should have received a copy of the GNU General Public License
+// along with this program.  If not, see <http://www.gnu.org/licenses/>.
+
+
+import (
+	"io"
+	"log/slog"
+	"signalbot_go/internal/modtest"
+	"signalbot_go/internal/signalsender"
+	"signalbot_go/signalcli"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// handler which records how many messages it handled at the same time
+type countingHandler struct {
+	cur atomic.Int32
+	max atomic.Int32
+	wg  *sync.WaitGroup
+}
+
+func (h *countingHandler) Handle(m *signalcli.Message, signal signalsender.SignalSender, virtRcv func(*signalcli.Message)) {
+	defer h.wg.Done()
+	c := h.cur.Add(1)
+	defer h.cur.Add(-1)
+	for {
+		old := h.max.Load()
+		if c <= old || h.max.CompareAndSwap(old, c) {
+			break
+		}
+	}
+	time.Sleep(10 * time.Millisecond)
+}
+
+func (h *countingHandler) Start(virtRcv func(*signalcli.Message)) error { return nil }
+func (h *countingHandler) Close(virtRcv func(*signalcli.Message))       {}
+
+func TestSerialize(t *testing.T) {
+	var cfg SignalServerCfg
+	err := yaml.Unmarshal([]byte(`
+handlers:
+  serial:
+    prefixes: [serial]
+    access:
+      default: Allow
+    serialize: true
+  parallel:
+    prefixes: [parallel]
+    access:
+      default: Allow
+`), &cfg)
+	if err != nil {
+		t.Fatalf("Err: %v", err)
+	}
+
+	wg := &sync.WaitGroup{}
+	serial := &countingHandler{wg: wg}
+	parallel := &countingHandler{wg: wg}
+	s := SignalServer{
+		SignalServerCfg: cfg,
+		log:             slog.New(slog.NewTextHandler(io.Discard, nil)),
+		modules: map[string]Handler{
+			"serial":   serial,
+			"parallel": parallel,
+		},
+	}
+	s.initHandlers()
+
+	const n = 5
+	wg.Add(2 * n)
+	for _, m := range modtest.Messages(n, "+49123", "+49123", "serial") {
+		go s.handle(m)
+	}
+	for _, m := range modtest.Messages(n, "+49123", "+49123", "parallel") {
+		go s.handle(m)
+	}
+	wg.Wait()
+
+	if max := serial.max.Load(); max != 1 {
+		t.Fatalf("Serialized handler handled %v messages at once", max)
+	}
+	if max := parallel.max.Load(); max < 2 {
+		t.Fatalf("Parallel handler never handled messages at once")
+	}
+}
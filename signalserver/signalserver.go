@@ -24,8 +24,11 @@ import (
 	"context"
 	"fmt"
 	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"signalbot_go/internal/signalsender"
+	"signalbot_go/internal/state"
 	"signalbot_go/modules/buechertreff"
 	"signalbot_go/modules/cmd"
 	"signalbot_go/modules/fernsehserien"
@@ -38,10 +41,10 @@ import (
 	"signalbot_go/modules/tv"
 	"signalbot_go/modules/weather"
 	"signalbot_go/signalcli"
-	signalconsole "signalbot_go/signalcli/drivers/console"
 	signaldbus "signalbot_go/signalcli/drivers/dbus"
-	signaljsonrpc "signalbot_go/signalcli/drivers/jsonrpc"
 	"strings"
+	"sync"
+	"time"
 
 	"log/slog"
 
@@ -49,13 +52,17 @@ import (
 )
 
 // use NewSignalServer to create these structs
-// TODO note on concurrency
+// Each received message is handled in its own goroutine, handlers which are
+// configured to be serialized only handle one message at a time.
 type SignalServer struct {
 	SignalServerCfg
 	prefix2module     map[string]string
-	acc               *signalcli.Account
-	self              string
-	modules           map[string]Handler
+	accounts          []*account
+	modules           map[string]Handler // shared by all accounts
+	serialize         map[string]*sync.Mutex // locks of the serialized handlers
+	state             state.Store
+	audit             state.Store
+	health            *http.Server
 	log               *slog.Logger
 	sockMsgCancel     context.CancelFunc
 	sockVirtRcvCancel context.CancelFunc
@@ -68,6 +75,9 @@ func NewSignalServer(log *slog.Logger, cfgDir string, dataDir string) (*SignalSe
 	cfg := SignalServerCfg{
 		Dbus: signaldbus.SystemBus,
 		UsedDriver: DriverDbus,
+		StateBackend: state.BackendYaml,
+		Outbox: signalcli.DefaultQueueCfg,
+		Delivery: signalcli.DefaultDeliveryCfg,
 	}
 
 	f, err := os.Open(filepath.Join(cfgDir, "main.yaml"))
@@ -92,53 +102,36 @@ func NewSignalServer(log *slog.Logger, cfgDir string, dataDir string) (*SignalSe
 		SignalServerCfg: cfg,
 	}
 
-	var driver signalcli.Driver
-	switch cfg.UsedDriver {
-	case DriverDbus:
-		driver, err = signaldbus.NewSignalDbusDriver(log.With(), s.Dbus)
-		if err != nil {
-			return nil, err
-		}
-	case DriverJsonRpc:
-		driver, err = signaljsonrpc.NewSignalJsonRpcDriver(log.With(), cfg.UnixSocket, cfg.SelfNr)
-		if err != nil {
-			return nil, err
-		}
-	case DriverConsole:
-		driver, err = signalconsole.NewSignalJsonRpcDriver(log.With(), cfg.UnixSocket, cfg.SelfNr)
-		if err != nil {
-			return nil, err
-		}
-	}
-
-	s.acc, err = signalcli.NewAccount(log.With(), driver)
+	// runtime state of the modules is kept in the data directory, the
+	// configuration files are only read
+	s.state, err = state.Open(cfg.StateBackend, dataDir)
 	if err != nil {
 		return nil, err
 	}
-
-	// register functions for handling the messages
-	// run the handler in a new goroutine so that new messages can be received
-	if err := s.acc.AddMessageHandlerFunc(func(m *signalcli.Message) { go s.handle(m) }); err != nil {
+	s.audit, err = s.state.Sub("signalserver")
+	if err != nil {
 		return nil, err
 	}
-	if err := s.acc.AddSyncMessageHandlerFunc(func(m *signalcli.SyncMessage) { go s.handle(&m.Message) }); err != nil {
+	if err := s.initAccounts(log, cfgDir, dataDir); err != nil {
 		return nil, err
 	}
 
-	// todoMod register modules
-	if _, ok := cfg.Handlers["help"]; ok {
-		if s.modules["help"], err = NewHelp(log.With("module", "help"), filepath.Join(cfgDir, "help"), s.Handlers, s.self); err != nil {
-			return nil, fmt.Errorf("'help' module: %v", err)
-		}
+	modState := func(name string) (state.Store, error) {
+		return s.state.Sub(name)
 	}
 
+	// todoMod register modules
 	if _, ok := cfg.Handlers["cmd"]; ok {
 		if s.modules["cmd"], err = cmd.NewCmd(log.With("module", "cmd"), filepath.Join(cfgDir, "cmd")); err != nil {
 			return nil, fmt.Errorf("'cmd' module: %v", err)
 		}
 	}
 	if _, ok := cfg.Handlers["periodic"]; ok {
-		if s.modules["periodic"], err = periodic.NewPeriodic(log.With("module", "periodic"), filepath.Join(cfgDir, "periodic")); err != nil {
+		sub, err := modState("periodic")
+		if err != nil {
+			return nil, fmt.Errorf("'periodic' module: %v", err)
+		}
+		if s.modules["periodic"], err = periodic.NewPeriodic(log.With("module", "periodic"), filepath.Join(cfgDir, "periodic"), sub); err != nil {
 			return nil, fmt.Errorf("'periodic' module: %v", err)
 		}
 	}
@@ -148,7 +141,11 @@ func NewSignalServer(log *slog.Logger, cfgDir string, dataDir string) (*SignalSe
 		}
 	}
 	if _, ok := cfg.Handlers["weather"]; ok {
-		if s.modules["weather"], err = weather.NewWeather(log.With("module", "weather"), filepath.Join(cfgDir, "weather")); err != nil {
+		sub, err := modState("weather")
+		if err != nil {
+			return nil, fmt.Errorf("'weather' module: %v", err)
+		}
+		if s.modules["weather"], err = weather.NewWeather(log.With("module", "weather"), filepath.Join(cfgDir, "weather"), sub); err != nil {
 			return nil, fmt.Errorf("'weather' module: %v", err)
 		}
 	}
@@ -158,7 +155,11 @@ func NewSignalServer(log *slog.Logger, cfgDir string, dataDir string) (*SignalSe
 		}
 	}
 	if _, ok := cfg.Handlers["buechertreff"]; ok {
-		if s.modules["buechertreff"], err = buechertreff.NewBuechertreff(log.With("module", "buechertreff"), filepath.Join(cfgDir, "buechertreff")); err != nil {
+		sub, err := modState("buechertreff")
+		if err != nil {
+			return nil, fmt.Errorf("'buechertreff' module: %v", err)
+		}
+		if s.modules["buechertreff"], err = buechertreff.NewBuechertreff(log.With("module", "buechertreff"), filepath.Join(cfgDir, "buechertreff"), sub); err != nil {
 			return nil, fmt.Errorf("'buechertreff' module: %v", err)
 		}
 	}
@@ -168,34 +169,44 @@ func NewSignalServer(log *slog.Logger, cfgDir string, dataDir string) (*SignalSe
 		}
 	}
 	if _, ok := cfg.Handlers["fernsehserien"]; ok {
-		if s.modules["fernsehserien"], err = fernsehserien.NewFernsehserien(log.With("module", "fernsehserien"), filepath.Join(cfgDir, "fernsehserien")); err != nil {
+		sub, err := modState("fernsehserien")
+		if err != nil {
+			return nil, fmt.Errorf("'fernsehserien' module: %v", err)
+		}
+		if s.modules["fernsehserien"], err = fernsehserien.NewFernsehserien(log.With("module", "fernsehserien"), filepath.Join(cfgDir, "fernsehserien"), sub); err != nil {
 			return nil, fmt.Errorf("'fernsehserien' module: %v", err)
 		}
 	}
 	if _, ok := cfg.Handlers["news"]; ok {
-		if s.modules["news"], err = news.NewNews(log.With("module", "news"), filepath.Join(cfgDir, "news")); err != nil {
+		sub, err := modState("news")
+		if err != nil {
+			return nil, fmt.Errorf("'news' module: %v", err)
+		}
+		if s.modules["news"], err = news.NewNews(log.With("module", "news"), filepath.Join(cfgDir, "news"), sub); err != nil {
 			return nil, fmt.Errorf("'news' module: %v", err)
 		}
 	}
 	if _, ok := cfg.Handlers["hugendubel"]; ok {
-		if s.modules["hugendubel"], err = hugendubel.NewHugendubel(log.With("module", "hugendubel"), filepath.Join(cfgDir, "hugendubel")); err != nil {
+		sub, err := modState("hugendubel")
+		if err != nil {
+			return nil, fmt.Errorf("'hugendubel' module: %v", err)
+		}
+		if s.modules["hugendubel"], err = hugendubel.NewHugendubel(log.With("module", "hugendubel"), filepath.Join(cfgDir, "hugendubel"), sub); err != nil {
 			return nil, fmt.Errorf("'hugendubel' module: %v", err)
 		}
 	}
 	if _, ok := cfg.Handlers["spotify"]; ok {
-		if s.modules["spotify"], err = spotify.NewSpotify(log.With("module", "spotify"), filepath.Join(cfgDir, "spotify")); err != nil {
+		sub, err := modState("spotify")
+		if err != nil {
 			return nil, fmt.Errorf("'spotify' module: %v", err)
 		}
-	}
-
-	// generate prefix2Module
-	s.prefix2module = make(map[string]string, len(s.Handlers))
-	for name, v := range s.Handlers {
-		for _, p := range v.Prefixes {
-			s.prefix2module[p] = name
+		if s.modules["spotify"], err = spotify.NewSpotify(log.With("module", "spotify"), filepath.Join(cfgDir, "spotify"), sub); err != nil {
+			return nil, fmt.Errorf("'spotify' module: %v", err)
 		}
 	}
 
+	s.initHandlers()
+
 	if err := s.Validate(); err != nil {
 		return nil, err
 	}
@@ -203,6 +214,23 @@ func NewSignalServer(log *slog.Logger, cfgDir string, dataDir string) (*SignalSe
 	return &s, nil
 }
 
+// generate the lookup tables for the handlers from the configuration
+func (s *SignalServer) initHandlers() {
+	s.prefix2module = make(map[string]string, len(s.Handlers))
+	s.serialize = make(map[string]*sync.Mutex)
+	for name, v := range s.Handlers {
+		for _, p := range v.Prefixes {
+			s.prefix2module[p] = name
+		}
+		if v.Serialize {
+			s.serialize[name] = &sync.Mutex{}
+		}
+		if h, ok := s.modules[name].(PrefixHandler); ok && len(v.Prefixes) > 0 {
+			h.SetPrefix(v.Prefixes[0])
+		}
+	}
+}
+
 // check if signalserver is in valid state
 func (s *SignalServer) Validate() error {
 	if err := s.SignalServerCfg.Validate(); err != nil {
@@ -237,12 +265,18 @@ func (s *SignalServer) startPortSendMsg(ctx context.Context) error {
 				s.log.Info(fmt.Sprintf("SendMsg: Connected with %s", conn.RemoteAddr().String()))
 				go func(conn net.Conn) {
 					defer conn.Close()
-					m, err := signalcli.NewMessageFromReader(conn, s.self)
+					m, err := signalcli.NewMessageFromReader(conn, "")
 					if err != nil || m == nil {
-						s.log.Error("SendMsg: Error on reading message from socket", "error", err)
+						s.log.Error("SendMsg: Error on reading message from socket, dropping it", "error", err)
+						return
 					}
 					s.log.Info(fmt.Sprintf("SendMsg: received: %v", m))
-					_, err = s.acc.SendGeneric(m.Message, m.Attachments, m.Sender, m.GroupId, false)
+					a := s.sendAccount(m.Receiver)
+					if a == nil {
+						s.log.Error("SendMsg: No account to send from, dropping message", "msg", m)
+						return
+					}
+					_, err = a.acc.SendGeneric(m.Message, m.Attachments, m.Sender, m.GroupId, false)
 					if err != nil {
 						s.log.Error("SendMsg: Error on sending message", "error", err)
 					}
@@ -253,6 +287,17 @@ func (s *SignalServer) startPortSendMsg(ctx context.Context) error {
 	return nil
 }
 
+// the account which sends a message received on the send port. The receiver
+// selects the account, the default account is used if no account has this
+// number.
+func (s *SignalServer) sendAccount(receiver string) *account {
+	if a := s.account(receiver); a != nil {
+		return a
+	}
+	s.log.Warn("SendMsg: No account with the receiver's number, sending from the default account", "receiver", receiver)
+	return s.account("")
+}
+
 func (s *SignalServer) startPortVirtRcv(ctx context.Context) error {
 	listen, err := net.Listen("tcp", fmt.Sprintf("localhost:%d", s.PortVirtRcvMsg))
 	if err != nil {
@@ -273,11 +318,13 @@ func (s *SignalServer) startPortVirtRcv(ctx context.Context) error {
 				s.log.Info(fmt.Sprintf("VirtRcv: Connected with %s", conn.RemoteAddr().String()))
 				go func(conn net.Conn) {
 					defer conn.Close()
-					m, err := signalcli.NewMessageFromReader(conn, s.self)
+					m, err := signalcli.NewMessageFromReader(conn, "")
 					if err != nil || m == nil {
 						s.log.Error("VirtRcv: Error on reading message from socket", "error", err)
 					}
 					s.log.Info(fmt.Sprintf("VirtRcv: received: %v", m))
+					// handle it as if the receiver received it
+					m.Account = m.Receiver
 					s.handle(m)
 				}(conn)
 			}
@@ -289,24 +336,35 @@ func (s *SignalServer) startPortVirtRcv(ctx context.Context) error {
 // starts the signalserver asynchronously. To fully cleanup call
 // signalserver.close()
 func (s *SignalServer) Start() error {
-	s.acc.ListenForSignals()
+	for _, a := range s.accounts {
+		a.acc.ListenForSignals()
+	}
 
 	var ctx context.Context
 
 	ctx, s.sockMsgCancel = context.WithCancel(context.Background())
 	if err := s.startPortSendMsg(ctx); err != nil {
-		s.acc.Close()
+		s.closeAccounts()
 		return err
 	}
 
 	ctx, s.sockVirtRcvCancel = context.WithCancel(context.Background())
 	if err := s.startPortVirtRcv(ctx); err != nil {
 		s.sockMsgCancel()
-		s.acc.Close()
+		s.closeAccounts()
 		return err
 	}
 
-	for _, mod := range s.modules {
+	if s.PortHealth != 0 {
+		if err := s.startPortHealth(); err != nil {
+			s.sockVirtRcvCancel()
+			s.sockMsgCancel()
+			s.closeAccounts()
+			return err
+		}
+	}
+
+	for _, mod := range s.allModules() {
 		if err := mod.Start(s.handle); err != nil {
 			return err
 		}
@@ -318,13 +376,24 @@ func (s *SignalServer) Start() error {
 // stops and closes the signalserver. After calling this, the signalserver
 // cannot be started again. Please construct a new one with NewSignalServer.
 func (s *SignalServer) Close() {
-	for _, mod := range s.modules {
+	for _, mod := range s.allModules() {
 		mod.Close(s.handle)
 	}
 
+	s.stopPortHealth()
 	s.sockVirtRcvCancel()
 	s.sockMsgCancel()
-	s.acc.Close()
+	s.closeAccounts()
+
+	if err := s.state.Close(); err != nil {
+		s.log.Error("Error closing the state store", "error", err)
+	}
+}
+
+func (s *SignalServer) closeAccounts() {
+	for _, a := range s.accounts {
+		a.acc.Close()
+	}
 }
 
 // handle a complete signalmessage
@@ -332,8 +401,12 @@ func (s *SignalServer) handle(m *signalcli.Message) {
 	// unwrap -r
 	if m.Message == "-r" {
 		if m.GroupId != nil {
+			a := s.accountOrDefault(m.Account)
+			if a == nil {
+				return
+			}
 			var err error
-			m.Message, err = s.acc.GetGroupName(m.GroupId)
+			m.Message, err = a.acc.GetGroupName(m.GroupId)
 			if err != nil {
 				s.log.Warn(fmt.Sprintf("could not retreive the groupname of %v. %v", m.GroupId, err))
 				return
@@ -343,6 +416,10 @@ func (s *SignalServer) handle(m *signalcli.Message) {
 		}
 	}
 
+	if m.Message == "" && len(m.Attachments) > 0 && !s.routeAttachments(m) {
+		return
+	}
+
 	// split the message and handle the different commands
 
 	// split at "\n" as well as "|"
@@ -365,28 +442,101 @@ func (s *SignalServer) handleLine(m *signalcli.Message) {
 	prefix, remainingMsg, _ := strings.Cut(m.Message, " ")
 	module, set := s.prefix2module[prefix]
 	if !set {
+		// a shared location comes without a prefix
+		if module, set = s.routeLocation(m); !set {
+			return
+		}
+		remainingMsg = m.Message
+	}
+
+	// the account which received the message
+	a := s.accountOrDefault(m.Account)
+	if a != nil && !a.handles(module) {
 		return
 	}
 
 	// check authorization
+	handler, set := s.Handlers[module]
 	{
-		handler, set := s.Handlers[module]
 		if !set {
 			s.log.Warn(fmt.Sprintf("No handler found for module %v", module))
 			return
 		}
+		if a != nil && a.Access != nil {
+			if err := a.Access.Check(m.Sender, m.Chat); err != nil {
+				s.log.Info("Accesscontrol of the account blocked.", "Error", err, "sender", a.contactName(m.Sender))
+				s.auditLog(m, module, false)
+				return
+			}
+		}
 		if err := handler.Access.Check(m.Sender, m.Chat); err != nil {
-			s.log.Info("Accesscontrol blocked.", "Error", err)
+			s.log.Info("Accesscontrol blocked.", "Error", err, "sender", a.contactName(m.Sender))
+			s.auditLog(m, module, false)
 			return
 		}
+		s.auditLog(m, module, true)
 	}
 	// at this point the user is authorized for this module
 
-	s.log.Info(fmt.Sprintf("Handling: %v -> %v", m, remainingMsg))
+	s.log.Info(fmt.Sprintf("Handling: %v -> %v", m, remainingMsg), "sender", a.contactName(m.Sender))
 	m.Message = remainingMsg
-	if mod,ok := s.modules[module]; !ok {
+	mod, ok := s.module(a, module)
+	if !ok {
 		s.log.Error("Trying to call module which is registered but not available", "module", module)
 	} else {
-		mod.Handle(m, s.acc, s.handle)
+		if mu, ok := s.serialize[module]; ok {
+			mu.Lock()
+			defer mu.Unlock()
+		}
+		var sender signalsender.SignalSender
+		if a != nil {
+			sender = a.acc
+		}
+		if handler.Typing && a != nil {
+			var stop func()
+			sender, stop = signalsender.WithTyping(a.acc, m)
+			defer stop()
+		}
+		if h, ok := mod.(AttachmentHandler); ok && len(m.Attachments) > 0 {
+			s.filterAttachments(m, h)
+		}
+		mod.Handle(m, sender, s.handle)
+	}
+}
+
+// entry of the audit log
+type auditEntry struct {
+	Sender  string `yaml:"sender"`
+	Chat    string `yaml:"chat"`
+	Module  string `yaml:"module"`
+	Allowed bool   `yaml:"allowed"`
+}
+
+// record that a command for `module` was received (and whether the access
+// control allowed it) in the audit log
+func (s *SignalServer) auditLog(m *signalcli.Message, module string, allowed bool) {
+	if s.audit == nil {
+		return
+	}
+	e := auditEntry{
+		Sender:  m.Sender,
+		Chat:    m.Chat,
+		Module:  module,
+		Allowed: allowed,
+	}
+	if err := s.audit.Append("audit", e); err != nil {
+		s.log.Error("Error writing the audit log", "error", err)
+	}
+}
+
+// notify the DeliveryAlert number that a message was not delivered
+func (s *SignalServer) alertUndelivered(a *account, d *signalcli.Undelivered) {
+	if d.Chat == s.DeliveryAlert {
+		// the alert would probably not be delivered either
+		return
+	}
+	msg := fmt.Sprintf("Message %d to %s was not delivered (sent %s)", d.Timestamp, d.Chat, d.Sent.Format(time.DateTime))
+	if _, err := a.acc.SendGeneric(msg, nil, s.DeliveryAlert, nil, true); err != nil {
+		s.log.Error("Error sending the delivery alert", "error", err)
 	}
 }
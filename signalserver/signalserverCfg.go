@@ -18,6 +18,8 @@ package signalserver
 
 import (
 	"fmt"
+	"signalbot_go/internal/state"
+	"signalbot_go/signalcli"
 	signaldbus "signalbot_go/signalcli/drivers/dbus"
 )
 
@@ -26,18 +28,95 @@ var (
 	DriverDbus UsedDriver = "dbus"
 	DriverJsonRpc UsedDriver = "jsonrpc"
 	DriverConsole UsedDriver = "console"
+	DriverRest UsedDriver = "rest"
+	DriverReplay UsedDriver = "replay"
 )
 
+// an account the bot runs with. Can be parsed by yaml
+type AccountCfg struct {
+	UsedDriver UsedDriver          `yaml:"driver"`
+	Dbus       signaldbus.DbusType `yaml:"dbus"`
+	UnixSocket string              `yaml:"unixSocket"`
+	// base url of signal-cli-rest-api (e.g. http://localhost:8080)
+	RestUrl string `yaml:"restUrl"`
+	// directory in which signal-cli stores received attachments (jsonRpc
+	// driver, default ~/.local/share/signal-cli/attachments)
+	SignalCliAttachments string `yaml:"signalCliAttachments"`
+	// file with the lines the console driver reads instead of stdin
+	Script string `yaml:"script"`
+	// recording which is fed to the bot by the replay driver
+	Replay string `yaml:"replay"`
+	// append the traffic of the account to this file (JSONL, empty disables)
+	Record string `yaml:"record"`
+	SelfNr  string `yaml:"selfNr"`
+	// checked in addition to the access control of the handlers (unset
+	// allows everything)
+	Access *Accesscontrol `yaml:"access"`
+	// names of the handlers which are enabled for this account (empty
+	// enables all)
+	Handlers []string `yaml:"handlers"`
+}
+
+// check if stored values are valid
+func (c *AccountCfg) Validate() error {
+	if c.UsedDriver != DriverDbus && c.UsedDriver != DriverJsonRpc && c.UsedDriver != DriverConsole && c.UsedDriver != DriverRest && c.UsedDriver != DriverReplay {
+		return fmt.Errorf("Invalid driver set")
+	}
+	if c.UsedDriver == DriverDbus {
+		if c.Dbus != signaldbus.SystemBus && c.Dbus != signaldbus.SessionBus {
+			return fmt.Errorf("Invalid dbus type")
+		}
+	} else if c.UsedDriver == DriverJsonRpc {
+		if c.UnixSocket == "" {
+			return fmt.Errorf("Unix socket must be set")
+		}
+		if c.SelfNr == "" {
+			return fmt.Errorf("selfNr must be set when using jsonRpc driver")
+		}
+	} else if c.UsedDriver == DriverRest {
+		if c.RestUrl == "" {
+			return fmt.Errorf("restUrl must be set when using the rest driver")
+		}
+		if c.SelfNr == "" {
+			return fmt.Errorf("selfNr must be set when using the rest driver")
+		}
+	} else if c.UsedDriver == DriverReplay {
+		if c.Replay == "" {
+			return fmt.Errorf("replay must be set when using the replay driver")
+		}
+	}
+	if c.Access != nil {
+		if err := c.Access.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // configuration of a signalServer. Can be parsed by yaml
 // TODO note on concurrency
 type SignalServerCfg struct {
+	// the accounts the bot runs with. If empty, a single account is
+	// configured by dbus, unixSocket, restUrl, script, replay, record, driver and
+	// selfNr.
+	Accounts []AccountCfg `yaml:"accounts"`
 	Dbus           signaldbus.DbusType   `yaml:"dbus"`
 	UnixSocket     string `yaml:"unixSocket"`
+	RestUrl string `yaml:"restUrl"`
+	Script string `yaml:"script"`
+	Replay string `yaml:"replay"`
+	Record string `yaml:"record"`
 	UsedDriver UsedDriver `yaml:"driver"`
 	PortSendMsg    uint16                `yaml:"portSendMsg"`
 	PortVirtRcvMsg uint16                `yaml:"portVirtRcvMsg"`
+	PortHealth     uint16                `yaml:"portHealth"` // 0 disables the health endpoint
 	Handlers       map[string]HandlerCfg `yaml:"handlers"` // maps name to prefix
 	SelfNr string `yaml:"selfNr"`
+	StateBackend state.Backend `yaml:"stateBackend"`
+	Outbox signalcli.QueueCfg `yaml:"outbox"`
+	Delivery signalcli.DeliveryCfg `yaml:"delivery"`
+	// number which is notified about undelivered messages (empty disables)
+	DeliveryAlert string `yaml:"deliveryAlert"`
 
 	// just to have a place where to define anchors to alias to laster
 	Chats []string `yaml:"chats"`
@@ -46,21 +125,38 @@ type SignalServerCfg struct {
 
 // check if stored values are valid
 func (c *SignalServerCfg) Validate() error {
-	if c.UsedDriver != DriverDbus && c.UsedDriver != DriverJsonRpc && c.UsedDriver != DriverConsole {
-		return fmt.Errorf("Invalid driver set")
-	}
-	if c.UsedDriver == DriverDbus {
-		if c.Dbus != signaldbus.SystemBus && c.Dbus != signaldbus.SessionBus {
-			return fmt.Errorf("Invalid dbus type")
+	accounts := c.AccountCfgs()
+	numbers := make(map[string]bool, len(accounts))
+	local := 0
+	for i, a := range accounts {
+		if err := a.Validate(); err != nil {
+			return fmt.Errorf("account %d: %v", i, err)
 		}
-	} else if c.UsedDriver == DriverJsonRpc {
-		if c.UnixSocket == "" {
-			return fmt.Errorf("Unix socket must be set")
+		if len(accounts) > 1 {
+			// the number is used to tell the accounts apart
+			if a.SelfNr == "" {
+				return fmt.Errorf("account %d: selfNr must be set when using multiple accounts", i)
+			}
+			if numbers[a.SelfNr] {
+				return fmt.Errorf("account %d: %s is used twice", i, a.SelfNr)
+			}
+			numbers[a.SelfNr] = true
 		}
-		if c.SelfNr == "" {
-			return fmt.Errorf("selfNr must be set when using jsonRpc driver")
+		if a.UsedDriver == DriverDbus || a.UsedDriver == DriverConsole {
+			local++
+		}
+		for _, h := range a.Handlers {
+			if _, ok := c.Handlers[h]; !ok {
+				return fmt.Errorf("account %d: unknown handler %s", i, h)
+			}
 		}
 	}
+	if local > 1 {
+		return fmt.Errorf("Only one account can use the dbus or console driver")
+	}
+	if c.StateBackend != state.BackendYaml && c.StateBackend != state.BackendSQLite {
+		return fmt.Errorf("Invalid state backend set")
+	}
 	for _, h := range c.Handlers {
 		if err := h.Validate(); err != nil {
 			return err
@@ -69,3 +165,36 @@ func (c *SignalServerCfg) Validate() error {
 	// no validation of Chats and Users as it is only for anchors in the config
 	return nil
 }
+
+// the configured accounts with the defaults filled in
+func (c *SignalServerCfg) AccountCfgs() []AccountCfg {
+	if len(c.Accounts) == 0 {
+		return []AccountCfg{{
+			UsedDriver: c.UsedDriver,
+			Dbus:       c.Dbus,
+			UnixSocket: c.UnixSocket,
+			RestUrl:    c.RestUrl,
+			Script:     c.Script,
+			Replay:     c.Replay,
+			Record:     c.Record,
+			SelfNr:     c.SelfNr,
+		}}
+	}
+	ret := make([]AccountCfg, 0, len(c.Accounts))
+	for _, a := range c.Accounts {
+		if a.UsedDriver == "" {
+			a.UsedDriver = c.UsedDriver
+		}
+		if a.Dbus == "" {
+			a.Dbus = c.Dbus
+		}
+		if a.UnixSocket == "" {
+			a.UnixSocket = c.UnixSocket
+		}
+		if a.RestUrl == "" {
+			a.RestUrl = c.RestUrl
+		}
+		ret = append(ret, a)
+	}
+	return ret
+}